@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
@@ -19,7 +20,11 @@ var (
 	incidentSeverities = []string{"Low", "Medium", "High"}
 )
 
+var electionID = flag.String("election", "default", "ID of the election to seed results for")
+
 func main() {
+	flag.Parse()
+
 	// Initialize random seed
 	rand.Seed(time.Now().UnixNano())
 
@@ -37,7 +42,7 @@ func main() {
 	}
 	defer db.DB.Close()
 
-	log.Println("Database connection established. Starting seed process...")
+	log.Printf("Database connection established. Seeding election %s...", *electionID)
 
 	// Fetch all wards
 	wards, err := fetchWards(db.DB)
@@ -93,21 +98,24 @@ func seedWardResult(database *sql.DB, ward models.Ward) error {
 	rejectedVotes := accreditedVoters - validVotes
 	votesCast := accreditedVoters
 
+	// Demo data is published straight away rather than going through review
 	query := `
 		INSERT INTO ward_results (
-			ward_id, arrival_time, collation_start_time, inec_staff, security_present, party_agents,
+			election_id, ward_id, arrival_time, collation_start_time, inec_staff, security_present, party_agents,
 			ec8b_submitted, ec8c_collated, csrvs_done, votes_announced, agents_countersigned, ec60e_displayed,
-			accredited_voters, valid_votes, rejected_votes, votes_cast, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-		ON CONFLICT (ward_id) DO UPDATE SET
+			accredited_voters, valid_votes, rejected_votes, votes_cast, updated_at, review_status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, 'approved')
+		ON CONFLICT (election_id, ward_id) DO UPDATE SET
 			accredited_voters = EXCLUDED.accredited_voters,
 			valid_votes = EXCLUDED.valid_votes,
 			rejected_votes = EXCLUDED.rejected_votes,
 			votes_cast = EXCLUDED.votes_cast,
-			updated_at = EXCLUDED.updated_at
+			updated_at = EXCLUDED.updated_at,
+			review_status = EXCLUDED.review_status
 	`
 
 	_, err := database.Exec(query,
+		*electionID,
 		ward.ID,
 		randomTime("08:00", "10:00"), // Arrival Time
 		randomTime("14:00", "18:00"), // Collation Start Time
@@ -141,7 +149,7 @@ func seedPartyResults(database *sql.DB, ward models.Ward) error {
 	// Note: In a real app, we'd pass validVotes from seedWardResult or return it.
 	// For this script, let's just do a quick fetch to be accurate.
 	var validVotes int
-	err := database.QueryRow("SELECT valid_votes FROM ward_results WHERE ward_id = $1", ward.ID).Scan(&validVotes)
+	err := database.QueryRow("SELECT valid_votes FROM ward_results WHERE election_id = $1 AND ward_id = $2", *electionID, ward.ID).Scan(&validVotes)
 	if err != nil {
 		return err
 	}
@@ -175,10 +183,10 @@ func seedPartyResults(database *sql.DB, ward models.Ward) error {
 		remainingVotes -= score
 
 		_, err := database.Exec(`
-			INSERT INTO party_results (ward_id, party_name, score)
-			VALUES ($1, $2, $3)
-			ON CONFLICT (ward_id, party_name) DO UPDATE SET score = EXCLUDED.score
-		`, ward.ID, party, score)
+			INSERT INTO party_results (election_id, ward_id, party_name, score)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (election_id, ward_id, party_name) DO UPDATE SET score = EXCLUDED.score
+		`, *electionID, ward.ID, party, score)
 		if err != nil {
 			return err
 		}
@@ -190,9 +198,10 @@ func seedIncidents(database *sql.DB, ward models.Ward) error {
 	numIncidents := rand.Intn(3) + 1
 	for i := 0; i < numIncidents; i++ {
 		_, err := database.Exec(`
-			INSERT INTO incidents (ward_id, title, description, type, severity, status, timestamp)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			INSERT INTO incidents (election_id, ward_id, title, description, type, severity, status, timestamp)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		`,
+			*electionID,
 			ward.ID,
 			fmt.Sprintf("Incident in %s", ward.Name),
 			"Description of the incident goes here.",
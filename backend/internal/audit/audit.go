@@ -0,0 +1,280 @@
+// Package audit keeps the audit log tamper-evident. Every entry carries a
+// SHA-256 hash of its content and of the entry before it, so changing or
+// removing an entry breaks the chain from that point on, and signed
+// checkpoints of the chain head can be published for outsiders to check against.
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// chainLock is the advisory lock key that serialises appends, so each entry
+// sees the one before it
+const chainLock = 7_042_173
+
+// Entry is one audit log entry
+type Entry struct {
+	ID        int       `json:"id"`
+	UserID    *int      `json:"user_id"`
+	APIKeyID  *int      `json:"api_key_id"`
+	Action    string    `json:"action"`
+	Details   string    `json:"details"`
+	IPAddress string    `json:"ip_address"`
+	Timestamp time.Time `json:"timestamp"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+
+	// What the entry is about and the request that caused it; empty for
+	// entries that don't come from a request
+	EntityType string `json:"entity_type,omitempty"`
+	EntityID   string `json:"entity_id,omitempty"`
+	Changes    string `json:"changes,omitempty"` // JSON of the before and after values
+	RequestID  string `json:"request_id,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Status     int    `json:"status,omitempty"`
+}
+
+// entryColumns are the audit_logs columns scanEntry reads
+const entryColumns = `id, user_id, api_key_id, action, COALESCE(details, ''), COALESCE(ip_address, ''), timestamp,
+	COALESCE(prev_hash, ''), COALESCE(hash, ''), COALESCE(entity_type, ''), COALESCE(entity_id, ''),
+	COALESCE(changes::text, ''), COALESCE(request_id, ''), COALESCE(method, ''), COALESCE(path, ''), COALESCE(status, 0)`
+
+func scanEntry(rows *sql.Rows) (Entry, error) {
+	var e Entry
+	var userID, keyID sql.NullInt64
+	err := rows.Scan(&e.ID, &userID, &keyID, &e.Action, &e.Details, &e.IPAddress, &e.Timestamp,
+		&e.PrevHash, &e.Hash, &e.EntityType, &e.EntityID,
+		&e.Changes, &e.RequestID, &e.Method, &e.Path, &e.Status)
+	e.UserID, e.APIKeyID = intPtr(userID), intPtr(keyID)
+	return e, err
+}
+
+// requestFields reports whether any of the fields added with request capture
+// are set. Entries without them hash as before, so older chains still verify.
+func (e *Entry) requestFields() bool {
+	return e.EntityType != "" || e.EntityID != "" || e.Changes != "" || e.RequestID != "" || e.Method != "" || e.Path != "" || e.Status != 0
+}
+
+// timestampFormat matches the microsecond precision Postgres stores
+const timestampFormat = "2006-01-02T15:04:05.000000Z"
+
+// ComputeHash hashes an entry's content together with the previous entry's hash
+func ComputeHash(e Entry) string {
+	// A JSON array gives an unambiguous, stable encoding of the fields
+	fields := []interface{}{
+		"v1", e.ID, e.Timestamp.UTC().Format(timestampFormat), e.UserID, e.APIKeyID, e.Action, e.Details, e.IPAddress, e.PrevHash,
+	}
+	if e.requestFields() {
+		fields[0] = "v2"
+		fields = append(fields, e.EntityType, e.EntityID, e.Changes, e.RequestID, e.Method, e.Path, e.Status)
+	}
+	content, _ := json.Marshal(fields)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Append adds an entry to the end of the chain. Its ID, timestamp and hashes
+// are filled in here; the timestamp is set in Go, not by the database, so the
+// value hashed is exactly the value stored.
+func Append(db *sql.DB, e Entry) (Entry, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return e, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", chainLock); err != nil {
+		return e, err
+	}
+	var prev sql.NullString
+	err = tx.QueryRow("SELECT hash FROM audit_logs ORDER BY id DESC LIMIT 1").Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return e, err
+	}
+	if err := tx.QueryRow("SELECT nextval(pg_get_serial_sequence('audit_logs', 'id'))").Scan(&e.ID); err != nil {
+		return e, err
+	}
+
+	e.Timestamp = time.Now().UTC().Truncate(time.Microsecond)
+	e.PrevHash = prev.String
+	e.Hash = ComputeHash(e)
+	_, err = tx.Exec(`
+		INSERT INTO audit_logs (id, user_id, api_key_id, action, details, ip_address, timestamp, prev_hash, hash,
+			entity_type, entity_id, changes, request_id, method, path, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		e.ID, e.UserID, e.APIKeyID, e.Action, e.Details, e.IPAddress, e.Timestamp, e.PrevHash, e.Hash,
+		nullString(e.EntityType), nullString(e.EntityID), nullString(e.Changes), nullString(e.RequestID),
+		nullString(e.Method), nullString(e.Path), nullInt(e.Status))
+	if err != nil {
+		return e, err
+	}
+	return e, tx.Commit()
+}
+
+// Break is the first point where the chain doesn't hold
+type Break struct {
+	EntryID int    `json:"entry_id"`
+	Reason  string `json:"reason"`
+}
+
+// Report is the outcome of verifying the chain
+type Report struct {
+	Valid       bool   `json:"valid"`
+	Entries     int    `json:"entries"`     // Chained entries checked
+	Unchained   int    `json:"unchained"`   // Entries from before the chain was introduced
+	Checkpoints int    `json:"checkpoints"` // Signed checkpoints checked
+	Archived    int    `json:"archived"`    // Entries moved out of the database before the chain's remaining start
+	LastID      int    `json:"last_id,omitempty"`
+	LastHash    string `json:"last_hash,omitempty"`
+	FirstBreak  *Break `json:"first_break,omitempty"`
+}
+
+// Verify walks the whole chain in order, recomputing every hash, and checks
+// each checkpoint against the entry it covers, with its signature checked
+// against the trusted keys. It stops at the first break. Once entries have
+// been archived the walk starts from the last archived entry's hash, and
+// checkpoints of archived entries can no longer be checked.
+func Verify(db *sql.DB, trusted TrustedKeys) (Report, error) {
+	archive, err := LatestArchive(db)
+	if err != nil {
+		return Report{}, err
+	}
+	checkpoints, err := loadCheckpoints(db)
+	if err != nil {
+		return Report{}, err
+	}
+	start := 0
+	if archive != nil {
+		start = archive.UpToID
+	}
+
+	return VerifyChain(archive, checkpoints, trusted, func(fn func(Entry) error) error {
+		rows, err := db.Query("SELECT "+entryColumns+" FROM audit_logs WHERE id > $1 ORDER BY id", start)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			e, err := scanEntry(rows)
+			if err != nil {
+				return err
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// errStop ends a walk over the entries at the first break
+var errStop = errors.New("stop")
+
+// VerifyChain is Verify over entries from any source. each must call fn with
+// every entry after the archive, if any, in ID order, and return the first
+// error fn returns.
+func VerifyChain(archive *Archive, checkpoints []Checkpoint, trusted TrustedKeys, each func(fn func(Entry) error) error) (Report, error) {
+	var report Report
+
+	chained := false
+	prevID, prevHash := 0, ""
+	if archive != nil {
+		report.Archived = archive.Entries
+		prevID, prevHash = archive.UpToID, archive.LastHash
+		chained = archive.LastHash != ""
+	}
+	start := prevID
+
+	byEntry := make(map[int][]Checkpoint)
+	for _, c := range checkpoints {
+		if c.EntryID <= start {
+			continue
+		}
+		if err := c.Verify(trusted); err != nil {
+			report.FirstBreak = &Break{EntryID: c.EntryID, Reason: err.Error()}
+			return report, nil
+		}
+		byEntry[c.EntryID] = append(byEntry[c.EntryID], c)
+	}
+
+	err := each(func(e Entry) error {
+		if e.Hash == "" && !chained {
+			report.Unchained++
+			return nil
+		}
+		chained = true
+
+		switch {
+		case e.Hash == "":
+			report.FirstBreak = &Break{EntryID: e.ID, Reason: "entry has no hash"}
+		case e.PrevHash != prevHash:
+			report.FirstBreak = &Break{EntryID: e.ID, Reason: fmt.Sprintf("previous hash does not match entry %d; entries were removed or reordered", prevID)}
+		case ComputeHash(e) != e.Hash:
+			report.FirstBreak = &Break{EntryID: e.ID, Reason: "hash does not match the entry's content; it was changed"}
+		}
+		for _, c := range byEntry[e.ID] {
+			if report.FirstBreak == nil && c.EntryHash != e.Hash {
+				report.FirstBreak = &Break{EntryID: e.ID, Reason: fmt.Sprintf("entry does not match checkpoint %d", c.ID)}
+			}
+			report.Checkpoints++
+			delete(byEntry, e.ID)
+		}
+		if report.FirstBreak != nil {
+			return errStop
+		}
+
+		report.Entries++
+		prevID, prevHash = e.ID, e.Hash
+		return nil
+	})
+	if err == errStop {
+		return report, nil
+	}
+	if err != nil {
+		return report, err
+	}
+
+	// Any checkpoint left over covers an entry that no longer exists
+	for _, c := range checkpoints {
+		if c.EntryID <= start {
+			continue
+		}
+		if _, missing := byEntry[c.EntryID]; missing {
+			report.FirstBreak = &Break{EntryID: c.EntryID, Reason: fmt.Sprintf("entry covered by checkpoint %d is missing; the log was truncated", c.ID)}
+			return report, nil
+		}
+	}
+
+	report.Valid = true
+	report.LastID, report.LastHash = prevID, prevHash
+	return report, nil
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullInt(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func intPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
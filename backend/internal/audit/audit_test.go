@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chain builds n chained entries starting after prevID and prevHash
+func chain(n, prevID int, prevHash string) []Entry {
+	start := time.Date(2026, 2, 21, 8, 0, 0, 0, time.UTC)
+	entries := make([]Entry, n)
+	for i := range entries {
+		userID := 1
+		e := Entry{
+			ID:        prevID + i + 1,
+			UserID:    &userID,
+			Action:    "SUBMIT_RESULTS",
+			Details:   "Submitted results",
+			IPAddress: "10.0.0.1",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			PrevHash:  prevHash,
+		}
+		if i%2 == 1 {
+			e.EntityType, e.EntityID, e.Method, e.Status = "ward", "w1", "POST", 200
+		}
+		e.Hash = ComputeHash(e)
+		prevHash = e.Hash
+		entries[i] = e
+	}
+	return entries
+}
+
+func each(entries []Entry) func(fn func(Entry) error) error {
+	return func(fn func(Entry) error) error {
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func newSigner(t *testing.T) *Signer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSigner(private)
+}
+
+// checkpoint signs e as checkpoint id, as CreateCheckpoint does
+func checkpoint(s *Signer, id int, e Entry) Checkpoint {
+	c := Checkpoint{ID: id, EntryID: e.ID, EntryHash: e.Hash, CreatedAt: e.Timestamp.Add(time.Second), KeyID: s.KeyID, PublicKey: s.PublicKey()}
+	c.Statement = statement(c.EntryID, c.EntryHash, c.CreatedAt)
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.private, []byte(c.Statement)))
+	return c
+}
+
+func TestComputeHash(t *testing.T) {
+	e := chain(1, 0, "")[0]
+	if ComputeHash(e) != e.Hash {
+		t.Fatal("hash is not stable")
+	}
+
+	// Timestamps hash at the microsecond precision Postgres keeps, in UTC
+	lagos := time.FixedZone("WAT", 3600)
+	same := e
+	same.Timestamp = e.Timestamp.In(lagos).Add(300 * time.Nanosecond)
+	if ComputeHash(same) != e.Hash {
+		t.Error("hash depends on the time zone or sub-microsecond precision")
+	}
+
+	changes := map[string]func(*Entry){
+		"action":    func(e *Entry) { e.Action = "DELETE_USER" },
+		"details":   func(e *Entry) { e.Details += "." },
+		"user":      func(e *Entry) { e.UserID = nil },
+		"timestamp": func(e *Entry) { e.Timestamp = e.Timestamp.Add(time.Microsecond) },
+		"prev hash": func(e *Entry) { e.PrevHash = "00" },
+		"entity":    func(e *Entry) { e.EntityID = "w2" },
+		"status":    func(e *Entry) { e.Status = 403 },
+	}
+	for field, change := range changes {
+		changed := e
+		change(&changed)
+		if ComputeHash(changed) == e.Hash {
+			t.Errorf("changing the %s doesn't change the hash", field)
+		}
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	signer := newSigner(t)
+	trusted := TrustedKeys{}
+	trusted.Add(signer.public)
+
+	entries := chain(5, 0, "")
+	report, err := VerifyChain(nil, []Checkpoint{checkpoint(signer, 1, entries[2])}, trusted, each(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Entries != 5 || report.Checkpoints != 1 || report.LastID != 5 || report.LastHash != entries[4].Hash {
+		t.Errorf("intact chain: %+v, want valid with 5 entries and 1 checkpoint up to entry 5", report)
+	}
+}
+
+func TestVerifyChainBreaks(t *testing.T) {
+	signer := newSigner(t)
+	trusted := TrustedKeys{}
+	trusted.Add(signer.public)
+	attacker := newSigner(t)
+
+	tests := []struct {
+		name   string
+		tamper func(entries []Entry, checkpoints []Checkpoint) ([]Entry, []Checkpoint)
+		entry  int
+		reason string
+	}{
+		{"changed content", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			e[2].Details = "Nothing to see"
+			return e, c
+		}, 3, "content"},
+		{"changed and rehashed", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			e[2].Details = "Nothing to see"
+			e[2].Hash = ComputeHash(e[2])
+			return e, c
+		}, 4, "removed or reordered"},
+		{"removed entry", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			return append(e[:1:1], e[2:]...), c
+		}, 3, "removed or reordered"},
+		{"reordered entries", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			e[1], e[2] = e[2], e[1]
+			return e, c
+		}, 3, "removed or reordered"},
+		{"missing hash", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			e[3].Hash = ""
+			return e, c
+		}, 4, "no hash"},
+		{"rewritten chain under a checkpoint", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			e[0].Details = "Rewritten"
+			e[0].Hash = ComputeHash(e[0])
+			rewritten := append(e[:1:1], chain(4, 1, e[0].Hash)...)
+			return rewritten, c
+		}, 4, "does not match checkpoint 1"},
+		{"truncated log", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			return e[:3], c
+		}, 4, "truncated"},
+		{"forged signature", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			c[0].EntryHash = e[0].Hash
+			return e, c
+		}, 4, "invalid signature"},
+		{"re-signed by an untrusted key", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			// Rewrite the chain and re-sign its head with a key stored alongside the checkpoint
+			e[0].Details = "Rewritten"
+			e[0].Hash = ComputeHash(e[0])
+			rewritten := append(e[:1:1], chain(4, 1, e[0].Hash)...)
+			return rewritten, []Checkpoint{checkpoint(attacker, 1, rewritten[3])}
+		}, 4, "untrusted key"},
+		{"untrusted key claiming a trusted key ID", func(e []Entry, c []Checkpoint) ([]Entry, []Checkpoint) {
+			forged := checkpoint(attacker, 1, e[3])
+			forged.KeyID = signer.KeyID
+			return e, []Checkpoint{forged}
+		}, 4, "invalid signature"},
+	}
+	for _, tt := range tests {
+		entries := chain(5, 0, "")
+		checkpoints := []Checkpoint{checkpoint(signer, 1, entries[3])}
+		entries, checkpoints = tt.tamper(entries, checkpoints)
+
+		report, err := VerifyChain(nil, checkpoints, trusted, each(entries))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if report.Valid || report.FirstBreak == nil {
+			t.Errorf("%s: chain verified", tt.name)
+			continue
+		}
+		if report.FirstBreak.EntryID != tt.entry || !strings.Contains(report.FirstBreak.Reason, tt.reason) {
+			t.Errorf("%s: broke at %+v, want entry %d with %q", tt.name, *report.FirstBreak, tt.entry, tt.reason)
+		}
+	}
+}
+
+func TestVerifyChainRetiredKey(t *testing.T) {
+	retired, current := newSigner(t), newSigner(t)
+	entries := chain(4, 0, "")
+	checkpoints := []Checkpoint{checkpoint(retired, 1, entries[1]), checkpoint(current, 2, entries[3])}
+
+	trusted := TrustedKeys{}
+	trusted.Add(current.public)
+	if report, _ := VerifyChain(nil, checkpoints, trusted, each(entries)); report.Valid {
+		t.Error("checkpoint of a key no longer trusted verified")
+	}
+
+	public, err := ParsePublicKey(retired.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted.Add(public)
+	if report, _ := VerifyChain(nil, checkpoints, trusted, each(entries)); !report.Valid || report.Checkpoints != 2 {
+		t.Errorf("with the retired key trusted: %+v, want valid with 2 checkpoints", report)
+	}
+}
+
+func TestVerifyChainAfterArchive(t *testing.T) {
+	signer := newSigner(t)
+	trusted := TrustedKeys{}
+	trusted.Add(signer.public)
+
+	// Entries 1 to 3 were archived; a checkpoint of them can't be checked any more
+	all := chain(6, 0, "")
+	archive := &Archive{UpToID: 3, LastHash: all[2].Hash, Entries: 3}
+	checkpoints := []Checkpoint{checkpoint(signer, 1, all[1]), checkpoint(signer, 2, all[4])}
+
+	report, err := VerifyChain(archive, checkpoints, trusted, each(all[3:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Entries != 3 || report.Archived != 3 || report.Checkpoints != 1 {
+		t.Errorf("after archiving: %+v, want valid with 3 entries, 3 archived and 1 checkpoint", report)
+	}
+
+	// The first entry left must follow on from the archive
+	report, _ = VerifyChain(archive, nil, trusted, each(all[4:]))
+	if report.Valid || report.FirstBreak.EntryID != 5 {
+		t.Errorf("with entry 4 missing after the archive: %+v, want a break at entry 5", report)
+	}
+}
+
+func TestVerifyChainUnchainedEntries(t *testing.T) {
+	// Entries from before the chain was introduced have no hashes
+	old := []Entry{{ID: 1, Action: "LOGIN"}, {ID: 2, Action: "LOGIN"}}
+	entries := append(old, chain(2, 2, "")...)
+	report, err := VerifyChain(nil, nil, TrustedKeys{}, each(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Unchained != 2 || report.Entries != 2 {
+		t.Errorf("got %+v, want valid with 2 unchained and 2 chained entries", report)
+	}
+}
@@ -0,0 +1,280 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs checkpoints with an Ed25519 key
+type Signer struct {
+	KeyID   string
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// Config holds the checkpoint and retention settings loaded by LoadConfig
+var Config struct {
+	Signer      *Signer       // nil when checkpoints are disabled
+	TrustedKeys TrustedKeys   // The signer's key and any retired keys checkpoints may still be signed with
+	Interval    time.Duration // How often the chain head is checkpointed
+	Retention   time.Duration // How long entries stay in the database; 0 keeps them forever
+	ArchiveDir  string        // Where entries past retention are archived
+}
+
+// LoadConfig reads the checkpoint and retention settings from the environment:
+//
+//	AUDIT_CHECKPOINT_KEY       path to a PEM file holding an Ed25519 private key
+//	                           (PKCS #8); checkpoints are disabled when unset
+//	AUDIT_TRUSTED_KEYS         comma-separated base64 Ed25519 public keys that
+//	                           checkpoints may also be signed with, such as
+//	                           retired signing keys
+//	AUDIT_CHECKPOINT_INTERVAL  Go duration between checkpoints, default 1h
+//	AUDIT_RETENTION_DAYS       days entries stay in the database before they are
+//	                           archived; unset or 0 keeps them forever
+//	AUDIT_ARCHIVE_DIR          directory archived entries are written to, required
+//	                           with a retention period
+func LoadConfig() error {
+	Config.Retention, Config.ArchiveDir = 0, ""
+	if v := os.Getenv("AUDIT_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return fmt.Errorf("AUDIT_RETENTION_DAYS must be a whole number of days")
+		}
+		Config.Retention = time.Duration(days) * 24 * time.Hour
+	}
+	if Config.Retention > 0 {
+		Config.ArchiveDir = os.Getenv("AUDIT_ARCHIVE_DIR")
+		if Config.ArchiveDir == "" {
+			return fmt.Errorf("AUDIT_ARCHIVE_DIR is required with AUDIT_RETENTION_DAYS; entries are never deleted without being archived")
+		}
+	}
+
+	Config.Interval = time.Hour
+	if v := os.Getenv("AUDIT_CHECKPOINT_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("AUDIT_CHECKPOINT_INTERVAL must be a positive duration such as 1h")
+		}
+		Config.Interval = d
+	}
+
+	Config.TrustedKeys = TrustedKeys{}
+	for _, v := range strings.Split(os.Getenv("AUDIT_TRUSTED_KEYS"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		public, err := ParsePublicKey(v)
+		if err != nil {
+			return fmt.Errorf("AUDIT_TRUSTED_KEYS: %w", err)
+		}
+		Config.TrustedKeys.Add(public)
+	}
+
+	Config.Signer = nil
+	path := os.Getenv("AUDIT_CHECKPOINT_KEY")
+	if path == "" {
+		return nil
+	}
+	signer, err := LoadSigner(path)
+	if err != nil {
+		return fmt.Errorf("AUDIT_CHECKPOINT_KEY: %w", err)
+	}
+	Config.Signer = signer
+	Config.TrustedKeys.Add(signer.public)
+	return nil
+}
+
+// LoadSigner reads an Ed25519 private key from a PEM file
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+	}
+	return NewSigner(private), nil
+}
+
+// NewSigner wraps an Ed25519 private key. The key ID is derived from the public key.
+func NewSigner(private ed25519.PrivateKey) *Signer {
+	public := private.Public().(ed25519.PublicKey)
+	return &Signer{KeyID: keyID(public), private: private, public: public}
+}
+
+// PublicKey is the base64 public key checkpoints are verified with
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.public)
+}
+
+func keyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// TrustedKeys are the public keys checkpoints may be signed with, by key ID.
+// Checkpoints are checked against these rather than the public key stored
+// with them, so whoever can write to the database can't re-sign a rewritten
+// chain with a key of their own.
+type TrustedKeys map[string]ed25519.PublicKey
+
+// Add trusts a public key
+func (k TrustedKeys) Add(public ed25519.PublicKey) {
+	k[keyID(public)] = public
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key, as Signer.PublicKey encodes them
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	public, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%q is not a base64 Ed25519 public key", s)
+	}
+	return ed25519.PublicKey(public), nil
+}
+
+// Checkpoint is a signed statement of the chain head at a point in time.
+// Publishing checkpoints outside the database lets anyone holding one prove
+// later that the log up to that entry hasn't been rewritten.
+type Checkpoint struct {
+	ID        int       `json:"id"`
+	EntryID   int       `json:"entry_id"`
+	EntryHash string    `json:"entry_hash"`
+	CreatedAt time.Time `json:"created_at"`
+	KeyID     string    `json:"key_id"`
+	PublicKey string    `json:"public_key"`
+	Signature string    `json:"signature"`
+	Statement string    `json:"statement"` // The exact text that was signed
+}
+
+// statement is the text a checkpoint's signature covers
+func statement(entryID int, entryHash string, createdAt time.Time) string {
+	return fmt.Sprintf("abuja-watch audit checkpoint\nentry_id: %d\nentry_hash: %s\ncreated_at: %s\n",
+		entryID, entryHash, createdAt.UTC().Format(timestampFormat))
+}
+
+// Verify checks the checkpoint's signature against the trusted key it names.
+// A checkpoint signed by a key that isn't trusted fails, whatever public key
+// is stored with it.
+func (c *Checkpoint) Verify(trusted TrustedKeys) error {
+	public, ok := trusted[c.KeyID]
+	if !ok {
+		return fmt.Errorf("checkpoint %d is signed by untrusted key %q", c.ID, c.KeyID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || !ed25519.Verify(public, []byte(statement(c.EntryID, c.EntryHash, c.CreatedAt)), signature) {
+		return fmt.Errorf("checkpoint %d has an invalid signature", c.ID)
+	}
+	return nil
+}
+
+// ErrChainBroken means the chain failed verification, so it won't be checkpointed
+var ErrChainBroken = errors.New("audit chain is broken")
+
+// CreateCheckpoint verifies the chain, with earlier checkpoints checked
+// against the trusted keys, and signs its head. It returns nil without error
+// when there is nothing new to checkpoint.
+func CreateCheckpoint(db *sql.DB, signer *Signer, trusted TrustedKeys) (*Checkpoint, error) {
+	report, err := Verify(db, trusted)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid {
+		return nil, fmt.Errorf("%w at entry %d: %s", ErrChainBroken, report.FirstBreak.EntryID, report.FirstBreak.Reason)
+	}
+	if report.LastID == 0 {
+		return nil, nil
+	}
+
+	var last sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(entry_id) FROM audit_checkpoints").Scan(&last); err != nil {
+		return nil, err
+	}
+	if last.Valid && int(last.Int64) >= report.LastID {
+		return nil, nil
+	}
+
+	c := Checkpoint{
+		EntryID:   report.LastID,
+		EntryHash: report.LastHash,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		KeyID:     signer.KeyID,
+		PublicKey: signer.PublicKey(),
+	}
+	c.Statement = statement(c.EntryID, c.EntryHash, c.CreatedAt)
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(signer.private, []byte(c.Statement)))
+	err = db.QueryRow(`
+		INSERT INTO audit_checkpoints (entry_id, entry_hash, created_at, key_id, public_key, signature)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		c.EntryID, c.EntryHash, c.CreatedAt, c.KeyID, c.PublicKey, c.Signature).Scan(&c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Checkpoints lists every checkpoint, oldest first
+func Checkpoints(db *sql.DB) ([]Checkpoint, error) {
+	return loadCheckpoints(db)
+}
+
+func loadCheckpoints(db *sql.DB) ([]Checkpoint, error) {
+	rows, err := db.Query(`
+		SELECT id, entry_id, entry_hash, created_at, key_id, public_key, signature
+		FROM audit_checkpoints ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []Checkpoint{}
+	for rows.Next() {
+		var c Checkpoint
+		if err := rows.Scan(&c.ID, &c.EntryID, &c.EntryHash, &c.CreatedAt, &c.KeyID, &c.PublicKey, &c.Signature); err != nil {
+			return nil, err
+		}
+		c.Statement = statement(c.EntryID, c.EntryHash, c.CreatedAt)
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
+
+// RunCheckpoints checkpoints the chain head every interval until ctx is done
+func RunCheckpoints(ctx context.Context, db *sql.DB, signer *Signer, trusted TrustedKeys, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c, err := CreateCheckpoint(db, signer, trusted)
+			if err != nil {
+				log.Printf("audit checkpoint failed: %v", err)
+			} else if c != nil {
+				log.Printf("audit checkpoint %d signed at entry %d (%s)", c.ID, c.EntryID, c.EntryHash)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package audit
+
+import "context"
+
+// Record collects what a request did, for the audit middleware to write once
+// the request completes. Handlers name the actions they took and the entity
+// they changed; the middleware adds the outcome.
+type Record struct {
+	UserID     *int
+	APIKeyID   *int
+	EntityType string
+	EntityID   string
+	Changes    interface{} // Before and after values, marshalled to JSON
+	Events     []Event
+}
+
+// Event is one action taken during a request
+type Event struct {
+	UserID  *int // Overrides the record's actor, e.g. for a login
+	Action  string
+	Details string
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying rec
+func NewContext(ctx context.Context, rec *Record) context.Context {
+	return context.WithValue(ctx, contextKey{}, rec)
+}
+
+// FromContext returns the record of the request, or nil outside the audit middleware
+func FromContext(ctx context.Context) *Record {
+	rec, _ := ctx.Value(contextKey{}).(*Record)
+	return rec
+}
@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Archive records entries moved out of the database into an NDJSON file,
+// along with the file's SHA-256. The chain carries on from the last archived
+// entry's hash, so the entries left in the database still verify.
+type Archive struct {
+	ID         int       `json:"id"`
+	UpToID     int       `json:"up_to_id"`  // Last entry archived; every entry up to it is in the file
+	LastHash   string    `json:"last_hash"` // Hash of that entry, where the remaining chain starts
+	Entries    int       `json:"entries"`
+	File       string    `json:"file"`
+	FileSHA256 string    `json:"file_sha256"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+const archiveColumns = `id, up_to_id, COALESCE(last_hash, ''), entries, file, file_sha256, archived_at`
+
+func scanArchive(row interface{ Scan(...interface{}) error }) (Archive, error) {
+	var a Archive
+	err := row.Scan(&a.ID, &a.UpToID, &a.LastHash, &a.Entries, &a.File, &a.FileSHA256, &a.ArchivedAt)
+	return a, err
+}
+
+// LatestArchive returns the most recent archive, or nil if nothing has been archived
+func LatestArchive(db *sql.DB) (*Archive, error) {
+	a, err := scanArchive(db.QueryRow("SELECT " + archiveColumns + " FROM audit_archives ORDER BY up_to_id DESC LIMIT 1"))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Archives lists every archive, oldest first
+func Archives(db *sql.DB) ([]Archive, error) {
+	rows, err := db.Query("SELECT " + archiveColumns + " FROM audit_archives ORDER BY up_to_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	archives := []Archive{}
+	for rows.Next() {
+		a, err := scanArchive(rows)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// ArchiveBefore moves every entry older than cutoff into a new NDJSON file in
+// dir, oldest first, and deletes them from the database once the file is
+// safely written. It returns nil without error when nothing is old enough.
+func ArchiveBefore(db *sql.DB, cutoff time.Time, dir string) (*Archive, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Hold off appends, so the archive and the chain it leaves behind agree
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", chainLock); err != nil {
+		return nil, err
+	}
+
+	var upTo sql.NullInt64
+	if err := tx.QueryRow("SELECT MAX(id) FROM audit_logs WHERE timestamp < $1", cutoff.UTC()).Scan(&upTo); err != nil {
+		return nil, err
+	}
+	if !upTo.Valid {
+		return nil, nil
+	}
+
+	a := Archive{
+		UpToID:     int(upTo.Int64),
+		ArchivedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	a.File = filepath.Join(dir, fmt.Sprintf("audit-%s-%d.ndjson", a.ArchivedAt.Format("20060102T150405Z"), a.UpToID))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(a.File, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	written := false
+	defer func() {
+		f.Close()
+		if !written {
+			os.Remove(a.File)
+		}
+	}()
+
+	rows, err := tx.Query("SELECT "+entryColumns+" FROM audit_logs WHERE id <= $1 ORDER BY id", a.UpToID)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(f, sum))
+	enc := json.NewEncoder(out)
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if err := enc.Encode(e); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.Entries++
+		a.LastHash = e.Hash
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := out.Flush(); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	a.FileSHA256 = hex.EncodeToString(sum.Sum(nil))
+
+	err = tx.QueryRow(`
+		INSERT INTO audit_archives (up_to_id, last_hash, entries, file, file_sha256, archived_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		a.UpToID, nullString(a.LastHash), a.Entries, a.File, a.FileSHA256, a.ArchivedAt).Scan(&a.ID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM audit_logs WHERE id <= $1", a.UpToID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	written = true
+	return &a, nil
+}
+
+// RunRetention archives entries older than retention once a day until ctx is done
+func RunRetention(ctx context.Context, db *sql.DB, retention time.Duration, dir string) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		a, err := ArchiveBefore(db, time.Now().Add(-retention), dir)
+		if err != nil {
+			log.Printf("audit archive failed: %v", err)
+		} else if a != nil {
+			log.Printf("archived %d audit entries up to %d to %s", a.Entries, a.UpToID, a.File)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
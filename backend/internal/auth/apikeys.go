@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, so they can be told apart from JWTs
+const APIKeyPrefix = "aw_"
+
+// API key scopes
+const (
+	ScopeRead     = "read"     // The public read-only API, identified rather than anonymous
+	ScopeSubmit   = "submit"   // Submissions and incident reports for the key's Area Councils
+	ScopeWebhooks = "webhooks" // Managing partner webhooks
+)
+
+// Scopes lists every scope an API key can have
+var Scopes = []string{ScopeRead, ScopeSubmit, ScopeWebhooks}
+
+// scopePermissions maps each scope to the permissions it grants
+var scopePermissions = map[string][]Permission{
+	ScopeSubmit:   {PermSubmitResults, PermReportIncidents},
+	ScopeWebhooks: {PermManageWebhooks},
+}
+
+// ErrInvalidAPIKey means the key is unknown, expired or revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ValidScope reports whether scope is one of Scopes
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is an authenticated API key
+type APIKey struct {
+	ID             int
+	Name           string
+	Scopes         []string
+	AreaCouncilIDs []string
+}
+
+// HasScope reports whether the key was issued with a scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the key's scopes grant a permission
+func (k *APIKey) Can(p Permission) bool {
+	for _, scope := range k.Scopes {
+		for _, granted := range scopePermissions[scope] {
+			if granted == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CoversAreaCouncil reports whether the key may submit for an Area Council
+func (k *APIKey) CoversAreaCouncil(lgaID string) bool {
+	for _, id := range k.AreaCouncilIDs {
+		if id == lgaID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKey reports whether a bearer credential looks like an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// GenerateAPIKey returns a new key, its display prefix and the hash to store
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + secret
+	return key, key[:len(APIKeyPrefix)+8], hashToken(key), nil
+}
+
+// APIKeyStore looks up API keys by their hash
+type APIKeyStore interface {
+	// UseAPIKey returns the key with the hash and records that it was used
+	// from ip. Unknown, expired and revoked keys give ErrInvalidAPIKey.
+	UseAPIKey(hash, ip string) (*APIKey, error)
+}
+
+// AuthenticateAPIKey looks up a key and records that it was used from ip
+func AuthenticateAPIKey(s APIKeyStore, key, ip string) (*APIKey, error) {
+	return s.UseAPIKey(hashToken(key), ip)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	read := &APIKey{Scopes: []string{ScopeRead}}
+	submit := &APIKey{Scopes: []string{ScopeRead, ScopeSubmit}}
+	webhooks := &APIKey{Scopes: []string{ScopeWebhooks}}
+
+	tests := []struct {
+		name string
+		key  *APIKey
+		perm Permission
+		want bool
+	}{
+		{"read can't submit", read, PermSubmitResults, false},
+		{"read can't report incidents", read, PermReportIncidents, false},
+		{"submit can submit", submit, PermSubmitResults, true},
+		{"submit can report incidents", submit, PermReportIncidents, true},
+		{"submit can't review", submit, PermReviewResults, false},
+		{"submit can't manage incidents", submit, PermManageIncidents, false},
+		{"submit isn't everywhere", submit, PermAllJurisdictions, false},
+		{"webhooks can manage webhooks", webhooks, PermManageWebhooks, true},
+		{"webhooks can't submit", webhooks, PermSubmitResults, false},
+		{"no key manages keys", &APIKey{Scopes: Scopes}, PermManageAPIKeys, false},
+	}
+	for _, tt := range tests {
+		if got := tt.key.Can(tt.perm); got != tt.want {
+			t.Errorf("%s: Can(%s) = %v, want %v", tt.name, tt.perm, got, tt.want)
+		}
+	}
+
+	if !submit.HasScope(ScopeRead) || submit.HasScope(ScopeWebhooks) {
+		t.Errorf("HasScope wrong for scopes %v", submit.Scopes)
+	}
+	if ValidScope("admin") || !ValidScope(ScopeSubmit) {
+		t.Error("ValidScope wrong")
+	}
+}
+
+func TestAPIKeyCoversAreaCouncil(t *testing.T) {
+	key := &APIKey{Scopes: []string{ScopeSubmit}, AreaCouncilIDs: []string{"amac", "bwari"}}
+	for lga, want := range map[string]bool{"amac": true, "bwari": true, "kuje": false, "": false} {
+		if got := key.CoversAreaCouncil(lga); got != want {
+			t.Errorf("CoversAreaCouncil(%q) = %v, want %v", lga, got, want)
+		}
+	}
+	// A key with no Area Councils covers none, rather than all
+	if (&APIKey{Scopes: []string{ScopeSubmit}}).CoversAreaCouncil("amac") {
+		t.Error("key without Area Councils covers amac")
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIKey(key) || !strings.HasPrefix(key, prefix) || len(prefix) != len(APIKeyPrefix)+8 {
+		t.Errorf("key %q, prefix %q", key, prefix)
+	}
+	if hash != hashToken(key) || strings.Contains(hash, key) {
+		t.Errorf("hash %q isn't the key's hash", hash)
+	}
+	if IsAPIKey("eyJhbGciOiJIUzI1NiJ9.e30.sig") {
+		t.Error("JWT taken for an API key")
+	}
+	other, _, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Error("two keys are the same")
+	}
+}
@@ -2,20 +2,54 @@ package auth
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey = []byte("secret-key-change-me") // TODO: Move to env
-
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    string `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	// PasswordChangeRequired limits the token to changing the password after an admin reset
+	PasswordChangeRequired bool `json:"pwd_change,omitempty"`
+	// TwoFactorSetupRequired limits the token to enrolling in two-factor
+	// authentication, for roles that must use it
+	TwoFactorSetupRequired bool `json:"mfa_setup,omitempty"`
+	// Purpose is set on tokens that are not access tokens
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// PurposeTwoFactor marks the token handed out between a correct password and
+// a correct two-factor code
+const PurposeTwoFactor = "2fa"
+
+// TwoFactorTokenTTL is how long a user has to enter their two-factor code
+var TwoFactorTokenTTL = 5 * time.Minute
+
+// Limits restrict an access token to finishing account setup
+type Limits struct {
+	PasswordChange bool
+	TwoFactorSetup bool
+}
+
+// LimitsFor works out the limits for a user's tokens
+func LimitsFor(role string, mustChangePassword, twoFactorEnabled bool) Limits {
+	return Limits{
+		PasswordChange: mustChangePassword,
+		TwoFactorSetup: TwoFactorRequired(role) && !twoFactorEnabled,
+	}
+}
+
+// Limited reports whether the token only allows finishing account setup
+func (c *Claims) Limited() bool {
+	return c.PasswordChangeRequired || c.TwoFactorSetupRequired
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -26,25 +60,98 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateJWT(userID string, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+// GenerateJWT issues a short-lived access token for a session, signed with
+// the current key and identified by its kid. Each token has its own jti so it
+// can be revoked on its own.
+func GenerateJWT(userID, role, sessionID string, limits Limits) (string, error) {
+	return signJWT(&Claims{
+		UserID:                 userID,
+		Role:                   role,
+		SessionID:              sessionID,
+		PasswordChangeRequired: limits.PasswordChange,
+		TwoFactorSetupRequired: limits.TwoFactorSetup,
+	}, AccessTokenTTL)
+}
+
+// GenerateTwoFactorToken issues the token a user trades, along with their
+// two-factor code, for a session. It is not accepted as an access token.
+func GenerateTwoFactorToken(userID int) (string, error) {
+	return signJWT(&Claims{UserID: strconv.Itoa(userID), Purpose: PurposeTwoFactor}, TwoFactorTokenTTL)
+}
+
+func signJWT(claims *Claims, ttl time.Duration) (string, error) {
+	if Config == nil {
+		return "", errors.New("JWT keys not loaded")
+	}
+	key := Config.Keys[Config.Current]
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Issuer:    Config.Issuer,
+		Subject:   claims.UserID,
+		Audience:  jwt.ClaimStrings{Config.Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signing)
 }
 
+// ValidateJWT verifies an access token
 func ValidateJWT(tokenStr string) (*Claims, error) {
+	claims, err := parseJWT(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+// ValidateTwoFactorToken verifies a token from GenerateTwoFactorToken
+func ValidateTwoFactorToken(tokenStr string) (*Claims, error) {
+	claims, err := parseJWT(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeTwoFactor {
+		return nil, errors.New("not a two-factor token")
+	}
+	return claims, nil
+}
+
+// parseJWT verifies a token against the key named by its kid, which must
+// use the algorithm the token claims, and checks its issuer and audience
+func parseJWT(tokenStr string) (*Claims, error) {
+	if Config == nil {
+		return nil, errors.New("JWT keys not loaded")
+	}
+
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := Config.Keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+		}
+		return key.verifying, nil
+	},
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(Config.Issuer),
+		jwt.WithAudience(Config.Audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
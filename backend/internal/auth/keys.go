@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minSecretLength is the shortest HS256 secret accepted, in bytes
+const minSecretLength = 32
+
+// placeholderSecret starts the example secret older .env files shipped with.
+// Anyone can read it, so it is refused rather than used to sign tokens.
+const placeholderSecret = "change-me"
+
+// Key is a signing or verification key identified by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+	signing   interface{} // Secret or private key; nil for verify-only keys
+	verifying interface{} // Secret or public key
+}
+
+// CanSign reports whether tokens can be issued with this key
+func (k *Key) CanSign() bool {
+	return k.signing != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeySet holds every key tokens may be signed with. New tokens are signed
+// with the current key; the others still verify until they are removed, so
+// keys can be rotated without logging everyone out.
+type KeySet struct {
+	Keys     map[string]*Key
+	Current  string // kid new tokens are signed with
+	Issuer   string
+	Audience string
+}
+
+// Config is the active key set, loaded by LoadConfig at startup
+var Config *KeySet
+
+// LoadConfig loads the key set from the environment:
+//
+//	JWT_KEYS         comma-separated kid:alg:value entries. For HS256 the value is
+//	                 the shared secret; for RS256 and EdDSA it is the path to a PEM
+//	                 file holding a private key (sign and verify) or a public key
+//	                 (verify only, e.g. a key being retired)
+//	JWT_SIGNING_KEY  kid new tokens are signed with; defaults to the first entry
+//	JWT_SECRET       shorthand for a single HS256 key with kid "default"
+//	JWT_ISSUER       iss claim, default "abuja-watch"
+//	JWT_AUDIENCE     aud claim, default "abuja-watch"
+//
+// along with the token lifetimes ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL, given
+// as Go durations such as "15m" or "168h", and the two-factor policy
+// TOTP_REQUIRED_ROLES (comma-separated roles) and TOTP_ISSUER.
+func LoadConfig() error {
+	ks, err := KeySetFromEnv(os.Getenv)
+	if err != nil {
+		return err
+	}
+	if err := loadTwoFactorConfig(os.Getenv); err != nil {
+		return err
+	}
+	for name, ttl := range map[string]*time.Duration{"ACCESS_TOKEN_TTL": &AccessTokenTTL, "REFRESH_TOKEN_TTL": &RefreshTokenTTL} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%s must be a positive duration such as 15m", name)
+		}
+		*ttl = d
+	}
+	Config = ks
+	return nil
+}
+
+// KeySetFromEnv builds a key set from environment variables read with getenv
+func KeySetFromEnv(getenv func(string) string) (*KeySet, error) {
+	ks := &KeySet{
+		Keys:     make(map[string]*Key),
+		Current:  strings.TrimSpace(getenv("JWT_SIGNING_KEY")),
+		Issuer:   strings.TrimSpace(getenv("JWT_ISSUER")),
+		Audience: strings.TrimSpace(getenv("JWT_AUDIENCE")),
+	}
+	if ks.Issuer == "" {
+		ks.Issuer = "abuja-watch"
+	}
+	if ks.Audience == "" {
+		ks.Audience = "abuja-watch"
+	}
+
+	entries := strings.Split(getenv("JWT_KEYS"), ",")
+	if secret := getenv("JWT_SECRET"); secret != "" {
+		entries = append(entries, "default:"+AlgHS256+":"+secret)
+	}
+
+	first := ""
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("JWT_KEYS entries must look like kid:alg:value")
+		}
+		if _, dup := ks.Keys[parts[0]]; dup {
+			return nil, fmt.Errorf("JWT key %q is configured twice", parts[0])
+		}
+		key, err := parseKey(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, err
+		}
+		ks.Keys[key.ID] = key
+		if first == "" {
+			first = key.ID
+		}
+	}
+
+	if len(ks.Keys) == 0 {
+		return nil, fmt.Errorf("no JWT signing keys configured; set JWT_KEYS or JWT_SECRET")
+	}
+	if ks.Current == "" {
+		ks.Current = first
+	}
+	current, ok := ks.Keys[ks.Current]
+	if !ok {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY %q is not in JWT_KEYS", ks.Current)
+	}
+	if !current.CanSign() {
+		return nil, fmt.Errorf("JWT key %q is a public key and can't sign tokens", ks.Current)
+	}
+	return ks, nil
+}
+
+func parseKey(kid, alg, value string) (*Key, error) {
+	key := &Key{ID: kid, Algorithm: alg}
+
+	if alg == AlgHS256 {
+		if len(value) < minSecretLength {
+			return nil, fmt.Errorf("JWT key %q: HS256 secrets must be at least %d bytes", kid, minSecretLength)
+		}
+		if strings.HasPrefix(value, placeholderSecret) {
+			return nil, fmt.Errorf("JWT key %q: still the example secret; generate a random one", kid)
+		}
+		key.signing, key.verifying = []byte(value), []byte(value)
+		return key, nil
+	}
+
+	if alg != AlgRS256 && alg != AlgEdDSA {
+		return nil, fmt.Errorf("JWT key %q: unsupported algorithm %q; use %s, %s or %s", kid, alg, AlgHS256, AlgRS256, AlgEdDSA)
+	}
+	pem, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("JWT key %q: %w", kid, err)
+	}
+
+	switch alg {
+	case AlgRS256:
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+			key.signing, key.verifying = private, &private.PublicKey
+		} else if public, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+			key.verifying = public
+		} else {
+			return nil, fmt.Errorf("JWT key %q: %s is not an RSA key", kid, value)
+		}
+	case AlgEdDSA:
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+			key.signing, key.verifying = private, private.(ed25519.PrivateKey).Public()
+		} else if public, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+			key.verifying = public
+		} else {
+			return nil, fmt.Errorf("JWT key %q: %s is not an Ed25519 key", kid, value)
+		}
+	}
+	return key, nil
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS returns the public keys of every asymmetric key, so other services can
+// verify our tokens. Shared HS256 secrets are never published.
+func (ks *KeySet) JWKS() []JWK {
+	ids := make([]string, 0, len(ks.Keys))
+	for kid := range ks.Keys {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+
+	keys := []JWK{}
+	for _, kid := range ids {
+		key := ks.Keys[kid]
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch public := key.verifying.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
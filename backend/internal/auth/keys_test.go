@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func envFrom(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestKeySetFromEnvSecret(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLength)
+	ks, err := KeySetFromEnv(envFrom(map[string]string{"JWT_SECRET": secret}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ks.Current != "default" || !ks.Keys["default"].CanSign() {
+		t.Errorf("key set = %+v, want a signing key called default", ks)
+	}
+
+	for _, bad := range []string{"", "too-short", "change-me-to-a-long-random-secret-value"} {
+		if _, err := KeySetFromEnv(envFrom(map[string]string{"JWT_SECRET": bad})); err == nil {
+			t.Errorf("JWT_SECRET %q was accepted", bad)
+		}
+	}
+	if _, err := KeySetFromEnv(envFrom(map[string]string{"JWT_KEYS": "old:HS256:change-me-to-a-long-random-secret-value"})); err == nil {
+		t.Error("placeholder secret in JWT_KEYS was accepted")
+	}
+}
@@ -0,0 +1,102 @@
+package auth_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+func TestLoginLockout(t *testing.T) {
+	m := store.NewMemoryStore()
+	const ip = "203.0.113.7"
+
+	if block, err := auth.CheckLogin(m, "editor", ip); err != nil || block != nil {
+		t.Fatalf("before any failure: block = %+v, %v; want none", block, err)
+	}
+	for i := 1; i < auth.MaxLoginFailures; i++ {
+		userLocked, ipLocked, err := auth.RecordLoginFailure(m, "editor", ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userLocked || ipLocked {
+			t.Fatalf("locked out after %d failures", i)
+		}
+	}
+	block, err := auth.CheckLogin(m, "editor", ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block == nil || block.Locked || block.RetryAfter <= 0 {
+		t.Fatalf("after %d failures: block = %+v, want a delay", auth.MaxLoginFailures-1, block)
+	}
+
+	userLocked, ipLocked, err := auth.RecordLoginFailure(m, "editor", ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !userLocked || ipLocked {
+		t.Fatalf("after %d failures: userLocked = %v, ipLocked = %v; want only the user", auth.MaxLoginFailures, userLocked, ipLocked)
+	}
+	block, err = auth.CheckLogin(m, "editor", ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block == nil || !block.Locked || block.RetryAfter > auth.LoginLockout {
+		t.Fatalf("block = %+v, want a lockout of at most %v", block, auth.LoginLockout)
+	}
+	// Another username from the same address is only slowed by the address's count
+	if block, err := auth.CheckLogin(m, "reviewer", ip); err != nil || block == nil || block.Locked {
+		t.Errorf("other user, same address: block = %+v, %v; want a delay", block, err)
+	}
+
+	if unlocked, err := auth.UnlockLogin(m, "editor"); err != nil || !unlocked {
+		t.Fatalf("UnlockLogin = %v, %v; want true", unlocked, err)
+	}
+	if unlocked, err := auth.UnlockLogin(m, "editor"); err != nil || unlocked {
+		t.Errorf("second UnlockLogin = %v, %v; want false", unlocked, err)
+	}
+	if block, err := auth.CheckLogin(m, "editor", "198.51.100.1"); err != nil || block != nil {
+		t.Errorf("after unlock: block = %+v, %v; want none", block, err)
+	}
+}
+
+func TestLoginLockoutByIP(t *testing.T) {
+	m := store.NewMemoryStore()
+	const ip = "203.0.113.7"
+
+	var ipLocked bool
+	for i := 0; i < auth.MaxLoginFailuresPerIP; i++ {
+		// A different username each time, as in password spraying
+		var err error
+		if _, ipLocked, err = auth.RecordLoginFailure(m, fmt.Sprintf("user%d", i), ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !ipLocked {
+		t.Fatalf("address not locked out after %d failures", auth.MaxLoginFailuresPerIP)
+	}
+	block, err := auth.CheckLogin(m, "editor", ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block == nil || !block.Locked {
+		t.Fatalf("block = %+v, want a lockout", block)
+	}
+
+	// A successful login clears the username but not the address
+	if err := auth.RecordLoginSuccess(m, "editor"); err != nil {
+		t.Fatal(err)
+	}
+	if block, err := auth.CheckLogin(m, "editor", ip); err != nil || block == nil || !block.Locked {
+		t.Errorf("after a success: block = %+v, %v; want the address still locked", block, err)
+	}
+
+	if unlocked, err := auth.UnlockIP(m, ip); err != nil || !unlocked {
+		t.Fatalf("UnlockIP = %v, %v; want true", unlocked, err)
+	}
+	if block, err := auth.CheckLogin(m, "editor", ip); err != nil || block != nil {
+		t.Errorf("after unlock: block = %+v, %v; want none", block, err)
+	}
+}
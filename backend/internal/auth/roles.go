@@ -0,0 +1,67 @@
+package auth
+
+// Roles
+const (
+	RoleAdmin    = "admin"
+	RoleEditor   = "editor"
+	RoleReviewer = "reviewer"
+)
+
+// Roles lists every role a user can hold
+var Roles = []string{RoleAdmin, RoleEditor, RoleReviewer}
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Permission is something a role allows its holders to do
+type Permission string
+
+// Permissions
+const (
+	PermSubmitResults      Permission = "results:submit"       // Ward, polling unit and Area Council submissions
+	PermReviewResults      Permission = "results:review"       // Approve or reject submitted ward results
+	PermRevertResults      Permission = "results:revert"       // Roll a ward back to an earlier version
+	PermManageParties      Permission = "parties:manage"       // Configure the parties contesting in an Area Council
+	PermManagePollingUnits Permission = "polling_units:manage" // Create, edit, import and delete polling units
+	PermViewSubmissions    Permission = "submissions:read"     // Unpublished history and the review queue
+	PermReportIncidents    Permission = "incidents:report"
+	PermManageIncidents    Permission = "incidents:manage" // Move incidents through their workflow
+	PermManageAPIKeys      Permission = "api_keys:manage"
+	PermManageElections    Permission = "elections:manage"
+	PermManageRisk         Permission = "risk:manage"
+	PermManageUsers        Permission = "users:manage"
+	PermManageWebhooks     Permission = "webhooks:manage"
+	PermViewAuditLogs      Permission = "audit:read"
+	PermAllJurisdictions   Permission = "jurisdictions:all" // Not limited to assigned Area Councils and wards
+)
+
+// rolePermissions maps each role to what it may do
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermSubmitResults, PermReviewResults, PermRevertResults, PermManageParties, PermManagePollingUnits,
+		PermViewSubmissions, PermReportIncidents, PermManageIncidents, PermManageElections, PermManageRisk,
+		PermManageUsers, PermManageWebhooks, PermManageAPIKeys, PermViewAuditLogs, PermAllJurisdictions,
+	},
+	RoleEditor: {
+		PermSubmitResults, PermManageParties, PermManagePollingUnits, PermViewSubmissions,
+		PermReportIncidents, PermManageIncidents,
+	},
+	RoleReviewer: {PermReviewResults, PermViewSubmissions, PermReportIncidents, PermManageIncidents},
+}
+
+// Can reports whether a role grants a permission
+func Can(role string, p Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// Token lifetimes, overridable with ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL.
+// A refresh token's lifetime restarts each time it is rotated, so a session
+// lasts as long as it keeps being used.
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// Session revocation reasons
+const (
+	RevokedLogout           = "logout"
+	RevokedByAdmin          = "admin"
+	RevokedRefreshReuse     = "refresh_reuse"
+	RevokedDeactivated      = "deactivated"
+	RevokedRoleChanged      = "role_changed"
+	RevokedPasswordChanged  = "password_changed"
+	RevokedTwoFactorChanged = "2fa_changed"
+)
+
+var (
+	// ErrInvalidRefreshToken means the refresh token is unknown, expired or its session has ended
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused means an already rotated refresh token was presented; the session has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// TokenPair is what a login or refresh hands back to the client
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // Seconds until the access token expires
+}
+
+// randomToken returns n random bytes, URL-safe base64 encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SessionStore keeps sessions, their refresh tokens and revoked access
+// tokens. Expiry is measured by the store's clock.
+type SessionStore interface {
+	// CreateSession saves a new session along with its first refresh token,
+	// which expires after ttl
+	CreateSession(s models.Session, refreshHash string, ttl time.Duration) error
+	// RotateRefreshToken marks a refresh token used and gives its session the
+	// next one, expiring after ttl. Unknown and expired tokens, and tokens of
+	// ended sessions or deactivated users, give ErrInvalidRefreshToken. A
+	// token that was already used gives ErrRefreshTokenReused and its session
+	// is revoked. The session is returned, alongside either error if known.
+	RotateRefreshToken(hash, nextHash string, ttl time.Duration) (RefreshedSession, error)
+	// EndSession revokes a session and blocks the access token jti, issued to
+	// userID, for the remaining time it would have been valid
+	EndSession(sessionID, reason, jti string, userID int, remaining time.Duration) error
+	// RevokeUserSessions revokes every open session of a user and returns how many there were
+	RevokeUserSessions(userID int, reason string) (int, error)
+	// IsRevoked reports whether the access token jti is blocked or its session has ended
+	IsRevoked(jti, sessionID string) (bool, error)
+}
+
+// RefreshedSession is a session whose refresh token was rotated, with its
+// user's current role and account state
+type RefreshedSession struct {
+	ID                 string
+	UserID             int
+	Role               string
+	MustChangePassword bool
+	TwoFactorEnabled   bool
+}
+
+func tokenPair(userID int, role string, limits Limits, sessionID, refreshToken string) (TokenPair, error) {
+	access, err := GenerateJWT(strconv.Itoa(userID), role, sessionID, limits)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// StartSession opens a session for a user who has just logged in. While any
+// limits apply the access token only allows finishing account setup.
+func StartSession(s SessionStore, userID int, role string, limits Limits, ip, userAgent string) (TokenPair, error) {
+	sessionID, err := randomToken(18)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	session := models.Session{ID: sessionID, UserID: userID, IPAddress: ip, UserAgent: userAgent}
+	if err := s.CreateSession(session, hashToken(refresh), RefreshTokenTTL); err != nil {
+		return TokenPair{}, err
+	}
+	return tokenPair(userID, role, limits, sessionID, refresh)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working; if it is ever presented again
+// the whole session is revoked. The user ID is returned for auditing, and
+// also alongside ErrRefreshTokenReused.
+func Refresh(s SessionStore, refreshToken string) (TokenPair, int, error) {
+	next, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, 0, err
+	}
+	session, err := s.RotateRefreshToken(hashToken(refreshToken), hashToken(next), RefreshTokenTTL)
+	if err != nil {
+		return TokenPair{}, session.UserID, err
+	}
+	limits := LimitsFor(session.Role, session.MustChangePassword, session.TwoFactorEnabled)
+	pair, err := tokenPair(session.UserID, session.Role, limits, session.ID, next)
+	return pair, session.UserID, err
+}
+
+// Logout ends the session an access token belongs to and revokes the token itself
+func Logout(s SessionStore, claims *Claims) error {
+	remaining := time.Minute
+	if claims.ExpiresAt != nil {
+		remaining = time.Until(claims.ExpiresAt.Time)
+	}
+	userID, _ := strconv.Atoi(claims.UserID)
+	return s.EndSession(claims.SessionID, RevokedLogout, claims.ID, userID, remaining)
+}
+
+// IsRevoked reports whether an access token has been revoked, either by its
+// own jti or because its session has ended
+func IsRevoked(s SessionStore, claims *Claims) (bool, error) {
+	return s.IsRevoked(claims.ID, claims.SessionID)
+}
@@ -0,0 +1,128 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// newSessionStore loads a signing key and returns a store holding an admin
+// and an editor, and the editor's ID
+func newSessionStore(t *testing.T) (*store.MemoryStore, int) {
+	t.Helper()
+	ks, err := auth.KeySetFromEnv(func(name string) string {
+		if name == "JWT_SECRET" {
+			return strings.Repeat("s", 32)
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Config = ks
+
+	m := store.NewMemoryStore()
+	if _, err := m.CreateUser("admin", "hash", auth.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	u, err := m.CreateUser("editor", "hash", auth.RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, u.ID
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	m, userID := newSessionStore(t)
+	first, err := auth.StartSession(m, userID, auth.RoleEditor, auth.Limits{}, "203.0.113.7", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, gotUserID, err := auth.Refresh(m, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotUserID != userID {
+		t.Errorf("user ID = %d, want %d", gotUserID, userID)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	claims, err := auth.ValidateJWT(second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Role != auth.RoleEditor {
+		t.Errorf("role = %q, want %q", claims.Role, auth.RoleEditor)
+	}
+
+	third, _, err := auth.Refresh(m, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("rotated token was refused: %v", err)
+	}
+	if _, _, err := auth.Refresh(m, "not-a-token"); err != auth.ErrInvalidRefreshToken {
+		t.Errorf("unknown token: err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Presenting a used token again ends the session, so the thief and the
+	// owner are both signed out
+	_, gotUserID, err = auth.Refresh(m, first.RefreshToken)
+	if err != auth.ErrRefreshTokenReused {
+		t.Fatalf("reused token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("reused token: user ID = %d, want %d", gotUserID, userID)
+	}
+	if _, _, err := auth.Refresh(m, third.RefreshToken); err != auth.ErrInvalidRefreshToken {
+		t.Errorf("latest token after reuse: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	claims, err = auth.ValidateJWT(third.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := auth.IsRevoked(m, claims); err != nil || !revoked {
+		t.Errorf("access token after reuse: revoked = %v, %v; want true", revoked, err)
+	}
+}
+
+func TestRefreshRefusesDeactivatedUser(t *testing.T) {
+	m, userID := newSessionStore(t)
+	pair, err := auth.StartSession(m, userID, auth.RoleEditor, auth.Limits{}, "203.0.113.7", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetUsersActive([]int{userID}, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := auth.Refresh(m, pair.RefreshToken); err != auth.ErrInvalidRefreshToken {
+		t.Errorf("err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	m, userID := newSessionStore(t)
+	pair, err := auth.StartSession(m, userID, auth.RoleEditor, auth.Limits{}, "203.0.113.7", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := auth.ValidateJWT(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked, err := auth.IsRevoked(m, claims); err != nil || revoked {
+		t.Fatalf("before logout: revoked = %v, %v; want false", revoked, err)
+	}
+	if err := auth.Logout(m, claims); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := auth.IsRevoked(m, claims); err != nil || !revoked {
+		t.Errorf("after logout: revoked = %v, %v; want true", revoked, err)
+	}
+	if _, _, err := auth.Refresh(m, pair.RefreshToken); err != auth.ErrInvalidRefreshToken {
+		t.Errorf("refresh after logout: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Login throttling. After LoginDelayAfter failures in a row, each further
+// attempt has to wait twice as long as the last, up to LoginMaxDelay. Reaching
+// MaxLoginFailures for a username, or MaxLoginFailuresPerIP for an address,
+// locks it out for LoginLockout. Failures are forgotten once none has happened
+// for LoginFailureWindow.
+var (
+	MaxLoginFailures      = 5
+	MaxLoginFailuresPerIP = 20
+	LoginDelayAfter       = 3
+	LoginBaseDelay        = time.Second
+	LoginMaxDelay         = 30 * time.Second
+	LoginLockout          = 15 * time.Minute
+	LoginFailureWindow    = 15 * time.Minute
+)
+
+// LoginBlock says why and for how long login attempts are being refused
+type LoginBlock struct {
+	RetryAfter time.Duration
+	Locked     bool // Locked out, rather than just slowed down
+}
+
+func userThrottleKey(username string) string { return "user:" + username }
+func ipThrottleKey(ip string) string         { return "ip:" + ip }
+
+// loginDelay is how long to wait after the given number of failures
+func loginDelay(failures int) time.Duration {
+	if failures < LoginDelayAfter {
+		return 0
+	}
+	d := LoginBaseDelay
+	for i := LoginDelayAfter; i < failures && d < LoginMaxDelay; i++ {
+		d *= 2
+	}
+	if d > LoginMaxDelay {
+		d = LoginMaxDelay
+	}
+	return d
+}
+
+// ThrottleStore counts failed logins by key. Times are measured by the store's clock.
+type ThrottleStore interface {
+	// LoginFailures returns how each of the keys stands, leaving out keys
+	// with no failure in the last window
+	LoginFailures(keys []string, window time.Duration) ([]ThrottleState, error)
+	// RecordLoginFailures counts a failure against each key, starting again
+	// from one if its last failure is older than window, and locks a key out
+	// for lockout once its failures reach its limit. It reports whether each
+	// key is locked out by this failure, and forgets keys that have gone stale.
+	RecordLoginFailures(limits []ThrottleLimit, window, lockout time.Duration) ([]bool, error)
+	// ClearLoginFailures forgets a key's failures
+	ClearLoginFailures(key string) error
+	// UnlockLogins forgets a key's failures and lifts any lockout, reporting
+	// whether it was locked out
+	UnlockLogins(key string) (bool, error)
+}
+
+// ThrottleState is how a key stands
+type ThrottleState struct {
+	Failures  int
+	Since     time.Duration // Since the last failure
+	LockedFor time.Duration // Until the lockout ends; 0 when not locked out
+}
+
+// ThrottleLimit is how many failures lock a key out
+type ThrottleLimit struct {
+	Key string
+	Max int
+}
+
+// CheckLogin reports whether a login attempt for the username from ip has to
+// be refused, and for how long. A nil block means the attempt may go ahead.
+func CheckLogin(s ThrottleStore, username, ip string) (*LoginBlock, error) {
+	states, err := s.LoginFailures([]string{userThrottleKey(username), ipThrottleKey(ip)}, LoginFailureWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	var block *LoginBlock
+	for _, state := range states {
+		b := LoginBlock{RetryAfter: loginDelay(state.Failures) - state.Since}
+		if state.LockedFor > 0 {
+			b = LoginBlock{RetryAfter: state.LockedFor, Locked: true}
+		}
+		if b.RetryAfter > 0 && (block == nil || b.RetryAfter > block.RetryAfter) {
+			block = &b
+		}
+	}
+	return block, nil
+}
+
+// RecordLoginFailure counts a failed attempt against the username and the
+// address. It reports whether this failure locked either of them out.
+func RecordLoginFailure(s ThrottleStore, username, ip string) (userLocked, ipLocked bool, err error) {
+	locked, err := s.RecordLoginFailures([]ThrottleLimit{
+		{Key: userThrottleKey(username), Max: MaxLoginFailures},
+		{Key: ipThrottleKey(ip), Max: MaxLoginFailuresPerIP},
+	}, LoginFailureWindow, LoginLockout)
+	if err != nil {
+		return false, false, err
+	}
+	return locked[0], locked[1], nil
+}
+
+// RecordLoginSuccess clears the username's failures. The address keeps its
+// count, so one working account can't be used to reset it between guesses.
+func RecordLoginSuccess(s ThrottleStore, username string) error {
+	return s.ClearLoginFailures(userThrottleKey(username))
+}
+
+// UnlockLogin lifts a lockout on a username and clears its failures. It
+// reports whether the username was locked out.
+func UnlockLogin(s ThrottleStore, username string) (bool, error) {
+	return s.UnlockLogins(userThrottleKey(username))
+}
+
+// UnlockIP lifts a lockout on an address, e.g. a collation centre whose
+// devices all share one
+func UnlockIP(s ThrottleStore, ip string) (bool, error) {
+	return s.UnlockLogins(ipThrottleKey(ip))
+}
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// CheckPasswordAgainstNothing spends as long as CheckPasswordHash does, for
+// logins with an unknown username, so response times don't tell an attacker
+// which usernames exist
+func CheckPasswordAgainstNothing(password string) {
+	dummyHashOnce.Do(func() {
+		token, _ := randomToken(16)
+		dummyHash, _ = HashPassword(token)
+	})
+	CheckPasswordHash(password, dummyHash)
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{LoginDelayAfter - 1, 0},
+		{LoginDelayAfter, LoginBaseDelay},
+		{LoginDelayAfter + 1, 2 * LoginBaseDelay},
+		{LoginDelayAfter + 2, 4 * LoginBaseDelay},
+		{LoginDelayAfter + 4, 16 * LoginBaseDelay},
+		{LoginDelayAfter + 5, LoginMaxDelay},
+		{1000, LoginMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
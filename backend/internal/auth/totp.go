@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Two-factor authentication uses RFC 6238 time-based one-time passwords with
+// the parameters every authenticator app supports: SHA-1, 6 digits and a 30
+// second step. Codes are computed locally, so no network access is needed.
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	totpSkew   = 1 // Steps either side of now that are still accepted, for clock drift
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time
+const RecoveryCodeCount = 10
+
+var (
+	// TwoFactorRequiredRoles must enrol in two-factor authentication before
+	// they can do anything else. Set with TOTP_REQUIRED_ROLES.
+	TwoFactorRequiredRoles []string
+	// TwoFactorIssuer is the account label shown in authenticator apps. Set with TOTP_ISSUER.
+	TwoFactorIssuer = "Abuja Watch"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// loadTwoFactorConfig reads the two-factor policy from the environment
+func loadTwoFactorConfig(getenv func(string) string) error {
+	var roles []string
+	for _, role := range strings.Split(getenv("TOTP_REQUIRED_ROLES"), ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+		if !ValidRole(role) {
+			return fmt.Errorf("TOTP_REQUIRED_ROLES: unknown role %q", role)
+		}
+		roles = append(roles, role)
+	}
+	TwoFactorRequiredRoles = roles
+	if issuer := strings.TrimSpace(getenv("TOTP_ISSUER")); issuer != "" {
+		TwoFactorIssuer = issuer
+	}
+	return nil
+}
+
+// TwoFactorRequired reports whether holders of a role must use two-factor authentication
+func TwoFactorRequired(role string) bool {
+	for _, r := range TwoFactorRequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateTOTPSecret returns a new random base32 secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI is the otpauth:// URI authenticator apps enrol from, usually shown as a QR code
+func TOTPURI(account, secret string) string {
+	label := url.PathEscape(TwoFactorIssuer) + ":" + url.PathEscape(account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", TwoFactorIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	// Some authenticator apps don't read + as a space
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+// totpCode computes the code for a time step
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// CheckTOTP checks a code against a secret at time now. Codes from steps up
+// to lastStep have already been used and are refused, so each code works
+// once. It returns the step the code belongs to, to be stored as the new lastStep.
+func CheckTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns new single-use recovery codes, formatted
+// like "k3v9x-7hq2m" for reading off paper
+func GenerateRecoveryCodes() ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	// Bytes past the last whole multiple of the alphabet are skipped so every letter is equally likely
+	limit := byte(256 / len(alphabet) * len(alphabet))
+	codes := make([]string, RecoveryCodeCount)
+	buf := make([]byte, 1)
+	for i := range codes {
+		code := make([]byte, 0, 10)
+		for len(code) < 10 {
+			if _, err := rand.Read(buf); err != nil {
+				return nil, err
+			}
+			if buf[0] < limit {
+				code = append(code, alphabet[int(buf[0])%len(alphabet)])
+			}
+		}
+		codes[i] = string(code[:5]) + "-" + string(code[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage, ignoring case, spaces and dashes
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return hashToken(code)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from RFC 6238 appendix B, "12345678901234567890"
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// RFC 6238 appendix B gives 8 digit codes; ours are their last 6 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCheckTOTPVectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		step, ok := CheckTOTP(rfcSecret, v.code, time.Unix(v.unix, 0), 0)
+		if !ok {
+			t.Errorf("code %s at %d was refused", v.code, v.unix)
+			continue
+		}
+		if want := v.unix / totpPeriod; step != want {
+			t.Errorf("code %s at %d: step = %d, want %d", v.code, v.unix, step, want)
+		}
+	}
+	if _, ok := CheckTOTP(rfcSecret, "287083", time.Unix(59, 0), 0); ok {
+		t.Error("wrong code was accepted")
+	}
+	if _, ok := CheckTOTP(rfcSecret, "28 70 82", time.Unix(59, 0), 0); !ok {
+		t.Error("code with spaces was refused")
+	}
+	if _, ok := CheckTOTP("not base32!", "287082", time.Unix(59, 0), 0); ok {
+		t.Error("code was accepted for a malformed secret")
+	}
+}
+
+func TestCheckTOTPSkew(t *testing.T) {
+	// 1111111109 is step 37037036; its code is good one step either side
+	code, at := "081804", int64(1111111109)
+	for _, offset := range []int64{-totpPeriod, 0, totpPeriod} {
+		if _, ok := CheckTOTP(rfcSecret, code, time.Unix(at+offset, 0), 0); !ok {
+			t.Errorf("code refused %ds from its step", offset)
+		}
+	}
+	for _, offset := range []int64{-2 * totpPeriod, 2 * totpPeriod} {
+		if _, ok := CheckTOTP(rfcSecret, code, time.Unix(at+offset, 0), 0); ok {
+			t.Errorf("code accepted %ds from its step", offset)
+		}
+	}
+}
+
+func TestCheckTOTPReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step, ok := CheckTOTP(rfcSecret, "005924", now, 0)
+	if !ok {
+		t.Fatal("code refused")
+	}
+	if _, ok := CheckTOTP(rfcSecret, "005924", now, step); ok {
+		t.Error("code accepted a second time")
+	}
+	// Nor can an older code still inside the skew window be used once a later one has been
+	previous := totpCode([]byte("12345678901234567890"), step-1)
+	if _, ok := CheckTOTP(rfcSecret, previous, now, step); ok {
+		t.Error("earlier code accepted after a later one was used")
+	}
+	if _, ok := CheckTOTP(rfcSecret, previous, now, step-2); !ok {
+		t.Error("earlier code refused before anything was used")
+	}
+}
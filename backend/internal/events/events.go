@@ -0,0 +1,186 @@
+// Package events is an in-process publish/subscribe bus for live updates.
+// Handlers publish after their writes commit; the SSE stream subscribes.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	WardResultUpdated    = "ward.result_updated"
+	WardResultApproved   = "ward.result_approved"
+	IncidentReported     = "incident.reported"
+	IncidentStatusChange = "incident.status_changed"
+	IncidentResolved     = "incident.resolved"
+	IntegrityFlagChanged = "integrity.flag_changed"
+	PartyConfigChanged   = "party_config.changed"
+)
+
+// Types lists every event type, for validating subscriptions
+var Types = []string{
+	WardResultUpdated,
+	WardResultApproved,
+	IncidentReported,
+	IncidentStatusChange,
+	IncidentResolved,
+	IntegrityFlagChanged,
+	PartyConfigChanged,
+}
+
+// ValidType reports whether t is a known event type
+func ValidType(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a single live update. Data carries identifiers and a summary of
+// what changed, never unpublished figures; clients refetch what they show.
+// Approvals are the exception: they publish the approved figures themselves.
+type Event struct {
+	ID            uint64      `json:"id"`
+	Type          string      `json:"type"`
+	ElectionID    string      `json:"election_id,omitempty"`
+	AreaCouncilID string      `json:"area_council_id,omitempty"`
+	WardID        string      `json:"ward_id,omitempty"`
+	Data          interface{} `json:"data,omitempty"`
+	Time          time.Time   `json:"time"`
+}
+
+// Filter restricts a subscription to one election and/or Area Council.
+// Empty fields match everything.
+type Filter struct {
+	ElectionID    string
+	AreaCouncilID string
+}
+
+// Match reports whether an event passes the filter
+func (f Filter) Match(e Event) bool {
+	if f.ElectionID != "" && e.ElectionID != "" && e.ElectionID != f.ElectionID {
+		return false
+	}
+	if f.AreaCouncilID != "" && e.AreaCouncilID != "" && e.AreaCouncilID != f.AreaCouncilID {
+		return false
+	}
+	return true
+}
+
+// Bus fans events out to subscribers and keeps the most recent ones so
+// reconnecting clients can resume from the last event they saw.
+type Bus struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []Event // Oldest first, at most size entries
+	size    int
+	buffer  int
+	subs    map[*Subscription]struct{}
+}
+
+// Subscription receives matching events on C. C is closed if the subscriber
+// falls too far behind, so it can reconnect and resume instead of blocking
+// publishers.
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+	bus    *Bus
+}
+
+// NewBus creates a bus that remembers the last historySize events and buffers
+// up to buffer undelivered events per subscriber.
+func NewBus(historySize, buffer int) *Bus {
+	return &Bus{
+		// IDs start from the boot time so they keep increasing across restarts
+		// and a client resuming from before a restart is told to refetch.
+		lastID: uint64(time.Now().UnixMilli()) * 1000,
+		size:   historySize,
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Default is the bus the handlers publish to
+var Default = NewBus(1000, 256)
+
+// Publish sends an event to the default bus
+func Publish(e Event) Event {
+	return Default.Publish(e)
+}
+
+// Publish assigns the event its ID and time, records it and delivers it to
+// every matching subscriber without blocking.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			// Too slow: drop it rather than hold up everyone else
+			delete(b.subs, sub)
+			close(sub.c)
+		}
+	}
+	return e
+}
+
+// Subscribe registers a subscriber. With a non-zero lastID it also returns the
+// matching events published since then; complete is false when some of those
+// are no longer held, in which case the client should refetch its state.
+func (b *Bus) Subscribe(lastID uint64, filter Filter) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastID > 0 {
+		switch {
+		case lastID > b.lastID:
+			complete = false // From another process lifetime
+		case len(b.history) > 0 && lastID < b.history[0].ID-1:
+			complete = false
+		case len(b.history) == 0 && lastID < b.lastID:
+			complete = false
+		}
+		for _, e := range b.history {
+			if e.ID > lastID && filter.Match(e) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	c := make(chan Event, b.buffer)
+	sub = &Subscription{C: c, c: c, filter: filter, bus: b}
+	b.subs[sub] = struct{}{}
+	return sub, missed, complete
+}
+
+// Close unregisters the subscription
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,111 @@
+package events
+
+import "testing"
+
+func TestResumeWithinWindow(t *testing.T) {
+	b := NewBus(1000, 16)
+	var published []Event
+	for _, lga := range []string{"amac", "bwari", "amac", "kuje", "amac"} {
+		published = append(published, b.Publish(Event{Type: WardResultUpdated, ElectionID: "e1", AreaCouncilID: lga}))
+	}
+
+	sub, missed, complete := b.Subscribe(published[1].ID, Filter{})
+	defer sub.Close()
+	if !complete {
+		t.Error("resume within the window reported incomplete")
+	}
+	if len(missed) != 3 || missed[0].ID != published[2].ID || missed[2].ID != published[4].ID {
+		t.Errorf("missed %d events from %v, want the last 3", len(missed), missed)
+	}
+
+	amac, missed, complete := b.Subscribe(published[0].ID, Filter{AreaCouncilID: "amac"})
+	defer amac.Close()
+	if !complete || len(missed) != 2 || missed[0].ID != published[2].ID || missed[1].ID != published[4].ID {
+		t.Errorf("amac resume: complete = %v, missed %v; want events %d and %d", complete, missed, published[2].ID, published[4].ID)
+	}
+
+	upToDate, missed, complete := b.Subscribe(published[4].ID, Filter{})
+	defer upToDate.Close()
+	if !complete || len(missed) != 0 {
+		t.Errorf("resume from the latest event: complete = %v, missed %d; want nothing missed", complete, len(missed))
+	}
+
+	// IDs from a later process lifetime than this one can't be resumed
+	later, _, complete := b.Subscribe(published[4].ID+1000, Filter{})
+	defer later.Close()
+	if complete {
+		t.Error("resume from an unknown future ID reported complete")
+	}
+}
+
+func TestResumePastWindow(t *testing.T) {
+	const size = 1000
+	b := NewBus(size, 16)
+	first := b.Publish(Event{Type: IncidentReported})
+	second := b.Publish(Event{Type: IncidentReported})
+	for i := 0; i < size-1; i++ {
+		b.Publish(Event{Type: IncidentReported})
+	}
+
+	// first has fallen out of the window, but everything after it is still held
+	sub, missed, complete := b.Subscribe(first.ID, Filter{})
+	sub.Close()
+	if !complete || len(missed) != size || missed[0].ID != second.ID {
+		t.Errorf("resume from the event just out of the window: complete = %v, missed %d; want all %d held", complete, len(missed), size)
+	}
+
+	// Anything before it was lost, so the client has to refetch
+	sub, missed, complete = b.Subscribe(first.ID-1, Filter{})
+	sub.Close()
+	if complete {
+		t.Error("resume past the window reported complete")
+	}
+	if len(missed) != size {
+		t.Errorf("missed %d events, want the %d still held", len(missed), size)
+	}
+
+	// A bus that has published nothing since boot holds no history at all
+	empty := NewBus(size, 16)
+	sub, _, complete = empty.Subscribe(empty.lastID-1, Filter{})
+	sub.Close()
+	if complete {
+		t.Error("resume from before boot reported complete")
+	}
+}
+
+func TestSlowConsumerDropped(t *testing.T) {
+	b := NewBus(10, 2)
+	slow, _, _ := b.Subscribe(0, Filter{AreaCouncilID: "amac"})
+	fast, _, _ := b.Subscribe(0, Filter{})
+	defer fast.Close()
+
+	// Events the slow subscriber filters out don't fill its buffer
+	for i := 0; i < 3; i++ {
+		b.Publish(Event{Type: IncidentReported, AreaCouncilID: "bwari"})
+		<-fast.C
+	}
+	b.Publish(Event{Type: IncidentReported, AreaCouncilID: "amac"})
+	b.Publish(Event{Type: IncidentReported, AreaCouncilID: "amac"})
+	<-fast.C
+	<-fast.C
+	if _, ok := b.subs[slow]; !ok {
+		t.Fatal("subscriber dropped before its buffer filled")
+	}
+
+	// The next one would block, so the slow subscriber is cut off instead
+	e := b.Publish(Event{Type: IncidentReported, AreaCouncilID: "amac"})
+	if got := <-fast.C; got.ID != e.ID {
+		t.Errorf("fast subscriber got event %d, want %d", got.ID, e.ID)
+	}
+	var received int
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscriber received %d events before being dropped, want 2", received)
+	}
+	if _, ok := b.subs[slow]; ok {
+		t.Error("slow subscriber still registered")
+	}
+	slow.Close() // Closing after being dropped is harmless
+}
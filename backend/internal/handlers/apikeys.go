@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// GetAPIKeys lists every API key, newest first. The keys themselves are never shown again.
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.APIKeys.APIKeys()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// CreateAPIKey issues a named key. Keys with the submit scope must name the
+// Area Councils they may submit for. The key is only shown in this response.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name           string   `json:"name"`
+		Scopes         []string `json:"scopes"`
+		AreaCouncilIDs []string `json:"area_council_ids"`
+		ExpiresInDays  int      `json:"expires_in_days"` // 0: never expires
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Scopes) == 0 {
+		http.Error(w, "scopes must include at least one of "+strings.Join(auth.Scopes, ", "), http.StatusBadRequest)
+		return
+	}
+	submit := false
+	for _, scope := range payload.Scopes {
+		if !auth.ValidScope(scope) {
+			http.Error(w, fmt.Sprintf("unknown scope %q; expected one of %s", scope, strings.Join(auth.Scopes, ", ")), http.StatusBadRequest)
+			return
+		}
+		submit = submit || scope == auth.ScopeSubmit
+	}
+	if payload.AreaCouncilIDs == nil {
+		payload.AreaCouncilIDs = []string{}
+	}
+	if submit && len(payload.AreaCouncilIDs) == 0 {
+		http.Error(w, "area_council_ids is required for the submit scope", http.StatusBadRequest)
+		return
+	}
+	for _, lgaID := range payload.AreaCouncilIDs {
+		if !h.areaCouncilExists(lgaID) {
+			http.Error(w, "Area Council "+lgaID+" not found", http.StatusBadRequest)
+			return
+		}
+	}
+	if payload.ExpiresInDays < 0 {
+		http.Error(w, "expires_in_days cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userID := currentUserID(r)
+	k, err := h.APIKeys.CreateAPIKey(models.APIKey{
+		Name:           payload.Name,
+		Prefix:         prefix,
+		Scopes:         payload.Scopes,
+		AreaCouncilIDs: payload.AreaCouncilIDs,
+		CreatedBy:      &userID,
+	}, hash, time.Duration(payload.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		http.Error(w, "Failed to create API key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(userID, "CREATE_API_KEY", fmt.Sprintf("Created API key %d (%s) with scopes %s", k.ID, k.Name, strings.Join(k.Scopes, ", ")), r)
+
+	k.Key = key
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(k)
+}
+
+// RevokeAPIKey stops a key working straight away. It stays listed so the
+// audit log can still name it.
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.Atoi(chi.URLParam(r, "keyID"))
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	k, err := h.APIKeys.RevokeAPIKey(keyID)
+	if err == store.ErrNotFound {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "REVOKE_API_KEY", fmt.Sprintf("Revoked API key %d (%s)", k.ID, k.Name), r)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// areaCouncilExists reports whether an Area Council ID is known
+func (h *Handler) areaCouncilExists(lgaID string) bool {
+	exists, _ := h.Wards.AreaCouncilExists(lgaID)
+	return exists
+}
+
+// SubmitAreaCouncilLogistics handles the submission of Area Council collation logistics
+func (h *Handler) SubmitAreaCouncilLogistics(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID         string `json:"election_id"`
+		AreaCouncilID      string `json:"area_council_id"`
+		ArrivalTime        string `json:"arrival_time"`
+		CollationStartTime string `json:"collation_start_time"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.areaCouncilExists(payload.AreaCouncilID) {
+		http.Error(w, "Area Council not found", http.StatusBadRequest)
+		return
+	}
+	if !h.requireAreaCouncilAccess(w, r, payload.AreaCouncilID) {
+		return
+	}
+
+	err := h.Collations.SaveAreaCouncilResult(electionID, payload.AreaCouncilID, func(c *models.AreaCouncilResult) error {
+		c.ArrivalTime = payload.ArrivalTime
+		c.CollationStartTime = payload.CollationStartTime
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save logistics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// SubmitAreaCouncilStaffing handles the submission of Area Council staffing and security data
+func (h *Handler) SubmitAreaCouncilStaffing(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID      string `json:"election_id"`
+		AreaCouncilID   string `json:"area_council_id"`
+		INECStaff       int    `json:"inec_staff"`
+		SecurityPresent bool   `json:"security_present"`
+		PartyAgents     int    `json:"party_agents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.areaCouncilExists(payload.AreaCouncilID) {
+		http.Error(w, "Area Council not found", http.StatusBadRequest)
+		return
+	}
+	if !h.requireAreaCouncilAccess(w, r, payload.AreaCouncilID) {
+		return
+	}
+
+	err := h.Collations.SaveAreaCouncilResult(electionID, payload.AreaCouncilID, func(c *models.AreaCouncilResult) error {
+		c.INECStaff = payload.INECStaff
+		c.SecurityPresent = payload.SecurityPresent
+		c.PartyAgents = payload.PartyAgents
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save staffing: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// SubmitAreaCouncilIntegrity handles the submission of Area Council integrity checks
+func (h *Handler) SubmitAreaCouncilIntegrity(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID          string `json:"election_id"`
+		AreaCouncilID       string `json:"area_council_id"`
+		EC8BSubmitted       bool   `json:"ec8b_submitted"`
+		EC8CCollated        bool   `json:"ec8c_collated"`
+		CSRVSDone           bool   `json:"csrvs_done"`
+		VotesAnnounced      bool   `json:"votes_announced"`
+		AgentsCountersigned bool   `json:"agents_countersigned"`
+		EC60EDisplayed      bool   `json:"ec60e_displayed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.areaCouncilExists(payload.AreaCouncilID) {
+		http.Error(w, "Area Council not found", http.StatusBadRequest)
+		return
+	}
+	if !h.requireAreaCouncilAccess(w, r, payload.AreaCouncilID) {
+		return
+	}
+
+	err := h.Collations.SaveAreaCouncilResult(electionID, payload.AreaCouncilID, func(c *models.AreaCouncilResult) error {
+		c.EC8BSubmitted = payload.EC8BSubmitted
+		c.EC8CCollated = payload.EC8CCollated
+		c.CSRVSDone = payload.CSRVSDone
+		c.VotesAnnounced = payload.VotesAnnounced
+		c.AgentsCountersigned = payload.AgentsCountersigned
+		c.EC60EDisplayed = payload.EC60EDisplayed
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save integrity checks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	events.Publish(events.Event{
+		Type:          events.IntegrityFlagChanged,
+		ElectionID:    electionID,
+		AreaCouncilID: payload.AreaCouncilID,
+		Data: map[string]interface{}{
+			"ec8b_submitted":       payload.EC8BSubmitted,
+			"ec8c_collated":        payload.EC8CCollated,
+			"csrvs_done":           payload.CSRVSDone,
+			"votes_announced":      payload.VotesAnnounced,
+			"agents_countersigned": payload.AgentsCountersigned,
+			"ec60e_displayed":      payload.EC60EDisplayed,
+		},
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// SubmitAreaCouncilResults handles the officially collated Area Council vote counts and party scores
+func (h *Handler) SubmitAreaCouncilResults(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID       string         `json:"election_id"`
+		AreaCouncilID    string         `json:"area_council_id"`
+		AccreditedVoters int            `json:"accredited_voters"`
+		ValidVotes       int            `json:"valid_votes"`
+		RejectedVotes    int            `json:"rejected_votes"`
+		VotesCast        int            `json:"votes_cast"`
+		PartyResults     map[string]int `json:"party_results"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireAreaCouncilAccess(w, r, payload.AreaCouncilID) {
+		return
+	}
+
+	allowed, err := h.Parties.AreaCouncilParties(electionID, payload.AreaCouncilID)
+	if err != nil {
+		http.Error(w, "Area Council not found", http.StatusBadRequest)
+		return
+	}
+
+	partyResults, err := validateResults(payload.ValidVotes, payload.RejectedVotes, payload.VotesCast, payload.PartyResults, allowed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = h.Collations.SaveAreaCouncilResult(electionID, payload.AreaCouncilID, func(c *models.AreaCouncilResult) error {
+		c.AccreditedVoters = payload.AccreditedVoters
+		c.ValidVotes = payload.ValidVotes
+		c.RejectedVotes = payload.RejectedVotes
+		c.VotesCast = payload.VotesCast
+		c.PartyResults = partyResults
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetAreaCouncilCollation returns the Area Council level collation submission
+func (h *Handler) GetAreaCouncilCollation(w http.ResponseWriter, r *http.Request) {
+	lgaID := chi.URLParam(r, "lgaID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	res, err := h.Collations.AreaCouncilResult(electionID, lgaID)
+	if err == store.ErrNotFound {
+		http.Error(w, "No collation submitted for this Area Council", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// GetAreaCouncilReconciliation compares the officially collated Area Council
+// figures with the sum of its ward figures, per vote count and per party.
+func (h *Handler) GetAreaCouncilReconciliation(w http.ResponseWriter, r *http.Request) {
+	lgaID := chi.URLParam(r, "lgaID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+	if !h.areaCouncilExists(lgaID) {
+		http.Error(w, "Area Council not found", http.StatusNotFound)
+		return
+	}
+
+	rec := models.Reconciliation{
+		ElectionID:    electionID,
+		AreaCouncilID: lgaID,
+		VoteCounts:    make(map[string]models.FigureComparison),
+		PartyResults:  make(map[string]models.FigureComparison),
+	}
+
+	collated, err := h.Collations.AreaCouncilResult(electionID, lgaID)
+	if err != nil && err != store.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rec.CollationReceived = err == nil
+
+	// Sum the visible results of the Area Council's wards
+	wards, err := h.Wards.Wards(lgaID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	subs, err := h.Results.WardResults(electionID, publishedOnly(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	inCouncil := make(map[string]bool, len(wards))
+	for _, ward := range wards {
+		inCouncil[ward.ID] = true
+	}
+	rec.Wards = len(wards)
+
+	var ward models.WardResult
+	wardParties := make(map[string]int)
+	for _, sub := range subs {
+		if !inCouncil[sub.WardID] {
+			continue
+		}
+		rec.WardsReported++
+		ward.AccreditedVoters += sub.AccreditedVoters
+		ward.ValidVotes += sub.ValidVotes
+		ward.RejectedVotes += sub.RejectedVotes
+		ward.VotesCast += sub.VotesCast
+		for party, score := range sub.PartyResults {
+			wardParties[party] += score
+		}
+	}
+
+	compare := func(collatedValue, wardValue int) models.FigureComparison {
+		c := models.FigureComparison{Collated: collatedValue, WardTotal: wardValue, Difference: collatedValue - wardValue}
+		// Without a collation there is nothing to disagree with yet
+		c.Flagged = rec.CollationReceived && c.Difference != 0
+		if c.Flagged {
+			rec.Discrepancies++
+		}
+		return c
+	}
+
+	rec.VoteCounts["accreditedVoters"] = compare(collated.AccreditedVoters, ward.AccreditedVoters)
+	rec.VoteCounts["validVotes"] = compare(collated.ValidVotes, ward.ValidVotes)
+	rec.VoteCounts["rejectedVotes"] = compare(collated.RejectedVotes, ward.RejectedVotes)
+	rec.VoteCounts["votesCast"] = compare(collated.VotesCast, ward.VotesCast)
+
+	for party, score := range collated.PartyResults {
+		rec.PartyResults[party] = compare(score, wardParties[party])
+	}
+	for party, score := range wardParties {
+		if _, seen := rec.PartyResults[party]; !seen {
+			rec.PartyResults[party] = compare(0, score)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+// auditLogFilter turns the audit log query parameters into a filter
+func auditLogFilter(q url.Values) (store.AuditFilter, error) {
+	var f store.AuditFilter
+	for param, id := range map[string]**int{"user_id": &f.UserID, "api_key_id": &f.APIKeyID} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s", param)
+		}
+		*id = &n
+	}
+	if v := q.Get("action"); v != "" {
+		f.Actions = strings.Split(strings.ToUpper(v), ",")
+		for i := range f.Actions {
+			f.Actions[i] = strings.TrimSpace(f.Actions[i])
+		}
+	}
+	f.Username = q.Get("username")
+	f.EntityType = q.Get("entity_type")
+	f.EntityID = q.Get("entity_id")
+	f.IP = q.Get("ip")
+	f.RequestID = q.Get("request_id")
+	f.Search = q.Get("q")
+	for param, bound := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s: expected RFC 3339 timestamp", param)
+		}
+		*bound = t
+	}
+	return f, nil
+}
+
+// GetAuditLogs searches the audit log, newest first. Supported query
+// parameters: user_id, username, api_key_id, action (comma-separated),
+// entity_type, entity_id, ip, request_id, from and to (RFC 3339), q (words
+// to search for in details), cursor and limit. Each page's next_cursor fetches
+// the one after it. With format=csv or format=ndjson every matching entry is
+// streamed instead, oldest first, for export.
+func (h *Handler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, err := auditLogFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch format := q.Get("format"); format {
+	case "", "json":
+	case "csv", "ndjson":
+		h.exportAuditLogs(w, r, format, f)
+		return
+	default:
+		http.Error(w, "format must be json, csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	f.Limit = defaultAuditPageSize
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		f.Limit = v
+	}
+	if f.Limit > maxAuditPageSize {
+		f.Limit = maxAuditPageSize
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		f.Before = cursor
+	}
+
+	logs := []models.AuditLog{}
+	err = h.Audit.EachAuditLog(f, func(l models.AuditLog) error {
+		logs = append(logs, l)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// IDs only grow, so the last ID seen marks where the next page starts
+	nextCursor := ""
+	if len(logs) == f.Limit {
+		nextCursor = strconv.Itoa(logs[len(logs)-1].ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Entries    []models.AuditLog `json:"entries"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+		Limit      int               `json:"limit"`
+	}{logs, nextCursor, f.Limit})
+}
+
+var auditCSVHeader = []string{
+	"id", "timestamp", "user_id", "username", "api_key_id", "api_key_name", "action", "details",
+	"entity_type", "entity_id", "changes", "ip_address", "request_id", "method", "path", "status",
+	"prev_hash", "hash",
+}
+
+// exportAuditLogs streams every matching entry, oldest first, writing each
+// row as it is read so large ranges don't build up in memory
+func (h *Handler) exportAuditLogs(w http.ResponseWriter, r *http.Request, format string, f store.AuditFilter) {
+	h.logAudit(currentUserID(r), "EXPORT_AUDIT_LOGS", fmt.Sprintf("Exported audit log as %s (%s)", format, r.URL.RawQuery), r)
+
+	filename := "audit-log-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	flusher, _ := w.(http.Flusher)
+
+	var csvOut *csv.Writer
+	var jsonOut *json.Encoder
+	started := false
+	start := func() {
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			csvOut = csv.NewWriter(w)
+			csvOut.Write(auditCSVHeader)
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			jsonOut = json.NewEncoder(w)
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		started = true
+	}
+
+	// The response starts with the first entry, so a failed query can still be answered with an error
+	n := 0
+	f.Oldest = true
+	err := h.Audit.EachAuditLog(f, func(l models.AuditLog) error {
+		if !started {
+			start()
+		}
+
+		if csvOut != nil {
+			csvOut.Write([]string{
+				strconv.Itoa(l.ID), l.Timestamp.UTC().Format(time.RFC3339Nano), optionalInt(l.UserID), l.Username,
+				optionalInt(l.APIKeyID), l.APIKeyName, l.Action, l.Details,
+				l.EntityType, l.EntityID, string(l.Changes), l.IPAddress, l.RequestID, l.Method, l.Path, optionalInt(&l.Status),
+				l.PrevHash, l.Hash,
+			})
+		} else if err := jsonOut.Encode(l); err != nil {
+			return err
+		}
+
+		n++
+		if n%500 == 0 {
+			if csvOut != nil {
+				csvOut.Flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil && !started {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !started {
+		start()
+	}
+	if csvOut != nil {
+		csvOut.Flush()
+	}
+}
+
+// optionalInt formats n for CSV, leaving nil and zero blank
+func optionalInt(n *int) string {
+	if n == nil || *n == 0 {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// VerifyAuditLogs walks the audit log's hash chain and checkpoints and
+// reports the first break, if any
+func (h *Handler) VerifyAuditLogs(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Audit.VerifyAuditLog(audit.Config.TrustedKeys)
+	if err != nil {
+		http.Error(w, "Failed to verify audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetAuditRetention reports how long entries stay in the database and the
+// archives of entries moved out after that
+func (h *Handler) GetAuditRetention(w http.ResponseWriter, r *http.Request) {
+	archives, err := h.Audit.AuditArchives()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		RetentionDays int             `json:"retention_days"` // 0: entries are kept forever
+		ArchiveDir    string          `json:"archive_dir,omitempty"`
+		Archives      []audit.Archive `json:"archives"`
+	}{int(audit.Config.Retention.Hours() / 24), audit.Config.ArchiveDir, archives})
+}
+
+// GetAuditCheckpoints publishes the signed checkpoints of the audit log, with
+// the public keys they verify against, so outsiders can keep copies
+func (h *Handler) GetAuditCheckpoints(w http.ResponseWriter, r *http.Request) {
+	checkpoints, err := h.Audit.AuditCheckpoints()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		KeyID       string             `json:"key_id,omitempty"`
+		PublicKey   string             `json:"public_key,omitempty"` // The key new checkpoints are signed with
+		TrustedKeys map[string]string  `json:"trusted_keys"`         // Every key checkpoints verify against, by key ID
+		Checkpoints []audit.Checkpoint `json:"checkpoints"`
+	}{TrustedKeys: make(map[string]string), Checkpoints: checkpoints}
+	if signer := audit.Config.Signer; signer != nil {
+		response.KeyID, response.PublicKey = signer.KeyID, signer.PublicKey()
+	}
+	for id, public := range audit.Config.TrustedKeys {
+		response.TrustedKeys[id] = base64.StdEncoding.EncodeToString(public)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(response)
+}
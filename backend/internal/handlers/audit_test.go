@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+type auditPage struct {
+	Entries    []models.AuditLog `json:"entries"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+func TestWritesAreAudited(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.do(http.MethodPost, "/submit/results", map[string]interface{}{"ward_id": "w1", "valid_votes": 1, "votes_cast": 2}, http.StatusUnprocessableEntity)
+	s.do(http.MethodPost, "/area-councils/bwari/parties", []string{"APC", "ADC"}, http.StatusOK)
+
+	var page auditPage
+	s.get("/audit-logs", &page)
+	if len(page.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(page.Entries))
+	}
+
+	parties, failed, submitted := page.Entries[0], page.Entries[1], page.Entries[2]
+	if parties.Action != "UPDATE_PARTIES" || parties.EntityType != "area_council" || parties.EntityID != "bwari" {
+		t.Errorf("parties entry = %+v, want UPDATE_PARTIES on area_council bwari", parties)
+	}
+	if failed.Status != http.StatusUnprocessableEntity || !strings.Contains(failed.Details, "must equal votes_cast") {
+		t.Errorf("failed entry = %+v, want status 422 with the error", failed)
+	}
+	if submitted.Action != "SUBMIT_RESULTS" || submitted.Username != "admin" || submitted.EntityID != "w1" || submitted.Status != http.StatusOK {
+		t.Errorf("submission entry = %+v, want SUBMIT_RESULTS on w1 by admin", submitted)
+	}
+	var changes map[string]models.FieldChange
+	if err := json.Unmarshal(submitted.Changes, &changes); err != nil {
+		t.Fatalf("submission changes %s: %v", submitted.Changes, err)
+	}
+	if _, ok := changes["votes_cast"]; !ok {
+		t.Errorf("submission changes %s don't include votes_cast", submitted.Changes)
+	}
+
+	// Entries are chained
+	if parties.PrevHash != failed.Hash || failed.PrevHash != submitted.Hash {
+		t.Error("entries are not chained by hash")
+	}
+}
+
+func TestGetAuditLogsFilters(t *testing.T) {
+	s := newTestServer(t)
+	for _, ward := range []string{"w1", "w2", "w3"} {
+		s.do(http.MethodPost, "/submit/logistics", map[string]interface{}{"ward_id": ward, "arrival_time": "09:00"}, http.StatusOK)
+	}
+	s.as(2, "editor")
+	s.do(http.MethodPost, "/submit/logistics", map[string]interface{}{"ward_id": "w1", "arrival_time": "10:00"}, http.StatusForbidden)
+	s.as(1, "admin")
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 4},
+		{"?username=EDITOR", 1},
+		{"?user_id=1", 3},
+		{"?action=SUBMIT_LOGISTICS", 3},
+		{"?entity_type=ward&entity_id=w3", 1},
+		{"?q=ward+w2", 1},
+		{"?q=logistics+unchanged", 0},
+	}
+	for _, tt := range tests {
+		var page auditPage
+		s.get("/audit-logs"+tt.query, &page)
+		if len(page.Entries) != tt.want {
+			t.Errorf("%q: got %d entries, want %d", tt.query, len(page.Entries), tt.want)
+		}
+	}
+
+	// Pages pick up where the last left off
+	var ids []int
+	path := "/audit-logs?limit=3"
+	for path != "" {
+		var page auditPage
+		s.get(path, &page)
+		for _, e := range page.Entries {
+			ids = append(ids, e.ID)
+		}
+		path = ""
+		if page.NextCursor != "" {
+			path = "/audit-logs?limit=3&cursor=" + page.NextCursor
+		}
+	}
+	if len(ids) != 4 || ids[0] != 4 || ids[3] != 1 {
+		t.Errorf("paged through entries %v, want 4 to 1", ids)
+	}
+
+	s.do(http.MethodGet, "/audit-logs?cursor=next", nil, http.StatusBadRequest)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// SectionCancelledPUs is the ward history section for cancelled polling unit reports
+const SectionCancelledPUs = "cancelled_pus"
+
+// validateCancelledPUs checks a cancellation report against the ward's size
+func validateCancelledPUs(cancelledPUs, cancelledVoters, totalPUs, registeredVoters int) error {
+	if cancelledPUs < 0 || cancelledVoters < 0 {
+		return fmt.Errorf("cancelled polling units and voters cannot be negative")
+	}
+	if cancelledPUs > totalPUs {
+		return fmt.Errorf("number_of_cancelled_pus (%d) exceeds the ward's %d polling units", cancelledPUs, totalPUs)
+	}
+	if cancelledVoters > registeredVoters {
+		return fmt.Errorf("registered_voters_in_cancelled_pus (%d) exceeds the ward's %d registered voters", cancelledVoters, registeredVoters)
+	}
+	if cancelledPUs == 0 && cancelledVoters > 0 {
+		return fmt.Errorf("registered_voters_in_cancelled_pus must be 0 when no polling units were cancelled")
+	}
+	return nil
+}
+
+// marginOfLead returns the leading party and its lead over the runner-up.
+// Ties are broken alphabetically so the result is stable.
+func marginOfLead(partyResults map[string]int) (string, int) {
+	parties := make([]string, 0, len(partyResults))
+	for p := range partyResults {
+		parties = append(parties, p)
+	}
+	sort.Slice(parties, func(i, j int) bool {
+		if partyResults[parties[i]] != partyResults[parties[j]] {
+			return partyResults[parties[i]] > partyResults[parties[j]]
+		}
+		return parties[i] < parties[j]
+	})
+
+	switch len(parties) {
+	case 0:
+		return "", 0
+	case 1:
+		return parties[0], partyResults[parties[0]]
+	}
+	return parties[0], partyResults[parties[0]] - partyResults[parties[1]]
+}
+
+// outcomeAtRisk reports whether the voters lost to cancellations could
+// overturn the lead, which calls for a supplementary election.
+func outcomeAtRisk(margin, lostVoters int) bool {
+	return lostVoters > 0 && lostVoters >= margin
+}
+
+// areaCouncilsAtRisk lists the Area Councils, in ID order, whose voters lost to
+// cancelled PUs could overturn the leading party's margin
+func areaCouncilsAtRisk(summaries []models.LGASummary) []string {
+	atRisk := []string{}
+	for _, summary := range summaries {
+		if summary.OutcomeAtRisk {
+			atRisk = append(atRisk, summary.ID)
+		}
+	}
+	sort.Strings(atRisk)
+	return atRisk
+}
+
+// SubmitCancelledPUs records how many of a ward's polling units were cancelled
+// and how many registered voters they held
+func (h *Handler) SubmitCancelledPUs(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID      string `json:"election_id"`
+		WardID          string `json:"ward_id"`
+		CancelledPUs    int    `json:"number_of_cancelled_pus"`
+		CancelledVoters int    `json:"registered_voters_in_cancelled_pus"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
+
+	ward, err := h.Wards.Ward(payload.WardID)
+	if err != nil {
+		http.Error(w, "Ward not found", http.StatusBadRequest)
+		return
+	}
+	if err := validateCancelledPUs(payload.CancelledPUs, payload.CancelledVoters, ward.TotalPollingUnits, ward.RegisteredVoters); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	version, err := h.Results.SaveWardResult(electionID, payload.WardID, SectionCancelledPUs, currentUserID(r), nil, func(s *models.WardResult) error {
+		s.CancelledPUs = payload.CancelledPUs
+		s.CancelledPUVoters = payload.CancelledVoters
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save cancelled polling units: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.wardSubmitted(r, SectionCancelledPUs, electionID, payload.WardID, version)
+	w.WriteHeader(http.StatusOK)
+}
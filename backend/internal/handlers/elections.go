@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// Election statuses
+const (
+	ElectionUpcoming  = "upcoming"
+	ElectionActive    = "active"
+	ElectionConcluded = "concluded"
+)
+
+var (
+	electionTypes    = map[string]bool{"general": true, "by_election": true, "re_run": true}
+	electionStatuses = map[string]bool{ElectionUpcoming: true, ElectionActive: true, ElectionConcluded: true}
+)
+
+// electionForRequest resolves which election a request is scoped to: the
+// explicit ID if given, else the election_id query parameter, else the most
+// recent active election. It writes the error response and returns false on failure.
+func (h *Handler) electionForRequest(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	election, ok := h.resolveElection(w, r, requested)
+	return election.ID, ok
+}
+
+// electionForSubmission is electionForRequest for writes, which are refused
+// once an election has concluded.
+func (h *Handler) electionForSubmission(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	election, ok := h.resolveElection(w, r, requested)
+	if !ok {
+		return "", false
+	}
+	if election.Status == ElectionConcluded {
+		http.Error(w, fmt.Sprintf("Election %s has concluded and no longer accepts submissions", election.ID), http.StatusConflict)
+		return "", false
+	}
+	return election.ID, true
+}
+
+func (h *Handler) resolveElection(w http.ResponseWriter, r *http.Request, requested string) (models.Election, bool) {
+	if requested == "" {
+		requested = r.URL.Query().Get("election_id")
+	}
+
+	var e models.Election
+	var err error
+	if requested != "" {
+		e, err = h.Elections.Election(requested)
+	} else {
+		e, err = h.Elections.ActiveElection()
+	}
+
+	if err == store.ErrNotFound {
+		if requested != "" {
+			http.Error(w, "Election not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "No active election; pass election_id", http.StatusBadRequest)
+		}
+		return e, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return e, false
+	}
+	return e, true
+}
+
+// validateElection normalises and checks an election before it is written
+func validateElection(e *models.Election) error {
+	e.Name = strings.TrimSpace(e.Name)
+	e.Type = strings.ToLower(strings.TrimSpace(e.Type))
+	e.Status = strings.ToLower(strings.TrimSpace(e.Status))
+	if e.Type == "" {
+		e.Type = "general"
+	}
+	if e.Status == "" {
+		e.Status = ElectionUpcoming
+	}
+
+	if e.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !electionTypes[e.Type] {
+		return fmt.Errorf("type must be one of general, by_election, re_run")
+	}
+	if !electionStatuses[e.Status] {
+		return fmt.Errorf("status must be one of upcoming, active, concluded")
+	}
+	if e.Date != "" {
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return fmt.Errorf("date must be YYYY-MM-DD")
+		}
+	}
+	return nil
+}
+
+// GetElections lists all elections, most recent first
+func (h *Handler) GetElections(w http.ResponseWriter, r *http.Request) {
+	elections, err := h.Elections.Elections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(elections)
+}
+
+// GetElection returns a single election
+func (h *Handler) GetElection(w http.ResponseWriter, r *http.Request) {
+	e, err := h.Elections.Election(chi.URLParam(r, "electionID"))
+	if err == store.ErrNotFound {
+		http.Error(w, "Election not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// CreateElection registers a new election and seeds its party configuration
+func (h *Handler) CreateElection(w http.ResponseWriter, r *http.Request) {
+	var e models.Election
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e.ID = strings.ToLower(strings.TrimSpace(e.ID))
+	if e.ID == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateElection(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Every Area Council starts with the default party list for the new election
+	created, err := h.Elections.CreateElection(e)
+	if err == store.ErrConflict {
+		http.Error(w, "An election with this ID already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create election: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "CREATE_ELECTION", fmt.Sprintf("Created election %s (%s)", e.ID, e.Name), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// UpdateElection changes an election's name, date, type or status
+func (h *Handler) UpdateElection(w http.ResponseWriter, r *http.Request) {
+	electionID := chi.URLParam(r, "electionID")
+
+	var e models.Election
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateElection(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e.ID = electionID
+
+	updated, err := h.Elections.UpdateElection(e)
+	if err == store.ErrNotFound {
+		http.Error(w, "Election not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update election: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "UPDATE_ELECTION", fmt.Sprintf("Updated election %s (status %s)", electionID, e.Status), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// CompareWardAcrossElections returns a ward's figures in each election, oldest
+// first. Pass elections=a,b to restrict the comparison to specific elections.
+func (h *Handler) CompareWardAcrossElections(w http.ResponseWriter, r *http.Request) {
+	wardID := chi.URLParam(r, "wardID")
+
+	ward, err := h.Wards.Ward(wardID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Ward not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	elections, err := h.Elections.Elections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var only map[string]bool
+	if ids := r.URL.Query().Get("elections"); ids != "" {
+		only = make(map[string]bool)
+		for _, id := range strings.Split(ids, ",") {
+			only[id] = true
+		}
+	}
+
+	// Elections come most recent first; undated ones stay last, in the order they were created
+	oldestFirst := make([]models.Election, 0, len(elections))
+	for i := len(elections) - 1; i >= 0; i-- {
+		if only == nil || only[elections[i].ID] {
+			oldestFirst = append(oldestFirst, elections[i])
+		}
+	}
+	sort.SliceStable(oldestFirst, func(i, j int) bool {
+		a, b := oldestFirst[i].Date, oldestFirst[j].Date
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		return a < b
+	})
+
+	comparison := []models.WardElectionFigures{}
+	for _, e := range oldestFirst {
+		f := models.WardElectionFigures{ElectionID: e.ID, ElectionName: e.Name, Date: e.Date, PartyResults: make(map[string]int)}
+
+		sub, err := h.Results.WardResult(e.ID, wardID, publishedOnly(r))
+		if err != nil && err != store.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			f.Reported = true
+			f.AccreditedVoters = sub.AccreditedVoters
+			f.VotesCast = sub.VotesCast
+			f.ValidVotes = sub.ValidVotes
+			f.RejectedVotes = sub.RejectedVotes
+			for party, score := range sub.PartyResults {
+				f.PartyResults[party] = score
+			}
+		}
+		if ward.RegisteredVoters > 0 {
+			f.TurnoutPercent = float64(f.VotesCast) / float64(ward.RegisteredVoters) * 100
+		}
+
+		incidents, err := h.Incidents.IncidentCounts(e.ID, wardID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, c := range incidents[wardID] {
+			f.IncidentCount += c.Count
+		}
+		comparison = append(comparison, f)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
@@ -3,155 +3,49 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
+	"net"
 	"net/http"
 	"strconv" // Added
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
 	"github.com/yiaga/abuja-watch/backend/internal/auth" // Added
-	"github.com/yiaga/abuja-watch/backend/internal/db"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
 	"github.com/yiaga/abuja-watch/backend/internal/middleware" // Added
 	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
 )
 
+// Handler serves the API from its stores
+type Handler struct {
+	store.Stores
+}
+
+// New creates a handler on the given stores
+func New(stores store.Stores) *Handler {
+	return &Handler{Stores: stores}
+}
+
 // GetAreaCouncils returns the aggregated summary for all Area Councils
-func GetAreaCouncils(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.DB.Query("SELECT id, name, state FROM area_councils")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (h *Handler) GetAreaCouncils(w http.ResponseWriter, r *http.Request) {
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	var summaries []models.LGASummary
-	for rows.Next() {
-		var ac models.AreaCouncil
-		if err := rows.Scan(&ac.ID, &ac.Name, &ac.State); err != nil {
-			continue
-		}
-
-		summary := models.LGASummary{
-			ID:                ac.ID,
-			Name:              ac.Name,
-			State:             ac.State,
-			PartyResults:      make(map[string]int),
-			IncidentBreakdown: make(map[string]int),
-			RiskLevel:         "low",
-			LostVoters:        0,
-		}
-
-		// Fetch Wards to aggregate data
-		wRows, err := db.DB.Query("SELECT id, registered_voters, total_polling_units FROM wards WHERE area_council_id = $1", ac.ID)
-		if err == nil {
-			var totalCompliance int
-			var securityCount int
-
-			for wRows.Next() {
-				var wID string
-				var regVoters, pus int
-				wRows.Scan(&wID, &regVoters, &pus)
-
-				summary.Wards++
-				summary.RegisteredVoters += regVoters
-				summary.PollingUnits += pus
-
-				// Fetch Ward Result
-				var res models.WardResult
-				err := db.DB.QueryRow(`
-					SELECT 
-						accredited_voters, valid_votes, rejected_votes, votes_cast, 
-						arrival_time, collation_start_time, security_present,
-						ec8b_submitted, ec8c_collated, csrvs_done
-					FROM ward_results WHERE ward_id = $1`, wID).Scan(
-					&res.AccreditedVoters, &res.ValidVotes, &res.RejectedVotes, &res.VotesCast,
-					&res.ArrivalTime, &res.CollationStartTime, &res.SecurityPresent,
-					&res.EC8BSubmitted, &res.EC8CCollated, &res.CSRVSDone,
-				)
-
-				if err == nil {
-					summary.WardsReported++
-					summary.AccreditedVoters += res.AccreditedVoters
-					summary.VotesCast += res.VotesCast
-					summary.ValidVotes += res.ValidVotes
-					summary.RejectedVotes += res.RejectedVotes
-
-					if res.SecurityPresent {
-						securityCount++
-					}
-
-					// Compliance Calculation (Mock logic based on boolean flags)
-					wardCompliance := 0
-					if res.EC8BSubmitted {
-						wardCompliance += 30
-					}
-					if res.EC8CCollated {
-						wardCompliance += 30
-					}
-					if res.CSRVSDone {
-						wardCompliance += 40
-					}
-					totalCompliance += wardCompliance
-
-					// Late Start / Arrival
-					if res.ArrivalTime == "after_6pm" {
-						// summary.LateArrivals++ // Field not in struct yet, skipping
-					}
-					if res.CollationStartTime == "not_started" || res.CollationStartTime == "9_12am" {
-						summary.LateStartCount++
-					}
-				}
-
-				// Fetch Incident Count and Breakdown for Ward
-				iRows, err := db.DB.Query("SELECT type, COUNT(*) FROM incidents WHERE ward_id = $1 GROUP BY type", wID)
-				if err == nil {
-					for iRows.Next() {
-						var iType string
-						var count int
-						iRows.Scan(&iType, &count)
-						summary.IncidentCount += count
-						summary.IncidentBreakdown[iType] += count
-					}
-					iRows.Close()
-				}
-
-				// Fetch Party Results for Ward
-				pRows, err := db.DB.Query("SELECT party_name, score FROM party_results WHERE ward_id = $1", wID)
-				if err == nil {
-					for pRows.Next() {
-						var pName string
-						var score int
-						pRows.Scan(&pName, &score)
-						summary.PartyResults[pName] += score
-					}
-					pRows.Close()
-				}
-			}
-			wRows.Close()
-
-			if summary.Wards > 0 {
-				summary.SecurityPresent = int(float64(securityCount) / float64(summary.Wards) * 100)
-				// Average compliance of reported wards
-				if summary.WardsReported > 0 {
-					summary.ComplianceScore = totalCompliance / summary.WardsReported
-				}
-			}
-		}
 
-		if summary.RegisteredVoters > 0 {
-			summary.TurnoutPercent = float64(summary.VotesCast) / float64(summary.RegisteredVoters) * 100
-		}
-
-		// Risk Level Logic
-		if summary.IncidentCount > 10 {
-			summary.RiskLevel = "high"
-		} else if summary.IncidentCount > 5 {
-			summary.RiskLevel = "medium"
-		}
-
-		summaries = append(summaries, summary)
+	in, err := h.loadSummaryInput(electionID, publishedOnly(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(summaries)
+	json.NewEncoder(w).Encode(buildAreaCouncilSummaries(in))
 }
 
 // ==========================================
@@ -164,43 +58,146 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	auth.TokenPair
+	User models.User `json:"user"`
 }
 
-func Login(w http.ResponseWriter, r *http.Request) {
+// TwoFactorChallenge answers a correct password for a user with two-factor
+// authentication; the token and a code go to /login/2fa
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	TwoFactorToken    string `json:"two_factor_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// Login signs a user in. Failed attempts are counted per username and per
+// address: repeated failures have to wait progressively longer between tries
+// and eventually lock the username or address out for a while.
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	ip := clientIP(r)
 
-	var user models.User
-	err := db.DB.QueryRow("SELECT id, username, password_hash, role FROM users WHERE username = $1", req.Username).Scan(&user.ID, &user.Username, &user.Password, &user.Role)
+	block, err := auth.CheckLogin(h.Throttles, req.Username, ip)
+	if err != nil {
+		http.Error(w, "Login is temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if block != nil {
+		tooManyLoginAttempts(w, block)
+		return
+	}
+
+	user, err := h.Users.UserByUsername(req.Username)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		// Take as long as a wrong password would, so unknown usernames can't be told apart
+		auth.CheckPasswordAgainstNothing(req.Password)
+		h.loginFailed(w, r, 0, req.Username, ip, "Invalid credentials")
 		return
 	}
 
 	if !auth.CheckPasswordHash(req.Password, user.Password) {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		h.loginFailed(w, r, user.ID, req.Username, ip, "Invalid credentials")
 		return
 	}
 
-	token, err := auth.GenerateJWT(fmt.Sprintf("%d", user.ID), user.Role)
+	// Only say the account is deactivated to someone who knows its password
+	if !user.Active {
+		h.logAudit(user.ID, "LOGIN_DEACTIVATED", "Login refused: account is deactivated", r)
+		http.Error(w, "Account is deactivated", http.StatusForbidden)
+		return
+	}
+
+	// With two-factor authentication on, the password only earns a token to trade for a session along with a code
+	if user.TwoFactorEnabled {
+		token, err := auth.GenerateTwoFactorToken(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(TwoFactorChallenge{
+			TwoFactorRequired: true,
+			TwoFactorToken:    token,
+			ExpiresIn:         int(auth.TwoFactorTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	h.completeLogin(w, r, user, ip)
+}
+
+// completeLogin starts a session for a user who has proven who they are
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, user models.User, ip string) {
+	limits := auth.LimitsFor(user.Role, user.MustChangePassword, user.TwoFactorEnabled)
+	tokens, err := auth.StartSession(h.Sessions, user.ID, user.Role, limits, ip, r.UserAgent())
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
+	auth.RecordLoginSuccess(h.Throttles, user.Username)
 
 	// Audit Login
-	logAudit(user.ID, "LOGIN", "User logged in", r)
+	h.logAudit(user.ID, "LOGIN", "User logged in", r)
 
 	user.Password = "" // Don't send hash back
-	json.NewEncoder(w).Encode(LoginResponse{Token: token, User: user})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(LoginResponse{TokenPair: tokens, User: user})
+}
+
+// loginFailed audits and counts a failed login, audits any lockout it causes
+// and answers 401 with the message. userID is 0 for unknown usernames.
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, userID int, username, ip, message string) {
+	h.logAudit(userID, "LOGIN_FAILED", fmt.Sprintf("Failed login for username %q", username), r)
+	if h.countLoginFailure(r, userID, username, ip) {
+		tooManyLoginAttempts(w, &auth.LoginBlock{RetryAfter: auth.LoginLockout, Locked: true})
+		return
+	}
+	http.Error(w, message, http.StatusUnauthorized)
+}
+
+// countLoginFailure counts a failed attempt against the username and the
+// address and audits any lockout it causes. It reports whether either of them
+// is now locked out.
+func (h *Handler) countLoginFailure(r *http.Request, userID int, username, ip string) bool {
+	userLocked, ipLocked, err := auth.RecordLoginFailure(h.Throttles, username, ip)
+	if err != nil {
+		return false
+	}
+	if userLocked {
+		h.logAudit(userID, "LOGIN_LOCKOUT", fmt.Sprintf("Username %q locked out for %s after %d failed logins", username, auth.LoginLockout, auth.MaxLoginFailures), r)
+	}
+	if ipLocked {
+		h.logAudit(userID, "LOGIN_LOCKOUT", fmt.Sprintf("Address %s locked out for %s after %d failed logins", ip, auth.LoginLockout, auth.MaxLoginFailuresPerIP), r)
+	}
+	return userLocked || ipLocked
+}
+
+func tooManyLoginAttempts(w http.ResponseWriter, block *auth.LoginBlock) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(block.RetryAfter.Seconds()))))
+	if block.Locked {
+		http.Error(w, "Too many failed logins; try again later", http.StatusTooManyRequests)
+		return
+	}
+	http.Error(w, "Too many failed logins; wait before trying again", http.StatusTooManyRequests)
 }
 
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+// GetJWKS publishes the public half of every asymmetric signing key so partner
+// services can verify our tokens
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(struct {
+		Keys []auth.JWK `json:"keys"`
+	}{auth.Config.JWKS()})
+}
+
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -209,7 +206,24 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	// Enforce RBAC: Only admin can create users
 	// This should be double-checked here even if middleware handles it, as extra safety
-	// But middleware.RequireRole("admin") will handle it.
+	// But middleware.RequirePermission(auth.PermManageUsers) will handle it.
+
+	user.Username = strings.TrimSpace(user.Username)
+	if user.Username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+	if user.Role == "" {
+		user.Role = auth.RoleEditor
+	}
+	if !auth.ValidRole(user.Role) {
+		http.Error(w, "role must be one of "+strings.Join(auth.Roles, ", "), http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(user.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	hash, err := auth.HashPassword(user.Password)
 	if err != nil {
@@ -217,216 +231,174 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = db.DB.Exec("INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3)", user.Username, hash, user.Role)
+	user, err = h.Users.CreateUser(user.Username, hash, user.Role)
+	if err == store.ErrConflict {
+		http.Error(w, "Username already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 
 	// Audit
-	userID, _ := strconv.Atoi(r.Context().Value(middleware.UserKey).(string))
-	logAudit(userID, "CREATE_USER", fmt.Sprintf("Created user %s", user.Username), r)
+	h.logAudit(currentUserID(r), "CREATE_USER", fmt.Sprintf("Created user %s with role %s", user.Username, user.Role), r)
 
+	user.Password = ""
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
 }
 
-func GetUsers(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.DB.Query("SELECT id, username, role, created_at FROM users ORDER BY created_at DESC")
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Users.Users()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt); err != nil {
-			continue
-		}
-		users = append(users, u)
-	}
 
 	json.NewEncoder(w).Encode(users)
 }
 
-func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.DB.Query(`
-		SELECT a.id, a.user_id, u.username, a.action, a.details, a.ip_address, a.timestamp 
-		FROM audit_logs a 
-		JOIN users u ON a.user_id = u.id 
-		ORDER BY a.timestamp DESC LIMIT 100`)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// currentUserID returns the authenticated user's ID, or 0 on public routes
+func currentUserID(r *http.Request) int {
+	idStr, _ := r.Context().Value(middleware.UserKey).(string)
+	userID, _ := strconv.Atoi(idStr)
+	return userID
+}
+
+// Helper for audit logging. userID 0 records an anonymous action, such as a
+// lockout of an unknown username; actions taken with an API key are
+// attributed to the key. Within a write request the action is handed to the
+// audit middleware, which logs it with the request's outcome; elsewhere it is
+// appended to the hash chain straight away.
+func (h *Handler) logAudit(userID int, action, details string, r *http.Request) {
+	var user *int
+	if userID != 0 {
+		user = &userID
+	}
+	if rec := audit.FromContext(r.Context()); rec != nil {
+		rec.Events = append(rec.Events, audit.Event{UserID: user, Action: action, Details: details})
 		return
 	}
-	defer rows.Close()
 
-	var logs []models.AuditLog
-	for rows.Next() {
-		var l models.AuditLog
-		if err := rows.Scan(&l.ID, &l.UserID, &l.Username, &l.Action, &l.Details, &l.IPAddress, &l.Timestamp); err != nil {
-			continue
-		}
-		logs = append(logs, l)
+	entry := audit.Entry{UserID: user, Action: action, Details: details, IPAddress: clientIP(r), RequestID: chimiddleware.GetReqID(r.Context())}
+	if key := requestAPIKey(r); key != nil {
+		entry.APIKeyID = &key.ID
+	}
+	if _, err := h.Audit.Append(entry); err != nil {
+		log.Printf("audit log: %s: %v", action, err)
 	}
+}
 
-	json.NewEncoder(w).Encode(logs)
+// auditChanges names the entity a request changed and its values before and
+// after, for the request's audit entry
+func auditChanges(r *http.Request, entityType, entityID string, changes map[string]models.FieldChange) {
+	if rec := audit.FromContext(r.Context()); rec != nil {
+		rec.EntityType, rec.EntityID, rec.Changes = entityType, entityID, changes
+	}
 }
 
-// Helper for audit logging
-func logAudit(userID int, action, details string, r *http.Request) {
-	ip := r.RemoteAddr
-	db.DB.Exec("INSERT INTO audit_logs (user_id, action, details, ip_address) VALUES ($1, $2, $3, $4)", userID, action, details, ip)
+// clientIP is the caller's address without the port. The RealIP middleware
+// has already swapped in X-Forwarded-For or X-Real-IP if a trusted proxy sent them.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
 }
 
 // GetWards returns the list of wards for a given Area Council with full details
-func GetWards(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetWards(w http.ResponseWriter, r *http.Request) {
 	lgaID := chi.URLParam(r, "lgaID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
 
-	// 1. Fetch Wards
-	rows, err := db.DB.Query("SELECT id, area_council_id, name, total_polling_units, registered_voters FROM wards WHERE area_council_id = $1", lgaID)
+	wards, err := h.Wards.Wards(lgaID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	subs, err := h.Results.WardResults(electionID, publishedOnly(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	results := make(map[string]store.WardSubmission, len(subs))
+	for _, sub := range subs {
+		results[sub.WardID] = sub
+	}
+	incidents, err := h.Incidents.IncidentCounts(electionID, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	weights, err := h.Elections.RiskWeights(electionID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var wards []models.WardDetail
-	for rows.Next() {
-		var ward models.Ward
-		if err := rows.Scan(&ward.ID, &ward.AreaCouncilID, &ward.Name, &ward.TotalPollingUnits, &ward.RegisteredVoters); err != nil {
-			continue // Skip error rows
-		}
-
-		// 2. For each ward, fetch details (N+1 but acceptable for small N=10)
-		// Fetch Result
-		var result models.WardResult
-		result.WardID = ward.ID
-		err = db.DB.QueryRow(`
-			SELECT 
-				arrival_time, collation_start_time, inec_staff, security_present, party_agents,
-				ec8b_submitted, ec8c_collated, csrvs_done, votes_announced, agents_countersigned, ec60e_displayed,
-				accredited_voters, valid_votes, rejected_votes, votes_cast
-			FROM ward_results WHERE ward_id = $1`, ward.ID).Scan(
-			&result.ArrivalTime, &result.CollationStartTime, &result.INECStaff, &result.SecurityPresent, &result.PartyAgents,
-			&result.EC8BSubmitted, &result.EC8CCollated, &result.CSRVSDone, &result.VotesAnnounced, &result.AgentsCountersigned, &result.EC60EDisplayed,
-			&result.AccreditedVoters, &result.ValidVotes, &result.RejectedVotes, &result.VotesCast,
-		)
-		if err != nil {
-		} // Ignore, keep defaults
-
-		// Fetch Party Results
-		partyScores := make(map[string]int)
-		pRows, err := db.DB.Query("SELECT party_name, score FROM party_results WHERE ward_id = $1", ward.ID)
-		if err == nil {
-			for pRows.Next() {
-				var pName string
-				var score int
-				pRows.Scan(&pName, &score)
-				partyScores[pName] = score
-			}
-			pRows.Close()
-		}
 
-		// Incident Count
-		var incidentCount int
-		db.DB.QueryRow("SELECT COUNT(*) FROM incidents WHERE ward_id = $1", ward.ID).Scan(&incidentCount)
-
-		// Construct Detail
-		detail := models.WardDetail{
-			ID:               ward.ID,
-			LgaID:            ward.AreaCouncilID,
-			Name:             ward.Name,
-			PollingUnits:     ward.TotalPollingUnits,
-			RegisteredVoters: ward.RegisteredVoters,
-			AccreditedVoters: result.AccreditedVoters,
-			VotesCast:        result.VotesCast,
-			ValidVotes:       result.ValidVotes,
-			RejectedVotes:    result.RejectedVotes,
-			TurnoutPercent:   0,
-			ComplianceScore:  75,
-			IncidentCount:    incidentCount,
-			DeniedAccess:     false,
-			LateStart:        result.CollationStartTime == "not_started" || result.CollationStartTime == "9_12am",
-			CancelledPUs:     0,
-			SecurityPresent:  result.SecurityPresent,
-			ObserverPresent:  true,
-			RiskLevel:        "low",
-			ArrivalCategory:  result.ArrivalTime,
-			StartCategory:    result.CollationStartTime,
-			PartyResults:     partyScores,
-			Integrity: models.WardIntegrity{
-				EC8BSubmitted:       result.EC8BSubmitted,
-				EC8CCollated:        result.EC8CCollated,
-				CSRVSDone:           result.CSRVSDone,
-				VotesAnnounced:      result.VotesAnnounced,
-				AgentsCountersigned: result.AgentsCountersigned,
-				EC60EDisplayed:      result.EC60EDisplayed,
-			},
-		}
-		if ward.RegisteredVoters > 0 {
-			detail.TurnoutPercent = float64(result.VotesCast) / float64(ward.RegisteredVoters) * 100
-		}
-		wards = append(wards, detail)
+	details := []models.WardDetail{}
+	for _, ward := range wards {
+		sub, reported := results[ward.ID]
+		details = append(details, wardDetail(ward, sub, reported, incidents[ward.ID], weights))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(wards)
+	json.NewEncoder(w).Encode(details)
 }
 
 // GetWardDetails returns specific details for a ward including results
-func GetWardDetails(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetWardDetails(w http.ResponseWriter, r *http.Request) {
 	wardID := chi.URLParam(r, "wardID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
 
-	// 1. Fetch Ward Basic Info
-	var ward models.Ward
-	err := db.DB.QueryRow("SELECT id, area_council_id, name, total_polling_units, registered_voters FROM wards WHERE id = $1", wardID).Scan(
-		&ward.ID, &ward.AreaCouncilID, &ward.Name, &ward.TotalPollingUnits, &ward.RegisteredVoters,
-	)
+	ward, err := h.Wards.Ward(wardID)
 	if err != nil {
 		http.Error(w, "Ward not found", http.StatusNotFound)
 		return
 	}
 
-	// 2. Fetch Submitted Results (if any)
-	var result models.WardResult
-	// Initialize with defaults in case no result exists yet
-	result.WardID = wardID
-
-	err = db.DB.QueryRow(`
-		SELECT 
-			arrival_time, collation_start_time, inec_staff, security_present, party_agents,
-			ec8b_submitted, ec8c_collated, csrvs_done, votes_announced, agents_countersigned, ec60e_displayed,
-			accredited_voters, valid_votes, rejected_votes, votes_cast
-		FROM ward_results WHERE ward_id = $1`, wardID).Scan(
-		&result.ArrivalTime, &result.CollationStartTime, &result.INECStaff, &result.SecurityPresent, &result.PartyAgents,
-		&result.EC8BSubmitted, &result.EC8CCollated, &result.CSRVSDone, &result.VotesAnnounced, &result.AgentsCountersigned, &result.EC60EDisplayed,
-		&result.AccreditedVoters, &result.ValidVotes, &result.RejectedVotes, &result.VotesCast,
-	)
-	if err != nil && err != http.ErrNoCookie { // Ignore no rows error, just use defaults
-		// actually sql.ErrNoRows.
-	}
-
-	// 3. Fetch Party Results
-	partyScores := make(map[string]int)
-	rows, err := db.DB.Query("SELECT party_name, score FROM party_results WHERE ward_id = $1", wardID)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var pName string
-			var score int
-			rows.Scan(&pName, &score)
-			partyScores[pName] = score
-		}
+	// Anonymous callers only see approved submissions; no visible one means the ward hasn't reported
+	sub, err := h.Results.WardResult(electionID, wardID, publishedOnly(r))
+	if err != nil && err != store.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reported := err == nil
+
+	incidents, err := h.Incidents.IncidentCounts(electionID, wardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	weights, err := h.Elections.RiskWeights(electionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// 4. Incident Count
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wardDetail(ward, sub, reported, incidents[wardID], weights))
+}
+
+// wardDetail combines a ward with its visible submission, if it has reported,
+// and scores it for risk
+func wardDetail(ward models.Ward, sub store.WardSubmission, reported bool, incidents []risk.IncidentCount, weights risk.Weights) models.WardDetail {
+	result := sub.WardResult
 	var incidentCount int
-	db.DB.QueryRow("SELECT COUNT(*) FROM incidents WHERE ward_id = $1", wardID).Scan(&incidentCount)
+	for _, inc := range incidents {
+		incidentCount += inc.Count
+	}
+	assessment := weights.Assess(wardRiskInput(result, reported, incidents))
 
-	// Construct Response
-	response := models.WardDetail{
+	detail := models.WardDetail{
 		ID:               ward.ID,
 		LgaID:            ward.AreaCouncilID,
 		Name:             ward.Name,
@@ -436,39 +408,35 @@ func GetWardDetails(w http.ResponseWriter, r *http.Request) {
 		VotesCast:        result.VotesCast,
 		ValidVotes:       result.ValidVotes,
 		RejectedVotes:    result.RejectedVotes,
-		TurnoutPercent:   0,  // Calculate below
-		ComplianceScore:  75, // Mock calculation
+		ComplianceScore:  assessment.ComplianceScore,
 		IncidentCount:    incidentCount,
-		DeniedAccess:     false, // Need field in DB?
-		LateStart:        result.CollationStartTime == "not_started" || result.CollationStartTime == "9_12am",
-		CancelledPUs:     0,
+		DeniedAccess:     accessDenied(result.ObserverPermitted),
+		DenialReason:     result.DenialReason,
+		LateStart:        isLateStart(result.CollationStartTime),
+		CancelledPUs:     result.CancelledPUs,
+		LostVoters:       result.CancelledPUVoters,
 		SecurityPresent:  result.SecurityPresent,
-		ObserverPresent:  true,  // Mock
-		RiskLevel:        "low", // Mock calc
+		ObserverPresent:  accessPermitted(result.ObserverPermitted),
+		RiskLevel:        assessment.Level,
 		ArrivalCategory:  result.ArrivalTime,
 		StartCategory:    result.CollationStartTime,
-		PartyResults:     partyScores,
-		Integrity: models.WardIntegrity{
-			EC8BSubmitted:       result.EC8BSubmitted,
-			EC8CCollated:        result.EC8CCollated,
-			CSRVSDone:           result.CSRVSDone,
-			VotesAnnounced:      result.VotesAnnounced,
-			AgentsCountersigned: result.AgentsCountersigned,
-			EC60EDisplayed:      result.EC60EDisplayed,
-		},
+		PartyResults:     result.PartyResults,
+		Integrity:        wardIntegrity(result),
+		ReviewStatus:     sub.ReviewStatus,
+	}
+	if detail.PartyResults == nil {
+		detail.PartyResults = make(map[string]int)
 	}
-
 	if ward.RegisteredVoters > 0 {
-		response.TurnoutPercent = float64(result.VotesCast) / float64(ward.RegisteredVoters) * 100
+		detail.TurnoutPercent = float64(result.VotesCast) / float64(ward.RegisteredVoters) * 100
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return detail
 }
 
 // SubmitLogistics handles the submission of logistics data
-func SubmitLogistics(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SubmitLogistics(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
+		ElectionID         string `json:"election_id"`
 		WardID             string `json:"ward_id"`
 		ArrivalTime        string `json:"arrival_time"`
 		CollationStartTime string `json:"collation_start_time"`
@@ -477,26 +445,31 @@ func SubmitLogistics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
 
-	query := `
-		INSERT INTO ward_results (ward_id, arrival_time, collation_start_time, updated_at)
-		VALUES ($1, $2, $3, NOW())
-		ON CONFLICT (ward_id) DO UPDATE SET
-			arrival_time = EXCLUDED.arrival_time,
-			collation_start_time = EXCLUDED.collation_start_time,
-			updated_at = NOW()
-	`
-	_, err := db.DB.Exec(query, payload.WardID, payload.ArrivalTime, payload.CollationStartTime)
+	version, err := h.Results.SaveWardResult(electionID, payload.WardID, SectionLogistics, currentUserID(r), nil, func(s *models.WardResult) error {
+		s.ArrivalTime = payload.ArrivalTime
+		s.CollationStartTime = payload.CollationStartTime
+		return nil
+	})
 	if err != nil {
 		http.Error(w, "Failed to save logistics: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.wardSubmitted(r, SectionLogistics, electionID, payload.WardID, version)
 	w.WriteHeader(http.StatusOK)
 }
 
 // SubmitStaffing handles the submission of staffing and security data
-func SubmitStaffing(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SubmitStaffing(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
+		ElectionID      string `json:"election_id"`
 		WardID          string `json:"ward_id"`
 		INECStaff       int    `json:"inec_staff"`
 		SecurityPresent bool   `json:"security_present"`
@@ -506,27 +479,32 @@ func SubmitStaffing(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
 
-	query := `
-		INSERT INTO ward_results (ward_id, inec_staff, security_present, party_agents, updated_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		ON CONFLICT (ward_id) DO UPDATE SET
-			inec_staff = EXCLUDED.inec_staff,
-			security_present = EXCLUDED.security_present,
-			party_agents = EXCLUDED.party_agents,
-			updated_at = NOW()
-	`
-	_, err := db.DB.Exec(query, payload.WardID, payload.INECStaff, payload.SecurityPresent, payload.PartyAgents)
+	version, err := h.Results.SaveWardResult(electionID, payload.WardID, SectionStaffing, currentUserID(r), nil, func(s *models.WardResult) error {
+		s.INECStaff = payload.INECStaff
+		s.SecurityPresent = payload.SecurityPresent
+		s.PartyAgents = payload.PartyAgents
+		return nil
+	})
 	if err != nil {
 		http.Error(w, "Failed to save staffing: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.wardSubmitted(r, SectionStaffing, electionID, payload.WardID, version)
 	w.WriteHeader(http.StatusOK)
 }
 
 // SubmitIntegrity handles the submission of integrity checks
-func SubmitIntegrity(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SubmitIntegrity(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
+		ElectionID          string `json:"election_id"`
 		WardID              string `json:"ward_id"`
 		EC8BSubmitted       bool   `json:"ec8b_submitted"`
 		EC8CCollated        bool   `json:"ec8c_collated"`
@@ -539,74 +517,141 @@ func SubmitIntegrity(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
 
-	query := `
-		INSERT INTO ward_results (
-			ward_id, ec8b_submitted, ec8c_collated, csrvs_done, votes_announced, agents_countersigned, ec60e_displayed, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
-		ON CONFLICT (ward_id) DO UPDATE SET
-			ec8b_submitted = EXCLUDED.ec8b_submitted,
-			ec8c_collated = EXCLUDED.ec8c_collated,
-			csrvs_done = EXCLUDED.csrvs_done,
-			votes_announced = EXCLUDED.votes_announced,
-			agents_countersigned = EXCLUDED.agents_countersigned,
-			ec60e_displayed = EXCLUDED.ec60e_displayed,
-			updated_at = NOW()
-	`
-	_, err := db.DB.Exec(query, payload.WardID, payload.EC8BSubmitted, payload.EC8CCollated, payload.CSRVSDone, payload.VotesAnnounced, payload.AgentsCountersigned, payload.EC60EDisplayed)
+	version, err := h.Results.SaveWardResult(electionID, payload.WardID, SectionIntegrity, currentUserID(r), nil, func(s *models.WardResult) error {
+		s.EC8BSubmitted = payload.EC8BSubmitted
+		s.EC8CCollated = payload.EC8CCollated
+		s.CSRVSDone = payload.CSRVSDone
+		s.VotesAnnounced = payload.VotesAnnounced
+		s.AgentsCountersigned = payload.AgentsCountersigned
+		s.EC60EDisplayed = payload.EC60EDisplayed
+		return nil
+	})
 	if err != nil {
 		http.Error(w, "Failed to save integrity checks: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.wardSubmitted(r, SectionIntegrity, electionID, payload.WardID, version)
 	w.WriteHeader(http.StatusOK)
 }
 
-// SubmitResults handles the submission of vote counts
-func SubmitResults(w http.ResponseWriter, r *http.Request) {
+// SubmitResults handles the submission of vote counts and per-party scores
+func (h *Handler) SubmitResults(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
-		WardID           string `json:"ward_id"`
-		AccreditedVoters int    `json:"accredited_voters"`
-		ValidVotes       int    `json:"valid_votes"`
-		RejectedVotes    int    `json:"rejected_votes"`
-		VotesCast        int    `json:"votes_cast"`
+		ElectionID       string         `json:"election_id"`
+		WardID           string         `json:"ward_id"`
+		AccreditedVoters int            `json:"accredited_voters"`
+		ValidVotes       int            `json:"valid_votes"`
+		RejectedVotes    int            `json:"rejected_votes"`
+		VotesCast        int            `json:"votes_cast"`
+		PartyResults     map[string]int `json:"party_results"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
 
-	query := `
-		INSERT INTO ward_results (
-			ward_id, accredited_voters, valid_votes, rejected_votes, votes_cast, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, NOW())
-		ON CONFLICT (ward_id) DO UPDATE SET
-			accredited_voters = EXCLUDED.accredited_voters,
-			valid_votes = EXCLUDED.valid_votes,
-			rejected_votes = EXCLUDED.rejected_votes,
-			votes_cast = EXCLUDED.votes_cast,
-			updated_at = NOW()
-	`
-	_, err := db.DB.Exec(query, payload.WardID, payload.AccreditedVoters, payload.ValidVotes, payload.RejectedVotes, payload.VotesCast)
+	ward, err := h.Wards.Ward(payload.WardID)
+	if err != nil {
+		http.Error(w, "Ward not found", http.StatusBadRequest)
+		return
+	}
+
+	allowed, err := h.Parties.AreaCouncilParties(electionID, ward.AreaCouncilID)
+	if err != nil {
+		http.Error(w, "Party configuration not found for "+ward.AreaCouncilID, http.StatusInternalServerError)
+		return
+	}
+
+	partyResults, err := validateResults(payload.ValidVotes, payload.RejectedVotes, payload.VotesCast, payload.PartyResults, allowed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	version, err := h.Results.SaveWardResult(electionID, payload.WardID, SectionResults, currentUserID(r), nil, func(s *models.WardResult) error {
+		s.AccreditedVoters = payload.AccreditedVoters
+		s.ValidVotes = payload.ValidVotes
+		s.RejectedVotes = payload.RejectedVotes
+		s.VotesCast = payload.VotesCast
+		s.PartyResults = partyResults
+		return nil
+	})
 	if err != nil {
 		http.Error(w, "Failed to save results: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// TODO: Handle Party Results (separate table)
+	h.wardSubmitted(r, SectionResults, electionID, payload.WardID, version)
 	w.WriteHeader(http.StatusOK)
 }
 
+// validateResults checks a set of vote counts for internal consistency and
+// returns the party scores keyed by their configured party names.
+func validateResults(validVotes, rejectedVotes, votesCast int, partyResults map[string]int, allowed []string) (map[string]int, error) {
+	if validVotes < 0 || rejectedVotes < 0 || votesCast < 0 {
+		return nil, fmt.Errorf("vote counts cannot be negative")
+	}
+	if validVotes+rejectedVotes != votesCast {
+		return nil, fmt.Errorf("valid_votes (%d) + rejected_votes (%d) must equal votes_cast (%d)", validVotes, rejectedVotes, votesCast)
+	}
+
+	configured := make(map[string]string, len(allowed))
+	for _, p := range allowed {
+		configured[strings.ToUpper(p)] = p
+	}
+
+	scores := make(map[string]int, len(partyResults))
+	total := 0
+	for party, score := range partyResults {
+		name, ok := configured[strings.ToUpper(strings.TrimSpace(party))]
+		if !ok {
+			return nil, fmt.Errorf("party %q is not configured for this Area Council", party)
+		}
+		if _, dup := scores[name]; dup {
+			return nil, fmt.Errorf("party %q submitted more than once", name)
+		}
+		if score < 0 {
+			return nil, fmt.Errorf("score for %s cannot be negative", name)
+		}
+		scores[name] = score
+		total += score
+	}
+
+	if total != validVotes {
+		return nil, fmt.Errorf("party scores sum to %d but valid_votes is %d", total, validVotes)
+	}
+	return scores, nil
+}
+
 // GetDashboardStats returns aggregated statistics for the dashboard
-func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 	stats := struct {
-		TotalLGAs         int     `json:"totalLGAs"`
-		TotalWards        int     `json:"totalWards"`
-		WardsReported     int     `json:"wardsReported"`
-		LGAsReported      int     `json:"lgasReported"`
-		CompliancePercent float64 `json:"compliancePercent"`
-		TotalPollingUnits int     `json:"totalPollingUnits"`
-		OpenPollingUnits  int     `json:"openPollingUnits"`
+		TotalLGAs         int      `json:"totalLGAs"`
+		TotalWards        int      `json:"totalWards"`
+		WardsReported     int      `json:"wardsReported"`
+		LGAsReported      int      `json:"lgasReported"`
+		CompliancePercent float64  `json:"compliancePercent"`
+		DeniedAccessCount int      `json:"deniedAccessCount"`
+		DeniedAccessPct   float64  `json:"deniedAccessPercent"` // Of wards that reported observer access
+		CancelledPUs      int      `json:"cancelledPUs"`
+		LostVoters        int      `json:"lostVoters"`
+		OutcomesAtRisk    []string `json:"outcomesAtRisk"` // Area Councils whose lost voters could overturn the lead
+		TotalPollingUnits int      `json:"totalPollingUnits"`
+		OpenPollingUnits  int      `json:"openPollingUnits"`
 		Breakdown         struct {
 			Operational int `json:"operational"`
 			MinorIssues int `json:"minorIssues"`
@@ -615,49 +660,87 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 		} `json:"pollingUnitBreakdown"`
 	}{}
 
-	// 1. Total Counts
-	db.DB.QueryRow("SELECT COUNT(*) FROM area_councils").Scan(&stats.TotalLGAs)
-	db.DB.QueryRow("SELECT COUNT(*) FROM wards").Scan(&stats.TotalWards)
-	db.DB.QueryRow("SELECT COUNT(*) FROM ward_results").Scan(&stats.WardsReported)
-
-	// Calculate Total Polling Units
-	db.DB.QueryRow("SELECT COALESCE(SUM(total_polling_units), 0) FROM wards").Scan(&stats.TotalPollingUnits)
-
-	// Calculate Open Polling Units (PUs in wards that have reported)
-	db.DB.QueryRow(`
-		SELECT COALESCE(SUM(w.total_polling_units), 0) 
-		FROM wards w 
-		JOIN ward_results wr ON w.id = wr.ward_id
-	`).Scan(&stats.OpenPollingUnits)
-
-	// Calculate Minor Issues (PUs in wards with active incidents)
-	var unitsWithIssues int
-	db.DB.QueryRow(`
-		SELECT COALESCE(SUM(w.total_polling_units), 0)
-		FROM wards w
-		JOIN incidents i ON w.id = i.ward_id
-		JOIN ward_results wr ON w.id = wr.ward_id
-	`).Scan(&unitsWithIssues)
-
-	stats.Breakdown.MinorIssues = unitsWithIssues
-	stats.Breakdown.Operational = stats.OpenPollingUnits - unitsWithIssues
-	if stats.Breakdown.Operational < 0 {
-		stats.Breakdown.Operational = 0
-	}
-	stats.Breakdown.Offline = stats.TotalPollingUnits - stats.OpenPollingUnits
-	stats.Breakdown.NotOpened = 0 // Placeholder until we track cancelled PUs
-
-	// 2. LGAs with at least one report
-	db.DB.QueryRow(`
-		SELECT COUNT(DISTINCT w.area_council_id) 
-		FROM ward_results wr
-		JOIN wards w ON wr.ward_id = w.id
-	`).Scan(&stats.LGAsReported)
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
 
-	// 3. Compliance Percent (Simple average of "checks passed" for now)
-	var compliantReports int
-	db.DB.QueryRow("SELECT COUNT(*) FROM ward_results WHERE ec8b_submitted = true AND ec8c_collated = true").Scan(&compliantReports)
+	in, err := h.loadSummaryInput(electionID, publishedOnly(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	statuses, err := h.PollingUnits.PollingUnitStatusCounts(electionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	// 1. Totals over the wards and their visible results
+	stats.TotalLGAs = len(in.Councils)
+	stats.TotalWards = len(in.Wards)
+	var accessReported, compliantReports, unitsWithIssues int
+	for _, ward := range in.Wards {
+		stats.TotalPollingUnits += ward.PollingUnits
+		if !ward.Reported {
+			continue
+		}
+		res := ward.Result
+		stats.WardsReported++
+		stats.OpenPollingUnits += ward.PollingUnits
+		if len(in.Incidents[ward.ID]) > 0 {
+			unitsWithIssues += ward.PollingUnits
+		}
+		if res.ObserverPermitted != nil {
+			accessReported++
+		}
+		if accessDenied(res.ObserverPermitted) {
+			stats.DeniedAccessCount++
+		}
+		stats.CancelledPUs += res.CancelledPUs
+		stats.LostVoters += res.CancelledPUVoters
+		if res.EC8BSubmitted && res.EC8CCollated {
+			compliantReports++
+		}
+	}
+
+	// Polling unit breakdown: use real PU statuses once polling units have been
+	// imported, otherwise estimate from which wards have reported.
+	trackedPUs := 0
+	for _, n := range statuses {
+		trackedPUs += n
+	}
+	if trackedPUs > 0 {
+		stats.Breakdown.Operational = statuses[PUOpen]
+		stats.Breakdown.MinorIssues = statuses[PULate]
+		stats.Breakdown.Offline = statuses[PUCancelled]
+		stats.Breakdown.NotOpened = statuses[PUNotOpened]
+		stats.OpenPollingUnits = stats.Breakdown.Operational + stats.Breakdown.MinorIssues
+	} else {
+		// Open units are those in wards that have reported; minor issues those in reported wards with incidents
+		stats.Breakdown.MinorIssues = unitsWithIssues
+		stats.Breakdown.Operational = stats.OpenPollingUnits - unitsWithIssues
+		if stats.Breakdown.Operational < 0 {
+			stats.Breakdown.Operational = 0
+		}
+		stats.Breakdown.Offline = stats.TotalPollingUnits - stats.OpenPollingUnits
+	}
+
+	// 2. LGAs with at least one report, and those where cancelled PUs could change the winner
+	summaries := buildAreaCouncilSummaries(in)
+	for _, summary := range summaries {
+		if summary.WardsReported > 0 {
+			stats.LGAsReported++
+		}
+	}
+	stats.OutcomesAtRisk = areaCouncilsAtRisk(summaries)
+
+	// Observer access denials
+	if accessReported > 0 {
+		stats.DeniedAccessPct = float64(stats.DeniedAccessCount) / float64(accessReported) * 100
+	}
+
+	// 3. Compliance Percent (Simple average of "checks passed" for now)
 	if stats.WardsReported > 0 {
 		stats.CompliancePercent = float64(compliantReports) / float64(stats.WardsReported) * 100
 	}
@@ -667,11 +750,14 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetAreaCouncilParties returns the configured parties for a specific Area Council
-func GetAreaCouncilParties(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAreaCouncilParties(w http.ResponseWriter, r *http.Request) {
 	lgaID := chi.URLParam(r, "lgaID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
 
-	var partiesJSON []byte
-	err := db.DB.QueryRow("SELECT parties FROM area_council_parties WHERE area_council_id = $1", lgaID).Scan(&partiesJSON)
+	parties, err := h.Parties.AreaCouncilParties(electionID, lgaID)
 	if err != nil {
 		// If not found, return default list or error
 		// Ideally migration seeded everything, so error means invalid ID or DB issue
@@ -680,12 +766,19 @@ func GetAreaCouncilParties(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(partiesJSON)
+	json.NewEncoder(w).Encode(parties)
 }
 
 // UpdateAreaCouncilParties updates the list of parties for an Area Council
-func UpdateAreaCouncilParties(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateAreaCouncilParties(w http.ResponseWriter, r *http.Request) {
 	lgaID := chi.URLParam(r, "lgaID")
+	electionID, ok := h.electionForSubmission(w, r, "")
+	if !ok {
+		return
+	}
+	if !h.requireAreaCouncilAccess(w, r, lgaID) {
+		return
+	}
 	var parties []string
 
 	if err := json.NewDecoder(r.Body).Decode(&parties); err != nil {
@@ -693,24 +786,26 @@ func UpdateAreaCouncilParties(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	partiesJSON, err := json.Marshal(parties)
-	if err != nil {
-		http.Error(w, "Invalid party list", http.StatusBadRequest)
+	previous, err := h.Parties.AreaCouncilParties(electionID, lgaID)
+	if err != nil && err != store.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	query := `
-		INSERT INTO area_council_parties (area_council_id, parties, updated_at)
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (area_council_id) DO UPDATE SET
-			parties = EXCLUDED.parties,
-			updated_at = NOW()
-	`
-	_, err = db.DB.Exec(query, lgaID, partiesJSON)
-	if err != nil {
+	if err := h.Parties.SetAreaCouncilParties(electionID, lgaID, parties); err != nil {
 		http.Error(w, "Failed to update configuration: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	h.logAudit(currentUserID(r), "UPDATE_PARTIES", fmt.Sprintf("Set parties for Area Council %s (election %s) to %s", lgaID, electionID, strings.Join(parties, ", ")), r)
+	auditChanges(r, "area_council", lgaID, map[string]models.FieldChange{"parties": {From: previous, To: parties}})
+
+	events.Publish(events.Event{
+		Type:          events.PartyConfigChanged,
+		ElectionID:    electionID,
+		AreaCouncilID: lgaID,
+		Data:          map[string]interface{}{"parties": parties},
+	})
+
 	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/middleware"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// testServer serves the handlers over a MemoryStore, through the audit
+// middleware, as whichever user and role are set, or anonymously as user 0
+type testServer struct {
+	t      *testing.T
+	store  *store.MemoryStore
+	router chi.Router
+	userID int
+	role   string
+}
+
+// newTestServer seeds an active election e1, a concluded election e0, wards
+// w1 and w2 in Area Council amac with APC, PDP and LP on the ballot, and w3 in
+// bwari. Requests are made as admin, user 1.
+func newTestServer(t *testing.T) *testServer {
+	m := store.NewMemoryStore()
+	m.AddElection(models.Election{ID: "e0", Date: "2022-02-12", Status: ElectionConcluded})
+	m.AddElection(models.Election{ID: "e1", Date: "2026-02-21", Status: ElectionActive})
+	m.AddWard(models.Ward{ID: "w1", AreaCouncilID: "amac", Name: "City Centre", RegisteredVoters: 10000})
+	m.AddWard(models.Ward{ID: "w2", AreaCouncilID: "amac", Name: "Garki", RegisteredVoters: 8000})
+	m.AddWard(models.Ward{ID: "w3", AreaCouncilID: "bwari", Name: "Kubwa", RegisteredVoters: 12000})
+	for _, e := range []string{"e0", "e1"} {
+		m.SetAreaCouncilParties(e, "amac", []string{"APC", "PDP", "LP"})
+	}
+	if _, err := m.CreateUser("admin", "hash", auth.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateUser("editor", "hash", auth.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{t: t, store: m, userID: 1, role: auth.RoleAdmin}
+	h := New(m.Stores())
+	r := chi.NewRouter()
+	r.Use(middleware.AuditWrites(m.Append))
+	// Stands in for authentication, as in main.go after the audit middleware
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.userID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if rec := audit.FromContext(r.Context()); rec != nil {
+				id := s.userID
+				rec.UserID = &id
+			}
+			ctx := context.WithValue(r.Context(), middleware.UserKey, fmt.Sprint(s.userID))
+			ctx = context.WithValue(ctx, middleware.RoleKey, s.role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Post("/users", h.CreateUser)
+	r.Get("/users", h.GetUsers)
+	r.Put("/users/{userID}/role", h.UpdateUserRole)
+	r.Post("/users/{userID}/deactivate", h.DeactivateUser)
+	r.Post("/users/{userID}/reactivate", h.ReactivateUser)
+	r.Post("/users/deactivate", h.DeactivateUsers)
+	r.Get("/audit-logs", h.GetAuditLogs)
+	r.Post("/submit/logistics", h.SubmitLogistics)
+	r.Post("/submit/results", h.SubmitResults)
+	r.Post("/submit/observer-access", h.SubmitObserverAccess)
+	r.Get("/wards/{wardID}/history", h.GetWardHistory)
+	r.Post("/wards/{wardID}/history/{version}/revert", h.RevertWardResult)
+	r.Get("/area-councils/{lgaID}/parties", h.GetAreaCouncilParties)
+	r.Post("/area-councils/{lgaID}/parties", h.UpdateAreaCouncilParties)
+	r.Post("/incidents", h.CreateIncident)
+	r.Get("/incidents", h.GetIncidents)
+	r.Get("/incidents/{incidentID}", h.GetIncident)
+	r.Patch("/incidents/{incidentID}/status", h.UpdateIncidentStatus)
+	r.Post("/incidents/{incidentID}/resolve", h.ResolveIncident)
+	r.Post("/polling-units", h.CreatePollingUnit)
+	r.Post("/submit/polling-unit-results", h.SubmitPollingUnitResults)
+	r.Post("/wards/{wardID}/review/submit", h.SubmitWardForReview)
+	r.Post("/wards/{wardID}/review/approve", h.ApproveWardResult)
+	r.Post("/wards/{wardID}/review/reject", h.RejectWardResult)
+	r.Get("/wards/{wardID}/review", h.GetWardReview)
+	r.Get("/reviews", h.GetReviewQueue)
+	r.Put("/risk/weights", h.UpdateRiskWeights)
+	r.Get("/risk/weights", h.GetRiskWeights)
+	// Public reads
+	r.Get("/elections", h.GetElections)
+	r.Get("/elections/{electionID}", h.GetElection)
+	r.Get("/area-councils", h.GetAreaCouncils)
+	r.Get("/area-councils/{lgaID}/wards", h.GetWards)
+	r.Get("/wards/{wardID}", h.GetWardDetails)
+	r.Get("/wards/{wardID}/polling-units", h.GetPollingUnits)
+	r.Get("/wards/{wardID}/compare", h.CompareWardAcrossElections)
+	r.Get("/polling-units/{puID}", h.GetPollingUnit)
+	r.Get("/dashboard/stats", h.GetDashboardStats)
+	r.Get("/red-flags/denied-access", h.GetDeniedAccessFlags)
+	s.router = r
+	return s
+}
+
+// as makes the following requests as another user, or anonymously for user 0
+func (s *testServer) as(userID int, role string) {
+	s.userID, s.role = userID, role
+}
+
+// do sends body, if any, as JSON and checks the response has the wanted status
+func (s *testServer) do(method, path string, body interface{}, want int) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != want {
+		s.t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, want, rec.Body.String())
+	}
+	return rec
+}
+
+// get fetches path and decodes its JSON response into v
+func (s *testServer) get(path string, v interface{}) {
+	s.t.Helper()
+	rec := s.do(http.MethodGet, path, nil, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		s.t.Fatalf("GET %s: %v", path, err)
+	}
+}
+
+func results(wardID string, apc, pdp, rejected int) map[string]interface{} {
+	return map[string]interface{}{
+		"ward_id":           wardID,
+		"accredited_voters": apc + pdp + rejected,
+		"valid_votes":       apc + pdp,
+		"rejected_votes":    rejected,
+		"votes_cast":        apc + pdp + rejected,
+		"party_results":     map[string]int{"APC": apc, "PDP": pdp},
+	}
+}
+
+func TestSubmitResultsRecordsVersions(t *testing.T) {
+	s := newTestServer(t)
+
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.store.SetReviewStatus("e1", "w1", "approved")
+
+	// The same figures again change nothing and leave the review alone
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	if got := s.store.ReviewStatus("e1", "w1"); got != "approved" {
+		t.Errorf("review status after unchanged submission = %q, want approved", got)
+	}
+
+	s.do(http.MethodPost, "/submit/results", results("w1", 310, 200, 10), http.StatusOK)
+	if got := s.store.ReviewStatus("e1", "w1"); got != "draft" {
+		t.Errorf("review status after change = %q, want draft", got)
+	}
+
+	var history []models.WardResultVersion
+	s.get("/wards/w1/history", &history)
+	if len(history) != 2 {
+		t.Fatalf("got %d versions, want 2", len(history))
+	}
+	latest := history[0]
+	if latest.Version != 2 || latest.Section != SectionResults {
+		t.Errorf("latest version = %d %s, want 2 %s", latest.Version, latest.Section, SectionResults)
+	}
+	if latest.SubmittedByName != "admin" {
+		t.Errorf("submitted by %q, want admin", latest.SubmittedByName)
+	}
+	if got := latest.Snapshot.PartyResults["APC"]; got != 310 {
+		t.Errorf("APC = %d, want 310", got)
+	}
+	if change, ok := latest.Diff["valid_votes"]; !ok || fmt.Sprint(change.From) != "500" || fmt.Sprint(change.To) != "510" {
+		t.Errorf("valid_votes change = %+v, want 500 -> 510", change)
+	}
+}
+
+func TestSubmitResultsRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		want    int
+	}{
+		{"votes don't add up", map[string]interface{}{"ward_id": "w1", "valid_votes": 10, "rejected_votes": 1, "votes_cast": 10}, http.StatusUnprocessableEntity},
+		{"scores don't add up", map[string]interface{}{"ward_id": "w1", "valid_votes": 10, "votes_cast": 10, "party_results": map[string]int{"APC": 9}}, http.StatusUnprocessableEntity},
+		{"party not on the ballot", map[string]interface{}{"ward_id": "w1", "valid_votes": 10, "votes_cast": 10, "party_results": map[string]int{"ADC": 10}}, http.StatusUnprocessableEntity},
+		{"unknown ward", results("w9", 1, 1, 0), http.StatusBadRequest},
+		{"unknown election", mergePayload(results("w1", 1, 1, 0), "election_id", "e9"), http.StatusNotFound},
+		{"concluded election", mergePayload(results("w1", 1, 1, 0), "election_id", "e0"), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.do(http.MethodPost, "/submit/results", tt.payload, tt.want)
+
+			var history []models.WardResultVersion
+			s.get("/wards/w1/history", &history)
+			if len(history) != 0 {
+				t.Errorf("rejected submission recorded %d versions", len(history))
+			}
+		})
+	}
+}
+
+func mergePayload(payload map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	payload[key] = value
+	return payload
+}
+
+func TestSubmissionJurisdictions(t *testing.T) {
+	s := newTestServer(t)
+	s.as(2, auth.RoleEditor)
+
+	s.do(http.MethodPost, "/submit/results", results("w1", 1, 1, 0), http.StatusForbidden)
+
+	s.store.Assign(2, "", "w1")
+	s.do(http.MethodPost, "/submit/results", results("w1", 1, 1, 0), http.StatusOK)
+	s.do(http.MethodPost, "/submit/results", results("w2", 1, 1, 0), http.StatusForbidden)
+	s.do(http.MethodPost, "/area-councils/amac/parties", []string{"APC"}, http.StatusForbidden)
+
+	s.store.Assign(2, "amac", "")
+	s.do(http.MethodPost, "/submit/results", results("w2", 1, 1, 0), http.StatusOK)
+	s.do(http.MethodPost, "/submit/logistics", map[string]interface{}{"ward_id": "w3"}, http.StatusForbidden)
+}
+
+func TestRevertWardResult(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.do(http.MethodPost, "/submit/results", results("w1", 100, 400, 10), http.StatusOK)
+
+	var reverted models.WardResultVersion
+	rec := s.do(http.MethodPost, "/wards/w1/history/1/revert", nil, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &reverted); err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Version != 3 || reverted.Section != SectionRevert {
+		t.Errorf("revert recorded as version %d %s, want 3 %s", reverted.Version, reverted.Section, SectionRevert)
+	}
+	if reverted.RevertedFrom == nil || *reverted.RevertedFrom != 1 {
+		t.Errorf("reverted_from = %v, want 1", reverted.RevertedFrom)
+	}
+	if got := reverted.Snapshot.PartyResults["APC"]; got != 300 {
+		t.Errorf("APC after revert = %d, want 300", got)
+	}
+
+	s.do(http.MethodPost, "/wards/w1/history/9/revert", nil, http.StatusNotFound)
+}
+
+func TestAreaCouncilParties(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/area-councils/bwari/parties", []string{"APC", "ADC"}, http.StatusOK)
+
+	var parties []string
+	s.get("/area-councils/bwari/parties", &parties)
+	if fmt.Sprint(parties) != "[APC ADC]" {
+		t.Errorf("parties = %v, want [APC ADC]", parties)
+	}
+
+	// The new configuration is what results are checked against
+	payload := map[string]interface{}{"ward_id": "w3", "valid_votes": 5, "votes_cast": 5, "party_results": map[string]int{"adc": 5}}
+	s.do(http.MethodPost, "/submit/results", payload, http.StatusOK)
+
+	// Other elections keep their own configuration
+	s.do(http.MethodGet, "/area-councils/bwari/parties?election_id=e0", nil, http.StatusNotFound)
+}
+
+func TestCreateUser(t *testing.T) {
+	s := newTestServer(t)
+
+	var created models.User
+	rec := s.do(http.MethodPost, "/users", map[string]string{"username": " ada ", "password": "correct horse"}, http.StatusCreated)
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == 0 || created.Username != "ada" || created.Role != auth.RoleEditor || created.Password != "" {
+		t.Errorf("created %+v, want editor ada without a password", created)
+	}
+
+	s.do(http.MethodPost, "/users", map[string]string{"username": "ada", "password": "correct horse"}, http.StatusConflict)
+	s.do(http.MethodPost, "/users", map[string]string{"username": "bola", "password": "short"}, http.StatusBadRequest)
+	s.do(http.MethodPost, "/users", map[string]string{"username": "bola", "password": "correct horse", "role": "owner"}, http.StatusBadRequest)
+
+	var users []models.User
+	s.get("/users", &users)
+	if len(users) != 3 || users[0].Username != "ada" {
+		t.Errorf("got users %+v, want ada first of 3", users)
+	}
+
+	user, err := s.store.UserByUsername("ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !auth.CheckPasswordHash("correct horse", user.Password) {
+		t.Error("stored password hash doesn't match the password")
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// Sections of a ward submission recorded in its version history
+const (
+	SectionLogistics    = "logistics"
+	SectionStaffing     = "staffing"
+	SectionIntegrity    = "integrity"
+	SectionResults      = "results"
+	SectionPollingUnits = "polling_units"
+	SectionRevert       = "revert"
+)
+
+// wardSubmitted records a committed ward submission in the audit log and, if
+// it changed anything, announces it to live subscribers
+func (h *Handler) wardSubmitted(r *http.Request, section, electionID, wardID string, version int) {
+	details := fmt.Sprintf("Submitted %s for ward %s (election %s)", section, wardID, electionID)
+	if version > 0 {
+		details += fmt.Sprintf(", version %d", version)
+	} else {
+		details += ", unchanged"
+	}
+	h.logAudit(currentUserID(r), "SUBMIT_"+strings.ToUpper(section), details, r)
+	h.auditWardVersion(r, electionID, wardID, version)
+
+	if version == 0 {
+		return
+	}
+	h.publishWardUpdate(electionID, wardID, section, version)
+	if section == SectionIntegrity {
+		events.Publish(events.Event{
+			Type:          events.IntegrityFlagChanged,
+			ElectionID:    electionID,
+			AreaCouncilID: h.wardAreaCouncil(wardID),
+			WardID:        wardID,
+			Data:          wardUpdateData(section, version),
+		})
+	}
+}
+
+// auditWardVersion puts the changes a submission made to a ward in the
+// request's audit entry. An unchanged submission has no changes.
+func (h *Handler) auditWardVersion(r *http.Request, electionID, wardID string, version int) {
+	if audit.FromContext(r.Context()) == nil {
+		return
+	}
+	changes := map[string]models.FieldChange{}
+	if version > 0 {
+		if v, err := h.Results.WardVersion(electionID, wardID, version); err == nil {
+			changes = v.Diff
+		}
+	}
+	auditChanges(r, "ward", wardID, changes)
+}
+
+// GetWardHistory lists every recorded version of a ward's submission in an election, newest first
+func (h *Handler) GetWardHistory(w http.ResponseWriter, r *http.Request) {
+	wardID := chi.URLParam(r, "wardID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	if _, err := h.Wards.Ward(wardID); err != nil {
+		http.Error(w, "Ward not found", http.StatusNotFound)
+		return
+	}
+
+	history, err := h.Results.WardHistory(electionID, wardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// RevertWardResult restores a ward's submission to an earlier version. The
+// revert is itself recorded as a new version, so history is never rewritten.
+// Wards with polling unit results are recomputed from them on the next PU
+// submission, which will supersede reverted vote counts.
+func (h *Handler) RevertWardResult(w http.ResponseWriter, r *http.Request) {
+	wardID := chi.URLParam(r, "wardID")
+	target, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, "")
+	if !ok {
+		return
+	}
+
+	restored, err := h.Results.WardVersion(electionID, wardID, target)
+	if err == store.ErrNotFound {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userID := currentUserID(r)
+	version, err := h.Results.SaveWardResult(electionID, wardID, SectionRevert, userID, &target, func(s *models.WardResult) error {
+		*s = restored.Snapshot
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to revert ward result: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(userID, "REVERT_WARD_RESULT", fmt.Sprintf("Reverted ward %s (election %s) to version %d as version %d", wardID, electionID, target, version), r)
+	h.publishWardUpdate(electionID, wardID, SectionRevert, version)
+
+	v, err := h.Results.WardVersion(electionID, wardID, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	auditChanges(r, "ward", wardID, v.Diff)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// Incident statuses, in workflow order
+const (
+	IncidentReported  = "reported"
+	IncidentVerified  = "verified"
+	IncidentEscalated = "escalated"
+	IncidentResolved  = "resolved"
+)
+
+// incidentTransitions lists the statuses an incident may move to from each status.
+// Incidents can be resolved from any open state, but never reopened.
+var incidentTransitions = map[string][]string{
+	IncidentReported:  {IncidentVerified, IncidentResolved},
+	IncidentVerified:  {IncidentEscalated, IncidentResolved},
+	IncidentEscalated: {IncidentResolved},
+	IncidentResolved:  {},
+}
+
+var incidentSeverities = map[string]bool{"low": true, "medium": true, "high": true}
+
+const (
+	defaultIncidentPageSize = 50
+	maxIncidentPageSize     = 200
+)
+
+// CreateIncident records a newly reported incident
+func (h *Handler) CreateIncident(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID  string     `json:"election_id"`
+		WardID      string     `json:"ward_id"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		Type        string     `json:"type"`
+		Severity    string     `json:"severity"`
+		Timestamp   *time.Time `json:"timestamp"` // When it happened; defaults to now
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+
+	payload.Title = strings.TrimSpace(payload.Title)
+	payload.Severity = strings.ToLower(strings.TrimSpace(payload.Severity))
+	if payload.WardID == "" || payload.Title == "" || payload.Type == "" {
+		http.Error(w, "ward_id, title and type are required", http.StatusBadRequest)
+		return
+	}
+	if !incidentSeverities[payload.Severity] {
+		http.Error(w, "severity must be one of low, medium, high", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Wards.Ward(payload.WardID); err != nil {
+		http.Error(w, "Ward not found", http.StatusBadRequest)
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
+
+	occurredAt := time.Now()
+	if payload.Timestamp != nil {
+		occurredAt = *payload.Timestamp
+	}
+
+	userID := currentUserID(r)
+	inc := models.Incident{
+		ElectionID:  electionID,
+		WardID:      payload.WardID,
+		Title:       payload.Title,
+		Description: payload.Description,
+		Type:        payload.Type,
+		Severity:    payload.Severity,
+		Status:      IncidentReported,
+		Timestamp:   occurredAt,
+	}
+	if userID != 0 {
+		inc.ReportedBy = &userID
+	}
+	inc, err := h.Incidents.CreateIncident(inc)
+	if err != nil {
+		http.Error(w, "Failed to save incident: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(userID, "REPORT_INCIDENT", fmt.Sprintf("Reported incident %d in ward %s", inc.ID, payload.WardID), r)
+
+	events.Publish(events.Event{
+		Type:          events.IncidentReported,
+		ElectionID:    inc.ElectionID,
+		AreaCouncilID: inc.AreaCouncilID,
+		WardID:        inc.WardID,
+		Data:          map[string]interface{}{"incident_id": inc.ID, "type": inc.Type, "severity": inc.Severity},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(inc)
+}
+
+// GetIncidents lists an election's incidents, newest first, with optional filters
+// and pagination. Supported query parameters: election_id, ward_id,
+// area_council_id, type, severity, status, from, to (RFC 3339), page and limit.
+func (h *Handler) GetIncidents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	f := store.IncidentFilter{
+		ElectionID:    electionID,
+		WardID:        q.Get("ward_id"),
+		AreaCouncilID: q.Get("area_council_id"),
+		Type:          q.Get("type"),
+		Severity:      q.Get("severity"),
+		Status:        strings.ToLower(q.Get("status")),
+	}
+	for param, bound := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s: expected RFC 3339 timestamp", param), http.StatusBadRequest)
+			return
+		}
+		*bound = t
+	}
+
+	page, limit := 1, defaultIncidentPageSize
+	if v, err := strconv.Atoi(q.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > maxIncidentPageSize {
+		limit = maxIncidentPageSize
+	}
+	f.Offset, f.Limit = (page-1)*limit, limit
+
+	incidents, total, err := h.Incidents.Incidents(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Incidents []models.Incident `json:"incidents"`
+		Total     int               `json:"total"`
+		Page      int               `json:"page"`
+		Limit     int               `json:"limit"`
+	}{incidents, total, page, limit})
+}
+
+// GetIncident returns a single incident with its status history
+func (h *Handler) GetIncident(w http.ResponseWriter, r *http.Request) {
+	incidentID, err := strconv.Atoi(chi.URLParam(r, "incidentID"))
+	if err != nil {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+
+	inc, err := h.Incidents.Incident(incidentID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inc)
+}
+
+// UpdateIncidentStatus moves an incident along its status workflow
+func (h *Handler) UpdateIncidentStatus(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.changeIncidentStatus(w, r, strings.ToLower(payload.Status), payload.Note)
+}
+
+// ResolveIncident marks an incident as resolved
+func (h *Handler) ResolveIncident(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	h.changeIncidentStatus(w, r, IncidentResolved, payload.Note)
+}
+
+func (h *Handler) changeIncidentStatus(w http.ResponseWriter, r *http.Request, status, note string) {
+	incidentID, err := strconv.Atoi(chi.URLParam(r, "incidentID"))
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := incidentTransitions[status]; !ok {
+		http.Error(w, "status must be one of reported, verified, escalated, resolved", http.StatusBadRequest)
+		return
+	}
+
+	// Only someone assigned to the incident's ward may move it along
+	inc, err := h.Incidents.Incident(incidentID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load incident: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !h.requireWardAccess(w, r, inc.WardID) {
+		return
+	}
+
+	userID := currentUserID(r)
+	inc, err = h.Incidents.ChangeIncidentStatus(incidentID, status, note, userID, canTransitionIncident)
+	if err == store.ErrNotFound {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+	if err == store.ErrConflict {
+		http.Error(w, fmt.Sprintf("Cannot move incident from %s to %s", inc.Status, status), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update incident: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current, electionID, wardID, lgaID := inc.Status, inc.ElectionID, inc.WardID, inc.AreaCouncilID
+
+	h.logAudit(userID, "UPDATE_INCIDENT_STATUS", fmt.Sprintf("Incident %d: %s -> %s", incidentID, current, status), r)
+
+	eventType := events.IncidentStatusChange
+	if status == IncidentResolved {
+		eventType = events.IncidentResolved
+	}
+	events.Publish(events.Event{
+		Type:          eventType,
+		ElectionID:    electionID,
+		AreaCouncilID: lgaID,
+		WardID:        wardID,
+		Data:          map[string]interface{}{"incident_id": incidentID, "from_status": current, "status": status},
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func canTransitionIncident(from, to string) bool {
+	for _, next := range incidentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
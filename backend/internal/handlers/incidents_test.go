@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+type incidentPage struct {
+	Incidents []models.Incident `json:"incidents"`
+	Total     int               `json:"total"`
+}
+
+func reportIncident(s *testServer, wardID, title, severity string) models.Incident {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/incidents", map[string]string{
+		"ward_id": wardID, "title": title, "type": "Violence", "severity": severity,
+	}, http.StatusCreated)
+	var inc models.Incident
+	if err := json.Unmarshal(rec.Body.Bytes(), &inc); err != nil {
+		s.t.Fatal(err)
+	}
+	return inc
+}
+
+func TestIncidentWorkflow(t *testing.T) {
+	s := newTestServer(t)
+	inc := reportIncident(s, "w1", "Ballot box snatched", "HIGH")
+	if inc.Status != IncidentReported || inc.Severity != "high" || inc.AreaCouncilID != "amac" || inc.ElectionID != "e1" {
+		t.Errorf("reported %+v, want a high severity incident reported in amac in e1", inc)
+	}
+	if inc.ReportedBy == nil || *inc.ReportedBy != 1 {
+		t.Errorf("reported_by = %v, want 1", inc.ReportedBy)
+	}
+
+	path := "/incidents/" + strconv.Itoa(inc.ID)
+	s.do(http.MethodPatch, path+"/status", map[string]string{"status": "escalated"}, http.StatusConflict)
+	s.do(http.MethodPatch, path+"/status", map[string]string{"status": "Verified", "note": "Confirmed by observer"}, http.StatusOK)
+	s.do(http.MethodPatch, path+"/status", map[string]string{"status": "closed"}, http.StatusBadRequest)
+	s.do(http.MethodPost, path+"/resolve", nil, http.StatusOK)
+	s.do(http.MethodPatch, path+"/status", map[string]string{"status": "reported"}, http.StatusConflict)
+	s.do(http.MethodPost, "/incidents/99/resolve", nil, http.StatusNotFound)
+
+	var got models.Incident
+	s.get(path, &got)
+	if got.Status != IncidentResolved || got.StatusUpdatedBy == nil || got.StatusUpdatedAt == nil {
+		t.Errorf("incident = %+v, want resolved with who and when", got)
+	}
+	if len(got.History) != 2 {
+		t.Fatalf("got %d status changes, want 2", len(got.History))
+	}
+	if c := got.History[0]; c.FromStatus != IncidentReported || c.ToStatus != IncidentVerified || c.Note != "Confirmed by observer" {
+		t.Errorf("first change = %+v, want reported -> verified with the note", c)
+	}
+	if c := got.History[1]; c.FromStatus != IncidentVerified || c.ToStatus != IncidentResolved {
+		t.Errorf("second change = %+v, want verified -> resolved", c)
+	}
+
+	s.do(http.MethodGet, "/incidents/99", nil, http.StatusNotFound)
+}
+
+func TestCreateIncidentRejected(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/incidents", map[string]string{"ward_id": "w1", "type": "Violence", "severity": "low"}, http.StatusBadRequest)
+	s.do(http.MethodPost, "/incidents", map[string]string{"ward_id": "w1", "title": "Delay", "type": "Logistics", "severity": "urgent"}, http.StatusBadRequest)
+	s.do(http.MethodPost, "/incidents", map[string]string{"ward_id": "w9", "title": "Delay", "type": "Logistics", "severity": "low"}, http.StatusBadRequest)
+	s.do(http.MethodPost, "/incidents", map[string]string{"election_id": "e0", "ward_id": "w1", "title": "Delay", "type": "Logistics", "severity": "low"}, http.StatusConflict)
+
+	var page incidentPage
+	s.get("/incidents", &page)
+	if page.Total != 0 {
+		t.Errorf("rejected reports stored %d incidents", page.Total)
+	}
+}
+
+func TestCreateIncidentRequiresWardAccess(t *testing.T) {
+	s := newTestServer(t)
+	s.store.Assign(2, "", "w1")
+	s.as(2, "editor")
+
+	reportIncident(s, "w1", "Late materials", "low")
+	s.do(http.MethodPost, "/incidents", map[string]string{"ward_id": "w3", "title": "Thugs", "type": "Violence", "severity": "high"}, http.StatusForbidden)
+
+	var page incidentPage
+	s.get("/incidents", &page)
+	if page.Total != 1 || page.Incidents[0].WardID != "w1" {
+		t.Errorf("got %+v, want only the incident in w1", page.Incidents)
+	}
+}
+
+func TestChangeIncidentStatusRequiresWardAccess(t *testing.T) {
+	s := newTestServer(t)
+	amac := reportIncident(s, "w1", "Ballot box snatched", "high")
+	bwari := reportIncident(s, "w3", "Thugs at collation", "high")
+
+	s.store.Assign(2, "bwari", "")
+	s.as(2, "editor")
+	s.do(http.MethodPatch, "/incidents/"+strconv.Itoa(amac.ID)+"/status", map[string]string{"status": "verified"}, http.StatusForbidden)
+	s.do(http.MethodPost, "/incidents/"+strconv.Itoa(amac.ID)+"/resolve", nil, http.StatusForbidden)
+	s.do(http.MethodPatch, "/incidents/"+strconv.Itoa(bwari.ID)+"/status", map[string]string{"status": "verified"}, http.StatusOK)
+
+	var got models.Incident
+	s.get("/incidents/"+strconv.Itoa(amac.ID), &got)
+	if got.Status != IncidentReported || len(got.History) != 0 {
+		t.Errorf("incident outside the editor's jurisdiction = %+v, want it untouched", got)
+	}
+}
+
+func TestGetIncidentsFilters(t *testing.T) {
+	s := newTestServer(t)
+	reportIncident(s, "w1", "Late materials", "low")
+	reportIncident(s, "w2", "Vote buying", "medium")
+	reportIncident(s, "w3", "Thugs at collation", "high")
+	last := reportIncident(s, "w3", "Result sheet missing", "high")
+
+	tests := []struct {
+		query  string
+		total  int
+		titles int
+	}{
+		{"", 4, 4},
+		{"?area_council_id=amac", 2, 2},
+		{"?ward_id=w3&severity=HIGH", 2, 2},
+		{"?type=violence&limit=3", 4, 3},
+		{"?limit=3&page=2", 4, 1},
+		{"?status=resolved", 0, 0},
+		{"?election_id=e0", 0, 0},
+	}
+	for _, tt := range tests {
+		var page incidentPage
+		s.get("/incidents"+tt.query, &page)
+		if page.Total != tt.total || len(page.Incidents) != tt.titles {
+			t.Errorf("%q: got %d of %d incidents, want %d of %d", tt.query, len(page.Incidents), page.Total, tt.titles, tt.total)
+		}
+	}
+
+	var page incidentPage
+	s.get("/incidents", &page)
+	if page.Incidents[0].ID != last.ID {
+		t.Errorf("first incident is %d, want the latest, %d", page.Incidents[0].ID, last.ID)
+	}
+
+	s.do(http.MethodGet, "/incidents?from=yesterday", nil, http.StatusBadRequest)
+}
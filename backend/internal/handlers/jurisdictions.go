@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/middleware"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// allJurisdictions reports whether the caller's role reaches every Area Council and ward
+func allJurisdictions(r *http.Request) bool {
+	if requestAPIKey(r) != nil {
+		return false
+	}
+	role, _ := r.Context().Value(middleware.RoleKey).(string)
+	return auth.Can(role, auth.PermAllJurisdictions)
+}
+
+// requestAPIKey is the API key the request was made with, if any
+func requestAPIKey(r *http.Request) *auth.APIKey {
+	key, _ := r.Context().Value(middleware.APIKeyKey).(*auth.APIKey)
+	return key
+}
+
+// requireWardAccess checks the caller is assigned to the ward or its Area
+// Council, writing a 400 for an unknown ward and a 403 otherwise
+func (h *Handler) requireWardAccess(w http.ResponseWriter, r *http.Request, wardID string) bool {
+	if allJurisdictions(r) {
+		return true
+	}
+	if key := requestAPIKey(r); key != nil {
+		ward, err := h.Wards.Ward(wardID)
+		if err != nil {
+			http.Error(w, "Ward not found", http.StatusBadRequest)
+			return false
+		}
+		if !key.CoversAreaCouncil(ward.AreaCouncilID) {
+			http.Error(w, "This API key does not cover ward "+wardID, http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	assigned, err := h.Users.AssignedToWard(currentUserID(r), wardID)
+	if err != nil {
+		http.Error(w, "Ward not found", http.StatusBadRequest)
+		return false
+	}
+	if !assigned {
+		http.Error(w, "You are not assigned to ward "+wardID, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireAreaCouncilAccess checks the caller is assigned to the whole Area
+// Council; assignments to some of its wards are not enough
+func (h *Handler) requireAreaCouncilAccess(w http.ResponseWriter, r *http.Request, lgaID string) bool {
+	if allJurisdictions(r) {
+		return true
+	}
+	if key := requestAPIKey(r); key != nil {
+		if !key.CoversAreaCouncil(lgaID) {
+			http.Error(w, "This API key does not cover Area Council "+lgaID, http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	assigned, err := h.Users.AssignedToAreaCouncil(currentUserID(r), lgaID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !assigned {
+		http.Error(w, "You are not assigned to Area Council "+lgaID, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requirePollingUnitAccess checks the caller is assigned to the polling unit's ward
+func (h *Handler) requirePollingUnitAccess(w http.ResponseWriter, r *http.Request, puID int) bool {
+	if allJurisdictions(r) {
+		return true
+	}
+	pu, err := h.PollingUnits.PollingUnit("", puID)
+	if err != nil {
+		http.Error(w, "Polling unit not found", http.StatusBadRequest)
+		return false
+	}
+	return h.requireWardAccess(w, r, pu.WardID)
+}
+
+// GetUserJurisdictions lists the Area Councils and wards a user is assigned to
+func (h *Handler) GetUserJurisdictions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.user(w, userID); !ok {
+		return
+	}
+	j, err := h.Users.Jurisdiction(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(j)
+}
+
+// SetUserJurisdictions replaces a user's assignments. Sending empty lists
+// removes them all.
+func (h *Handler) SetUserJurisdictions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	var payload models.Jurisdiction
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, lgaID := range payload.AreaCouncilIDs {
+		if !h.areaCouncilExists(lgaID) {
+			http.Error(w, "Unknown Area Council or ward", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, wardID := range payload.WardIDs {
+		_, err := h.Wards.Ward(wardID)
+		if err == store.ErrNotFound {
+			http.Error(w, "Unknown Area Council or ward", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	user, ok := h.user(w, userID)
+	if !ok {
+		return
+	}
+	actorID := currentUserID(r)
+	j, err := h.Users.SetJurisdiction(userID, payload, actorID)
+	if err == store.ErrNotFound {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save jurisdictions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logAudit(actorID, "SET_USER_JURISDICTIONS", fmt.Sprintf("Assigned user %s to Area Councils [%s] and wards [%s]",
+		user.Username, strings.Join(j.AreaCouncilIDs, ", "), strings.Join(j.WardIDs, ", ")), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(j)
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+// SectionObserverAccess is the ward history section for observer access reports
+const SectionObserverAccess = "observer_access"
+
+// observerAccessPayload is what observers report about being allowed to watch collation
+type observerAccessPayload struct {
+	ElectionID         string     `json:"election_id"`
+	WardID             string     `json:"ward_id"`
+	AreaCouncilID      string     `json:"area_council_id"`
+	PermittedToObserve *bool      `json:"permitted_to_observe"`
+	DenialReason       string     `json:"denial_reason"`
+	DeniedAt           *time.Time `json:"denied_at"`
+}
+
+// validate checks the report and returns the reason and time to store, which
+// are cleared when access was permitted. A denial without a time is taken to
+// have happened now.
+func (p *observerAccessPayload) validate() (interface{}, interface{}, error) {
+	if p.PermittedToObserve == nil {
+		return nil, nil, fmt.Errorf("permitted_to_observe is required")
+	}
+	if *p.PermittedToObserve {
+		return nil, nil, nil
+	}
+
+	reason := strings.TrimSpace(p.DenialReason)
+	if reason == "" {
+		return nil, nil, fmt.Errorf("denial_reason is required when access was denied")
+	}
+	deniedAt := time.Now()
+	if p.DeniedAt != nil {
+		if p.DeniedAt.After(time.Now().Add(5 * time.Minute)) {
+			return nil, nil, fmt.Errorf("denied_at cannot be in the future")
+		}
+		deniedAt = *p.DeniedAt
+	}
+	return reason, deniedAt, nil
+}
+
+// accessDenied reports whether an observer access report says access was refused
+func accessDenied(permitted *bool) bool {
+	return permitted != nil && !*permitted
+}
+
+// accessPermitted reports whether an observer was reported as permitted to observe
+func accessPermitted(permitted *bool) bool {
+	return permitted != nil && *permitted
+}
+
+// SubmitObserverAccess records whether the observer was permitted to watch a ward's collation
+func (h *Handler) SubmitObserverAccess(w http.ResponseWriter, r *http.Request) {
+	var payload observerAccessPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reason, deniedAt, err := payload.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requireWardAccess(w, r, payload.WardID) {
+		return
+	}
+
+	version, err := h.Results.SaveWardResult(electionID, payload.WardID, SectionObserverAccess, currentUserID(r), nil, func(s *models.WardResult) error {
+		s.ObserverPermitted = payload.PermittedToObserve
+		s.DenialReason, _ = reason.(string)
+		s.DeniedAt = nil
+		if t, ok := deniedAt.(time.Time); ok {
+			s.DeniedAt = &t
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save observer access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.wardSubmitted(r, SectionObserverAccess, electionID, payload.WardID, version)
+	w.WriteHeader(http.StatusOK)
+}
+
+// SubmitAreaCouncilObserverAccess records whether the observer was permitted to watch an Area Council's collation
+func (h *Handler) SubmitAreaCouncilObserverAccess(w http.ResponseWriter, r *http.Request) {
+	var payload observerAccessPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reason, deniedAt, err := payload.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.areaCouncilExists(payload.AreaCouncilID) {
+		http.Error(w, "Area Council not found", http.StatusBadRequest)
+		return
+	}
+	if !h.requireAreaCouncilAccess(w, r, payload.AreaCouncilID) {
+		return
+	}
+
+	err = h.Collations.SaveAreaCouncilResult(electionID, payload.AreaCouncilID, func(c *models.AreaCouncilResult) error {
+		c.ObserverPermitted = payload.PermittedToObserve
+		c.DenialReason, _ = reason.(string)
+		c.DeniedAt = nil
+		if t, ok := deniedAt.(time.Time); ok {
+			c.DeniedAt = &t
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to save observer access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "SUBMIT_AREA_COUNCIL_OBSERVER_ACCESS",
+		fmt.Sprintf("Area Council %s (election %s) observer permitted: %t", payload.AreaCouncilID, electionID, *payload.PermittedToObserve), r)
+	w.WriteHeader(http.StatusOK)
+}
+
+// collationRiskInput scores an Area Council's collation centre the same way as a ward
+func collationRiskInput(res models.AreaCouncilResult) risk.Input {
+	return risk.Input{
+		Reported:        true,
+		ObserverDenied:  accessDenied(res.ObserverPermitted),
+		SecurityPresent: res.SecurityPresent,
+		LateStart:       isLateStart(res.CollationStartTime),
+		Integrity: models.WardIntegrity{
+			EC8BSubmitted:       res.EC8BSubmitted,
+			EC8CCollated:        res.EC8CCollated,
+			CSRVSDone:           res.CSRVSDone,
+			VotesAnnounced:      res.VotesAnnounced,
+			AgentsCountersigned: res.AgentsCountersigned,
+			EC60EDisplayed:      res.EC60EDisplayed,
+		},
+	}
+}
+
+// GetDeniedAccessFlags lists every ward and Area Council collation where the
+// observer was refused access, most recent denial first. Pass
+// area_council_id to restrict the list to one Area Council.
+func (h *Handler) GetDeniedAccessFlags(w http.ResponseWriter, r *http.Request) {
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	lgaID := r.URL.Query().Get("area_council_id")
+
+	councils, err := h.Wards.AreaCouncils()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	councilNames := make(map[string]string, len(councils))
+	for _, ac := range councils {
+		councilNames[ac.ID] = ac.Name
+	}
+	wards, err := h.Wards.Wards(lgaID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	wardsByID := make(map[string]models.Ward, len(wards))
+	for _, ward := range wards {
+		wardsByID[ward.ID] = ward
+	}
+	subs, err := h.Results.WardResults(electionID, publishedOnly(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	collations, err := h.Collations.AreaCouncilResults(electionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	flags := []models.DeniedAccessFlag{}
+	for _, sub := range subs {
+		ward, ok := wardsByID[sub.WardID]
+		if !ok || !accessDenied(sub.ObserverPermitted) {
+			continue
+		}
+		flags = append(flags, models.DeniedAccessFlag{
+			Level: "ward", ID: ward.ID, Name: ward.Name,
+			AreaCouncilID: ward.AreaCouncilID, AreaCouncilName: councilNames[ward.AreaCouncilID],
+			DenialReason: sub.DenialReason, DeniedAt: sub.DeniedAt,
+		})
+	}
+	for _, c := range collations {
+		if (lgaID != "" && c.AreaCouncilID != lgaID) || !accessDenied(c.ObserverPermitted) {
+			continue
+		}
+		flags = append(flags, models.DeniedAccessFlag{
+			Level: "area_council", ID: c.AreaCouncilID, Name: councilNames[c.AreaCouncilID],
+			AreaCouncilID: c.AreaCouncilID, AreaCouncilName: councilNames[c.AreaCouncilID],
+			DenialReason: c.DenialReason, DeniedAt: c.DeniedAt,
+		})
+	}
+
+	// Most recent denial first, undated ones last
+	sort.SliceStable(flags, func(i, j int) bool {
+		a, b := flags[i].DeniedAt, flags[j].DeniedAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flags)
+}
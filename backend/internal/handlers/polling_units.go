@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// Polling Unit statuses
+const (
+	PUOpen      = "open"
+	PULate      = "late"
+	PUCancelled = "cancelled"
+	PUNotOpened = "not_opened"
+)
+
+var puStatuses = map[string]bool{PUOpen: true, PULate: true, PUCancelled: true, PUNotOpened: true}
+
+// validatePollingUnit normalises and checks a polling unit before it is written
+func validatePollingUnit(pu *models.PollingUnit) error {
+	pu.Code = strings.TrimSpace(pu.Code)
+	pu.Name = strings.TrimSpace(pu.Name)
+	pu.Status = strings.ToLower(strings.TrimSpace(pu.Status))
+
+	if pu.Code == "" || pu.Name == "" || pu.WardID == "" {
+		return fmt.Errorf("code, name and ward_id are required")
+	}
+	if pu.RegisteredVoters < 0 {
+		return fmt.Errorf("registered_voters cannot be negative")
+	}
+	if pu.Status != "" && !puStatuses[pu.Status] {
+		return fmt.Errorf("status must be one of open, late, cancelled, not_opened")
+	}
+	return nil
+}
+
+// statusElection resolves the election that status fields in a registry
+// request apply to. Requests without statuses don't need one.
+func (h *Handler) statusElection(w http.ResponseWriter, r *http.Request, units ...models.PollingUnit) (string, bool) {
+	for _, pu := range units {
+		if pu.Status != "" {
+			return h.electionForSubmission(w, r, "")
+		}
+	}
+	return "", true
+}
+
+// GetPollingUnits returns the polling units in a ward with their status in an election
+func (h *Handler) GetPollingUnits(w http.ResponseWriter, r *http.Request) {
+	wardID := chi.URLParam(r, "wardID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	units, err := h.PollingUnits.PollingUnits(electionID, wardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(units)
+}
+
+// GetPollingUnit returns a polling unit together with its submitted result in an election, if any
+func (h *Handler) GetPollingUnit(w http.ResponseWriter, r *http.Request) {
+	puID, err := strconv.Atoi(chi.URLParam(r, "puID"))
+	if err != nil {
+		http.Error(w, "Polling unit not found", http.StatusNotFound)
+		return
+	}
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	pu, err := h.PollingUnits.PollingUnit(electionID, puID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Polling unit not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		models.PollingUnit
+		Result *models.PollingUnitResult `json:"result"`
+	}{PollingUnit: pu}
+
+	// Polling unit results aren't versioned, so the public only sees them while
+	// the ward's totals they roll up into are approved as they stand
+	visible := true
+	if publishedOnly(r) {
+		rv, err := h.Reviews.WardReview(electionID, pu.WardID)
+		if err != nil && err != store.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		visible = err == nil && rv.Status == ReviewApproved
+	}
+	if visible {
+		res, err := h.PollingUnits.PollingUnitResult(electionID, pu.ID)
+		switch {
+		case err == nil:
+			response.Result = &res
+		case err != store.ErrNotFound:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreatePollingUnit adds a polling unit to a ward. A status, if given, applies
+// to the election selected by the election_id query parameter (default: active).
+func (h *Handler) CreatePollingUnit(w http.ResponseWriter, r *http.Request) {
+	var pu models.PollingUnit
+	if err := json.NewDecoder(r.Body).Decode(&pu); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePollingUnit(&pu); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.requireWardAccess(w, r, pu.WardID) {
+		return
+	}
+	electionID, ok := h.statusElection(w, r, pu)
+	if !ok {
+		return
+	}
+
+	pu, err := h.PollingUnits.CreatePollingUnit(pu, electionID, currentUserID(r))
+	if !pollingUnitWriteOK(w, err, "create") {
+		return
+	}
+
+	h.logAudit(currentUserID(r), "CREATE_POLLING_UNIT", fmt.Sprintf("Created polling unit %s in ward %s", pu.Code, pu.WardID), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pu)
+}
+
+// UpdatePollingUnit replaces a polling unit's details and, if given, its status
+func (h *Handler) UpdatePollingUnit(w http.ResponseWriter, r *http.Request) {
+	puID, err := strconv.Atoi(chi.URLParam(r, "puID"))
+	if err != nil {
+		http.Error(w, "Invalid polling unit ID", http.StatusBadRequest)
+		return
+	}
+
+	var pu models.PollingUnit
+	if err := json.NewDecoder(r.Body).Decode(&pu); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePollingUnit(&pu); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Both the ward it is in and any ward it moves to must be the caller's
+	if !h.requirePollingUnitAccess(w, r, puID) || !h.requireWardAccess(w, r, pu.WardID) {
+		return
+	}
+	electionID, ok := h.statusElection(w, r, pu)
+	if !ok {
+		return
+	}
+
+	pu.ID = puID
+	pu, err = h.PollingUnits.UpdatePollingUnit(pu, electionID, currentUserID(r))
+	if !pollingUnitWriteOK(w, err, "update") {
+		return
+	}
+
+	h.logAudit(currentUserID(r), "UPDATE_POLLING_UNIT", fmt.Sprintf("Updated polling unit %s", pu.Code), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pu)
+}
+
+// DeletePollingUnit removes a polling unit and its results
+func (h *Handler) DeletePollingUnit(w http.ResponseWriter, r *http.Request) {
+	puID, err := strconv.Atoi(chi.URLParam(r, "puID"))
+	if err != nil {
+		http.Error(w, "Invalid polling unit ID", http.StatusBadRequest)
+		return
+	}
+	if !h.requirePollingUnitAccess(w, r, puID) {
+		return
+	}
+
+	pu, err := h.PollingUnits.DeletePollingUnit(puID, currentUserID(r))
+	if err == store.ErrNotFound {
+		http.Error(w, "Polling unit not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete polling unit: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "DELETE_POLLING_UNIT", fmt.Sprintf("Deleted polling unit %s from ward %s", pu.Code, pu.WardID), r)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportPollingUnits creates or updates polling units in bulk, keyed on code.
+// Accepts a JSON array or, with Content-Type text/csv, a CSV file with the
+// header code,ward_id,name,registered_voters,status. Statuses apply to the
+// election selected by the election_id query parameter. The import is all-or-nothing.
+func (h *Handler) ImportPollingUnits(w http.ResponseWriter, r *http.Request) {
+	var units []models.PollingUnit
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		units, err = parsePollingUnitCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&units)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for i := range units {
+		if err := validatePollingUnit(&units[i]); err != nil {
+			http.Error(w, fmt.Sprintf("Row %d (%s): %v", i+1, units[i].Code, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if !allJurisdictions(r) && !h.requireImportAccess(w, r, units) {
+		return
+	}
+	electionID, ok := h.statusElection(w, r, units...)
+	if !ok {
+		return
+	}
+
+	err = h.PollingUnits.ImportPollingUnits(units, electionID, currentUserID(r))
+	if importErr, ok := err.(*store.ImportError); ok {
+		http.Error(w, importErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "IMPORT_POLLING_UNITS", fmt.Sprintf("Imported %d polling units", len(units)), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"imported": len(units)})
+}
+
+// requireImportAccess checks the caller is assigned to every ward an import
+// touches, including the current wards of existing units it would move
+func (h *Handler) requireImportAccess(w http.ResponseWriter, r *http.Request, units []models.PollingUnit) bool {
+	wards := make(map[string]bool)
+	codes := make([]string, len(units))
+	for i, pu := range units {
+		wards[pu.WardID] = true
+		codes[i] = pu.Code
+	}
+	existing, err := h.PollingUnits.PollingUnitWards(codes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	for _, wardID := range existing {
+		wards[wardID] = true
+	}
+	for wardID := range wards {
+		if !h.requireWardAccess(w, r, wardID) {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePollingUnitCSV(body io.Reader) ([]models.PollingUnit, error) {
+	records, err := csv.NewReader(body).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty CSV")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"code", "ward_id", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var units []models.PollingUnit
+	for n, record := range records[1:] {
+		pu := models.PollingUnit{
+			Code:   field(record, "code"),
+			WardID: field(record, "ward_id"),
+			Name:   field(record, "name"),
+			Status: field(record, "status"),
+		}
+		if v := field(record, "registered_voters"); v != "" {
+			pu.RegisteredVoters, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid registered_voters %q", n+1, v)
+			}
+		}
+		units = append(units, pu)
+	}
+	return units, nil
+}
+
+// SubmitPollingUnitStatus records whether a polling unit opened, opened late,
+// was cancelled or never opened in an election
+func (h *Handler) SubmitPollingUnitStatus(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ElectionID    string `json:"election_id"`
+		PollingUnitID int    `json:"polling_unit_id"`
+		Status        string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	if !h.requirePollingUnitAccess(w, r, payload.PollingUnitID) {
+		return
+	}
+
+	payload.Status = strings.ToLower(strings.TrimSpace(payload.Status))
+	if !puStatuses[payload.Status] {
+		http.Error(w, "status must be one of open, late, cancelled, not_opened", http.StatusBadRequest)
+		return
+	}
+
+	err := h.PollingUnits.SetPollingUnitStatus(electionID, payload.PollingUnitID, payload.Status, currentUserID(r))
+	if err == store.ErrNotFound {
+		http.Error(w, "Polling unit not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save polling unit status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logAudit(currentUserID(r), "SUBMIT_POLLING_UNIT_STATUS", fmt.Sprintf("Set polling unit %d to %s (election %s)", payload.PollingUnitID, payload.Status, electionID), r)
+	w.WriteHeader(http.StatusOK)
+}
+
+// SubmitPollingUnitResults handles vote counts for a single polling unit and
+// rolls the ward's totals up from all of its polling units.
+func (h *Handler) SubmitPollingUnitResults(w http.ResponseWriter, r *http.Request) {
+	var payload models.PollingUnitResult
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+
+	pu, err := h.PollingUnits.PollingUnit(electionID, payload.PollingUnitID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Polling unit not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	wardID := pu.WardID
+	if !h.requireWardAccess(w, r, wardID) {
+		return
+	}
+	if pu.Status == PUCancelled {
+		http.Error(w, "Cannot submit results for a cancelled polling unit", http.StatusUnprocessableEntity)
+		return
+	}
+	if payload.AccreditedVoters > pu.RegisteredVoters && pu.RegisteredVoters > 0 {
+		http.Error(w, fmt.Sprintf("accredited_voters (%d) exceeds registered voters (%d)", payload.AccreditedVoters, pu.RegisteredVoters), http.StatusUnprocessableEntity)
+		return
+	}
+
+	ward, err := h.Wards.Ward(wardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	allowed, err := h.Parties.AreaCouncilParties(electionID, ward.AreaCouncilID)
+	if err != nil {
+		http.Error(w, "Party configuration not found for "+ward.AreaCouncilID, http.StatusInternalServerError)
+		return
+	}
+	partyResults, err := validateResults(payload.ValidVotes, payload.RejectedVotes, payload.VotesCast, payload.PartyResults, allowed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	payload.ElectionID = electionID
+	payload.PartyResults = partyResults
+	if err := h.PollingUnits.SavePollingUnitResult(payload, currentUserID(r)); err != nil {
+		http.Error(w, "Failed to save results: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.logAudit(currentUserID(r), "SUBMIT_POLLING_UNIT_RESULTS", fmt.Sprintf("Submitted results for polling unit %d in ward %s (election %s)", payload.PollingUnitID, wardID, electionID), r)
+	h.publishWardUpdate(electionID, wardID, SectionPollingUnits, 0)
+	w.WriteHeader(http.StatusOK)
+}
+
+// pollingUnitWriteOK reports a failed registry write, returning false if there was one
+func pollingUnitWriteOK(w http.ResponseWriter, err error, action string) bool {
+	switch err {
+	case nil:
+		return true
+	case store.ErrConflict:
+		http.Error(w, "A polling unit with this code already exists", http.StatusConflict)
+	case store.ErrNotFound:
+		http.Error(w, "Failed to "+action+" polling unit: unknown polling unit or ward", http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to "+action+" polling unit: "+err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+func TestGetWards(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	reportIncident(s, "w1", "Ballot box snatched", "high")
+
+	var wards []models.WardDetail
+	s.get("/area-councils/amac/wards", &wards)
+	if len(wards) != 2 || wards[0].ID != "w1" || wards[1].ID != "w2" {
+		t.Fatalf("got wards %+v, want w1 and w2", wards)
+	}
+	w1, w2 := wards[0], wards[1]
+	if w1.PartyResults["APC"] != 300 || w1.VotesCast != 510 || w1.TurnoutPercent != 5.1 {
+		t.Errorf("w1 = %+v, want APC 300 and 510 votes cast (5.1%% turnout)", w1)
+	}
+	if w1.IncidentCount != 1 || w1.ReviewStatus != ReviewDraft {
+		t.Errorf("w1 has %d incidents in review %q, want 1 in draft", w1.IncidentCount, w1.ReviewStatus)
+	}
+	if w2.ReviewStatus != "" || w2.PartyResults == nil || len(w2.PartyResults) != 0 {
+		t.Errorf("unreported w2 = %+v, want no review status and no party results", w2)
+	}
+
+	s.get("/area-councils/fct/wards", &wards)
+	if len(wards) != 0 {
+		t.Errorf("unknown Area Council has %d wards, want none", len(wards))
+	}
+}
+
+func TestGetWardDetails(t *testing.T) {
+	s := newTestServer(t)
+	// Results without any logistics still make the ward reported
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+
+	var ward models.WardDetail
+	s.get("/wards/w1", &ward)
+	if ward.LgaID != "amac" || ward.RegisteredVoters != 10000 || ward.ValidVotes != 500 || ward.PartyResults["PDP"] != 200 {
+		t.Errorf("w1 = %+v, want amac with 500 valid votes and PDP 200", ward)
+	}
+	if ward.StartCategory != "" || ward.ReviewStatus != ReviewDraft {
+		t.Errorf("w1 start %q in review %q, want no start time in draft", ward.StartCategory, ward.ReviewStatus)
+	}
+
+	var earlier models.WardDetail
+	s.get("/wards/w1?election_id=e0", &earlier)
+	if earlier.ValidVotes != 0 || len(earlier.PartyResults) != 0 {
+		t.Errorf("w1 in e0 = %+v, want no results", earlier)
+	}
+
+	s.do(http.MethodGet, "/wards/w9", nil, http.StatusNotFound)
+	s.do(http.MethodGet, "/wards/w1?election_id=e9", nil, http.StatusNotFound)
+}
+
+func TestGetAreaCouncils(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.do(http.MethodPost, "/submit/results", results("w2", 100, 150, 5), http.StatusOK)
+	reportIncident(s, "w2", "Ballot box snatched", "high")
+	reportIncident(s, "w3", "Ballot box snatched", "low")
+
+	var summaries []models.LGASummary
+	s.get("/area-councils", &summaries)
+	if len(summaries) != 2 || summaries[0].ID != "amac" || summaries[1].ID != "bwari" {
+		t.Fatalf("got %+v, want amac and bwari", summaries)
+	}
+	amac, bwari := summaries[0], summaries[1]
+	if amac.Wards != 2 || amac.WardsReported != 2 || amac.RegisteredVoters != 18000 || amac.VotesCast != 765 {
+		t.Errorf("amac = %+v, want both wards reported with 765 votes cast of 18000", amac)
+	}
+	if amac.PartyResults["APC"] != 400 || amac.PartyResults["PDP"] != 350 || amac.LeadingParty != "APC" {
+		t.Errorf("amac parties = %v led by %s, want APC 400 ahead of PDP 350", amac.PartyResults, amac.LeadingParty)
+	}
+	if amac.IncidentCount != 1 || bwari.IncidentCount != 1 {
+		t.Errorf("incidents = %d in amac and %d in bwari, want 1 each", amac.IncidentCount, bwari.IncidentCount)
+	}
+	if bwari.WardsReported != 0 || len(bwari.PartyResults) != 0 {
+		t.Errorf("bwari = %+v, want nothing reported", bwari)
+	}
+}
+
+func TestGetDashboardStats(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.do(http.MethodPost, "/submit/observer-access", map[string]interface{}{
+		"ward_id": "w3", "permitted_to_observe": false, "denial_reason": "Turned away at the gate",
+	}, http.StatusOK)
+
+	var stats struct {
+		TotalLGAs         int      `json:"totalLGAs"`
+		TotalWards        int      `json:"totalWards"`
+		WardsReported     int      `json:"wardsReported"`
+		LGAsReported      int      `json:"lgasReported"`
+		DeniedAccessCount int      `json:"deniedAccessCount"`
+		OutcomesAtRisk    []string `json:"outcomesAtRisk"`
+	}
+	s.get("/dashboard/stats", &stats)
+	if stats.TotalLGAs != 2 || stats.TotalWards != 3 || stats.WardsReported != 2 || stats.LGAsReported != 2 {
+		t.Errorf("stats = %+v, want 2 of 3 wards reported across both Area Councils", stats)
+	}
+	if stats.DeniedAccessCount != 1 || stats.OutcomesAtRisk == nil {
+		t.Errorf("stats = %+v, want one denial and no outcomes at risk", stats)
+	}
+
+	var flags []models.DeniedAccessFlag
+	s.get("/red-flags/denied-access", &flags)
+	if len(flags) != 1 || flags[0].ID != "w3" || flags[0].AreaCouncilID != "bwari" || flags[0].DeniedAt == nil {
+		t.Errorf("flags = %+v, want w3 in bwari with a denial time", flags)
+	}
+	s.get("/red-flags/denied-access?area_council_id=amac", &flags)
+	if len(flags) != 0 {
+		t.Errorf("amac flags = %+v, want none", flags)
+	}
+}
+
+func TestPollingUnitReads(t *testing.T) {
+	s := newTestServer(t)
+	var pu models.PollingUnit
+	rec := s.do(http.MethodPost, "/polling-units", map[string]interface{}{
+		"code": "FC/01/01/001", "name": "Area 1 Primary School", "ward_id": "w1", "registered_voters": 700,
+	}, http.StatusCreated)
+	if err := json.Unmarshal(rec.Body.Bytes(), &pu); err != nil {
+		t.Fatal(err)
+	}
+	s.do(http.MethodPost, "/polling-units", map[string]interface{}{
+		"code": "FC/01/01/001", "name": "Duplicate", "ward_id": "w1",
+	}, http.StatusConflict)
+	s.do(http.MethodPost, "/submit/polling-unit-results", map[string]interface{}{
+		"polling_unit_id": pu.ID, "valid_votes": 90, "rejected_votes": 2, "votes_cast": 92,
+		"party_results": map[string]int{"APC": 60, "PDP": 30},
+	}, http.StatusOK)
+
+	var units []models.PollingUnit
+	s.get("/wards/w1/polling-units", &units)
+	if len(units) != 1 || units[0].Code != "FC/01/01/001" || units[0].Status != PUNotOpened {
+		t.Errorf("units = %+v, want FC/01/01/001 not opened", units)
+	}
+
+	var got struct {
+		models.PollingUnit
+		Result *models.PollingUnitResult `json:"result"`
+	}
+	path := "/polling-units/" + strconv.Itoa(pu.ID)
+	s.get(path, &got)
+	if got.Result == nil || got.Result.PartyResults["APC"] != 60 {
+		t.Errorf("polling unit result = %+v, want APC 60", got.Result)
+	}
+	s.get(path+"?election_id=e0", &got)
+	if got.Result != nil {
+		t.Errorf("polling unit result in e0 = %+v, want none", got.Result)
+	}
+
+	// The ward's totals are rolled up from its polling units
+	var ward models.WardDetail
+	s.get("/wards/w1", &ward)
+	if ward.VotesCast != 92 || ward.PartyResults["PDP"] != 30 {
+		t.Errorf("w1 = %+v, want the polling unit's 92 votes cast", ward)
+	}
+
+	s.do(http.MethodGet, "/polling-units/999", nil, http.StatusNotFound)
+	s.do(http.MethodGet, "/polling-units/abc", nil, http.StatusNotFound)
+}
+
+func TestElectionReads(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	reportIncident(s, "w1", "Ballot box snatched", "high")
+
+	var elections []models.Election
+	s.get("/elections", &elections)
+	if len(elections) != 2 || elections[0].ID != "e1" || elections[1].ID != "e0" {
+		t.Errorf("elections = %+v, want e1 then e0", elections)
+	}
+	var e models.Election
+	s.get("/elections/e0", &e)
+	if e.Status != ElectionConcluded {
+		t.Errorf("e0 = %+v, want concluded", e)
+	}
+	s.do(http.MethodGet, "/elections/e9", nil, http.StatusNotFound)
+
+	var comparison []models.WardElectionFigures
+	s.get("/wards/w1/compare", &comparison)
+	if len(comparison) != 2 || comparison[0].ElectionID != "e0" || comparison[1].ElectionID != "e1" {
+		t.Fatalf("comparison = %+v, want e0 then e1", comparison)
+	}
+	if comparison[0].Reported || !comparison[1].Reported {
+		t.Errorf("reported = %t in e0 and %t in e1, want only e1", comparison[0].Reported, comparison[1].Reported)
+	}
+	if f := comparison[1]; f.PartyResults["APC"] != 300 || f.IncidentCount != 1 || f.TurnoutPercent != 5.1 {
+		t.Errorf("e1 figures = %+v, want APC 300, one incident and 5.1%% turnout", f)
+	}
+
+	s.get("/wards/w1/compare?elections=e1", &comparison)
+	if len(comparison) != 1 || comparison[0].ElectionID != "e1" {
+		t.Errorf("comparison = %+v, want e1 only", comparison)
+	}
+	s.do(http.MethodGet, "/wards/w9/compare", nil, http.StatusNotFound)
+}
+
+func TestReviewFlow(t *testing.T) {
+	s := newTestServer(t)
+	s.store.Assign(2, "", "w1")
+	s.as(2, auth.RoleEditor)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.do(http.MethodPost, "/wards/w1/review/submit", nil, http.StatusOK)
+	s.do(http.MethodPost, "/wards/w2/review/submit", nil, http.StatusForbidden)
+
+	var queue []models.WardReview
+	s.get("/reviews", &queue)
+	if len(queue) != 1 || queue[0].WardID != "w1" || queue[0].SubmittedBy == nil || *queue[0].SubmittedBy != 2 {
+		t.Fatalf("queue = %+v, want w1 submitted by user 2", queue)
+	}
+
+	// Submitters can't approve their own work
+	s.do(http.MethodPost, "/wards/w1/review/approve", nil, http.StatusForbidden)
+	s.as(1, auth.RoleAdmin)
+	s.do(http.MethodPost, "/wards/w1/review/reject", nil, http.StatusBadRequest)
+	s.do(http.MethodPost, "/wards/w1/review/approve", nil, http.StatusOK)
+	s.do(http.MethodPost, "/wards/w1/review/approve", nil, http.StatusConflict)
+	s.do(http.MethodPost, "/wards/w3/review/approve", nil, http.StatusNotFound)
+
+	var rv models.WardReview
+	s.get("/wards/w1/review", &rv)
+	if rv.Status != ReviewApproved || rv.ReviewedVersion == nil || *rv.ReviewedVersion != 1 || rv.WardName != "City Centre" {
+		t.Errorf("review = %+v, want version 1 of City Centre approved", rv)
+	}
+	if len(rv.History) != 2 || rv.History[1].FromStatus != ReviewSubmitted || rv.History[1].ToStatus != ReviewApproved {
+		t.Errorf("history = %+v, want submitted then approved", rv.History)
+	}
+
+	s.get("/reviews", &queue)
+	if len(queue) != 0 {
+		t.Errorf("queue = %+v, want it empty after approval", queue)
+	}
+	s.get("/reviews?status=approved&area_council_id=amac", &queue)
+	if len(queue) != 1 {
+		t.Errorf("approved in amac = %+v, want w1", queue)
+	}
+	s.do(http.MethodGet, "/wards/w2/review", nil, http.StatusNotFound)
+	s.do(http.MethodGet, "/reviews?status=closed", nil, http.StatusBadRequest)
+}
+
+func TestAnonymousReadsArePublishedOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, "/submit/results", results("w1", 300, 200, 10), http.StatusOK)
+	s.do(http.MethodPost, "/wards/w1/review/submit", nil, http.StatusOK)
+	s.do(http.MethodPost, "/wards/w1/review/approve", nil, http.StatusOK)
+	s.do(http.MethodPost, "/submit/results", results("w2", 100, 150, 5), http.StatusOK)
+
+	// Changes since the approval wait for review; the public keeps the approved figures
+	s.do(http.MethodPost, "/submit/results", results("w1", 310, 200, 10), http.StatusOK)
+
+	var pu models.PollingUnit
+	rec := s.do(http.MethodPost, "/polling-units", map[string]interface{}{
+		"code": "FC/01/02/001", "name": "Garki Model School", "ward_id": "w2",
+	}, http.StatusCreated)
+	if err := json.Unmarshal(rec.Body.Bytes(), &pu); err != nil {
+		t.Fatal(err)
+	}
+	s.do(http.MethodPost, "/submit/polling-unit-results", map[string]interface{}{
+		"polling_unit_id": pu.ID, "valid_votes": 10, "votes_cast": 10, "party_results": map[string]int{"LP": 10},
+	}, http.StatusOK)
+
+	s.as(0, "")
+	var wards []models.WardDetail
+	s.get("/area-councils/amac/wards", &wards)
+	if len(wards) != 2 || wards[0].PartyResults["APC"] != 300 || wards[0].ReviewStatus != ReviewApproved {
+		t.Errorf("public w1 = %+v, want APC 300 as approved", wards[0])
+	}
+	if wards[1].VotesCast != 0 || wards[1].ReviewStatus != "" {
+		t.Errorf("public w2 = %+v, want its draft hidden", wards[1])
+	}
+
+	var ward models.WardDetail
+	s.get("/wards/w1", &ward)
+	if ward.PartyResults["APC"] != 300 {
+		t.Errorf("public w1 APC = %d, want the approved 300", ward.PartyResults["APC"])
+	}
+
+	var summaries []models.LGASummary
+	s.get("/area-councils", &summaries)
+	if amac := summaries[0]; amac.WardsReported != 1 || amac.PartyResults["APC"] != 300 || amac.PartyResults["PDP"] != 200 {
+		t.Errorf("public amac = %+v, want only w1 as approved", amac)
+	}
+
+	var stats struct {
+		WardsReported int `json:"wardsReported"`
+	}
+	s.get("/dashboard/stats", &stats)
+	if stats.WardsReported != 1 {
+		t.Errorf("public wards reported = %d, want 1", stats.WardsReported)
+	}
+
+	var comparison []models.WardElectionFigures
+	s.get("/wards/w2/compare", &comparison)
+	for _, f := range comparison {
+		if f.Reported {
+			t.Errorf("public w2 reported in %s, want no published results", f.ElectionID)
+		}
+	}
+
+	var unit struct {
+		Result *models.PollingUnitResult `json:"result"`
+	}
+	path := "/polling-units/" + strconv.Itoa(pu.ID)
+	s.get(path, &unit)
+	if unit.Result != nil {
+		t.Errorf("public polling unit result = %+v, want it hidden until w2 is approved", unit.Result)
+	}
+
+	// Signed in, the pending figures show
+	s.as(1, auth.RoleAdmin)
+	s.get("/wards/w1", &ward)
+	if ward.PartyResults["APC"] != 310 || ward.ReviewStatus != ReviewDraft {
+		t.Errorf("w1 = APC %d in %q, want the pending 310 in draft", ward.PartyResults["APC"], ward.ReviewStatus)
+	}
+	s.get(path, &unit)
+	if unit.Result == nil {
+		t.Error("signed in, the polling unit result is hidden")
+	}
+
+	s.do(http.MethodPost, "/wards/w2/review/submit", nil, http.StatusOK)
+	s.do(http.MethodPost, "/wards/w2/review/approve", nil, http.StatusOK)
+	s.as(0, "")
+	s.get(path, &unit)
+	if unit.Result == nil {
+		t.Error("public polling unit result hidden after w2 was approved")
+	}
+}
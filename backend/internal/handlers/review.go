@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/middleware"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// Review statuses of a ward submission
+const (
+	ReviewDraft     = "draft"
+	ReviewSubmitted = "submitted"
+	ReviewApproved  = "approved"
+	ReviewRejected  = "rejected"
+)
+
+var reviewStatuses = map[string]bool{ReviewDraft: true, ReviewSubmitted: true, ReviewApproved: true, ReviewRejected: true}
+
+// reviewTransitions lists the moves available through the review endpoints.
+// Changing the data itself returns a submission to draft from any state.
+var reviewTransitions = map[string][]string{
+	ReviewDraft:     {ReviewSubmitted},
+	ReviewSubmitted: {ReviewApproved, ReviewRejected},
+}
+
+func canTransitionReview(from, to string) bool {
+	for _, next := range reviewTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isAuthenticated reports whether the request carries a valid user token
+func isAuthenticated(r *http.Request) bool {
+	userID, _ := r.Context().Value(middleware.UserKey).(string)
+	return userID != ""
+}
+
+// publishedOnly reports whether the caller may only see approved submissions.
+// Anonymous callers may, and see a submission changed since its approval as it
+// was approved; signed-in users also see drafts and items pending review.
+func publishedOnly(r *http.Request) bool {
+	return !isAuthenticated(r)
+}
+
+// GetReviewQueue lists ward submissions by review status, defaulting to those awaiting review
+func (h *Handler) GetReviewQueue(w http.ResponseWriter, r *http.Request) {
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	status := strings.ToLower(r.URL.Query().Get("status"))
+	if status == "" {
+		status = ReviewSubmitted
+	}
+	if !reviewStatuses[status] {
+		http.Error(w, "status must be one of draft, submitted, approved, rejected", http.StatusBadRequest)
+		return
+	}
+
+	queue, err := h.Reviews.ReviewQueue(electionID, status, r.URL.Query().Get("area_council_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
+}
+
+// GetWardReview returns a ward's review state and the history of its review decisions
+func (h *Handler) GetWardReview(w http.ResponseWriter, r *http.Request) {
+	wardID := chi.URLParam(r, "wardID")
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	rv, err := h.Reviews.WardReview(electionID, wardID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Ward has no submission in this election", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rv)
+}
+
+// SubmitWardForReview hands a ward's draft submission to the reviewers
+func (h *Handler) SubmitWardForReview(w http.ResponseWriter, r *http.Request) {
+	h.changeWardReview(w, r, ReviewSubmitted)
+}
+
+// ApproveWardResult publishes a ward's submission
+func (h *Handler) ApproveWardResult(w http.ResponseWriter, r *http.Request) {
+	h.changeWardReview(w, r, ReviewApproved)
+}
+
+// RejectWardResult sends a ward's submission back to its editors with a reason
+func (h *Handler) RejectWardResult(w http.ResponseWriter, r *http.Request) {
+	h.changeWardReview(w, r, ReviewRejected)
+}
+
+func (h *Handler) changeWardReview(w http.ResponseWriter, r *http.Request, to string) {
+	wardID := chi.URLParam(r, "wardID")
+
+	var payload struct {
+		ElectionID string `json:"election_id"`
+		Reason     string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if to == ReviewRejected && payload.Reason == "" {
+		http.Error(w, "reason is required when rejecting a submission", http.StatusBadRequest)
+		return
+	}
+
+	electionID, ok := h.electionForSubmission(w, r, payload.ElectionID)
+	if !ok {
+		return
+	}
+	// Reviewers work across jurisdictions; editors can only hand in their own wards
+	if to == ReviewSubmitted && !h.requireWardAccess(w, r, wardID) {
+		return
+	}
+
+	userID := currentUserID(r)
+	role, _ := r.Context().Value(middleware.RoleKey).(string)
+	var refusal string
+	refusalCode := http.StatusConflict
+	before, err := h.Reviews.ChangeWardReview(electionID, wardID, to, payload.Reason, userID, func(rv models.WardReview) bool {
+		if !canTransitionReview(rv.Status, to) {
+			refusal = fmt.Sprintf("Cannot move a %s submission to %s", rv.Status, to)
+			return false
+		}
+		// Whoever submitted a result for review can't also approve it, unless they are an admin
+		if to == ReviewApproved && role != auth.RoleAdmin && rv.SubmittedBy != nil && *rv.SubmittedBy == userID {
+			refusal = "A submission must be approved by someone other than its submitter"
+			refusalCode = http.StatusForbidden
+			return false
+		}
+		return true
+	})
+	if err == store.ErrNotFound {
+		http.Error(w, "Ward has no submission in this election", http.StatusNotFound)
+		return
+	}
+	if err == store.ErrConflict {
+		http.Error(w, refusal, refusalCode)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update review status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	from, version := before.Status, before.Version
+
+	// The approval event carries the figures being approved
+	var approved models.WardResultVersion
+	if to == ReviewApproved && version > 0 {
+		if approved, err = h.Results.WardVersion(electionID, wardID, version); err != nil {
+			http.Error(w, "Failed to load the version approved: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	details := fmt.Sprintf("Ward %s (election %s) review %s -> %s", wardID, electionID, from, to)
+	if payload.Reason != "" {
+		details += ": " + payload.Reason
+	}
+	h.logAudit(userID, "REVIEW_WARD_RESULT", details, r)
+
+	// Approval is published separately so subscribers can follow verified results only
+	eventType := events.WardResultUpdated
+	if to == ReviewApproved {
+		eventType = events.WardResultApproved
+	}
+	data := wardUpdateData("review", version)
+	data["review_status"] = to
+	if to == ReviewApproved {
+		addApprovedFigures(data, approved.Snapshot)
+	}
+	events.Publish(events.Event{
+		Type:          eventType,
+		ElectionID:    electionID,
+		AreaCouncilID: h.wardAreaCouncil(wardID),
+		WardID:        wardID,
+		Data:          data,
+	})
+
+	rv, err := h.Reviews.WardReview(electionID, wardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rv)
+}
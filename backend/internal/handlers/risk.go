@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+// isLateStart reports whether collation started late or had not started
+func isLateStart(collationStartTime string) bool {
+	return collationStartTime == "not_started" || collationStartTime == "9_12am"
+}
+
+// wardRiskInput builds the risk engine input for a ward result
+func wardRiskInput(result models.WardResult, reported bool, incidents []risk.IncidentCount) risk.Input {
+	return risk.Input{
+		Reported:        reported,
+		ObserverDenied:  accessDenied(result.ObserverPermitted),
+		SecurityPresent: result.SecurityPresent,
+		LateStart:       isLateStart(result.CollationStartTime),
+		Integrity:       wardIntegrity(result),
+		Incidents:       incidents,
+	}
+}
+
+func wardIntegrity(result models.WardResult) models.WardIntegrity {
+	return models.WardIntegrity{
+		EC8BSubmitted:       result.EC8BSubmitted,
+		EC8CCollated:        result.EC8CCollated,
+		CSRVSDone:           result.CSRVSDone,
+		VotesAnnounced:      result.VotesAnnounced,
+		AgentsCountersigned: result.AgentsCountersigned,
+		EC60EDisplayed:      result.EC60EDisplayed,
+	}
+}
+
+// GetRiskWeights returns the weights the risk engine uses for an election
+func (h *Handler) GetRiskWeights(w http.ResponseWriter, r *http.Request) {
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	weights, err := h.Elections.RiskWeights(electionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weights)
+}
+
+// UpdateRiskWeights replaces the risk engine weights for an election
+func (h *Handler) UpdateRiskWeights(w http.ResponseWriter, r *http.Request) {
+	electionID, ok := h.electionForRequest(w, r, "")
+	if !ok {
+		return
+	}
+
+	// The body replaces the weights whole; anything it leaves out counts for nothing
+	var weights risk.Weights
+	if err := json.NewDecoder(r.Body).Decode(&weights); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := weights.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if weights.IncidentSeverity == nil {
+		weights.IncidentSeverity = map[string]int{}
+	}
+	if weights.IncidentType == nil {
+		weights.IncidentType = map[string]int{}
+	}
+
+	weightsJSON, err := json.Marshal(weights)
+	if err != nil {
+		http.Error(w, "Invalid weights", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Elections.SetRiskWeights(electionID, weights); err != nil {
+		http.Error(w, "Failed to update risk weights: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "UPDATE_RISK_WEIGHTS", fmt.Sprintf("Election %s: %s", electionID, weightsJSON), r)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+func TestUpdateRiskWeights(t *testing.T) {
+	s := newTestServer(t)
+
+	weights := risk.DefaultWeights()
+	weights.IncidentType = map[string]int{"violence": 3}
+	delete(weights.IncidentSeverity, "low")
+	s.do(http.MethodPut, "/risk/weights", weights, http.StatusOK)
+
+	// Keys left out of the maps are gone, not filled back in from the defaults
+	var got risk.Weights
+	s.get("/risk/weights", &got)
+	if len(got.IncidentType) != 1 || got.IncidentType["violence"] != 3 {
+		t.Errorf("incidentType = %v, want only violence: 3", got.IncidentType)
+	}
+	if _, ok := got.IncidentSeverity["low"]; ok || len(got.IncidentSeverity) != 2 {
+		t.Errorf("incidentSeverity = %v, want medium and high only", got.IncidentSeverity)
+	}
+
+	// Leaving a map out entirely clears it too
+	s.do(http.MethodPut, "/risk/weights", map[string]interface{}{
+		"observerDenied": 2, "thresholds": weights.Thresholds,
+	}, http.StatusOK)
+	got = risk.Weights{}
+	s.get("/risk/weights", &got)
+	if got.ObserverDenied != 2 || got.NoSecurity != 0 || len(got.IncidentType) != 0 || len(got.IncidentSeverity) != 0 {
+		t.Errorf("weights = %+v, want only observerDenied set", got)
+	}
+
+}
+
+func TestUpdateRiskWeightsRejected(t *testing.T) {
+	thresholds := risk.DefaultWeights().Thresholds
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+	}{
+		{"negative weight", map[string]interface{}{"noSecurity": -1, "thresholds": thresholds}},
+		{"negative severity", map[string]interface{}{"incidentSeverity": map[string]int{"high": -5}, "thresholds": thresholds}},
+		{"negative type", map[string]interface{}{"incidentType": map[string]int{"fraud": -1}, "thresholds": thresholds}},
+		{"no thresholds", map[string]interface{}{"observerDenied": 3}},
+		{"descending thresholds", map[string]interface{}{"thresholds": risk.Thresholds{Low: 5, Medium: 3, High: 6, Critical: 8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.do(http.MethodPut, "/risk/weights", tt.payload, http.StatusBadRequest)
+			var got risk.Weights
+			s.get("/risk/weights", &got)
+			if got.ObserverDenied != risk.DefaultWeights().ObserverDenied || len(got.IncidentType) != 3 {
+				t.Errorf("rejected update was saved: %+v", got)
+			}
+		})
+	}
+}
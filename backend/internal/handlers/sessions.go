@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/middleware"
+)
+
+// RefreshToken exchanges a refresh token for a new access token and refresh
+// token. Each refresh token works once; presenting one a second time ends the
+// session, since it means someone else has a copy.
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.RefreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	tokens, userID, err := auth.Refresh(h.Sessions, payload.RefreshToken)
+	switch err {
+	case nil:
+	case auth.ErrRefreshTokenReused:
+		h.logAudit(userID, "REFRESH_TOKEN_REUSED", "A used refresh token was presented again; the session was revoked", r)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	case auth.ErrInvalidRefreshToken:
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	default:
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout ends the caller's session. Its refresh token stops working and the
+// access token used for this request is rejected from now on.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*auth.Claims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := auth.Logout(h.Sessions, claims); err != nil {
+		http.Error(w, "Failed to log out: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "LOGOUT", "User logged out", r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserSessions lists a user's sessions, most recent first
+func (h *Handler) GetUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.Sessions.UserSessions(userID, 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeUserSessions signs a user out everywhere, e.g. when their phone is lost
+func (h *Handler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	user, ok := h.user(w, userID)
+	if !ok {
+		return
+	}
+
+	n, err := h.Sessions.RevokeUserSessions(userID, auth.RevokedByAdmin)
+	if err != nil {
+		http.Error(w, "Failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "REVOKE_USER_SESSIONS", fmt.Sprintf("Revoked %d sessions of user %s", n, user.Username), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"revoked": n})
+}
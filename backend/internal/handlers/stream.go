@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 15 * time.Second
+
+// wardAreaCouncil looks up the Area Council a ward belongs to
+func (h *Handler) wardAreaCouncil(wardID string) string {
+	ward, _ := h.Wards.Ward(wardID)
+	return ward.AreaCouncilID
+}
+
+// publishWardUpdate announces that a ward's submission changed
+func (h *Handler) publishWardUpdate(electionID, wardID, section string, version int) {
+	events.Publish(events.Event{
+		Type:          events.WardResultUpdated,
+		ElectionID:    electionID,
+		AreaCouncilID: h.wardAreaCouncil(wardID),
+		WardID:        wardID,
+		Data:          wardUpdateData(section, version),
+	})
+}
+
+// wardUpdateData describes a ward change; version is left out when the
+// change didn't produce a new one
+func wardUpdateData(section string, version int) map[string]interface{} {
+	data := map[string]interface{}{"section": section}
+	if version > 0 {
+		data["version"] = version
+	}
+	return data
+}
+
+// addApprovedFigures adds the totals and party scores of an approved version
+// to an approval event. Only approvals carry figures, since until then they
+// aren't public.
+func addApprovedFigures(data map[string]interface{}, approved models.WardResult) {
+	data["accredited_voters"] = approved.AccreditedVoters
+	data["valid_votes"] = approved.ValidVotes
+	data["rejected_votes"] = approved.RejectedVotes
+	data["votes_cast"] = approved.VotesCast
+	scores := make(map[string]int, len(approved.PartyResults))
+	for party, score := range approved.PartyResults {
+		scores[party] = score
+	}
+	data["party_results"] = scores
+}
+
+// StreamEvents is a Server-Sent Events stream of live updates. Filter with
+// election_id and area_council_id; reconnecting clients resume from the
+// Last-Event-ID header (or last_event_id parameter). A "reset" event means
+// updates were missed and the client should refetch its state.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter := events.Filter{
+		ElectionID:    r.URL.Query().Get("election_id"),
+		AreaCouncilID: r.URL.Query().Get("area_council_id"),
+	}
+	sub, missed, complete := events.Default.Subscribe(lastID, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes
+				return
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
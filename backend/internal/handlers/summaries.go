@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+// Area Council summaries are built from a fixed number of store reads, however
+// many wards, polling units or incidents there are. Everything is loaded up
+// front and aggregated in memory by buildAreaCouncilSummaries.
+
+// summaryWard is a ward together with its visible result, if any
+type summaryWard struct {
+	ID               string
+	AreaCouncilID    string
+	RegisteredVoters int
+	PollingUnits     int
+	Reported         bool
+	Result           models.WardResult
+}
+
+// summaryInput is everything buildAreaCouncilSummaries needs
+type summaryInput struct {
+	Councils   []models.AreaCouncil
+	Wards      []summaryWard
+	Incidents  map[string][]risk.IncidentCount     // Keyed by ward ID
+	Collations map[string]models.AreaCouncilResult // Keyed by Area Council ID
+	Weights    risk.Weights
+}
+
+// loadSummaryInput reads what the Area Council summaries are built from. With
+// published set only approved ward results count.
+func (h *Handler) loadSummaryInput(electionID string, published bool) (summaryInput, error) {
+	in := summaryInput{Collations: make(map[string]models.AreaCouncilResult)}
+	var err error
+	if in.Weights, err = h.Elections.RiskWeights(electionID); err != nil {
+		return in, err
+	}
+	if in.Councils, err = h.Wards.AreaCouncils(); err != nil {
+		return in, err
+	}
+
+	// Wards with their visible results; a ward without one hasn't reported
+	wards, err := h.Wards.Wards("")
+	if err != nil {
+		return in, err
+	}
+	subs, err := h.Results.WardResults(electionID, published)
+	if err != nil {
+		return in, err
+	}
+	results := make(map[string]models.WardResult, len(subs))
+	for _, sub := range subs {
+		results[sub.WardID] = sub.WardResult
+	}
+	for _, ward := range wards {
+		res, reported := results[ward.ID]
+		in.Wards = append(in.Wards, summaryWard{
+			ID:               ward.ID,
+			AreaCouncilID:    ward.AreaCouncilID,
+			RegisteredVoters: ward.RegisteredVoters,
+			PollingUnits:     ward.TotalPollingUnits,
+			Reported:         reported,
+			Result:           res,
+		})
+	}
+
+	if in.Incidents, err = h.Incidents.IncidentCounts(electionID, ""); err != nil {
+		return in, err
+	}
+
+	// Area Council collations, for scoring the collation centres
+	collations, err := h.Collations.AreaCouncilResults(electionID)
+	if err != nil {
+		return in, err
+	}
+	for _, c := range collations {
+		in.Collations[c.AreaCouncilID] = c
+	}
+	return in, nil
+}
+
+// buildAreaCouncilSummaries aggregates the loaded rows into one summary per
+// Area Council, in the order the councils were loaded
+func buildAreaCouncilSummaries(in summaryInput) []models.LGASummary {
+	summaries := make([]models.LGASummary, len(in.Councils))
+	index := make(map[string]int, len(in.Councils))
+	for i, ac := range in.Councils {
+		summaries[i] = models.LGASummary{
+			ID:                ac.ID,
+			Name:              ac.Name,
+			State:             ac.State,
+			PartyResults:      make(map[string]int),
+			IncidentBreakdown: make(map[string]int),
+			RiskLevel:         risk.LevelNone,
+		}
+		index[ac.ID] = i
+	}
+
+	riskInputs := make([][]risk.Input, len(in.Councils))
+	securityCount := make([]int, len(in.Councils))
+	observedCount := make([]int, len(in.Councils))
+
+	for _, ward := range in.Wards {
+		i, ok := index[ward.AreaCouncilID]
+		if !ok {
+			continue
+		}
+		summary := &summaries[i]
+		res := ward.Result
+
+		summary.Wards++
+		summary.RegisteredVoters += ward.RegisteredVoters
+		summary.PollingUnits += ward.PollingUnits
+
+		if ward.Reported {
+			summary.WardsReported++
+			summary.AccreditedVoters += res.AccreditedVoters
+			summary.VotesCast += res.VotesCast
+			summary.ValidVotes += res.ValidVotes
+			summary.RejectedVotes += res.RejectedVotes
+			summary.CancelledPUs += res.CancelledPUs
+			summary.LostVoters += res.CancelledPUVoters
+
+			if res.SecurityPresent {
+				securityCount[i]++
+			}
+			if isLateStart(res.CollationStartTime) {
+				summary.LateStartCount++
+			}
+			if accessDenied(res.ObserverPermitted) {
+				summary.DeniedAccessCount++
+			}
+			if accessPermitted(res.ObserverPermitted) {
+				observedCount[i]++
+			}
+			for party, score := range res.PartyResults {
+				summary.PartyResults[party] += score
+			}
+		}
+
+		incidents := in.Incidents[ward.ID]
+		for _, inc := range incidents {
+			summary.IncidentCount += inc.Count
+			summary.IncidentBreakdown[inc.Type] += inc.Count
+		}
+
+		riskInputs[i] = append(riskInputs[i], wardRiskInput(res, ward.Reported, incidents))
+	}
+
+	for i := range summaries {
+		summary := &summaries[i]
+		if summary.Wards > 0 {
+			summary.SecurityPresent = int(float64(securityCount[i]) / float64(summary.Wards) * 100)
+			summary.ObserverCoverage = int(float64(observedCount[i]) / float64(summary.Wards) * 100)
+		}
+
+		// The collation centre is scored like a ward once it has reported
+		if collation, ok := in.Collations[summary.ID]; ok {
+			summary.CollationDenied = accessDenied(collation.ObserverPermitted)
+			riskInputs[i] = append(riskInputs[i], collationRiskInput(collation))
+		}
+
+		if len(riskInputs[i]) > 0 {
+			assessment := in.Weights.AssessAreaCouncil(riskInputs[i])
+			summary.RiskLevel = assessment.Level
+			summary.ComplianceScore = assessment.ComplianceScore
+		}
+
+		if summary.RegisteredVoters > 0 {
+			summary.TurnoutPercent = float64(summary.VotesCast) / float64(summary.RegisteredVoters) * 100
+		}
+		summary.LeadingParty, summary.MarginOfLead = marginOfLead(summary.PartyResults)
+		summary.OutcomeAtRisk = outcomeAtRisk(summary.MarginOfLead, summary.LostVoters)
+	}
+
+	return summaries
+}
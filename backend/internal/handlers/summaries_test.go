@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+func TestBuildAreaCouncilSummaries(t *testing.T) {
+	in := summaryInput{
+		Councils: []models.AreaCouncil{{ID: "amac", Name: "AMAC"}, {ID: "bwari", Name: "Bwari"}},
+		Wards: []summaryWard{
+			{ID: "w1", AreaCouncilID: "amac", RegisteredVoters: 1000, PollingUnits: 4, Reported: true,
+				Result: models.WardResult{VotesCast: 160, ValidVotes: 150, PartyResults: map[string]int{"APC": 100, "PDP": 50}}},
+			{ID: "w2", AreaCouncilID: "amac", RegisteredVoters: 600, PollingUnits: 2, Reported: true,
+				Result: models.WardResult{VotesCast: 90, ValidVotes: 90, PartyResults: map[string]int{"APC": 20, "LP": 70}}},
+			// Unreported wards count towards the totals, but not their figures
+			{ID: "w3", AreaCouncilID: "amac", RegisteredVoters: 400, PollingUnits: 3,
+				Result: models.WardResult{VotesCast: 999, PartyResults: map[string]int{"PDP": 999}}},
+			{ID: "w4", AreaCouncilID: "bwari", RegisteredVoters: 800, PollingUnits: 5},
+			{ID: "w5", AreaCouncilID: "gone", RegisteredVoters: 50, Reported: true},
+		},
+		Incidents: map[string][]risk.IncidentCount{
+			"w1": {{Type: "violence", Severity: "high", Count: 2}, {Type: "logistics", Severity: "low", Count: 1}},
+			"w3": {{Type: "violence", Severity: "medium", Count: 1}},
+		},
+		Weights: risk.DefaultWeights(),
+	}
+
+	summaries := buildAreaCouncilSummaries(in)
+	if len(summaries) != 2 || summaries[0].ID != "amac" || summaries[1].ID != "bwari" {
+		t.Fatalf("got %+v, want amac and bwari", summaries)
+	}
+
+	amac := summaries[0]
+	if amac.Wards != 3 || amac.WardsReported != 2 || amac.RegisteredVoters != 2000 || amac.PollingUnits != 9 {
+		t.Errorf("amac has %d wards (%d reported), %d voters and %d polling units, want 3 (2), 2000 and 9",
+			amac.Wards, amac.WardsReported, amac.RegisteredVoters, amac.PollingUnits)
+	}
+	if amac.VotesCast != 250 || amac.ValidVotes != 240 || amac.TurnoutPercent != 12.5 {
+		t.Errorf("amac has %d votes cast, %d valid and %.1f%% turnout, want 250, 240 and 12.5%%",
+			amac.VotesCast, amac.ValidVotes, amac.TurnoutPercent)
+	}
+	if fmt.Sprint(amac.PartyResults) != "map[APC:120 LP:70 PDP:50]" {
+		t.Errorf("amac parties = %v, want APC 120, LP 70, PDP 50", amac.PartyResults)
+	}
+	if amac.LeadingParty != "APC" || amac.MarginOfLead != 50 {
+		t.Errorf("amac led by %s by %d, want APC by 50", amac.LeadingParty, amac.MarginOfLead)
+	}
+	if amac.IncidentCount != 4 || fmt.Sprint(amac.IncidentBreakdown) != "map[logistics:1 violence:3]" {
+		t.Errorf("amac has %d incidents %v, want 4: 3 violence and 1 logistics", amac.IncidentCount, amac.IncidentBreakdown)
+	}
+
+	bwari := summaries[1]
+	if bwari.Wards != 1 || bwari.WardsReported != 0 || bwari.VotesCast != 0 || len(bwari.PartyResults) != 0 {
+		t.Errorf("bwari = %+v, want one ward and nothing reported", bwari)
+	}
+	if bwari.IncidentCount != 0 || bwari.LeadingParty != "" {
+		t.Errorf("bwari has %d incidents led by %q, want none", bwari.IncidentCount, bwari.LeadingParty)
+	}
+}
+
+// syntheticSummaryInput builds what loadSummaryInput would return for
+// six Area Councils with the given number of wards, each with a result,
+// three incidents and five party scores.
+func syntheticSummaryInput(wards int) summaryInput {
+	in := summaryInput{
+		Incidents:  make(map[string][]risk.IncidentCount),
+		Collations: make(map[string]models.AreaCouncilResult),
+		Weights:    risk.DefaultWeights(),
+	}
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("ac-%d", i)
+		in.Councils = append(in.Councils, models.AreaCouncil{ID: id, Name: id, State: "FCT"})
+		in.Collations[id] = models.AreaCouncilResult{AreaCouncilID: id, SecurityPresent: true}
+	}
+
+	denied := false
+	for i := 0; i < wards; i++ {
+		id := fmt.Sprintf("ward-%d", i)
+		in.Wards = append(in.Wards, summaryWard{
+			ID:               id,
+			AreaCouncilID:    in.Councils[i%len(in.Councils)].ID,
+			RegisteredVoters: 10000,
+			PollingUnits:     20,
+			Reported:         i%4 != 0,
+			Result: models.WardResult{
+				AccreditedVoters:   4000,
+				ValidVotes:         3800,
+				RejectedVotes:      200,
+				VotesCast:          4000,
+				CollationStartTime: "9_12am",
+				SecurityPresent:    i%3 == 0,
+				EC8BSubmitted:      true,
+				ObserverPermitted:  &denied,
+				CancelledPUs:       1,
+				CancelledPUVoters:  500,
+				PartyResults:       map[string]int{"APC": 1500, "PDP": 1200, "LP": 800, "NNPP": 200, "ADC": 100},
+			},
+		})
+		in.Incidents[id] = []risk.IncidentCount{
+			{Type: "violence", Severity: "high", Count: 1},
+			{Type: "logistics", Severity: "low", Count: 2},
+			{Type: "fraud", Severity: "medium", Count: 1},
+		}
+	}
+	return in
+}
+
+// BenchmarkBuildAreaCouncilSummaries measures the in-memory aggregation behind
+// GetAreaCouncils. The store work is a fixed number of reads however large
+// the FCT gets (polling units are already rolled up into ward results),
+// so this is the only part that grows; ns/ward should stay flat across sizes.
+func BenchmarkBuildAreaCouncilSummaries(b *testing.B) {
+	for _, wards := range []int{62, 620, 6200} {
+		in := syntheticSummaryInput(wards)
+		b.Run(fmt.Sprintf("wards=%d", wards), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buildAreaCouncilSummaries(in)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*wards), "ns/ward")
+		})
+	}
+}
+
+// readCounter counts the store reads loadSummaryInput makes. Each SQL
+// implementation is a fixed number of queries, so a count that doesn't grow
+// with the data means the query count doesn't either.
+type readCounter struct {
+	reads int
+}
+
+type countedElections struct {
+	store.ElectionStore
+	c *readCounter
+}
+
+func (s countedElections) RiskWeights(electionID string) (risk.Weights, error) {
+	s.c.reads++
+	return s.ElectionStore.RiskWeights(electionID)
+}
+
+type countedWards struct {
+	store.WardStore
+	c *readCounter
+}
+
+func (s countedWards) Wards(lgaID string) ([]models.Ward, error) {
+	s.c.reads++
+	return s.WardStore.Wards(lgaID)
+}
+
+func (s countedWards) AreaCouncils() ([]models.AreaCouncil, error) {
+	s.c.reads++
+	return s.WardStore.AreaCouncils()
+}
+
+type countedResults struct {
+	store.ResultStore
+	c *readCounter
+}
+
+func (s countedResults) WardResults(electionID string, published bool) ([]store.WardSubmission, error) {
+	s.c.reads++
+	return s.ResultStore.WardResults(electionID, published)
+}
+
+type countedIncidents struct {
+	store.IncidentStore
+	c *readCounter
+}
+
+func (s countedIncidents) IncidentCounts(electionID, wardID string) (map[string][]risk.IncidentCount, error) {
+	s.c.reads++
+	return s.IncidentStore.IncidentCounts(electionID, wardID)
+}
+
+type countedCollations struct {
+	store.CollationStore
+	c *readCounter
+}
+
+func (s countedCollations) AreaCouncilResults(electionID string) ([]models.AreaCouncilResult, error) {
+	s.c.reads++
+	return s.CollationStore.AreaCouncilResults(electionID)
+}
+
+// countedHandler serves from stores that count their reads into c
+func countedHandler(stores store.Stores, c *readCounter) *Handler {
+	stores.Elections = countedElections{stores.Elections, c}
+	stores.Wards = countedWards{stores.Wards, c}
+	stores.Results = countedResults{stores.Results, c}
+	stores.Incidents = countedIncidents{stores.Incidents, c}
+	stores.Collations = countedCollations{stores.Collations, c}
+	return New(stores)
+}
+
+// syntheticSummaryStore stores six Area Councils with the given number of
+// wards, each with the given number of open polling units. Three in four
+// wards have reported a result and an incident.
+func syntheticSummaryStore(tb testing.TB, wards, unitsPerWard int) *store.MemoryStore {
+	m := store.NewMemoryStore()
+	m.AddElection(models.Election{ID: "e1", Status: ElectionActive})
+	for i := 0; i < 6; i++ {
+		m.AddAreaCouncil(models.AreaCouncil{ID: fmt.Sprintf("ac-%d", i), State: "FCT"})
+	}
+
+	var units []models.PollingUnit
+	for i := 0; i < wards; i++ {
+		id := fmt.Sprintf("ward-%d", i)
+		m.AddWard(models.Ward{ID: id, AreaCouncilID: fmt.Sprintf("ac-%d", i%6), TotalPollingUnits: unitsPerWard, RegisteredVoters: 10000})
+		for j := 0; j < unitsPerWard; j++ {
+			units = append(units, models.PollingUnit{Code: fmt.Sprintf("%s/%03d", id, j), Name: "PU", WardID: id, Status: PUOpen})
+		}
+		if i%4 == 0 {
+			continue
+		}
+		_, err := m.SaveWardResult("e1", id, SectionResults, 0, nil, func(r *models.WardResult) error {
+			r.ValidVotes, r.VotesCast = 3800, 4000
+			r.PartyResults = map[string]int{"APC": 1500, "PDP": 1200, "LP": 1100}
+			return nil
+		})
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := m.CreateIncident(models.Incident{ElectionID: "e1", WardID: id, Type: "violence", Severity: "high"}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := m.ImportPollingUnits(units, "e1", 0); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// BenchmarkLoadSummaryInput times the store reads behind GetAreaCouncils and
+// counts them. reads/op must stay the same as wards and polling units grow.
+func BenchmarkLoadSummaryInput(b *testing.B) {
+	fixedReads := 0
+	for _, size := range []struct{ wards, unitsPerWard int }{{62, 5}, {62, 20}, {620, 5}, {620, 20}} {
+		m := syntheticSummaryStore(b, size.wards, size.unitsPerWard)
+		b.Run(fmt.Sprintf("wards=%d/pus=%d", size.wards, size.wards*size.unitsPerWard), func(b *testing.B) {
+			c := &readCounter{}
+			h := countedHandler(m.Stores(), c)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := h.loadSummaryInput("e1", false); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reads := c.reads / b.N
+			if fixedReads == 0 {
+				fixedReads = reads
+			}
+			if reads != fixedReads {
+				b.Errorf("%d store reads per load, want %d however large the data", reads, fixedReads)
+			}
+			b.ReportMetric(float64(reads), "reads/op")
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// useTOTP checks a code and marks its time step used, so the same code can't
+// be replayed
+func (h *Handler) useTOTP(u store.TwoFactorUser, code string) bool {
+	step, ok := auth.CheckTOTP(u.Secret, code, time.Now(), u.LastStep)
+	if !ok {
+		return false
+	}
+	used, err := h.Users.UseTOTPStep(u.ID, step)
+	return err == nil && used
+}
+
+// useRecoveryCode spends one of the user's recovery codes
+func (h *Handler) useRecoveryCode(userID int, code string) bool {
+	used, err := h.Users.UseRecoveryCode(userID, auth.HashRecoveryCode(code))
+	return err == nil && used
+}
+
+// confirmTwoFactor runs check, which verifies a code the signed-in caller sent
+// to change their two-factor settings. Wrong codes count as failed logins, as
+// at /login/2fa, so a stolen access token can't be used to guess codes. If the
+// check fails, or the caller is being throttled, it answers and returns false;
+// wrong codes get status and message.
+func (h *Handler) confirmTwoFactor(w http.ResponseWriter, r *http.Request, user store.TwoFactorUser, status int, message string, check func() bool) bool {
+	ip := clientIP(r)
+	block, err := auth.CheckLogin(h.Throttles, user.Username, ip)
+	if err != nil {
+		http.Error(w, "Two-factor authentication is temporarily unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	if block != nil {
+		tooManyLoginAttempts(w, block)
+		return false
+	}
+	if check() {
+		return true
+	}
+
+	h.logAudit(user.ID, "2FA_CODE_FAILED", "Wrong two-factor code sent to change two-factor settings", r)
+	if h.countLoginFailure(r, user.ID, user.Username, ip) {
+		tooManyLoginAttempts(w, &auth.LoginBlock{RetryAfter: auth.LoginLockout, Locked: true})
+		return false
+	}
+	http.Error(w, message, status)
+	return false
+}
+
+// recoveryCodes generates a fresh set of recovery codes with their hashes
+func recoveryCodes() (codes, hashes []string, err error) {
+	codes, err = auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// VerifyTwoFactorLogin finishes a login for a user with two-factor
+// authentication, trading the token from Login and a code from their
+// authenticator app, or one of their recovery codes, for a session. Wrong
+// codes count as failed logins.
+func (h *Handler) VerifyTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		TwoFactorToken string `json:"two_factor_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.Code == "" && payload.RecoveryCode == "" {
+		http.Error(w, "code or recovery_code is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.ValidateTwoFactorToken(payload.TwoFactorToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired two-factor token; log in again", http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(claims.UserID)
+	user, err := h.Users.TwoFactorUser(userID)
+	if err != nil || !user.Active || !user.TwoFactorEnabled {
+		http.Error(w, "Invalid or expired two-factor token; log in again", http.StatusUnauthorized)
+		return
+	}
+
+	ip := clientIP(r)
+	block, err := auth.CheckLogin(h.Throttles, user.Username, ip)
+	if err != nil {
+		http.Error(w, "Login is temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if block != nil {
+		tooManyLoginAttempts(w, block)
+		return
+	}
+
+	if payload.Code != "" {
+		if !h.useTOTP(user, payload.Code) {
+			h.loginFailed(w, r, user.ID, user.Username, ip, "Invalid code")
+			return
+		}
+	} else {
+		if !h.useRecoveryCode(user.ID, payload.RecoveryCode) {
+			h.loginFailed(w, r, user.ID, user.Username, ip, "Invalid recovery code")
+			return
+		}
+		remaining, _ := h.Users.RecoveryCodesLeft(user.ID)
+		h.logAudit(user.ID, "LOGIN_RECOVERY_CODE", fmt.Sprintf("Logged in with a recovery code; %d left", remaining), r)
+	}
+
+	h.completeLogin(w, r, user.User, ip)
+}
+
+// GetTwoFactorStatus says whether the caller has two-factor authentication
+// on, whether their role requires it and how many recovery codes they have left
+func (h *Handler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	user, err := h.Users.TwoFactorUser(currentUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	remaining, err := h.Users.RecoveryCodesLeft(user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":                  user.TwoFactorEnabled,
+		"required":                 auth.TwoFactorRequired(user.Role),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor starts enrolment, generating a secret for the caller's
+// authenticator app. The secret is returned both as text and as an otpauth://
+// URI for the client to show as a QR code. Nothing changes until EnableTwoFactor
+// verifies a code from it; calling this again replaces the pending secret.
+func (h *Handler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := h.Users.TwoFactorUser(currentUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.TwoFactorEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.Users.SetTwoFactorSecret(user.ID, secret); err != nil {
+		http.Error(w, "Failed to start two-factor setup: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(user.Username, secret),
+	})
+}
+
+// EnableTwoFactor finishes enrolment once the caller proves their app works
+// by sending a code from it; wrong codes count as failed logins. It returns their recovery codes, which are only
+// shown here, and a fresh session; every other session is ended, since none
+// of them passed two-factor authentication.
+func (h *Handler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Users.TwoFactorUser(currentUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.TwoFactorEnabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if user.Secret == "" {
+		http.Error(w, "Start two-factor setup first", http.StatusConflict)
+		return
+	}
+	if !h.confirmTwoFactor(w, r, user, http.StatusBadRequest, "Invalid code", func() bool { return h.useTOTP(user, payload.Code) }) {
+		return
+	}
+
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		http.Error(w, "Failed to create recovery codes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.Users.EnableTwoFactor(user.ID, hashes); err != nil {
+		http.Error(w, "Failed to enable two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(user.ID, "ENABLE_2FA", "User enabled two-factor authentication", r)
+	if _, err := h.Sessions.RevokeUserSessions(user.ID, auth.RevokedTwoFactorChanged); err != nil {
+		http.Error(w, "Two-factor authentication enabled, but failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limits := auth.LimitsFor(user.Role, user.MustChangePassword, true)
+	tokens, err := auth.StartSession(h.Sessions, user.ID, user.Role, limits, clientIP(r), r.UserAgent())
+	if err != nil {
+		http.Error(w, "Two-factor authentication enabled, but failed to start a new session; please log in again", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		auth.TokenPair
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{tokens, codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off for the caller, who
+// must confirm with their password and a current code; wrong attempts count as
+// failed logins. Users whose role
+// requires two-factor authentication can't turn it off.
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Users.TwoFactorUser(currentUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !user.TwoFactorEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+	if auth.TwoFactorRequired(user.Role) {
+		http.Error(w, "Your role requires two-factor authentication", http.StatusForbidden)
+		return
+	}
+	confirmed := h.confirmTwoFactor(w, r, user, http.StatusForbidden, "Invalid password or code", func() bool {
+		return auth.CheckPasswordHash(payload.Password, user.Password) && h.useTOTP(user, payload.Code)
+	})
+	if !confirmed {
+		return
+	}
+
+	if err := h.Users.ClearTwoFactor(user.ID); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(user.ID, "DISABLE_2FA", "User disabled two-factor authentication", r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, e.g. when
+// they are running out. A current code is required, and wrong codes count as
+// failed logins.
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Users.TwoFactorUser(currentUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !user.TwoFactorEnabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return
+	}
+	if !h.confirmTwoFactor(w, r, user, http.StatusForbidden, "Invalid code", func() bool { return h.useTOTP(user, payload.Code) }) {
+		return
+	}
+
+	codes, hashes, err := recoveryCodes()
+	if err == nil {
+		err = h.Users.ReplaceRecoveryCodes(user.ID, hashes)
+	}
+	if err != nil {
+		http.Error(w, "Failed to create recovery codes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(user.ID, "REGENERATE_RECOVERY_CODES", "User replaced their recovery codes", r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor turns off a user's two-factor authentication, e.g. after
+// they lose their phone and their recovery codes, and ends their sessions. If
+// their role requires it they will have to enrol again when they next log in.
+func (h *Handler) ResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	user, ok := h.user(w, userID)
+	if !ok {
+		return
+	}
+
+	// Admins can't strip their own second factor; another admin has to
+	if userID == currentUserID(r) {
+		http.Error(w, "You cannot reset your own two-factor authentication", http.StatusConflict)
+		return
+	}
+
+	if err := h.Users.ClearTwoFactor(userID); err != nil {
+		http.Error(w, "Failed to reset two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "RESET_2FA", fmt.Sprintf("Reset two-factor authentication of user %s", user.Username), r)
+	if _, err := h.Sessions.RevokeUserSessions(userID, auth.RevokedTwoFactorChanged); err != nil {
+		http.Error(w, "Two-factor authentication reset, but failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
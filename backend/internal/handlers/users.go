@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// minPasswordLength is the shortest password accepted
+const minPasswordLength = 8
+
+// validatePassword checks a new password against the password policy
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// temporaryPassword generates a password for an admin reset
+func temporaryPassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// userIDParam reads the userID URL parameter, writing a 400 if it is invalid
+func userIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return userID, true
+}
+
+// user loads a user, writing a 404 if there is no such user
+func (h *Handler) user(w http.ResponseWriter, userID int) (models.User, bool) {
+	u, err := h.Users.User(userID)
+	if err == store.ErrNotFound {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return u, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return u, false
+	}
+	return u, true
+}
+
+// UpdateUserRole changes a user's role. Their sessions are ended so the new
+// role applies straight away rather than when their access token expires.
+func (h *Handler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !auth.ValidRole(payload.Role) {
+		http.Error(w, "role must be one of "+strings.Join(auth.Roles, ", "), http.StatusBadRequest)
+		return
+	}
+	actorID := currentUserID(r)
+	if userID == actorID {
+		http.Error(w, "You cannot change your own role", http.StatusConflict)
+		return
+	}
+
+	user, err := h.Users.SetUserRole(userID, payload.Role)
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case store.ErrConflict:
+		http.Error(w, "The last active admin cannot be demoted", http.StatusConflict)
+		return
+	default:
+		http.Error(w, "Failed to update role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.Role == payload.Role {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.logAudit(actorID, "UPDATE_USER_ROLE", fmt.Sprintf("Changed role of user %s from %s to %s", user.Username, user.Role, payload.Role), r)
+	auditChanges(r, "user", strconv.Itoa(userID), map[string]models.FieldChange{"role": {From: user.Role, To: payload.Role}})
+	if _, err := h.Sessions.RevokeUserSessions(userID, auth.RevokedRoleChanged); err != nil {
+		http.Error(w, "Role changed, but failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setUsersActive activates or deactivates users, audits each one that changed
+// and ends the sessions of deactivated ones. It returns the usernames that changed.
+func (h *Handler) setUsersActive(w http.ResponseWriter, r *http.Request, userIDs []int, active bool) ([]string, bool) {
+	actorID := currentUserID(r)
+	if !active {
+		for _, id := range userIDs {
+			if id == actorID {
+				http.Error(w, "You cannot deactivate your own account", http.StatusConflict)
+				return nil, false
+			}
+		}
+	}
+
+	changed, err := h.Users.SetUsersActive(userIDs, active, actorID)
+	if err == store.ErrConflict {
+		http.Error(w, "The last active admin cannot be deactivated", http.StatusConflict)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to update users: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	action, verb := "REACTIVATE_USER", "Reactivated"
+	if !active {
+		action, verb = "DEACTIVATE_USER", "Deactivated"
+	}
+	var usernames []string
+	for _, u := range changed {
+		usernames = append(usernames, u.Username)
+		h.logAudit(actorID, action, fmt.Sprintf("%s user %s", verb, u.Username), r)
+	}
+	if !active {
+		for _, u := range changed {
+			if _, err := h.Sessions.RevokeUserSessions(u.ID, auth.RevokedDeactivated); err != nil {
+				http.Error(w, "Users deactivated, but failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+				return nil, false
+			}
+		}
+	}
+	return usernames, true
+}
+
+// DeactivateUser blocks a user from logging in and ends their sessions.
+// Their submissions and audit history are kept.
+func (h *Handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.user(w, userID); !ok {
+		return
+	}
+	if _, ok := h.setUsersActive(w, r, []int{userID}, false); !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReactivateUser lets a deactivated user log in again
+func (h *Handler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.user(w, userID); !ok {
+		return
+	}
+	if _, ok := h.setUsersActive(w, r, []int{userID}, true); !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeactivateUsers offboards several users at once, e.g. temporary field staff
+// after an election
+func (h *Handler) DeactivateUsers(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(payload.UserIDs) == 0 {
+		http.Error(w, "user_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	usernames, ok := h.setUsersActive(w, r, payload.UserIDs, false)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deactivated": usernames})
+}
+
+// ResetUserPassword sets a temporary password, generated unless one is given,
+// and ends the user's sessions. They must choose a new password after logging
+// in with it. A generated password is only shown in this response.
+func (h *Handler) ResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	var payload struct {
+		Password string `json:"password"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	generated := payload.Password == ""
+	if generated {
+		password, err := temporaryPassword()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		payload.Password = password
+	} else if err := validatePassword(payload.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hash, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.Users.SetPassword(userID, hash, true)
+	if err == store.ErrNotFound {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "RESET_USER_PASSWORD", fmt.Sprintf("Reset password of user %s", user.Username), r)
+	if _, err := h.Sessions.RevokeUserSessions(userID, auth.RevokedPasswordChanged); err != nil {
+		http.Error(w, "Password reset, but failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !generated {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"temporary_password": payload.Password})
+}
+
+// ChangePassword lets users change their own password. Every existing session
+// is ended, including the current one, and a fresh session is returned.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(payload.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.NewPassword == payload.CurrentPassword {
+		http.Error(w, "The new password must be different from the current one", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUserID(r)
+	user, err := h.Users.User(userID)
+	if err != nil || !user.Active {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !auth.CheckPasswordHash(payload.CurrentPassword, user.Password) {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	hash, err := auth.HashPassword(payload.NewPassword)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.Users.SetPassword(userID, hash, false); err != nil {
+		http.Error(w, "Failed to change password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(userID, "CHANGE_PASSWORD", "User changed their password", r)
+	if _, err := h.Sessions.RevokeUserSessions(userID, auth.RevokedPasswordChanged); err != nil {
+		http.Error(w, "Password changed, but failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := auth.StartSession(h.Sessions, user.ID, user.Role, auth.LimitsFor(user.Role, false, user.TwoFactorEnabled), clientIP(r), r.UserAgent())
+	if err != nil {
+		http.Error(w, "Password changed, but failed to start a new session; please log in again", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// UnlockUserLogin lifts a login lockout on a user, e.g. after someone else
+// guessed at their password, and clears their failed attempts
+func (h *Handler) UnlockUserLogin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+	user, ok := h.user(w, userID)
+	if !ok {
+		return
+	}
+
+	locked, err := auth.UnlockLogin(h.Throttles, user.Username)
+	if err != nil {
+		http.Error(w, "Failed to unlock user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		h.logAudit(currentUserID(r), "LOGIN_UNLOCK", fmt.Sprintf("Unlocked logins for user %s", user.Username), r)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"was_locked": locked})
+}
+
+// UnlockLoginIP lifts a login lockout on an address, e.g. a collation centre
+// whose devices all share one
+func (h *Handler) UnlockLoginIP(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		IP string `json:"ip"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if net.ParseIP(payload.IP) == nil {
+		http.Error(w, "ip must be an IP address", http.StatusBadRequest)
+		return
+	}
+
+	locked, err := auth.UnlockIP(h.Throttles, payload.IP)
+	if err != nil {
+		http.Error(w, "Failed to unlock address: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		h.logAudit(currentUserID(r), "LOGIN_UNLOCK", fmt.Sprintf("Unlocked logins from address %s", payload.IP), r)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"was_locked": locked})
+}
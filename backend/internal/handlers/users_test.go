@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// openSession starts a session for a user straight in the store and returns its ID
+func openSession(s *testServer, userID int, id string) string {
+	s.t.Helper()
+	if err := s.store.CreateSession(models.Session{ID: id, UserID: userID}, "refresh-"+id, time.Hour); err != nil {
+		s.t.Fatal(err)
+	}
+	return id
+}
+
+func sessionRevoked(s *testServer, sessionID string) bool {
+	s.t.Helper()
+	revoked, err := s.store.IsRevoked("", sessionID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return revoked
+}
+
+func TestLastAdminGuards(t *testing.T) {
+	s := newTestServer(t)
+
+	// Admins can't lock themselves out
+	s.do(http.MethodPut, "/users/1/role", map[string]string{"role": auth.RoleEditor}, http.StatusConflict)
+	s.do(http.MethodPost, "/users/1/deactivate", nil, http.StatusConflict)
+	s.do(http.MethodPost, "/users/deactivate", map[string][]int{"user_ids": {2, 1}}, http.StatusConflict)
+
+	// Nor can anyone else take away the only admin
+	s.as(2, auth.RoleAdmin)
+	s.do(http.MethodPut, "/users/1/role", map[string]string{"role": auth.RoleEditor}, http.StatusConflict)
+	s.do(http.MethodPost, "/users/1/deactivate", nil, http.StatusConflict)
+	s.do(http.MethodPost, "/users/deactivate", map[string][]int{"user_ids": {1}}, http.StatusConflict)
+	if u, _ := s.store.User(1); u.Role != auth.RoleAdmin || !u.Active {
+		t.Fatalf("last admin = %s, active %v; want an active admin", u.Role, u.Active)
+	}
+
+	// With a second admin either one may go, but not both
+	second, err := s.store.CreateUser("admin2", "hash", auth.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.do(http.MethodPost, "/users/deactivate", map[string][]int{"user_ids": {1, second.ID}}, http.StatusConflict)
+	s.do(http.MethodPut, "/users/1/role", map[string]string{"role": auth.RoleEditor}, http.StatusNoContent)
+	if u, _ := s.store.User(1); u.Role != auth.RoleEditor {
+		t.Errorf("role = %s, want %s", u.Role, auth.RoleEditor)
+	}
+	s.do(http.MethodPut, "/users/"+strconv.Itoa(second.ID)+"/role", map[string]string{"role": auth.RoleEditor}, http.StatusConflict)
+	s.do(http.MethodPost, "/users/"+strconv.Itoa(second.ID)+"/deactivate", nil, http.StatusConflict)
+
+	s.do(http.MethodPut, "/users/99/role", map[string]string{"role": auth.RoleEditor}, http.StatusNotFound)
+	s.do(http.MethodPut, "/users/1/role", map[string]string{"role": "superuser"}, http.StatusBadRequest)
+}
+
+func TestRoleChangeEndsSessions(t *testing.T) {
+	s := newTestServer(t)
+	session := openSession(s, 2, "editor-laptop")
+
+	// Setting the role a user already has leaves their sessions alone
+	s.do(http.MethodPut, "/users/2/role", map[string]string{"role": auth.RoleEditor}, http.StatusNoContent)
+	if sessionRevoked(s, session) {
+		t.Fatal("session revoked without a role change")
+	}
+	s.do(http.MethodPut, "/users/2/role", map[string]string{"role": auth.RoleReviewer}, http.StatusNoContent)
+	if !sessionRevoked(s, session) {
+		t.Error("session still open after role change")
+	}
+}
+
+func TestDeactivateUser(t *testing.T) {
+	s := newTestServer(t)
+	session := openSession(s, 2, "editor-laptop")
+
+	s.do(http.MethodPost, "/users/2/deactivate", nil, http.StatusNoContent)
+	u, err := s.store.User(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Active || u.DeactivatedAt == nil {
+		t.Errorf("user active = %v, deactivated at %v; want deactivated", u.Active, u.DeactivatedAt)
+	}
+	if !sessionRevoked(s, session) {
+		t.Error("session still open after deactivation")
+	}
+
+	s.do(http.MethodPost, "/users/2/reactivate", nil, http.StatusNoContent)
+	if u, _ := s.store.User(2); !u.Active || u.DeactivatedAt != nil {
+		t.Errorf("user active = %v, deactivated at %v; want active", u.Active, u.DeactivatedAt)
+	}
+	// Reactivating doesn't bring old sessions back
+	if !sessionRevoked(s, session) {
+		t.Error("session reopened after reactivation")
+	}
+
+	s.do(http.MethodPost, "/users/99/deactivate", nil, http.StatusNotFound)
+	s.do(http.MethodPost, "/users/x/deactivate", nil, http.StatusBadRequest)
+}
+
+func TestDeactivateUsers(t *testing.T) {
+	s := newTestServer(t)
+	var sessions []string
+	var ids []int
+	for _, name := range []string{"field1", "field2"} {
+		u, err := s.store.CreateUser(name, "hash", auth.RoleEditor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+		sessions = append(sessions, openSession(s, u.ID, name+"-phone"))
+	}
+	kept := openSession(s, 2, "editor-laptop")
+
+	var got struct {
+		Deactivated []string `json:"deactivated"`
+	}
+	rec := s.do(http.MethodPost, "/users/deactivate", map[string][]int{"user_ids": ids}, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Deactivated) != 2 || got.Deactivated[0] != "field1" || got.Deactivated[1] != "field2" {
+		t.Errorf("deactivated = %v, want [field1 field2]", got.Deactivated)
+	}
+	for _, session := range sessions {
+		if !sessionRevoked(s, session) {
+			t.Errorf("session %s still open", session)
+		}
+	}
+	if sessionRevoked(s, kept) {
+		t.Error("session of a user who wasn't deactivated was revoked")
+	}
+
+	// Users already deactivated aren't reported again
+	got.Deactivated = nil
+	rec = s.do(http.MethodPost, "/users/deactivate", map[string][]int{"user_ids": ids}, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Deactivated) != 0 {
+		t.Errorf("deactivated again = %v, want none", got.Deactivated)
+	}
+
+	s.do(http.MethodPost, "/users/deactivate", map[string][]int{"user_ids": {}}, http.StatusBadRequest)
+}
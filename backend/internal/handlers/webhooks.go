@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+	"github.com/yiaga/abuja-watch/backend/internal/webhooks"
+)
+
+const (
+	minWebhookSecretLength     = 16
+	defaultWebhookPageSize     = 50
+	maxWebhookDeliveryPageSize = 200
+)
+
+// webhookPayload is what admins send to register or change a subscription
+type webhookPayload struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Secret        string   `json:"secret"`
+	EventTypes    []string `json:"event_types"`
+	AreaCouncilID string   `json:"area_council_id"`
+	Active        *bool    `json:"active"`
+}
+
+// validate checks the subscription. The secret may be left out, in which case
+// one is generated on creation and the current one is kept on update.
+func (p *webhookPayload) validate(areaCouncilExists func(string) bool) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.URL = strings.TrimSpace(p.URL)
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if p.Secret != "" && len(p.Secret) < minWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", minWebhookSecretLength)
+	}
+	if p.EventTypes == nil {
+		p.EventTypes = []string{}
+	}
+	for _, t := range p.EventTypes {
+		if !events.ValidType(t) {
+			return fmt.Errorf("unknown event type %q; expected one of %s", t, strings.Join(events.Types, ", "))
+		}
+	}
+	if p.AreaCouncilID != "" && !areaCouncilExists(p.AreaCouncilID) {
+		return fmt.Errorf("Area Council %s not found", p.AreaCouncilID)
+	}
+	return nil
+}
+
+// newWebhookSecret generates a random signing secret
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// webhookIDParam reads the webhookID URL parameter, writing a 400 if it is invalid
+func webhookIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return webhookID, true
+}
+
+// deliveryIDParam reads the deliveryID URL parameter, writing a 400 if it is invalid
+func deliveryIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	deliveryID, err := strconv.Atoi(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return deliveryID, true
+}
+
+// GetWebhooks lists every webhook subscription. Secrets are never returned.
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.Webhooks.Webhooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// CreateWebhook registers a partner endpoint. The response is the only time
+// the signing secret is shown.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var payload webhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := payload.validate(h.areaCouncilExists); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		payload.Secret = secret
+	}
+	active := payload.Active == nil || *payload.Active
+
+	userID := currentUserID(r)
+	sub, err := h.Webhooks.CreateWebhook(models.WebhookSubscription{
+		Name:          payload.Name,
+		URL:           payload.URL,
+		Secret:        payload.Secret,
+		EventTypes:    payload.EventTypes,
+		AreaCouncilID: payload.AreaCouncilID,
+		Active:        active,
+		CreatedBy:     &userID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sub.Secret = payload.Secret
+
+	h.logAudit(userID, "CREATE_WEBHOOK", fmt.Sprintf("Created webhook %d to %s", sub.ID, sub.URL), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// UpdateWebhook replaces a subscription's settings. Pending deliveries keep
+// going to the new URL; a new secret applies from the next attempt.
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := payload.validate(h.areaCouncilExists); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := h.Webhooks.UpdateWebhook(models.WebhookSubscription{
+		ID:            webhookID,
+		Name:          payload.Name,
+		URL:           payload.URL,
+		Secret:        payload.Secret,
+		EventTypes:    payload.EventTypes,
+		AreaCouncilID: payload.AreaCouncilID,
+	}, payload.Active)
+	if err == store.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	details := fmt.Sprintf("Updated webhook %d to %s (active: %t)", sub.ID, sub.URL, sub.Active)
+	if payload.Secret != "" {
+		details += ", secret rotated"
+	}
+	h.logAudit(currentUserID(r), "UPDATE_WEBHOOK", details, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteWebhook removes a subscription along with its delivery log
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := h.Webhooks.DeleteWebhook(webhookID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "DELETE_WEBHOOK", fmt.Sprintf("Deleted webhook %d to %s", sub.ID, sub.URL), r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries writes deliveries matching the filter, newest first,
+// paginated with page and limit
+func (h *Handler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, f store.WebhookDeliveryFilter) {
+	q := r.URL.Query()
+	page, limit := 1, defaultWebhookPageSize
+	if v, err := strconv.Atoi(q.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > maxWebhookDeliveryPageSize {
+		limit = maxWebhookDeliveryPageSize
+	}
+
+	f.Offset, f.Limit = (page-1)*limit, limit
+	deliveries, total, err := h.Webhooks.WebhookDeliveries(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Deliveries []models.WebhookDelivery `json:"deliveries"`
+		Total      int                      `json:"total"`
+		Page       int                      `json:"page"`
+		Limit      int                      `json:"limit"`
+	}{deliveries, total, page, limit})
+}
+
+// GetWebhookDeliveries is a subscription's delivery log, optionally filtered
+// by status (pending, delivered or dead)
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+	h.listWebhookDeliveries(w, r, store.WebhookDeliveryFilter{
+		SubscriptionID: webhookID,
+		Status:         strings.ToLower(r.URL.Query().Get("status")),
+	})
+}
+
+// GetWebhookDeadLetters lists deliveries that ran out of attempts, across all subscriptions
+func (h *Handler) GetWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.listWebhookDeliveries(w, r, store.WebhookDeliveryFilter{Status: webhooks.StatusDead})
+}
+
+// GetWebhookDelivery returns a single delivery with a log of every attempt
+func (h *Handler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, ok := deliveryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	d, err := h.Webhooks.WebhookDelivery(deliveryID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// RetryWebhookDelivery puts a dead-lettered delivery back in the queue with a
+// fresh set of attempts. Earlier attempts stay in its log.
+func (h *Handler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, ok := deliveryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	d, err := h.Webhooks.RetryWebhookDelivery(deliveryID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err == store.ErrConflict {
+		http.Error(w, fmt.Sprintf("Only dead-lettered deliveries can be retried; this one is %s", d.Status), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to requeue delivery: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(currentUserID(r), "RETRY_WEBHOOK_DELIVERY", fmt.Sprintf("Requeued delivery %d to webhook %d", d.ID, d.SubscriptionID), r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
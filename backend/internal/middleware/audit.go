@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+)
+
+// maxAuditedError caps how much of a failed response's body is kept as the entry's details
+const maxAuditedError = 500
+
+// AuditWrites writes an audit entry for every POST, PUT, PATCH and DELETE once
+// it completes, whether it succeeded or failed. Handlers describe what they did
+// with logAudit and name the entity they changed; requests that don't are
+// logged by method and route. Entries are added to the log with appendEntry.
+// Mount it after RealIP and RequestID, so the entry carries the client's real
+// address and the request ID.
+func AuditWrites(appendEntry func(audit.Entry) (audit.Entry, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &audit.Record{}
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			body := &errorBody{}
+			ww.Tee(body)
+
+			defer func() {
+				if p := recover(); p != nil {
+					writeAudit(appendEntry, r, rec, http.StatusInternalServerError, fmt.Sprint(p))
+					panic(p)
+				}
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				message := ""
+				if status >= http.StatusBadRequest {
+					message = strings.TrimSpace(body.String())
+				}
+				writeAudit(appendEntry, r, rec, status, message)
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(audit.NewContext(r.Context(), rec)))
+		})
+	}
+}
+
+// errorBody keeps the start of a response body
+type errorBody struct {
+	strings.Builder
+}
+
+func (b *errorBody) Write(p []byte) (int, error) {
+	if room := maxAuditedError - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Builder.Write(p[:room])
+		} else {
+			b.Builder.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// writeAudit appends the request's events to the audit log, or a single entry
+// named after the route if the handler recorded none
+func writeAudit(appendEntry func(audit.Entry) (audit.Entry, error), r *http.Request, rec *audit.Record, status int, message string) {
+	route := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			route = pattern
+		}
+		// Without a named entity, the last URL parameter is the likeliest target
+		if rec.EntityType == "" && len(rctx.URLParams.Keys) > 0 {
+			last := len(rctx.URLParams.Keys) - 1
+			rec.EntityType = strings.TrimSuffix(rctx.URLParams.Keys[last], "ID")
+			rec.EntityID = rctx.URLParams.Values[last]
+		}
+	}
+
+	events := rec.Events
+	if len(events) == 0 {
+		events = []audit.Event{{Action: r.Method + " " + route}}
+	}
+
+	var changes string
+	if rec.Changes != nil {
+		if raw, err := json.Marshal(rec.Changes); err == nil {
+			changes = string(raw)
+		}
+	}
+
+	for _, event := range events {
+		entry := audit.Entry{
+			UserID:     rec.UserID,
+			APIKeyID:   rec.APIKeyID,
+			Action:     event.Action,
+			Details:    event.Details,
+			IPAddress:  remoteIP(r),
+			EntityType: rec.EntityType,
+			EntityID:   rec.EntityID,
+			Changes:    changes,
+			RequestID:  chimiddleware.GetReqID(r.Context()),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     status,
+		}
+		if event.UserID != nil {
+			entry.UserID = event.UserID
+		}
+		if message != "" {
+			if entry.Details != "" {
+				entry.Details += "; "
+			}
+			entry.Details += "failed with " + strconv.Itoa(status) + ": " + message
+		}
+		if _, err := appendEntry(entry); err != nil {
+			log.Printf("audit log: %s: %v", entry.Action, err)
+		}
+	}
+}
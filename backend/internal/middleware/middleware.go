@@ -2,52 +2,178 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
 	"github.com/yiaga/abuja-watch/backend/internal/auth"
 )
 
 type contextKey string
 
 const (
-	UserKey contextKey = "user"
-	RoleKey contextKey = "role"
+	UserKey   contextKey = "user"
+	RoleKey   contextKey = "role"
+	ClaimsKey contextKey = "claims"  // *auth.Claims of the access token
+	APIKeyKey contextKey = "api_key" // *auth.APIKey, for requests made with an API key
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+// withClaims stores the caller's identity in the request context, and names
+// them as the actor of the request's audit entry
+func withClaims(r *http.Request, claims *auth.Claims) *http.Request {
+	if rec := audit.FromContext(r.Context()); rec != nil {
+		if id, err := strconv.Atoi(claims.UserID); err == nil {
+			rec.UserID = &id
 		}
+	}
+	ctx := context.WithValue(r.Context(), UserKey, claims.UserID)
+	ctx = context.WithValue(ctx, RoleKey, claims.Role)
+	ctx = context.WithValue(ctx, ClaimsKey, claims)
+	return r.WithContext(ctx)
+}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
-			return
-		}
+// withAPIKey stores the API key a request was made with in its context
+func withAPIKey(r *http.Request, key *auth.APIKey) *http.Request {
+	if rec := audit.FromContext(r.Context()); rec != nil {
+		rec.APIKeyID = &key.ID
+	}
+	return r.WithContext(context.WithValue(r.Context(), APIKeyKey, key))
+}
 
-		claims, err := auth.ValidateJWT(parts[1])
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
+// apiKey returns the API key sent in the X-API-Key header or as a Bearer token
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) == 2 && parts[0] == "Bearer" && auth.IsAPIKey(parts[1]) {
+		return parts[1]
+	}
+	return ""
+}
 
-		ctx := context.WithValue(r.Context(), UserKey, claims.UserID)
-		ctx = context.WithValue(ctx, RoleKey, claims.Role)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
 }
 
-func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userRole, ok := r.Context().Value(RoleKey).(string)
-		if !ok || userRole != role {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
+// AuthMiddleware accepts either a user's access token or an API key. API keys
+// only get past routes guarded by RequirePermission if their scopes allow it.
+func AuthMiddleware(sessions auth.SessionStore, keys auth.APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := apiKey(r); key != "" {
+				k, err := auth.AuthenticateAPIKey(keys, key, remoteIP(r))
+				if err == auth.ErrInvalidAPIKey {
+					http.Error(w, "Invalid API key", http.StatusUnauthorized)
+					return
+				}
+				if err != nil {
+					http.Error(w, "Could not verify API key", http.StatusServiceUnavailable)
+					return
+				}
+				next.ServeHTTP(w, withAPIKey(r, k))
+				return
+			}
+
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := auth.ValidateJWT(parts[1])
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			revoked, err := auth.IsRevoked(sessions, claims)
+			if err != nil {
+				http.Error(w, "Could not verify token", http.StatusServiceUnavailable)
+				return
+			}
+			if revoked {
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, withClaims(r, claims))
+		})
+	}
+}
+
+// OptionalAuth identifies the caller when a valid Bearer token is present and
+// otherwise lets the request through anonymously, for public routes that show
+// more to signed-in users. API keys with the read scope are identified too,
+// but see what anonymous callers see.
+func OptionalAuth(sessions auth.SessionStore, keys auth.APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := apiKey(r); key != "" {
+				if k, err := auth.AuthenticateAPIKey(keys, key, remoteIP(r)); err == nil && k.HasScope(auth.ScopeRead) {
+					r = withAPIKey(r, k)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			parts := strings.Split(r.Header.Get("Authorization"), " ")
+			if len(parts) == 2 && parts[0] == "Bearer" {
+				if claims, err := auth.ValidateJWT(parts[1]); err == nil {
+					if revoked, err := auth.IsRevoked(sessions, claims); err == nil && !revoked && !claims.Limited() {
+						r = withClaims(r, claims)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireFullAccess turns away tokens limited to finishing account setup:
+// after an admin password reset the user can only change their password, and
+// a user whose role requires two-factor authentication can only enrol in it
+func RequireFullAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(ClaimsKey).(*auth.Claims)
+		switch {
+		case claims != nil && claims.PasswordChangeRequired:
+			http.Error(w, "Password change required", http.StatusForbidden)
+		case claims != nil && claims.TwoFactorSetupRequired:
+			http.Error(w, "Two-factor authentication setup required", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r)
 		}
-		next(w, r)
+	})
+}
+
+// RequirePermission allows the request if the user's role, or the API key's
+// scopes, grant the permission
+func RequirePermission(p auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed := false
+			if key, ok := r.Context().Value(APIKeyKey).(*auth.APIKey); ok {
+				allowed = key.Can(p)
+			} else {
+				userRole, _ := r.Context().Value(RoleKey).(string)
+				allowed = auth.Can(userRole, p)
+			}
+			if !allowed {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
 }
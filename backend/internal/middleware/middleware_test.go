@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+)
+
+// issueAPIKey saves a key in the store and returns its secret
+func issueAPIKey(t *testing.T, m *store.MemoryStore, scopes ...string) (string, models.APIKey) {
+	t.Helper()
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := m.CreateAPIKey(models.APIKey{Name: "partner", Prefix: prefix, Scopes: scopes, AreaCouncilIDs: []string{"amac"}}, hash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, k
+}
+
+func TestRequirePermissionWithAPIKey(t *testing.T) {
+	m := store.NewMemoryStore()
+	readKey, _ := issueAPIKey(t, m, auth.ScopeRead)
+	submitKey, _ := issueAPIKey(t, m, auth.ScopeSubmit)
+	revokedKey, revoked := issueAPIKey(t, m, auth.ScopeSubmit)
+	if _, err := m.RevokeAPIKey(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var got *auth.APIKey
+	h := AuthMiddleware(m, m)(RequirePermission(auth.PermSubmitResults)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(APIKeyKey).(*auth.APIKey)
+	})))
+
+	tests := []struct {
+		name, header, value string
+		want                int
+	}{
+		{"submit key in X-API-Key", "X-API-Key", submitKey, http.StatusOK},
+		{"submit key as bearer token", "Authorization", "Bearer " + submitKey, http.StatusOK},
+		{"read key", "X-API-Key", readKey, http.StatusForbidden},
+		{"revoked key", "X-API-Key", revokedKey, http.StatusUnauthorized},
+		{"unknown key", "X-API-Key", auth.APIKeyPrefix + "nonsense", http.StatusUnauthorized},
+		{"no credentials", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		got = nil
+		req := httptest.NewRequest(http.MethodPost, "/submit/results", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if tt.want == http.StatusOK && (got == nil || !got.CoversAreaCouncil("amac")) {
+			t.Errorf("%s: key in context = %+v, want the partner's key", tt.name, got)
+		}
+	}
+}
+
+func TestRequirePermissionKeyIgnoresRole(t *testing.T) {
+	// A key's scopes decide, whatever role the request context carries
+	h := RequirePermission(auth.PermManageUsers)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req = withAPIKey(req, &auth.APIKey{Scopes: auth.Scopes})
+	req = req.WithContext(context.WithValue(req.Context(), RoleKey, auth.RoleAdmin))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req = req.WithContext(context.WithValue(req.Context(), RoleKey, auth.RoleAdmin))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin without a key: status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestOptionalAuthNeedsReadScope(t *testing.T) {
+	m := store.NewMemoryStore()
+	readKey, _ := issueAPIKey(t, m, auth.ScopeRead)
+	submitKey, _ := issueAPIKey(t, m, auth.ScopeSubmit)
+
+	for key, identified := range map[string]bool{readKey: true, submitKey: false, auth.APIKeyPrefix + "nonsense": false} {
+		var got *auth.APIKey
+		h := OptionalAuth(m, m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = r.Context().Value(APIKeyKey).(*auth.APIKey)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/dashboard/stats", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("status %d, want %d", rec.Code, http.StatusOK)
+		}
+		if (got != nil) != identified {
+			t.Errorf("key %.11s: identified = %v, want %v", key, got != nil, identified)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.1, 192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTrustedProxies("10.0.0.1,not-an-ip"); err == nil {
+		t.Error("bad TRUSTED_PROXIES entry was accepted")
+	}
+
+	tests := []struct {
+		name, remote, xff, xRealIP, want string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"direct client forging headers", "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"trusted proxy, X-Real-IP", "10.0.0.1:5000", "", "198.51.100.2", "198.51.100.2"},
+		{"forged hop left of the proxy's", "10.0.0.1:5000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:5000", "198.51.100.1, 192.168.1.1", "", "198.51.100.1"},
+		{"garbage header", "10.0.0.1:5000", "nonsense", "", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		var got string
+		h := RealIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = remoteIP(r) }))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if tt.xRealIP != "" {
+			req.Header.Set("X-Real-IP", tt.xRealIP)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("%s: client address = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
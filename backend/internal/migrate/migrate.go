@@ -0,0 +1,299 @@
+// Package migrate applies the embedded SQL migrations in order, each in its
+// own transaction, and records them in schema_migrations with a checksum of
+// the file, so a migration edited after it ran is caught rather than
+// silently skipped.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockID is the advisory lock key held while migrating, so two servers
+// starting together don't both apply the same migration
+const lockID = 7_042_174
+
+// Migration is one numbered migration
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // Empty when the migration can't be reversed
+	Checksum string // SHA-256 of Up
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// ErrDrift means the database disagrees with the migrations in this build:
+// an applied migration has changed since it ran, or is missing altogether
+var ErrDrift = errors.New("migration drift")
+
+// Load reads the migrations in fsys. Up migrations are named NNN_name.sql and
+// down migrations NNN_name.down.sql.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range names {
+		base := strings.TrimSuffix(file, ".sql")
+		down := strings.HasSuffix(base, ".down")
+		base = strings.TrimSuffix(base, ".down")
+
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must look like 001_description.sql", file)
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, name)
+		}
+		if down {
+			m.Down = string(content)
+		} else {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d (%s) has a down migration but no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock runs fn on a single connection holding the migration lock, after
+// making sure schema_migrations exists
+func withLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func loadApplied(conn *sql.Conn) (map[int]applied, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]applied)
+	for rows.Next() {
+		var version int
+		var a applied
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = a
+	}
+	return done, rows.Err()
+}
+
+// checkDrift fails if an applied migration has changed or is unknown to this build
+func checkDrift(migrations []Migration, done map[int]applied) error {
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	versions := make([]int, 0, len(done))
+	for v := range done {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	for _, v := range versions {
+		m, ok := known[v]
+		if !ok {
+			return fmt.Errorf("%w: migration %d (%s) has been applied but is not in this build", ErrDrift, v, done[v].name)
+		}
+		if m.Checksum != done[v].checksum {
+			return fmt.Errorf("%w: migration %d (%s) has changed since it was applied", ErrDrift, v, m.Name)
+		}
+	}
+	return nil
+}
+
+// run executes a migration's SQL and updates schema_migrations in one transaction
+func run(conn *sql.Conn, script, record string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies every pending migration in order and returns those it applied.
+// It refuses to apply anything if the database has drifted from this build.
+func Up(db *sql.DB, migrations []Migration) ([]Migration, error) {
+	var ran []Migration
+	err := withLock(db, func(conn *sql.Conn) error {
+		done, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkDrift(migrations, done); err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			err := run(conn, m.Up, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+				m.Version, m.Name, m.Checksum)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverses the latest steps applied migrations, newest first, and
+// returns those it reversed. It is meant for development databases.
+func Down(db *sql.DB, migrations []Migration, steps int) ([]Migration, error) {
+	var reversed []Migration
+	err := withLock(db, func(conn *sql.Conn) error {
+		done, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkDrift(migrations, done); err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reversed) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d (%s) has no down migration", m.Version, m.Name)
+			}
+			if err := run(conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version); err != nil {
+				return fmt.Errorf("reversing migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			reversed = append(reversed, m)
+		}
+		return nil
+	})
+	return reversed, err
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases that were migrated by hand before schema_migrations existed
+func Baseline(db *sql.DB, migrations []Migration, version int) ([]Migration, error) {
+	var marked []Migration
+	err := withLock(db, func(conn *sql.Conn) error {
+		done, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if m.Version > version {
+				break
+			}
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			_, err := conn.ExecContext(context.Background(),
+				"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", m.Version, m.Name, m.Checksum)
+			if err != nil {
+				return err
+			}
+			marked = append(marked, m)
+		}
+		return nil
+	})
+	return marked, err
+}
+
+// List reports every migration and when it was applied. It returns ErrDrift
+// along with the list if the database has drifted.
+func List(db *sql.DB, migrations []Migration) ([]Status, error) {
+	var statuses []Status
+	err := withLock(db, func(conn *sql.Conn) error {
+		done, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			s := Status{Migration: m}
+			if a, ok := done[m.Version]; ok {
+				appliedAt := a.appliedAt
+				s.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, s)
+		}
+		return checkDrift(migrations, done)
+	})
+	return statuses, err
+}
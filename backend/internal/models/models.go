@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // AreaCouncil represents an Area Council entity
 type AreaCouncil struct {
@@ -9,22 +12,96 @@ type AreaCouncil struct {
 	State string `json:"state" db:"state"`
 }
 
-type User struct {
-	ID        int    `json:"id" db:"id"`
-	Username  string `json:"username" db:"username"`
-	Password  string `json:"password,omitempty" db:"password_hash"`
-	Role      string `json:"role" db:"role"`
+// Election represents a single election event (general, by-election or re-run)
+type Election struct {
+	ID        string `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`
+	Date      string `json:"date" db:"election_date"` // YYYY-MM-DD
+	Type      string `json:"type" db:"type"`          // general, by_election, re_run
+	Status    string `json:"status" db:"status"`      // upcoming, active, concluded
 	CreatedAt string `json:"created_at" db:"created_at"`
 }
 
+// WardElectionFigures are a ward's headline figures in one election, used for comparisons
+type WardElectionFigures struct {
+	ElectionID       string         `json:"electionId"`
+	ElectionName     string         `json:"electionName"`
+	Date             string         `json:"date"`
+	Reported         bool           `json:"reported"`
+	AccreditedVoters int            `json:"accreditedVoters"`
+	VotesCast        int            `json:"votesCast"`
+	ValidVotes       int            `json:"validVotes"`
+	RejectedVotes    int            `json:"rejectedVotes"`
+	TurnoutPercent   float64        `json:"turnoutPercent"`
+	IncidentCount    int            `json:"incidentCount"`
+	PartyResults     map[string]int `json:"partyResults"`
+}
+
+type User struct {
+	ID                 int        `json:"id" db:"id"`
+	Username           string     `json:"username" db:"username"`
+	Password           string     `json:"password,omitempty" db:"password_hash"`
+	Role               string     `json:"role" db:"role"` // admin, editor or reviewer
+	Active             bool       `json:"active" db:"active"`
+	MustChangePassword bool       `json:"must_change_password" db:"must_change_password"`
+	TwoFactorEnabled   bool       `json:"two_factor_enabled" db:"totp_enabled"`
+	DeactivatedAt      *time.Time `json:"deactivated_at,omitempty" db:"deactivated_at"`
+	CreatedAt          string     `json:"created_at" db:"created_at"`
+}
+
+// Jurisdiction lists the Area Councils and wards a user may submit data for
+type Jurisdiction struct {
+	AreaCouncilIDs []string `json:"area_council_ids"`
+	WardIDs        []string `json:"ward_ids"`
+}
+
+// APIKey is a credential for a machine client. The key itself is only shown when it is created.
+type APIKey struct {
+	ID             int        `json:"id" db:"id"`
+	Name           string     `json:"name" db:"name"`
+	Prefix         string     `json:"prefix" db:"prefix"` // First characters of the key, to tell keys apart
+	Key            string     `json:"key,omitempty"`
+	Scopes         []string   `json:"scopes" db:"scopes"`
+	AreaCouncilIDs []string   `json:"area_council_ids" db:"area_council_ids"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP     string     `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	CreatedBy      *int       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Session is a login, kept alive by refreshing its tokens
+type Session struct {
+	ID              string     `json:"id" db:"id"`
+	UserID          int        `json:"user_id" db:"user_id"`
+	IPAddress       string     `json:"ip_address" db:"ip_address"`
+	UserAgent       string     `json:"user_agent" db:"user_agent"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	LastRefreshedAt *time.Time `json:"last_refreshed_at,omitempty" db:"last_refreshed_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedReason   string     `json:"revoked_reason,omitempty" db:"revoked_reason"`
+}
+
 type AuditLog struct {
-	ID        int    `json:"id" db:"id"`
-	UserID    int    `json:"user_id" db:"user_id"`
-	Username  string `json:"username"` // Joined field
-	Action    string `json:"action" db:"action"`
-	Details   string `json:"details" db:"details"`
-	IPAddress string `json:"ip_address" db:"ip_address"`
-	Timestamp string `json:"timestamp" db:"timestamp"`
+	ID         int             `json:"id" db:"id"`
+	UserID     *int            `json:"user_id" db:"user_id"` // nil for anonymous actions and API keys
+	Username   string          `json:"username"`             // Joined field
+	APIKeyID   *int            `json:"api_key_id,omitempty" db:"api_key_id"`
+	APIKeyName string          `json:"api_key_name,omitempty"` // Joined field
+	Action     string          `json:"action" db:"action"`
+	Details    string          `json:"details" db:"details"`
+	EntityType string          `json:"entity_type,omitempty" db:"entity_type"`
+	EntityID   string          `json:"entity_id,omitempty" db:"entity_id"`
+	Changes    json.RawMessage `json:"changes,omitempty" db:"changes"` // Before and after values by field
+	IPAddress  string          `json:"ip_address" db:"ip_address"`
+	RequestID  string          `json:"request_id,omitempty" db:"request_id"`
+	Method     string          `json:"method,omitempty" db:"method"`
+	Path       string          `json:"path,omitempty" db:"path"`
+	Status     int             `json:"status,omitempty" db:"status"`
+	Timestamp  time.Time       `json:"timestamp" db:"timestamp"`
+	PrevHash   string          `json:"prev_hash,omitempty" db:"prev_hash"` // Empty for entries from before the hash chain
+	Hash       string          `json:"hash,omitempty" db:"hash"`
 }
 
 // LGASummary represents the aggregated data for an Area Council
@@ -43,15 +120,19 @@ type LGASummary struct {
 	WardsReported     int            `json:"wardsReported"`
 	ComplianceScore   int            `json:"complianceScore"`
 	IncidentCount     int            `json:"incidentCount"`
-	DeniedAccessCount int            `json:"deniedAccessCount"`
+	DeniedAccessCount int            `json:"deniedAccessCount"` // Wards where the observer was refused access
 	LateStartCount    int            `json:"lateStartCount"`
 	CancelledPUs      int            `json:"cancelledPUs"`
-	LostVoters        int            `json:"lostVoters"`
-	SecurityPresent   int            `json:"securityPresent"` // Average %
-	ObserverCoverage  int            `json:"observerCoverage"`
+	LostVoters        int            `json:"lostVoters"`       // Registered voters in cancelled PUs
+	SecurityPresent   int            `json:"securityPresent"`  // Average %
+	ObserverCoverage  int            `json:"observerCoverage"` // % of wards where the observer was permitted
+	CollationDenied   bool           `json:"collationDenied"`  // Observer refused access to the Area Council collation
 	RiskLevel         string         `json:"riskLevel"`
 	IncidentBreakdown map[string]int `json:"incidentBreakdown"`
 	PartyResults      map[string]int `json:"partyResults"`
+	LeadingParty      string         `json:"leadingParty"`
+	MarginOfLead      int            `json:"marginOfLead"`
+	OutcomeAtRisk     bool           `json:"outcomeAtRisk"` // Lost voters could overturn the margin of lead
 }
 
 // Ward represents a Ward entity
@@ -63,46 +144,200 @@ type Ward struct {
 	RegisteredVoters  int    `json:"registered_voters" db:"registered_voters"`
 }
 
+// PollingUnit represents a Polling Unit within a Ward
+type PollingUnit struct {
+	ID               int       `json:"id" db:"id"`
+	Code             string    `json:"code" db:"code"`
+	WardID           string    `json:"ward_id" db:"ward_id"`
+	Name             string    `json:"name" db:"name"`
+	RegisteredVoters int       `json:"registered_voters" db:"registered_voters"`
+	Status           string    `json:"status"` // open, late, cancelled, not_opened; per election
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PollingUnitResult represents the vote counts submitted for a Polling Unit
+type PollingUnitResult struct {
+	ElectionID       string         `json:"election_id" db:"election_id"`
+	PollingUnitID    int            `json:"polling_unit_id" db:"polling_unit_id"`
+	AccreditedVoters int            `json:"accredited_voters" db:"accredited_voters"`
+	ValidVotes       int            `json:"valid_votes" db:"valid_votes"`
+	RejectedVotes    int            `json:"rejected_votes" db:"rejected_votes"`
+	VotesCast        int            `json:"votes_cast" db:"votes_cast"`
+	PartyResults     map[string]int `json:"party_results"`
+	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at"`
+}
+
 // WardResult represents the submission data for a ward
 type WardResult struct {
-	WardID              string    `json:"ward_id" db:"ward_id"`
-	ArrivalTime         string    `json:"arrival_time" db:"arrival_time"`
-	CollationStartTime  string    `json:"collation_start_time" db:"collation_start_time"`
-	INECStaff           int       `json:"inec_staff" db:"inec_staff"`
-	SecurityPresent     bool      `json:"security_present" db:"security_present"`
-	PartyAgents         int       `json:"party_agents" db:"party_agents"`
-	EC8BSubmitted       bool      `json:"ec8b_submitted" db:"ec8b_submitted"`
-	EC8CCollated        bool      `json:"ec8c_collated" db:"ec8c_collated"`
-	CSRVSDone           bool      `json:"csrvs_done" db:"csrvs_done"`
-	VotesAnnounced      bool      `json:"votes_announced" db:"votes_announced"`
-	AgentsCountersigned bool      `json:"agents_countersigned" db:"agents_countersigned"`
-	EC60EDisplayed      bool      `json:"ec60e_displayed" db:"ec60e_displayed"`
-	AccreditedVoters    int       `json:"accredited_voters" db:"accredited_voters"`
-	ValidVotes          int       `json:"valid_votes" db:"valid_votes"`
-	RejectedVotes       int       `json:"rejected_votes" db:"rejected_votes"`
-	VotesCast           int       `json:"votes_cast" db:"votes_cast"`
-	UpdatedAt           time.Time `json:"updated_at" db:"updated_at"`
+	ElectionID          string         `json:"election_id" db:"election_id"`
+	WardID              string         `json:"ward_id" db:"ward_id"`
+	ArrivalTime         string         `json:"arrival_time" db:"arrival_time"`
+	CollationStartTime  string         `json:"collation_start_time" db:"collation_start_time"`
+	INECStaff           int            `json:"inec_staff" db:"inec_staff"`
+	SecurityPresent     bool           `json:"security_present" db:"security_present"`
+	PartyAgents         int            `json:"party_agents" db:"party_agents"`
+	EC8BSubmitted       bool           `json:"ec8b_submitted" db:"ec8b_submitted"`
+	EC8CCollated        bool           `json:"ec8c_collated" db:"ec8c_collated"`
+	CSRVSDone           bool           `json:"csrvs_done" db:"csrvs_done"`
+	VotesAnnounced      bool           `json:"votes_announced" db:"votes_announced"`
+	AgentsCountersigned bool           `json:"agents_countersigned" db:"agents_countersigned"`
+	EC60EDisplayed      bool           `json:"ec60e_displayed" db:"ec60e_displayed"`
+	AccreditedVoters    int            `json:"accredited_voters" db:"accredited_voters"`
+	ValidVotes          int            `json:"valid_votes" db:"valid_votes"`
+	RejectedVotes       int            `json:"rejected_votes" db:"rejected_votes"`
+	VotesCast           int            `json:"votes_cast" db:"votes_cast"`
+	ObserverPermitted   *bool          `json:"observer_permitted" db:"observer_permitted"` // Nil until access is reported
+	DenialReason        string         `json:"denial_reason,omitempty" db:"denial_reason"`
+	DeniedAt            *time.Time     `json:"denied_at,omitempty" db:"denied_at"`
+	CancelledPUs        int            `json:"cancelled_pus" db:"cancelled_pus"`
+	CancelledPUVoters   int            `json:"cancelled_pu_voters" db:"cancelled_pu_voters"` // Registered voters in cancelled PUs
+	PartyResults        map[string]int `json:"party_results,omitempty"`
+	UpdatedAt           time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// WardResultVersion is an immutable snapshot of a ward's submission, recorded after every change
+type WardResultVersion struct {
+	ID              int                    `json:"id" db:"id"`
+	ElectionID      string                 `json:"election_id" db:"election_id"`
+	WardID          string                 `json:"ward_id" db:"ward_id"`
+	Version         int                    `json:"version" db:"version"`
+	Section         string                 `json:"section" db:"section"` // baseline, logistics, staffing, integrity, results, polling_units, revert
+	Snapshot        WardResult             `json:"snapshot" db:"snapshot"`
+	Diff            map[string]FieldChange `json:"diff" db:"diff"`
+	SubmittedBy     *int                   `json:"submitted_by" db:"submitted_by"`
+	SubmittedByName string                 `json:"submitted_by_name,omitempty"`
+	RevertedFrom    *int                   `json:"reverted_from,omitempty" db:"reverted_from"`
+	CreatedAt       time.Time              `json:"created_at" db:"created_at"`
+}
+
+// WardReview is the review state of a ward's submission in an election
+type WardReview struct {
+	ElectionID      string             `json:"election_id"`
+	WardID          string             `json:"ward_id"`
+	WardName        string             `json:"ward_name"`
+	AreaCouncilID   string             `json:"area_council_id"`
+	Status          string             `json:"status"` // draft, submitted, approved, rejected
+	Reason          string             `json:"reason,omitempty"`
+	Version         int                `json:"version"` // Latest recorded version of the submission
+	SubmittedBy     *int               `json:"submitted_by"`
+	SubmittedAt     *time.Time         `json:"submitted_at"`
+	ReviewedBy      *int               `json:"reviewed_by"`
+	ReviewedAt      *time.Time         `json:"reviewed_at"`
+	ReviewedVersion *int               `json:"reviewed_version"`
+	History         []WardReviewChange `json:"history,omitempty"`
+}
+
+// WardReviewChange records one move through the review workflow
+type WardReviewChange struct {
+	ID         int       `json:"id"`
+	Version    *int      `json:"version"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedBy  *int      `json:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// FieldChange records a field's value before and after a change
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AreaCouncilResult represents the Area Council level collation submission
+type AreaCouncilResult struct {
+	ElectionID          string         `json:"election_id" db:"election_id"`
+	AreaCouncilID       string         `json:"area_council_id" db:"area_council_id"`
+	ArrivalTime         string         `json:"arrival_time" db:"arrival_time"`
+	CollationStartTime  string         `json:"collation_start_time" db:"collation_start_time"`
+	INECStaff           int            `json:"inec_staff" db:"inec_staff"`
+	SecurityPresent     bool           `json:"security_present" db:"security_present"`
+	PartyAgents         int            `json:"party_agents" db:"party_agents"`
+	EC8BSubmitted       bool           `json:"ec8b_submitted" db:"ec8b_submitted"`
+	EC8CCollated        bool           `json:"ec8c_collated" db:"ec8c_collated"`
+	CSRVSDone           bool           `json:"csrvs_done" db:"csrvs_done"`
+	VotesAnnounced      bool           `json:"votes_announced" db:"votes_announced"`
+	AgentsCountersigned bool           `json:"agents_countersigned" db:"agents_countersigned"`
+	EC60EDisplayed      bool           `json:"ec60e_displayed" db:"ec60e_displayed"`
+	AccreditedVoters    int            `json:"accredited_voters" db:"accredited_voters"`
+	ValidVotes          int            `json:"valid_votes" db:"valid_votes"`
+	RejectedVotes       int            `json:"rejected_votes" db:"rejected_votes"`
+	VotesCast           int            `json:"votes_cast" db:"votes_cast"`
+	ObserverPermitted   *bool          `json:"observer_permitted" db:"observer_permitted"` // Nil until access is reported
+	DenialReason        string         `json:"denial_reason,omitempty" db:"denial_reason"`
+	DeniedAt            *time.Time     `json:"denied_at,omitempty" db:"denied_at"`
+	PartyResults        map[string]int `json:"party_results"`
+	UpdatedAt           time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// DeniedAccessFlag is a ward or Area Council collation where our observer was refused access
+type DeniedAccessFlag struct {
+	Level           string     `json:"level"` // ward or area_council
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	AreaCouncilID   string     `json:"areaCouncilId"`
+	AreaCouncilName string     `json:"areaCouncilName"`
+	DenialReason    string     `json:"denialReason"`
+	DeniedAt        *time.Time `json:"deniedAt"`
+}
+
+// FigureComparison compares an officially collated figure with the sum of ward figures
+type FigureComparison struct {
+	Collated   int  `json:"collated"`
+	WardTotal  int  `json:"wardTotal"`
+	Difference int  `json:"difference"` // Collated - WardTotal
+	Flagged    bool `json:"flagged"`
+}
+
+// Reconciliation compares an Area Council's collated figures with its wards
+type Reconciliation struct {
+	ElectionID        string                      `json:"electionId"`
+	AreaCouncilID     string                      `json:"areaCouncilId"`
+	CollationReceived bool                        `json:"collationReceived"`
+	WardsReported     int                         `json:"wardsReported"`
+	Wards             int                         `json:"wards"`
+	VoteCounts        map[string]FigureComparison `json:"voteCounts"`
+	PartyResults      map[string]FigureComparison `json:"partyResults"`
+	Discrepancies     int                         `json:"discrepancies"`
 }
 
 // PartyResult represents vote count for a party in a ward
 type PartyResult struct {
-	ID        int       `json:"id" db:"id"`
-	WardID    string    `json:"ward_id" db:"ward_id"`
-	PartyName string    `json:"party_name" db:"party_name"`
-	Score     int       `json:"score" db:"score"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID         int       `json:"id" db:"id"`
+	ElectionID string    `json:"election_id" db:"election_id"`
+	WardID     string    `json:"ward_id" db:"ward_id"`
+	PartyName  string    `json:"party_name" db:"party_name"`
+	Score      int       `json:"score" db:"score"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
 // Incident represents a security or process incident
 type Incident struct {
-	ID          int       `json:"id" db:"id"`
-	WardID      string    `json:"ward_id" db:"ward_id"`
-	Title       string    `json:"title" db:"title"`
-	Description string    `json:"description" db:"description"`
-	Type        string    `json:"type" db:"type"`
-	Severity    string    `json:"severity" db:"severity"`
-	Status      string    `json:"status" db:"status"`
-	Timestamp   time.Time `json:"timestamp" db:"timestamp"`
+	ID              int                    `json:"id" db:"id"`
+	ElectionID      string                 `json:"election_id" db:"election_id"`
+	WardID          string                 `json:"ward_id" db:"ward_id"`
+	AreaCouncilID   string                 `json:"area_council_id"` // Joined field
+	Title           string                 `json:"title" db:"title"`
+	Description     string                 `json:"description" db:"description"`
+	Type            string                 `json:"type" db:"type"`
+	Severity        string                 `json:"severity" db:"severity"`
+	Status          string                 `json:"status" db:"status"`
+	Timestamp       time.Time              `json:"timestamp" db:"timestamp"`
+	ReportedBy      *int                   `json:"reported_by,omitempty" db:"reported_by"`
+	StatusUpdatedBy *int                   `json:"status_updated_by,omitempty" db:"status_updated_by"`
+	StatusUpdatedAt *time.Time             `json:"status_updated_at,omitempty" db:"status_updated_at"`
+	History         []IncidentStatusChange `json:"history,omitempty"`
+}
+
+// IncidentStatusChange records a single step in an incident's status workflow
+type IncidentStatusChange struct {
+	ID         int       `json:"id" db:"id"`
+	IncidentID int       `json:"incident_id" db:"incident_id"`
+	FromStatus string    `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	Note       string    `json:"note" db:"note"`
+	ChangedBy  *int      `json:"changed_by,omitempty" db:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
 }
 
 // WardDetail matches the frontend WardSummary interface structure
@@ -119,16 +354,19 @@ type WardDetail struct {
 	TurnoutPercent   float64        `json:"turnoutPercent"`
 	ComplianceScore  int            `json:"complianceScore"` // Calculated
 	IncidentCount    int            `json:"incidentCount"`
-	DeniedAccess     bool           `json:"deniedAccess"` // Derived logic
-	LateStart        bool           `json:"lateStart"`    // Derived logic
-	CancelledPUs     int            `json:"cancelledPUs"` // Placeholder or derived
+	DeniedAccess     bool           `json:"deniedAccess"`
+	DenialReason     string         `json:"denialReason,omitempty"`
+	LateStart        bool           `json:"lateStart"` // Derived logic
+	CancelledPUs     int            `json:"cancelledPUs"`
+	LostVoters       int            `json:"lostVoters"`
 	SecurityPresent  bool           `json:"securityPresent"`
-	ObserverPresent  bool           `json:"observerPresent"` // Placeholder
+	ObserverPresent  bool           `json:"observerPresent"` // Access reported and permitted
 	RiskLevel        string         `json:"riskLevel"`       // Calculated
 	ArrivalCategory  string         `json:"arrivalCategory"`
 	StartCategory    string         `json:"startCategory"`
 	PartyResults     map[string]int `json:"partyResults"`
 	Integrity        WardIntegrity  `json:"integrity"`
+	ReviewStatus     string         `json:"reviewStatus,omitempty"` // Empty until the ward has a submission
 }
 
 type WardIntegrity struct {
@@ -139,3 +377,43 @@ type WardIntegrity struct {
 	AgentsCountersigned bool `json:"agentsCountersigned"`
 	EC60EDisplayed      bool `json:"ec60eDisplayed"`
 }
+
+// WebhookSubscription is a partner endpoint that receives live events
+type WebhookSubscription struct {
+	ID            int       `json:"id" db:"id"`
+	Name          string    `json:"name" db:"name"`
+	URL           string    `json:"url" db:"url"`
+	Secret        string    `json:"secret,omitempty" db:"secret"` // Only returned when the subscription is created
+	EventTypes    []string  `json:"event_types" db:"event_types"` // Empty means every event type
+	AreaCouncilID string    `json:"area_council_id,omitempty" db:"area_council_id"`
+	Active        bool      `json:"active" db:"active"`
+	CreatedBy     *int      `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDelivery struct {
+	ID             int              `json:"id" db:"id"`
+	SubscriptionID int              `json:"subscription_id" db:"subscription_id"`
+	EventID        uint64           `json:"event_id" db:"event_id"`
+	EventType      string           `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage  `json:"payload" db:"payload"`
+	Status         string           `json:"status" db:"status"` // pending, delivered or dead
+	Attempts       int              `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time        `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int             `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string           `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time       `json:"delivered_at,omitempty" db:"delivered_at"`
+	AttemptLog     []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records a single attempt at a delivery
+type WebhookAttempt struct {
+	Attempt     int       `json:"attempt" db:"attempt"`
+	StatusCode  *int      `json:"status_code,omitempty" db:"status_code"`
+	Error       string    `json:"error,omitempty" db:"error"`
+	DurationMs  int       `json:"duration_ms" db:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+}
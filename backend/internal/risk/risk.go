@@ -0,0 +1,239 @@
+package risk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// Risk levels, matching the frontend RiskLevel type
+const (
+	LevelNone     = "none"
+	LevelLow      = "low"
+	LevelMedium   = "medium"
+	LevelHigh     = "high"
+	LevelCritical = "critical"
+)
+
+// Thresholds are the minimum scores for each level above "none"
+type Thresholds struct {
+	Low      int `json:"low"`
+	Medium   int `json:"medium"`
+	High     int `json:"high"`
+	Critical int `json:"critical"`
+}
+
+// Weights controls how much each observation contributes to a ward's risk score.
+// Incident type and severity keys are matched case-insensitively.
+type Weights struct {
+	ObserverDenied       int            `json:"observerDenied"`
+	NoSecurity           int            `json:"noSecurity"`
+	LateStart            int            `json:"lateStart"`
+	FailedChecksPerPoint int            `json:"failedChecksPerPoint"` // One point per N failed integrity checks
+	IncidentSeverity     map[string]int `json:"incidentSeverity"`     // Points per incident of the given severity
+	IncidentType         map[string]int `json:"incidentType"`         // Extra points per incident of the given type
+	Thresholds           Thresholds     `json:"thresholds"`
+}
+
+// DefaultWeights mirrors calculateRiskLevel in the frontend
+func DefaultWeights() Weights {
+	return Weights{
+		ObserverDenied:       3,
+		NoSecurity:           1,
+		LateStart:            1,
+		FailedChecksPerPoint: 2,
+		IncidentSeverity: map[string]int{
+			"low":    1,
+			"medium": 2,
+			"high":   3,
+		},
+		IncidentType: map[string]int{
+			"violence":     1,
+			"intimidation": 1,
+			"fraud":        1,
+		},
+		Thresholds: Thresholds{Low: 1, Medium: 3, High: 6, Critical: 8},
+	}
+}
+
+// ParseWeights reads weights saved as JSON. Fields missing from raw keep
+// their default values, but the incident maps replace the defaults whole
+// rather than merging with them, so removed keys stay removed.
+func ParseWeights(raw []byte) (Weights, error) {
+	defaults := DefaultWeights()
+	w := defaults
+	w.IncidentSeverity, w.IncidentType = nil, nil
+	err := json.Unmarshal(raw, &w)
+	if w.IncidentSeverity == nil {
+		w.IncidentSeverity = defaults.IncidentSeverity
+	}
+	if w.IncidentType == nil {
+		w.IncidentType = defaults.IncidentType
+	}
+	return w, err
+}
+
+// Validate checks that no weight is negative and the thresholds ascend
+func (w Weights) Validate() error {
+	for name, v := range map[string]int{
+		"observerDenied": w.ObserverDenied, "noSecurity": w.NoSecurity,
+		"lateStart": w.LateStart, "failedChecksPerPoint": w.FailedChecksPerPoint,
+	} {
+		if v < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	for severity, v := range w.IncidentSeverity {
+		if v < 0 {
+			return fmt.Errorf("incidentSeverity %q must not be negative", severity)
+		}
+	}
+	for incidentType, v := range w.IncidentType {
+		if v < 0 {
+			return fmt.Errorf("incidentType %q must not be negative", incidentType)
+		}
+	}
+	t := w.Thresholds
+	if t.Low < 1 || t.Medium < t.Low || t.High < t.Medium || t.Critical < t.High {
+		return errors.New("thresholds must be positive and ascending (low <= medium <= high <= critical)")
+	}
+	return nil
+}
+
+// IncidentCount is the number of incidents of a given type and severity
+type IncidentCount struct {
+	Type     string
+	Severity string
+	Count    int
+}
+
+// Input holds everything the engine looks at for a single ward
+type Input struct {
+	Reported        bool // False when no ward_results row exists yet
+	ObserverDenied  bool
+	SecurityPresent bool
+	LateStart       bool
+	Integrity       models.WardIntegrity
+	Incidents       []IncidentCount
+}
+
+// Assessment is the outcome of scoring a ward
+type Assessment struct {
+	Score           int    `json:"score"`
+	Level           string `json:"level"`
+	ComplianceScore int    `json:"complianceScore"`
+}
+
+// Score computes the raw risk score for a ward
+func (w Weights) Score(in Input) int {
+	score := 0
+
+	for _, inc := range in.Incidents {
+		perIncident := w.IncidentSeverity[strings.ToLower(inc.Severity)] + w.IncidentType[strings.ToLower(inc.Type)]
+		score += perIncident * inc.Count
+	}
+
+	// Process observations only mean something once the ward has reported
+	if !in.Reported {
+		return score
+	}
+
+	if in.ObserverDenied {
+		score += w.ObserverDenied
+	}
+	if !in.SecurityPresent {
+		score += w.NoSecurity
+	}
+	if in.LateStart {
+		score += w.LateStart
+	}
+	if w.FailedChecksPerPoint > 0 {
+		score += FailedChecks(in.Integrity) / w.FailedChecksPerPoint
+	}
+
+	return score
+}
+
+// Level maps a score onto the five-level scale
+func (w Weights) Level(score int) string {
+	t := w.Thresholds
+	switch {
+	case score >= t.Critical:
+		return LevelCritical
+	case score >= t.High:
+		return LevelHigh
+	case score >= t.Medium:
+		return LevelMedium
+	case score >= t.Low:
+		return LevelLow
+	default:
+		return LevelNone
+	}
+}
+
+// Assess scores a ward and derives its level and compliance score
+func (w Weights) Assess(in Input) Assessment {
+	score := w.Score(in)
+	a := Assessment{Score: score, Level: w.Level(score)}
+	if in.Reported {
+		a.ComplianceScore = ComplianceScore(in.Integrity)
+	}
+	return a
+}
+
+// AssessAreaCouncil rolls ward assessments up to an Area Council. The council
+// takes the score of its riskiest ward so a single critical ward is never
+// averaged away; compliance is the mean over reported wards.
+func (w Weights) AssessAreaCouncil(wards []Input) Assessment {
+	var a Assessment
+	var totalCompliance, reported int
+
+	for _, in := range wards {
+		wa := w.Assess(in)
+		if wa.Score > a.Score {
+			a.Score = wa.Score
+		}
+		if in.Reported {
+			totalCompliance += wa.ComplianceScore
+			reported++
+		}
+	}
+
+	if reported > 0 {
+		a.ComplianceScore = totalCompliance / reported
+	}
+	a.Level = w.Level(a.Score)
+	return a
+}
+
+// FailedChecks counts the integrity flags that were not satisfied
+func FailedChecks(i models.WardIntegrity) int {
+	failed := 0
+	for _, ok := range integrityFlags(i) {
+		if !ok {
+			failed++
+		}
+	}
+	return failed
+}
+
+// ComplianceScore is the percentage of integrity checks that passed
+func ComplianceScore(i models.WardIntegrity) int {
+	flags := integrityFlags(i)
+	passed := len(flags) - FailedChecks(i)
+	return passed * 100 / len(flags)
+}
+
+func integrityFlags(i models.WardIntegrity) []bool {
+	return []bool{
+		i.EC8BSubmitted,
+		i.EC8CCollated,
+		i.CSRVSDone,
+		i.VotesAnnounced,
+		i.AgentsCountersigned,
+		i.EC60EDisplayed,
+	}
+}
@@ -0,0 +1,164 @@
+package risk
+
+import (
+	"testing"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// allPassed is an integrity report with every check satisfied
+var allPassed = models.WardIntegrity{
+	EC8BSubmitted:       true,
+	EC8CCollated:        true,
+	CSRVSDone:           true,
+	VotesAnnounced:      true,
+	AgentsCountersigned: true,
+	EC60EDisplayed:      true,
+}
+
+func TestLevel(t *testing.T) {
+	w := DefaultWeights() // Thresholds 1, 3, 6 and 8
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{0, LevelNone},
+		{1, LevelLow},
+		{2, LevelLow},
+		{3, LevelMedium},
+		{5, LevelMedium},
+		{6, LevelHigh},
+		{7, LevelHigh},
+		{8, LevelCritical},
+		{40, LevelCritical},
+	}
+	for _, tt := range tests {
+		if got := w.Level(tt.score); got != tt.want {
+			t.Errorf("Level(%d) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Input
+		want int
+	}{
+		{"quiet ward", Input{Reported: true, SecurityPresent: true, Integrity: allPassed}, 0},
+		{"not reported", Input{}, 0},
+		{"observer denied", Input{Reported: true, ObserverDenied: true, SecurityPresent: true, Integrity: allPassed}, 3},
+		{"no security", Input{Reported: true, Integrity: allPassed}, 1},
+		{"late start", Input{Reported: true, SecurityPresent: true, LateStart: true, Integrity: allPassed}, 1},
+		{"one failed check", Input{Reported: true, SecurityPresent: true, Integrity: models.WardIntegrity{
+			EC8BSubmitted: true, EC8CCollated: true, CSRVSDone: true, VotesAnnounced: true, AgentsCountersigned: true,
+		}}, 0},
+		{"every check failed", Input{Reported: true, SecurityPresent: true}, 3},
+		{"observations ignored until reported", Input{ObserverDenied: true, LateStart: true}, 0},
+		{"low logistics incidents", Input{Incidents: []IncidentCount{{Type: "Logistics", Severity: "low", Count: 2}}}, 2},
+		{"high violence incident", Input{Incidents: []IncidentCount{{Type: "Violence", Severity: "HIGH", Count: 1}}}, 4},
+		{"unknown type and severity", Input{Incidents: []IncidentCount{{Type: "other", Severity: "unknown", Count: 5}}}, 0},
+		{"incidents count before reporting", Input{Reported: true, ObserverDenied: true, Integrity: allPassed, Incidents: []IncidentCount{
+			{Type: "fraud", Severity: "medium", Count: 1},
+			{Type: "intimidation", Severity: "low", Count: 1},
+		}}, 9},
+	}
+	w := DefaultWeights()
+	for _, tt := range tests {
+		if got := w.Score(tt.in); got != tt.want {
+			t.Errorf("%s: Score = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScoreCustomWeights(t *testing.T) {
+	w := DefaultWeights()
+	w.ObserverDenied = 10
+	w.FailedChecksPerPoint = 0 // Integrity checks don't count
+	w.IncidentType["violence"] = 5
+
+	in := Input{Reported: true, ObserverDenied: true, SecurityPresent: true, Incidents: []IncidentCount{{Type: "violence", Severity: "low", Count: 2}}}
+	if got := w.Score(in); got != 22 {
+		t.Errorf("Score = %d, want 22", got)
+	}
+}
+
+func TestAssess(t *testing.T) {
+	w := DefaultWeights()
+	in := Input{Reported: true, ObserverDenied: true, LateStart: true, Integrity: models.WardIntegrity{EC8BSubmitted: true, EC8CCollated: true, CSRVSDone: true}}
+	a := w.Assess(in)
+	if a.Score != 6 || a.Level != LevelHigh || a.ComplianceScore != 50 {
+		t.Errorf("Assess = %+v, want score 6, high, compliance 50", a)
+	}
+
+	if a := w.Assess(Input{Integrity: allPassed}); a.ComplianceScore != 0 || a.Level != LevelNone {
+		t.Errorf("unreported Assess = %+v, want no compliance score and level none", a)
+	}
+}
+
+func TestAssessAreaCouncil(t *testing.T) {
+	w := DefaultWeights()
+	a := w.AssessAreaCouncil([]Input{
+		{Reported: true, SecurityPresent: true, Integrity: allPassed},
+		{Reported: true, ObserverDenied: true, Integrity: models.WardIntegrity{}},
+		{Incidents: []IncidentCount{{Type: "violence", Severity: "high", Count: 1}}},
+	})
+	// The riskiest ward scores 3 + 1 + 3; compliance averages 100 and 0 over the reported wards
+	if a.Score != 7 || a.Level != LevelHigh || a.ComplianceScore != 50 {
+		t.Errorf("AssessAreaCouncil = %+v, want score 7, high, compliance 50", a)
+	}
+
+	if a := w.AssessAreaCouncil(nil); a.Score != 0 || a.Level != LevelNone {
+		t.Errorf("AssessAreaCouncil(nil) = %+v, want score 0 and level none", a)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights([]byte(`{"observerDenied": 5, "incidentType": {"violence": 4}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ObserverDenied != 5 || w.NoSecurity != DefaultWeights().NoSecurity {
+		t.Errorf("observerDenied = %d, noSecurity = %d; want 5 and the default", w.ObserverDenied, w.NoSecurity)
+	}
+	// A saved map replaces the default one rather than merging with it
+	if len(w.IncidentType) != 1 || w.IncidentType["violence"] != 4 {
+		t.Errorf("incidentType = %v, want only violence: 4", w.IncidentType)
+	}
+	if len(w.IncidentSeverity) != 3 {
+		t.Errorf("incidentSeverity = %v, want the defaults", w.IncidentSeverity)
+	}
+
+	w, err = ParseWeights([]byte(`{"incidentType": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.IncidentType) != 0 {
+		t.Errorf("incidentType = %v, want none", w.IncidentType)
+	}
+}
+
+func TestValidateWeights(t *testing.T) {
+	if err := DefaultWeights().Validate(); err != nil {
+		t.Errorf("default weights: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(w *Weights)
+	}{
+		{"negative observer weight", func(w *Weights) { w.ObserverDenied = -1 }},
+		{"negative checks per point", func(w *Weights) { w.FailedChecksPerPoint = -2 }},
+		{"negative severity", func(w *Weights) { w.IncidentSeverity["high"] = -3 }},
+		{"negative type", func(w *Weights) { w.IncidentType["fraud"] = -1 }},
+		{"zero low threshold", func(w *Weights) { w.Thresholds.Low = 0 }},
+		{"descending thresholds", func(w *Weights) { w.Thresholds.High = 2 }},
+	}
+	for _, tt := range tests {
+		w := DefaultWeights()
+		tt.change(&w)
+		if err := w.Validate(); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
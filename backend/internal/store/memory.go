@@ -0,0 +1,1258 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+// MemoryStore keeps everything in memory, for tests and local experiments. It
+// behaves like SQLStore, except that audit log searches match the words given
+// rather than using full-text search.
+type MemoryStore struct {
+	mu            sync.Mutex
+	elections     []models.Election
+	riskWeights   map[string][]byte // JSON, by election
+	areaCouncils  map[string]models.AreaCouncil
+	wards         map[string]models.Ward
+	results       map[wardKey]*memoryWardResult
+	pollingUnits  map[int]models.PollingUnit
+	lastPUID      int
+	puStatuses    map[puKey]string
+	puResults     map[puKey]models.PollingUnitResult
+	collations    map[wardKey]models.AreaCouncilResult // Keyed by election and Area Council
+	parties       map[string][]string                  // By election and Area Council
+	incidents     []models.Incident
+	users         []models.User
+	jurisdictions map[int][]models.Jurisdiction
+	twoFactor     map[int]*memoryTwoFactor
+	sessions      []models.Session // Oldest first
+	refreshTokens map[string]*memoryRefreshToken
+	revokedTokens map[string]time.Time // Expiry, by jti
+	throttles     map[string]*memoryThrottle
+	apiKeys       []memoryAPIKey
+	webhooks      []models.WebhookSubscription // With secrets
+	lastWebhookID int
+	deliveries    []models.WebhookDelivery
+	lastDelivery  int
+	auditLog      []audit.Entry
+}
+
+type wardKey struct {
+	electionID, wardID string
+}
+
+type puKey struct {
+	electionID string
+	id         int
+}
+
+type memoryWardResult struct {
+	result   models.WardResult
+	versions []models.WardResultVersion // Oldest first
+	review   models.WardReview          // Without the ward's details or history
+	history  []models.WardReviewChange
+	approved int // Version last approved, shown while later changes are reviewed
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		riskWeights:   make(map[string][]byte),
+		areaCouncils:  make(map[string]models.AreaCouncil),
+		wards:         make(map[string]models.Ward),
+		results:       make(map[wardKey]*memoryWardResult),
+		pollingUnits:  make(map[int]models.PollingUnit),
+		puStatuses:    make(map[puKey]string),
+		puResults:     make(map[puKey]models.PollingUnitResult),
+		collations:    make(map[wardKey]models.AreaCouncilResult),
+		parties:       make(map[string][]string),
+		jurisdictions: make(map[int][]models.Jurisdiction),
+		twoFactor:     make(map[int]*memoryTwoFactor),
+		refreshTokens: make(map[string]*memoryRefreshToken),
+		revokedTokens: make(map[string]time.Time),
+		throttles:     make(map[string]*memoryThrottle),
+	}
+}
+
+// Stores returns the store as each of the handlers' stores
+func (m *MemoryStore) Stores() Stores {
+	return Stores{
+		Elections: m, Wards: m, Results: m, Reviews: m, PollingUnits: m, Collations: m,
+		Parties: m, Incidents: m, Users: m, Sessions: m, Throttles: m, APIKeys: m, Webhooks: m, Audit: m,
+	}
+}
+
+// AddElection adds an election. Later elections on the same date count as more recent.
+func (m *MemoryStore) AddElection(e models.Election) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elections = append(m.elections, e)
+}
+
+// AddAreaCouncil adds an Area Council, or names one a ward added
+func (m *MemoryStore) AddAreaCouncil(ac models.AreaCouncil) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.areaCouncils[ac.ID] = ac
+}
+
+// AddWard adds a ward and, if it is new, its Area Council
+func (m *MemoryStore) AddWard(w models.Ward) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wards[w.ID] = w
+	if _, ok := m.areaCouncils[w.AreaCouncilID]; !ok {
+		m.areaCouncils[w.AreaCouncilID] = models.AreaCouncil{ID: w.AreaCouncilID}
+	}
+}
+
+// Assign assigns a user to an Area Council, or to a single ward if wardID is set
+func (m *MemoryStore) Assign(userID int, lgaID, wardID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := models.Jurisdiction{}
+	if wardID != "" {
+		j.WardIDs = []string{wardID}
+	} else {
+		j.AreaCouncilIDs = []string{lgaID}
+	}
+	m.jurisdictions[userID] = append(m.jurisdictions[userID], j)
+}
+
+// Election implements ElectionStore
+func (m *MemoryStore) Election(id string) (models.Election, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.elections {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return models.Election{}, ErrNotFound
+}
+
+// ActiveElection implements ElectionStore
+func (m *MemoryStore) ActiveElection() (models.Election, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var active *models.Election
+	for i, e := range m.elections {
+		if e.Status == "active" && (active == nil || e.Date >= active.Date) {
+			active = &m.elections[i]
+		}
+	}
+	if active == nil {
+		return models.Election{}, ErrNotFound
+	}
+	return *active, nil
+}
+
+// Elections implements ElectionStore
+func (m *MemoryStore) Elections() ([]models.Election, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elections := make([]models.Election, 0, len(m.elections))
+	for i := len(m.elections) - 1; i >= 0; i-- {
+		elections = append(elections, m.elections[i])
+	}
+	// Undated elections sort last, as NULLS LAST does
+	sort.SliceStable(elections, func(i, j int) bool {
+		a, b := elections[i].Date, elections[j].Date
+		return a != "" && (b == "" || a > b)
+	})
+	return elections, nil
+}
+
+// defaultParties are the parties a new election starts with in every Area
+// Council, matching the area_council_parties column default
+var defaultParties = []string{"APC", "LP", "PDP", "NNPP", "APGA", "ADC", "SDP", "YPP"}
+
+// CreateElection implements ElectionStore
+func (m *MemoryStore) CreateElection(e models.Election) (models.Election, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.elections {
+		if existing.ID == e.ID {
+			return e, ErrConflict
+		}
+	}
+	e.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	m.elections = append(m.elections, e)
+	for lgaID := range m.areaCouncils {
+		key := e.ID + "/" + lgaID
+		if _, ok := m.parties[key]; !ok {
+			m.parties[key] = append([]string(nil), defaultParties...)
+		}
+	}
+	return e, nil
+}
+
+// UpdateElection implements ElectionStore
+func (m *MemoryStore) UpdateElection(e models.Election) (models.Election, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.elections {
+		if m.elections[i].ID == e.ID {
+			e.CreatedAt = m.elections[i].CreatedAt
+			m.elections[i] = e
+			return e, nil
+		}
+	}
+	return e, ErrNotFound
+}
+
+// RiskWeights implements ElectionStore
+func (m *MemoryStore) RiskWeights(electionID string) (risk.Weights, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	raw, ok := m.riskWeights[electionID]
+	if !ok {
+		return risk.DefaultWeights(), nil
+	}
+	return risk.ParseWeights(raw)
+}
+
+// SetRiskWeights implements ElectionStore
+func (m *MemoryStore) SetRiskWeights(electionID string, weights risk.Weights) error {
+	raw, err := json.Marshal(weights)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.riskWeights[electionID] = raw
+	return nil
+}
+
+// Ward implements WardStore
+func (m *MemoryStore) Ward(id string) (models.Ward, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.wards[id]
+	if !ok {
+		return w, ErrNotFound
+	}
+	return w, nil
+}
+
+// AreaCouncilExists implements WardStore
+func (m *MemoryStore) AreaCouncilExists(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.areaCouncils[id]
+	return ok, nil
+}
+
+// Wards implements WardStore
+func (m *MemoryStore) Wards(lgaID string) ([]models.Ward, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wards := []models.Ward{}
+	for _, w := range m.wards {
+		if lgaID == "" || w.AreaCouncilID == lgaID {
+			wards = append(wards, w)
+		}
+	}
+	sort.Slice(wards, func(i, j int) bool {
+		if wards[i].AreaCouncilID != wards[j].AreaCouncilID {
+			return wards[i].AreaCouncilID < wards[j].AreaCouncilID
+		}
+		return wards[i].ID < wards[j].ID
+	})
+	return wards, nil
+}
+
+// AreaCouncils implements WardStore
+func (m *MemoryStore) AreaCouncils() ([]models.AreaCouncil, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	councils := []models.AreaCouncil{}
+	for _, ac := range m.areaCouncils {
+		councils = append(councils, ac)
+	}
+	sort.Slice(councils, func(i, j int) bool { return councils[i].ID < councils[j].ID })
+	return councils, nil
+}
+
+// copyWardResult copies a submission so callers can't change the stored one
+func copyWardResult(r models.WardResult) models.WardResult {
+	r.PartyResults = copyScores(r.PartyResults)
+	return r
+}
+
+// SaveWardResult implements ResultStore
+func (m *MemoryStore) SaveWardResult(electionID, wardID, section string, userID int, revertedFrom *int, change func(*models.WardResult) error) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.saveWardResult(electionID, wardID, section, userID, revertedFrom, change)
+}
+
+// saveWardResult does the work of SaveWardResult. Call with m.mu held.
+func (m *MemoryStore) saveWardResult(electionID, wardID, section string, userID int, revertedFrom *int, change func(*models.WardResult) error) (int, error) {
+	key := wardKey{electionID, wardID}
+	stored, ok := m.results[key]
+	if !ok {
+		stored = &memoryWardResult{
+			result: models.WardResult{ElectionID: electionID, WardID: wardID, PartyResults: map[string]int{}},
+			review: models.WardReview{Status: reviewDraft},
+		}
+	}
+	next := copyWardResult(stored.result)
+	if err := change(&next); err != nil {
+		return 0, err
+	}
+	next.ElectionID, next.WardID = electionID, wardID
+	next.UpdatedAt = time.Now()
+	next = copyWardResult(next)
+	stored.result = next
+	m.results[key] = stored
+
+	var prev *models.WardResult
+	if n := len(stored.versions); n > 0 {
+		prev = &stored.versions[n-1].Snapshot
+	}
+	diff := diffSnapshots(prev, &next)
+	if len(diff) == 0 && prev != nil && revertedFrom == nil {
+		return 0, nil
+	}
+
+	v := models.WardResultVersion{
+		ID:              len(stored.versions) + 1,
+		ElectionID:      electionID,
+		WardID:          wardID,
+		Version:         len(stored.versions) + 1,
+		Section:         section,
+		Snapshot:        copyWardResult(next),
+		Diff:            diff,
+		SubmittedByName: m.username(userID),
+		RevertedFrom:    revertedFrom,
+		CreatedAt:       time.Now(),
+	}
+	if userID != 0 {
+		v.SubmittedBy = &userID
+	}
+	stored.versions = append(stored.versions, v)
+
+	// As resetWardReview does, send changed figures back for review
+	if from := stored.review.Status; from != reviewDraft {
+		stored.review.Status = reviewDraft
+		stored.review.Reason = ""
+		stored.recordReviewChange(v.Version, from, reviewDraft, "Submission changed", userID)
+	}
+	return v.Version, nil
+}
+
+// recordReviewChange adds a move through the review workflow to the history
+func (stored *memoryWardResult) recordReviewChange(version int, from, to, reason string, userID int) {
+	c := models.WardReviewChange{
+		ID:         len(stored.history) + 1,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		ChangedAt:  time.Now(),
+	}
+	if version > 0 {
+		c.Version = &version
+	}
+	if userID != 0 {
+		c.ChangedBy = &userID
+	}
+	stored.history = append(stored.history, c)
+}
+
+// WardVersion implements ResultStore
+func (m *MemoryStore) WardVersion(electionID, wardID string, version int) (models.WardResultVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.results[wardKey{electionID, wardID}]; ok && version >= 1 && version <= len(stored.versions) {
+		v := stored.versions[version-1]
+		v.Snapshot = copyWardResult(v.Snapshot)
+		return v, nil
+	}
+	return models.WardResultVersion{}, ErrNotFound
+}
+
+// WardHistory implements ResultStore
+func (m *MemoryStore) WardHistory(electionID, wardID string) ([]models.WardResultVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	history := []models.WardResultVersion{}
+	if stored, ok := m.results[wardKey{electionID, wardID}]; ok {
+		for i := len(stored.versions) - 1; i >= 0; i-- {
+			v := stored.versions[i]
+			v.Snapshot = copyWardResult(v.Snapshot)
+			history = append(history, v)
+		}
+	}
+	return history, nil
+}
+
+// ReviewStatus returns the review status of a ward's submission, or "" if it hasn't reported
+func (m *MemoryStore) ReviewStatus(electionID, wardID string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.results[wardKey{electionID, wardID}]; ok {
+		return stored.review.Status
+	}
+	return ""
+}
+
+// SetReviewStatus sets the review status of a ward's submission, as reviewers would
+func (m *MemoryStore) SetReviewStatus(electionID, wardID, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.results[wardKey{electionID, wardID}]; ok {
+		stored.review.Status = status
+		if status == reviewApproved {
+			stored.approved = len(stored.versions)
+		}
+	}
+}
+
+// submission returns a ward's submission as WardResult would. Call with m.mu held.
+func (m *MemoryStore) submission(electionID, wardID string, published bool) (WardSubmission, bool) {
+	stored, ok := m.results[wardKey{electionID, wardID}]
+	if !ok {
+		return WardSubmission{}, false
+	}
+	if published && stored.review.Status != reviewApproved {
+		if stored.approved == 0 {
+			return WardSubmission{}, false
+		}
+		snapshot := stored.versions[stored.approved-1].Snapshot
+		return WardSubmission{WardResult: copyWardResult(snapshot), ReviewStatus: reviewApproved}, true
+	}
+	return WardSubmission{WardResult: copyWardResult(stored.result), ReviewStatus: stored.review.Status}, true
+}
+
+// WardResult implements ResultStore
+func (m *MemoryStore) WardResult(electionID, wardID string, published bool) (WardSubmission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.submission(electionID, wardID, published)
+	if !ok {
+		return sub, ErrNotFound
+	}
+	return sub, nil
+}
+
+// WardResults implements ResultStore
+func (m *MemoryStore) WardResults(electionID string, published bool) ([]WardSubmission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := []WardSubmission{}
+	for key := range m.results {
+		if key.electionID != electionID {
+			continue
+		}
+		if sub, ok := m.submission(electionID, key.wardID, published); ok {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].WardID < subs[j].WardID })
+	return subs, nil
+}
+
+// wardReview returns a ward's review state without its history. Call with m.mu held.
+func (m *MemoryStore) wardReview(electionID, wardID string) (models.WardReview, *memoryWardResult, bool) {
+	stored, ok := m.results[wardKey{electionID, wardID}]
+	if !ok {
+		return models.WardReview{}, nil, false
+	}
+	rv := stored.review
+	w := m.wards[wardID]
+	rv.ElectionID, rv.WardID, rv.WardName, rv.AreaCouncilID = electionID, wardID, w.Name, w.AreaCouncilID
+	rv.Version = len(stored.versions)
+	rv.History = nil
+	return rv, stored, true
+}
+
+// WardReview implements ReviewStore
+func (m *MemoryStore) WardReview(electionID, wardID string) (models.WardReview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rv, stored, ok := m.wardReview(electionID, wardID)
+	if !ok {
+		return rv, ErrNotFound
+	}
+	rv.History = append([]models.WardReviewChange{}, stored.history...)
+	return rv, nil
+}
+
+// ReviewQueue implements ReviewStore
+func (m *MemoryStore) ReviewQueue(electionID, status, lgaID string) ([]models.WardReview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queue := []models.WardReview{}
+	for key := range m.results {
+		if key.electionID != electionID {
+			continue
+		}
+		rv, _, _ := m.wardReview(electionID, key.wardID)
+		if rv.Status == status && (lgaID == "" || rv.AreaCouncilID == lgaID) {
+			queue = append(queue, rv)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		a, b := queue[i].SubmittedAt, queue[j].SubmittedAt
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return queue[i].WardID < queue[j].WardID
+	})
+	return queue, nil
+}
+
+// ChangeWardReview implements ReviewStore
+func (m *MemoryStore) ChangeWardReview(electionID, wardID, status, reason string, userID int, allowed func(models.WardReview) bool) (models.WardReview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before, stored, ok := m.wardReview(electionID, wardID)
+	if !ok {
+		return before, ErrNotFound
+	}
+	if !allowed(before) {
+		return before, ErrConflict
+	}
+
+	now := time.Now()
+	var user *int
+	if userID != 0 {
+		user = &userID
+	}
+	rv := &stored.review
+	rv.Status = status
+	if status == reviewSubmitted {
+		rv.Reason = ""
+		rv.SubmittedBy, rv.SubmittedAt = user, &now
+		rv.ReviewedBy, rv.ReviewedAt, rv.ReviewedVersion = nil, nil, nil
+	} else {
+		rv.Reason = reason
+		rv.ReviewedBy, rv.ReviewedAt, rv.ReviewedVersion = user, &now, nil
+		if before.Version > 0 {
+			version := before.Version
+			rv.ReviewedVersion = &version
+		}
+		if status == reviewApproved {
+			stored.approved = before.Version
+		}
+	}
+	stored.recordReviewChange(before.Version, before.Status, status, reason, userID)
+	return before, nil
+}
+
+// pollingUnit returns a polling unit with its status in an election. Call with m.mu held.
+func (m *MemoryStore) pollingUnit(electionID string, id int) (models.PollingUnit, bool) {
+	pu, ok := m.pollingUnits[id]
+	if !ok {
+		return pu, false
+	}
+	pu.Status = m.puStatuses[puKey{electionID, id}]
+	if pu.Status == "" {
+		pu.Status = puNotOpened
+	}
+	return pu, true
+}
+
+// PollingUnits implements PollingUnitStore
+func (m *MemoryStore) PollingUnits(electionID, wardID string) ([]models.PollingUnit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	units := []models.PollingUnit{}
+	for id, pu := range m.pollingUnits {
+		if pu.WardID == wardID {
+			pu, _ = m.pollingUnit(electionID, id)
+			units = append(units, pu)
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].Code < units[j].Code })
+	return units, nil
+}
+
+// PollingUnit implements PollingUnitStore
+func (m *MemoryStore) PollingUnit(electionID string, id int) (models.PollingUnit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pu, ok := m.pollingUnit(electionID, id)
+	if !ok {
+		return pu, ErrNotFound
+	}
+	return pu, nil
+}
+
+// PollingUnitResult implements PollingUnitStore
+func (m *MemoryStore) PollingUnitResult(electionID string, id int) (models.PollingUnitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res, ok := m.puResults[puKey{electionID, id}]
+	if !ok {
+		return res, ErrNotFound
+	}
+	res.PartyResults = copyScores(res.PartyResults)
+	return res, nil
+}
+
+// PollingUnitStatusCounts implements PollingUnitStore
+func (m *MemoryStore) PollingUnitStatusCounts(electionID string) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]int)
+	for id := range m.pollingUnits {
+		pu, _ := m.pollingUnit(electionID, id)
+		counts[pu.Status]++
+	}
+	return counts, nil
+}
+
+// PollingUnitWards implements PollingUnitStore
+func (m *MemoryStore) PollingUnitWards(codes []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := make(map[string]bool)
+	var wards []string
+	for _, pu := range m.pollingUnits {
+		for _, code := range codes {
+			if pu.Code == code && !found[pu.WardID] {
+				found[pu.WardID] = true
+				wards = append(wards, pu.WardID)
+			}
+		}
+	}
+	sort.Strings(wards)
+	return wards, nil
+}
+
+// checkPollingUnit returns ErrConflict if another unit has pu's code and
+// ErrNotFound if its ward is unknown. Call with m.mu held.
+func (m *MemoryStore) checkPollingUnit(pu models.PollingUnit) error {
+	for id, other := range m.pollingUnits {
+		if id != pu.ID && other.Code == pu.Code {
+			return ErrConflict
+		}
+	}
+	if _, ok := m.wards[pu.WardID]; !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreatePollingUnit implements PollingUnitStore
+func (m *MemoryStore) CreatePollingUnit(pu models.PollingUnit, electionID string, userID int) (models.PollingUnit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pu.ID = 0
+	if err := m.checkPollingUnit(pu); err != nil {
+		return pu, err
+	}
+	m.lastPUID++
+	pu.ID = m.lastPUID
+	pu.UpdatedAt = time.Now()
+	status := pu.Status
+	pu.Status = ""
+	m.pollingUnits[pu.ID] = pu
+	if status != "" {
+		if err := m.setPollingUnitStatus(electionID, pu.ID, status, userID); err != nil {
+			return pu, err
+		}
+	}
+	pu.Status = status
+	return pu, nil
+}
+
+// UpdatePollingUnit implements PollingUnitStore
+func (m *MemoryStore) UpdatePollingUnit(pu models.PollingUnit, electionID string, userID int) (models.PollingUnit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous, ok := m.pollingUnits[pu.ID]
+	if !ok {
+		return pu, ErrNotFound
+	}
+	if err := m.checkPollingUnit(pu); err != nil {
+		return pu, err
+	}
+	pu.UpdatedAt = time.Now()
+	status := pu.Status
+	pu.Status = ""
+	m.pollingUnits[pu.ID] = pu
+	if status != "" {
+		if err := m.setPollingUnitStatus(electionID, pu.ID, status, userID); err != nil {
+			return pu, err
+		}
+	}
+	pu.Status = status
+	if previous.WardID != pu.WardID {
+		if err := m.rollUpPollingUnitMove(pu.ID, previous.WardID, pu.WardID, userID); err != nil {
+			return pu, err
+		}
+	}
+	return pu, nil
+}
+
+// DeletePollingUnit implements PollingUnitStore
+func (m *MemoryStore) DeletePollingUnit(id, userID int) (models.PollingUnit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pu, ok := m.pollingUnits[id]
+	if !ok {
+		return pu, ErrNotFound
+	}
+	elections := m.pollingUnitElections(id)
+	delete(m.pollingUnits, id)
+	for key := range m.puStatuses {
+		if key.id == id {
+			delete(m.puStatuses, key)
+		}
+	}
+	for key := range m.puResults {
+		if key.id == id {
+			delete(m.puResults, key)
+		}
+	}
+	for _, electionID := range elections {
+		if err := m.rollUpWardResults(electionID, pu.WardID, userID); err != nil {
+			return pu, err
+		}
+	}
+	return pu, nil
+}
+
+// ImportPollingUnits implements PollingUnitStore. Every unit is checked
+// before any is written, so a failed import changes nothing.
+func (m *MemoryStore) ImportPollingUnits(units []models.PollingUnit, electionID string, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, pu := range units {
+		if _, ok := m.wards[pu.WardID]; !ok {
+			return &ImportError{Row: i + 1, Code: pu.Code, Err: ErrNotFound}
+		}
+	}
+
+	for _, pu := range units {
+		var previous models.PollingUnit
+		for _, existing := range m.pollingUnits {
+			if existing.Code == pu.Code {
+				previous = existing
+			}
+		}
+		if previous.ID == 0 {
+			m.lastPUID++
+			pu.ID = m.lastPUID
+		} else {
+			pu.ID = previous.ID
+		}
+		status := pu.Status
+		pu.Status = ""
+		pu.UpdatedAt = time.Now()
+		m.pollingUnits[pu.ID] = pu
+		if previous.ID != 0 && previous.WardID != pu.WardID {
+			if err := m.rollUpPollingUnitMove(pu.ID, previous.WardID, pu.WardID, userID); err != nil {
+				return err
+			}
+		}
+		if status != "" {
+			if err := m.setPollingUnitStatus(electionID, pu.ID, status, userID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetPollingUnitStatus implements PollingUnitStore
+func (m *MemoryStore) SetPollingUnitStatus(electionID string, id int, status string, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setPollingUnitStatus(electionID, id, status, userID)
+}
+
+// setPollingUnitStatus does the work of SetPollingUnitStatus, rolling the
+// ward up again as setPollingUnitStatus does. Call with m.mu held.
+func (m *MemoryStore) setPollingUnitStatus(electionID string, id int, status string, userID int) error {
+	pu, ok := m.pollingUnit(electionID, id)
+	if !ok {
+		return ErrNotFound
+	}
+	m.puStatuses[puKey{electionID, id}] = status
+	_, hasResults := m.puResults[puKey{electionID, id}]
+	if hasResults && (pu.Status == puCancelled) != (status == puCancelled) {
+		return m.rollUpWardResults(electionID, pu.WardID, userID)
+	}
+	return nil
+}
+
+// SavePollingUnitResult implements PollingUnitStore
+func (m *MemoryStore) SavePollingUnitResult(res models.PollingUnitResult, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pu, ok := m.pollingUnits[res.PollingUnitID]
+	if !ok {
+		return ErrNotFound
+	}
+	res.PartyResults = copyScores(res.PartyResults)
+	res.UpdatedAt = time.Now()
+	m.puResults[puKey{res.ElectionID, res.PollingUnitID}] = res
+	return m.rollUpWardResults(res.ElectionID, pu.WardID, userID)
+}
+
+// pollingUnitElections lists the elections a polling unit has results in. Call with m.mu held.
+func (m *MemoryStore) pollingUnitElections(id int) []string {
+	var elections []string
+	for key := range m.puResults {
+		if key.id == id {
+			elections = append(elections, key.electionID)
+		}
+	}
+	sort.Strings(elections)
+	return elections
+}
+
+// rollUpPollingUnitMove rolls up both wards a polling unit moved between. Call with m.mu held.
+func (m *MemoryStore) rollUpPollingUnitMove(id int, fromWard, toWard string, userID int) error {
+	for _, electionID := range m.pollingUnitElections(id) {
+		if err := m.rollUpWardResults(electionID, fromWard, userID); err != nil {
+			return err
+		}
+		if err := m.rollUpWardResults(electionID, toWard, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollUpWardResults recomputes a ward's totals from its polling units as
+// rollUpWardResults does in Postgres. Call with m.mu held.
+func (m *MemoryStore) rollUpWardResults(electionID, wardID string, userID int) error {
+	var total models.PollingUnitResult
+	total.PartyResults = make(map[string]int)
+	for key, res := range m.puResults {
+		pu, ok := m.pollingUnit(electionID, key.id)
+		if key.electionID != electionID || !ok || pu.WardID != wardID || pu.Status == puCancelled {
+			continue
+		}
+		total.AccreditedVoters += res.AccreditedVoters
+		total.ValidVotes += res.ValidVotes
+		total.RejectedVotes += res.RejectedVotes
+		total.VotesCast += res.VotesCast
+		for party, score := range res.PartyResults {
+			total.PartyResults[party] += score
+		}
+	}
+
+	_, err := m.saveWardResult(electionID, wardID, sectionPollingUnits, userID, nil, func(r *models.WardResult) error {
+		r.AccreditedVoters = total.AccreditedVoters
+		r.ValidVotes = total.ValidVotes
+		r.RejectedVotes = total.RejectedVotes
+		r.VotesCast = total.VotesCast
+		r.PartyResults = total.PartyResults
+		return nil
+	})
+	return err
+}
+
+// copyScores copies party scores so callers can't change the stored ones
+func copyScores(scores map[string]int) map[string]int {
+	copied := make(map[string]int, len(scores))
+	for party, score := range scores {
+		copied[party] = score
+	}
+	return copied
+}
+
+// AreaCouncilResult implements CollationStore
+func (m *MemoryStore) AreaCouncilResult(electionID, lgaID string) (models.AreaCouncilResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.collations[wardKey{electionID, lgaID}]
+	if !ok {
+		return r, ErrNotFound
+	}
+	r.PartyResults = copyScores(r.PartyResults)
+	return r, nil
+}
+
+// AreaCouncilResults implements CollationStore
+func (m *MemoryStore) AreaCouncilResults(electionID string) ([]models.AreaCouncilResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	results := []models.AreaCouncilResult{}
+	for key, r := range m.collations {
+		if key.electionID == electionID {
+			r.PartyResults = copyScores(r.PartyResults)
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].AreaCouncilID < results[j].AreaCouncilID })
+	return results, nil
+}
+
+// SaveAreaCouncilResult implements CollationStore
+func (m *MemoryStore) SaveAreaCouncilResult(electionID, lgaID string, change func(*models.AreaCouncilResult) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := wardKey{electionID, lgaID}
+	r, ok := m.collations[key]
+	if !ok {
+		r = models.AreaCouncilResult{ElectionID: electionID, AreaCouncilID: lgaID}
+	}
+	r.PartyResults = copyScores(r.PartyResults)
+	if err := change(&r); err != nil {
+		return err
+	}
+	r.ElectionID, r.AreaCouncilID = electionID, lgaID
+	r.PartyResults = copyScores(r.PartyResults)
+	r.UpdatedAt = time.Now()
+	m.collations[key] = r
+	return nil
+}
+
+// AreaCouncilParties implements PartyStore
+func (m *MemoryStore) AreaCouncilParties(electionID, lgaID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parties, ok := m.parties[electionID+"/"+lgaID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]string(nil), parties...), nil
+}
+
+// SetAreaCouncilParties implements PartyStore
+func (m *MemoryStore) SetAreaCouncilParties(electionID, lgaID string, parties []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parties[electionID+"/"+lgaID] = append([]string(nil), parties...)
+	return nil
+}
+
+// CreateIncident implements IncidentStore
+func (m *MemoryStore) CreateIncident(inc models.Incident) (models.Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.wards[inc.WardID]
+	if !ok {
+		return inc, ErrNotFound
+	}
+	inc.ID = len(m.incidents) + 1
+	inc.AreaCouncilID = w.AreaCouncilID
+	inc.History = nil
+	m.incidents = append(m.incidents, inc)
+	return inc, nil
+}
+
+// Incident implements IncidentStore
+func (m *MemoryStore) Incident(id int) (models.Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.incidents) {
+		return models.Incident{}, ErrNotFound
+	}
+	inc := m.incidents[id-1]
+	inc.History = append([]models.IncidentStatusChange(nil), inc.History...)
+	return inc, nil
+}
+
+// Incidents implements IncidentStore
+func (m *MemoryStore) Incidents(f IncidentFilter) ([]models.Incident, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []models.Incident
+	for _, inc := range m.incidents {
+		switch {
+		case f.ElectionID != "" && inc.ElectionID != f.ElectionID,
+			f.WardID != "" && inc.WardID != f.WardID,
+			f.AreaCouncilID != "" && inc.AreaCouncilID != f.AreaCouncilID,
+			f.Type != "" && !strings.EqualFold(inc.Type, f.Type),
+			f.Severity != "" && !strings.EqualFold(inc.Severity, f.Severity),
+			f.Status != "" && inc.Status != f.Status,
+			!f.From.IsZero() && inc.Timestamp.Before(f.From),
+			!f.To.IsZero() && inc.Timestamp.After(f.To):
+			continue
+		}
+		inc.History = nil
+		matches = append(matches, inc)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !matches[i].Timestamp.Equal(matches[j].Timestamp) {
+			return matches[i].Timestamp.After(matches[j].Timestamp)
+		}
+		return matches[i].ID > matches[j].ID
+	})
+
+	total := len(matches)
+	page := []models.Incident{}
+	for i := f.Offset; i < total && (f.Limit <= 0 || i < f.Offset+f.Limit); i++ {
+		page = append(page, matches[i])
+	}
+	return page, total, nil
+}
+
+// ChangeIncidentStatus implements IncidentStore
+func (m *MemoryStore) ChangeIncidentStatus(id int, status, note string, userID int, allowed func(from, to string) bool) (models.Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.incidents) {
+		return models.Incident{}, ErrNotFound
+	}
+	inc := &m.incidents[id-1]
+	before := *inc
+	before.History = nil
+	if !allowed(inc.Status, status) {
+		return before, ErrConflict
+	}
+
+	now := time.Now()
+	change := models.IncidentStatusChange{
+		ID:         len(inc.History) + 1,
+		IncidentID: id,
+		FromStatus: inc.Status,
+		ToStatus:   status,
+		Note:       note,
+		ChangedAt:  now,
+	}
+	inc.Status = status
+	inc.StatusUpdatedAt = &now
+	inc.StatusUpdatedBy = nil
+	if userID != 0 {
+		inc.StatusUpdatedBy = &userID
+		change.ChangedBy = &userID
+	}
+	inc.History = append(inc.History, change)
+	return before, nil
+}
+
+// IncidentCounts implements IncidentStore
+func (m *MemoryStore) IncidentCounts(electionID, wardID string) (map[string][]risk.IncidentCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string][]risk.IncidentCount)
+	for _, inc := range m.incidents {
+		if inc.ElectionID != electionID || inc.WardID == "" || wardID != "" && inc.WardID != wardID {
+			continue
+		}
+		found := false
+		for i, c := range counts[inc.WardID] {
+			if c.Type == inc.Type && c.Severity == inc.Severity {
+				counts[inc.WardID][i].Count++
+				found = true
+			}
+		}
+		if !found {
+			counts[inc.WardID] = append(counts[inc.WardID], risk.IncidentCount{Type: inc.Type, Severity: inc.Severity, Count: 1})
+		}
+	}
+	return counts, nil
+}
+
+// UserByUsername implements UserStore
+func (m *MemoryStore) UserByUsername(username string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// CreateUser implements UserStore
+func (m *MemoryStore) CreateUser(username, passwordHash, role string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			return models.User{}, ErrConflict
+		}
+	}
+	u := models.User{
+		ID:        len(m.users) + 1,
+		Username:  username,
+		Password:  passwordHash,
+		Role:      role,
+		Active:    true,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	m.users = append(m.users, u)
+	u.Password = ""
+	return u, nil
+}
+
+// Users implements UserStore
+func (m *MemoryStore) Users() ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []models.User
+	for i := len(m.users) - 1; i >= 0; i-- {
+		u := m.users[i]
+		u.Password = ""
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// username returns a user's name, or "" for unknown users. Call with m.mu held.
+func (m *MemoryStore) username(userID int) string {
+	if userID < 1 || userID > len(m.users) {
+		return ""
+	}
+	return m.users[userID-1].Username
+}
+
+// AssignedToWard implements UserStore
+func (m *MemoryStore) AssignedToWard(userID int, wardID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.wards[wardID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	for _, j := range m.jurisdictions[userID] {
+		for _, id := range j.WardIDs {
+			if id == wardID {
+				return true, nil
+			}
+		}
+		for _, id := range j.AreaCouncilIDs {
+			if id == w.AreaCouncilID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// AssignedToAreaCouncil implements UserStore
+func (m *MemoryStore) AssignedToAreaCouncil(userID int, lgaID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jurisdictions[userID] {
+		for _, id := range j.AreaCouncilIDs {
+			if id == lgaID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Append implements AuditStore, chaining entries as audit.Append does
+func (m *MemoryStore) Append(e audit.Entry) (audit.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = len(m.auditLog) + 1
+	e.Timestamp = time.Now().UTC().Truncate(time.Microsecond)
+	e.PrevHash = ""
+	if n := len(m.auditLog); n > 0 {
+		e.PrevHash = m.auditLog[n-1].Hash
+	}
+	e.Hash = audit.ComputeHash(e)
+	m.auditLog = append(m.auditLog, e)
+	return e, nil
+}
+
+// auditMatches reports whether an entry passes a filter. Call with m.mu held.
+func (m *MemoryStore) auditMatches(e audit.Entry, f AuditFilter) bool {
+	switch {
+	case f.UserID != nil && (e.UserID == nil || *e.UserID != *f.UserID),
+		f.APIKeyID != nil && (e.APIKeyID == nil || *e.APIKeyID != *f.APIKeyID),
+		f.Username != "" && (e.UserID == nil || !strings.EqualFold(m.username(*e.UserID), f.Username)),
+		f.EntityType != "" && e.EntityType != f.EntityType,
+		f.EntityID != "" && e.EntityID != f.EntityID,
+		f.IP != "" && e.IPAddress != f.IP,
+		f.RequestID != "" && e.RequestID != f.RequestID,
+		!f.From.IsZero() && e.Timestamp.Before(f.From),
+		!f.To.IsZero() && e.Timestamp.After(f.To),
+		f.Before > 0 && e.ID >= f.Before:
+		return false
+	}
+	if len(f.Actions) > 0 {
+		found := false
+		for _, action := range f.Actions {
+			found = found || e.Action == action
+		}
+		if !found {
+			return false
+		}
+	}
+	details := strings.ToLower(e.Details)
+	for _, word := range strings.Fields(strings.ToLower(f.Search)) {
+		if !strings.Contains(details, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// EachAuditLog implements AuditStore
+func (m *MemoryStore) EachAuditLog(f AuditFilter, fn func(models.AuditLog) error) error {
+	m.mu.Lock()
+	var logs []models.AuditLog
+	for i := range m.auditLog {
+		e := m.auditLog[len(m.auditLog)-1-i]
+		if f.Oldest {
+			e = m.auditLog[i]
+		}
+		if !m.auditMatches(e, f) {
+			continue
+		}
+		l := models.AuditLog{
+			ID: e.ID, UserID: e.UserID, APIKeyID: e.APIKeyID, Action: e.Action, Details: e.Details,
+			EntityType: e.EntityType, EntityID: e.EntityID, IPAddress: e.IPAddress, RequestID: e.RequestID,
+			Method: e.Method, Path: e.Path, Status: e.Status, Timestamp: e.Timestamp, PrevHash: e.PrevHash, Hash: e.Hash,
+		}
+		if e.UserID != nil {
+			l.Username = m.username(*e.UserID)
+		}
+		if e.Changes != "" {
+			l.Changes = json.RawMessage(e.Changes)
+		}
+		logs = append(logs, l)
+		if f.Limit > 0 && len(logs) == f.Limit {
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	for _, l := range logs {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyAuditLog implements AuditStore. The memory store keeps no archives or checkpoints.
+func (m *MemoryStore) VerifyAuditLog(trusted audit.TrustedKeys) (audit.Report, error) {
+	m.mu.Lock()
+	entries := append([]audit.Entry(nil), m.auditLog...)
+	m.mu.Unlock()
+	return audit.VerifyChain(nil, nil, trusted, func(fn func(audit.Entry) error) error {
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AuditArchives implements AuditStore
+func (m *MemoryStore) AuditArchives() ([]audit.Archive, error) {
+	return []audit.Archive{}, nil
+}
+
+// AuditCheckpoints implements AuditStore
+func (m *MemoryStore) AuditCheckpoints() ([]audit.Checkpoint, error) {
+	return []audit.Checkpoint{}, nil
+}
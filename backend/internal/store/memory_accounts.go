@@ -0,0 +1,533 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+type memoryTwoFactor struct {
+	secret        string
+	lastStep      int64
+	recoveryCodes map[string]bool // By hash, true once used
+}
+
+type memoryRefreshToken struct {
+	sessionID string
+	expiresAt time.Time
+	used      bool
+}
+
+type memoryThrottle struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type memoryAPIKey struct {
+	key  models.APIKey
+	hash string
+}
+
+// user returns a user by ID. Call with m.mu held.
+func (m *MemoryStore) user(id int) (*models.User, error) {
+	if id < 1 || id > len(m.users) {
+		return nil, ErrNotFound
+	}
+	return &m.users[id-1], nil
+}
+
+// User implements UserStore
+func (m *MemoryStore) User(id int) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.user(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	return *u, nil
+}
+
+// otherActiveAdmins counts the active admins besides the given users. Call with m.mu held.
+func (m *MemoryStore) otherActiveAdmins(ids []int) int {
+	n := 0
+	for _, u := range m.users {
+		if u.Role == auth.RoleAdmin && u.Active && !containsInt(ids, u.ID) {
+			n++
+		}
+	}
+	return n
+}
+
+func containsInt(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUserRole implements UserStore
+func (m *MemoryStore) SetUserRole(id int, role string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.user(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	before := *u
+	if u.Role == role {
+		return before, nil
+	}
+	if u.Role == auth.RoleAdmin && u.Active && m.otherActiveAdmins([]int{id}) == 0 {
+		return before, ErrConflict
+	}
+	u.Role = role
+	return before, nil
+}
+
+// SetUsersActive implements UserStore
+func (m *MemoryStore) SetUsersActive(ids []int, active bool, by int) ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !active && m.otherActiveAdmins(ids) == 0 {
+		return nil, ErrConflict
+	}
+	var changed []models.User
+	for i := range m.users {
+		u := &m.users[i]
+		if !containsInt(ids, u.ID) || u.Active == active {
+			continue
+		}
+		u.Active = active
+		u.DeactivatedAt = nil
+		if !active {
+			now := time.Now().UTC()
+			u.DeactivatedAt = &now
+		}
+		changed = append(changed, *u)
+	}
+	return changed, nil
+}
+
+// SetPassword implements UserStore
+func (m *MemoryStore) SetPassword(id int, passwordHash string, mustChange bool) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.user(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	u.Password = passwordHash
+	u.MustChangePassword = mustChange
+	return *u, nil
+}
+
+// twoFactorState returns a user's two-factor state, creating it if needed. Call with m.mu held.
+func (m *MemoryStore) twoFactorState(userID int) *memoryTwoFactor {
+	tf, ok := m.twoFactor[userID]
+	if !ok {
+		tf = &memoryTwoFactor{recoveryCodes: make(map[string]bool)}
+		m.twoFactor[userID] = tf
+	}
+	return tf
+}
+
+// TwoFactorUser implements UserStore
+func (m *MemoryStore) TwoFactorUser(id int) (TwoFactorUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.user(id)
+	if err != nil {
+		return TwoFactorUser{}, err
+	}
+	tf := m.twoFactorState(id)
+	return TwoFactorUser{User: *u, Secret: tf.secret, LastStep: tf.lastStep}, nil
+}
+
+// SetTwoFactorSecret implements UserStore
+func (m *MemoryStore) SetTwoFactorSecret(userID int, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.user(userID); err != nil {
+		return err
+	}
+	tf := m.twoFactorState(userID)
+	tf.secret, tf.lastStep = secret, 0
+	return nil
+}
+
+// UseTOTPStep implements UserStore
+func (m *MemoryStore) UseTOTPStep(userID int, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.twoFactorState(userID)
+	if tf.lastStep >= step {
+		return false, nil
+	}
+	tf.lastStep = step
+	return true, nil
+}
+
+// EnableTwoFactor implements UserStore
+func (m *MemoryStore) EnableTwoFactor(userID int, recoveryHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TwoFactorEnabled = true
+	m.replaceRecoveryCodes(userID, recoveryHashes)
+	return nil
+}
+
+// ClearTwoFactor implements UserStore
+func (m *MemoryStore) ClearTwoFactor(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.user(userID)
+	if err != nil {
+		return err
+	}
+	u.TwoFactorEnabled = false
+	delete(m.twoFactor, userID)
+	return nil
+}
+
+// replaceRecoveryCodes swaps a user's recovery codes for new ones. Call with m.mu held.
+func (m *MemoryStore) replaceRecoveryCodes(userID int, hashes []string) {
+	tf := m.twoFactorState(userID)
+	tf.recoveryCodes = make(map[string]bool)
+	for _, hash := range hashes {
+		tf.recoveryCodes[hash] = false
+	}
+}
+
+// ReplaceRecoveryCodes implements UserStore
+func (m *MemoryStore) ReplaceRecoveryCodes(userID int, hashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replaceRecoveryCodes(userID, hashes)
+	return nil
+}
+
+// UseRecoveryCode implements UserStore
+func (m *MemoryStore) UseRecoveryCode(userID int, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tf := m.twoFactorState(userID)
+	used, ok := tf.recoveryCodes[hash]
+	if !ok || used {
+		return false, nil
+	}
+	tf.recoveryCodes[hash] = true
+	return true, nil
+}
+
+// RecoveryCodesLeft implements UserStore
+func (m *MemoryStore) RecoveryCodesLeft(userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, used := range m.twoFactorState(userID).recoveryCodes {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Jurisdiction implements UserStore
+func (m *MemoryStore) Jurisdiction(userID int) (models.Jurisdiction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jurisdiction(userID), nil
+}
+
+// jurisdiction merges a user's assignments, sorted as SQLStore sorts them. Call with m.mu held.
+func (m *MemoryStore) jurisdiction(userID int) models.Jurisdiction {
+	j := models.Jurisdiction{AreaCouncilIDs: []string{}, WardIDs: []string{}}
+	for _, a := range m.jurisdictions[userID] {
+		j.AreaCouncilIDs = appendMissing(j.AreaCouncilIDs, a.AreaCouncilIDs...)
+		j.WardIDs = appendMissing(j.WardIDs, a.WardIDs...)
+	}
+	sort.Strings(j.AreaCouncilIDs)
+	sort.Strings(j.WardIDs)
+	return j
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, have := range list {
+			found = found || have == v
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// SetJurisdiction implements UserStore
+func (m *MemoryStore) SetJurisdiction(userID int, j models.Jurisdiction, by int) (models.Jurisdiction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.user(userID); err != nil {
+		return models.Jurisdiction{}, err
+	}
+	m.jurisdictions[userID] = []models.Jurisdiction{j}
+	return m.jurisdiction(userID), nil
+}
+
+// session returns a session by ID, or nil. Call with m.mu held.
+func (m *MemoryStore) session(id string) *models.Session {
+	for i := range m.sessions {
+		if m.sessions[i].ID == id {
+			return &m.sessions[i]
+		}
+	}
+	return nil
+}
+
+// CreateSession implements auth.SessionStore
+func (m *MemoryStore) CreateSession(s models.Session, refreshHash string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.CreatedAt = time.Now().UTC()
+	m.sessions = append(m.sessions, s)
+	m.refreshTokens[refreshHash] = &memoryRefreshToken{sessionID: s.ID, expiresAt: s.CreatedAt.Add(ttl)}
+	return nil
+}
+
+// RotateRefreshToken implements auth.SessionStore
+func (m *MemoryStore) RotateRefreshToken(hash, nextHash string, ttl time.Duration) (auth.RefreshedSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.refreshTokens[hash]
+	if !ok {
+		return auth.RefreshedSession{}, auth.ErrInvalidRefreshToken
+	}
+	s := m.session(t.sessionID)
+	if s == nil {
+		return auth.RefreshedSession{}, auth.ErrInvalidRefreshToken
+	}
+	u, err := m.user(s.UserID)
+	if err != nil {
+		return auth.RefreshedSession{}, auth.ErrInvalidRefreshToken
+	}
+	rs := auth.RefreshedSession{
+		ID: s.ID, UserID: u.ID, Role: u.Role, MustChangePassword: u.MustChangePassword, TwoFactorEnabled: u.TwoFactorEnabled,
+	}
+	now := time.Now().UTC()
+	if s.RevokedAt != nil || !u.Active || t.expiresAt.Before(now) {
+		return rs, auth.ErrInvalidRefreshToken
+	}
+	if t.used {
+		s.RevokedAt, s.RevokedReason = &now, auth.RevokedRefreshReuse
+		return rs, auth.ErrRefreshTokenReused
+	}
+
+	t.used = true
+	s.LastRefreshedAt = &now
+	for h, other := range m.refreshTokens {
+		if other.sessionID == s.ID && other.used && other.expiresAt.Before(now) {
+			delete(m.refreshTokens, h)
+		}
+	}
+	m.refreshTokens[nextHash] = &memoryRefreshToken{sessionID: s.ID, expiresAt: now.Add(ttl)}
+	return rs, nil
+}
+
+// EndSession implements auth.SessionStore
+func (m *MemoryStore) EndSession(sessionID, reason, jti string, userID int, remaining time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	if s := m.session(sessionID); s != nil && s.RevokedAt == nil {
+		s.RevokedAt, s.RevokedReason = &now, reason
+	}
+	for id, expiresAt := range m.revokedTokens {
+		if expiresAt.Before(now) {
+			delete(m.revokedTokens, id)
+		}
+	}
+	if _, ok := m.revokedTokens[jti]; !ok {
+		m.revokedTokens[jti] = now.Add(remaining)
+	}
+	return nil
+}
+
+// RevokeUserSessions implements auth.SessionStore
+func (m *MemoryStore) RevokeUserSessions(userID int, reason string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	n := 0
+	for i := range m.sessions {
+		s := &m.sessions[i]
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt, s.RevokedReason = &now, reason
+			n++
+		}
+	}
+	return n, nil
+}
+
+// IsRevoked implements auth.SessionStore
+func (m *MemoryStore) IsRevoked(jti, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	s := m.session(sessionID)
+	return s == nil || s.RevokedAt != nil, nil
+}
+
+// UserSessions implements SessionStore
+func (m *MemoryStore) UserSessions(userID, limit int) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := []models.Session{}
+	for i := len(m.sessions) - 1; i >= 0 && len(sessions) < limit; i-- {
+		if m.sessions[i].UserID == userID {
+			sessions = append(sessions, m.sessions[i])
+		}
+	}
+	return sessions, nil
+}
+
+// LoginFailures implements auth.ThrottleStore
+func (m *MemoryStore) LoginFailures(keys []string, window time.Duration) ([]auth.ThrottleState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var states []auth.ThrottleState
+	for _, key := range keys {
+		t, ok := m.throttles[key]
+		if !ok || !t.lastFailure.After(now.Add(-window)) {
+			continue
+		}
+		state := auth.ThrottleState{Failures: t.failures, Since: now.Sub(t.lastFailure)}
+		if t.lockedUntil.After(now) {
+			state.LockedFor = t.lockedUntil.Sub(now)
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// RecordLoginFailures implements auth.ThrottleStore
+func (m *MemoryStore) RecordLoginFailures(limits []auth.ThrottleLimit, window, lockout time.Duration) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for key, t := range m.throttles {
+		if t.lastFailure.Before(now.Add(-window)) && t.lockedUntil.Before(now) {
+			delete(m.throttles, key)
+		}
+	}
+	locked := make([]bool, len(limits))
+	for i, limit := range limits {
+		t, ok := m.throttles[limit.Key]
+		if !ok {
+			t = &memoryThrottle{}
+			m.throttles[limit.Key] = t
+		}
+		if t.lastFailure.Before(now.Add(-window)) {
+			t.failures = 0
+		}
+		t.failures++
+		t.lastFailure = now
+		if t.failures >= limit.Max {
+			t.lockedUntil = now.Add(lockout)
+			locked[i] = true
+		}
+	}
+	return locked, nil
+}
+
+// ClearLoginFailures implements auth.ThrottleStore
+func (m *MemoryStore) ClearLoginFailures(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.throttles, key)
+	return nil
+}
+
+// UnlockLogins implements auth.ThrottleStore
+func (m *MemoryStore) UnlockLogins(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.throttles[key]
+	if !ok {
+		return false, nil
+	}
+	delete(m.throttles, key)
+	return t.lockedUntil.After(time.Now()), nil
+}
+
+// UseAPIKey implements auth.APIKeyStore
+func (m *MemoryStore) UseAPIKey(hash, ip string) (*auth.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for i := range m.apiKeys {
+		k := &m.apiKeys[i].key
+		if m.apiKeys[i].hash != hash || k.RevokedAt != nil || (k.ExpiresAt != nil && !k.ExpiresAt.After(now)) {
+			continue
+		}
+		k.LastUsedAt, k.LastUsedIP = &now, ip
+		return &auth.APIKey{ID: k.ID, Name: k.Name, Scopes: k.Scopes, AreaCouncilIDs: k.AreaCouncilIDs}, nil
+	}
+	return nil, auth.ErrInvalidAPIKey
+}
+
+// APIKeys implements APIKeyStore
+func (m *MemoryStore) APIKeys() ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []models.APIKey{}
+	for i := len(m.apiKeys) - 1; i >= 0; i-- {
+		keys = append(keys, m.apiKeys[i].key)
+	}
+	return keys, nil
+}
+
+// CreateAPIKey implements APIKeyStore
+func (m *MemoryStore) CreateAPIKey(k models.APIKey, hash string, expiresIn time.Duration) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k.ID = len(m.apiKeys) + 1
+	k.Key = ""
+	k.CreatedAt = time.Now().UTC()
+	if expiresIn > 0 {
+		expiresAt := k.CreatedAt.Add(expiresIn)
+		k.ExpiresAt = &expiresAt
+	}
+	if k.AreaCouncilIDs == nil {
+		k.AreaCouncilIDs = []string{}
+	}
+	m.apiKeys = append(m.apiKeys, memoryAPIKey{key: k, hash: hash})
+	return k, nil
+}
+
+// RevokeAPIKey implements APIKeyStore
+func (m *MemoryStore) RevokeAPIKey(id int) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.apiKeys) {
+		return models.APIKey{}, ErrNotFound
+	}
+	k := &m.apiKeys[id-1].key
+	if k.RevokedAt == nil {
+		now := time.Now().UTC()
+		k.RevokedAt = &now
+	}
+	return *k, nil
+}
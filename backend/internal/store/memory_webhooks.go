@@ -0,0 +1,164 @@
+package store
+
+import (
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/webhooks"
+)
+
+// AddWebhookDelivery adds a delivery to the log and returns it with its ID
+func (m *MemoryStore) AddWebhookDelivery(d models.WebhookDelivery) models.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastDelivery++
+	d.ID = m.lastDelivery
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now().UTC()
+	}
+	m.deliveries = append(m.deliveries, d)
+	return d
+}
+
+// webhookIndex finds a subscription by ID. Call with m.mu held.
+func (m *MemoryStore) webhookIndex(id int) (int, error) {
+	for i, sub := range m.webhooks {
+		if sub.ID == id {
+			return i, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// Webhooks implements WebhookStore
+func (m *MemoryStore) Webhooks() ([]models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := []models.WebhookSubscription{}
+	for _, sub := range m.webhooks {
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// CreateWebhook implements WebhookStore
+func (m *MemoryStore) CreateWebhook(sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastWebhookID++
+	sub.ID = m.lastWebhookID
+	sub.CreatedAt = time.Now().UTC()
+	sub.UpdatedAt = sub.CreatedAt
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	m.webhooks = append(m.webhooks, sub)
+	sub.Secret = ""
+	return sub, nil
+}
+
+// UpdateWebhook implements WebhookStore
+func (m *MemoryStore) UpdateWebhook(sub models.WebhookSubscription, active *bool) (models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.webhookIndex(sub.ID)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	stored := &m.webhooks[i]
+	stored.Name, stored.URL, stored.EventTypes, stored.AreaCouncilID = sub.Name, sub.URL, sub.EventTypes, sub.AreaCouncilID
+	if stored.EventTypes == nil {
+		stored.EventTypes = []string{}
+	}
+	if sub.Secret != "" {
+		stored.Secret = sub.Secret
+	}
+	if active != nil {
+		stored.Active = *active
+	}
+	stored.UpdatedAt = time.Now().UTC()
+	updated := *stored
+	updated.Secret = ""
+	return updated, nil
+}
+
+// DeleteWebhook implements WebhookStore
+func (m *MemoryStore) DeleteWebhook(id int) (models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.webhookIndex(id)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	sub := m.webhooks[i]
+	m.webhooks = append(m.webhooks[:i], m.webhooks[i+1:]...)
+	kept := m.deliveries[:0]
+	for _, d := range m.deliveries {
+		if d.SubscriptionID != id {
+			kept = append(kept, d)
+		}
+	}
+	m.deliveries = kept
+	sub.Secret = ""
+	return sub, nil
+}
+
+// WebhookDeliveries implements WebhookStore
+func (m *MemoryStore) WebhookDeliveries(f WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matches []models.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		d := m.deliveries[i]
+		if (f.SubscriptionID != 0 && d.SubscriptionID != f.SubscriptionID) || (f.Status != "" && d.Status != f.Status) {
+			continue
+		}
+		d.AttemptLog = nil
+		matches = append(matches, d)
+	}
+	total := len(matches)
+	page := []models.WebhookDelivery{}
+	for i := f.Offset; i < total && (f.Limit <= 0 || len(page) < f.Limit); i++ {
+		page = append(page, matches[i])
+	}
+	return page, total, nil
+}
+
+// delivery returns a delivery by ID. Call with m.mu held.
+func (m *MemoryStore) delivery(id int) (*models.WebhookDelivery, error) {
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == id {
+			return &m.deliveries[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// WebhookDelivery implements WebhookStore
+func (m *MemoryStore) WebhookDelivery(id int) (models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, err := m.delivery(id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return *d, nil
+}
+
+// RetryWebhookDelivery implements WebhookStore
+func (m *MemoryStore) RetryWebhookDelivery(id int) (models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, err := m.delivery(id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if d.Status != webhooks.StatusDead {
+		return *d, ErrConflict
+	}
+	d.Status, d.Attempts, d.NextAttemptAt = webhooks.StatusPending, 0, time.Now().UTC()
+	retried := *d
+	retried.AttemptLog = nil
+	return retried, nil
+}
@@ -0,0 +1,467 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+// SQLStore keeps everything in Postgres
+type SQLStore struct {
+	DB *sql.DB
+}
+
+// NewSQLStore creates a store on the given connection pool
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+// Stores returns the store as each of the handlers' stores
+func (s *SQLStore) Stores() Stores {
+	return Stores{
+		Elections: s, Wards: s, Results: s, Reviews: s, PollingUnits: s, Collations: s,
+		Parties: s, Incidents: s, Users: s, Sessions: s, Throttles: s, APIKeys: s, Webhooks: s, Audit: s,
+	}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound turns a missing row into ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+const electionColumns = `id, name, COALESCE(TO_CHAR(election_date, 'YYYY-MM-DD'), ''), type, status, created_at`
+
+func scanElection(row rowScanner) (models.Election, error) {
+	var e models.Election
+	err := row.Scan(&e.ID, &e.Name, &e.Date, &e.Type, &e.Status, &e.CreatedAt)
+	return e, notFound(err)
+}
+
+// Election implements ElectionStore
+func (s *SQLStore) Election(id string) (models.Election, error) {
+	return scanElection(s.DB.QueryRow(`SELECT `+electionColumns+` FROM elections WHERE id = $1`, id))
+}
+
+// ActiveElection implements ElectionStore
+func (s *SQLStore) ActiveElection() (models.Election, error) {
+	return scanElection(s.DB.QueryRow(`
+		SELECT ` + electionColumns + ` FROM elections WHERE status = 'active'
+		ORDER BY election_date DESC NULLS LAST, created_at DESC LIMIT 1`))
+}
+
+// Elections implements ElectionStore
+func (s *SQLStore) Elections() ([]models.Election, error) {
+	rows, err := s.DB.Query(`SELECT ` + electionColumns + ` FROM elections ORDER BY election_date DESC NULLS LAST, created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	elections := []models.Election{}
+	for rows.Next() {
+		e, err := scanElection(rows)
+		if err != nil {
+			return nil, err
+		}
+		elections = append(elections, e)
+	}
+	return elections, rows.Err()
+}
+
+// CreateElection implements ElectionStore. The party lists take the column default.
+func (s *SQLStore) CreateElection(e models.Election) (models.Election, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return e, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO elections (id, name, election_date, type, status) VALUES ($1, $2, $3, $4, $5)",
+		e.ID, e.Name, nullableString(e.Date), e.Type, e.Status)
+	if isUniqueViolation(err) {
+		return e, ErrConflict
+	}
+	if err != nil {
+		return e, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO area_council_parties (election_id, area_council_id)
+		SELECT $1, id FROM area_councils
+		ON CONFLICT DO NOTHING`, e.ID)
+	if err != nil {
+		return e, err
+	}
+	created, err := scanElection(tx.QueryRow(`SELECT `+electionColumns+` FROM elections WHERE id = $1`, e.ID))
+	if err != nil {
+		return e, err
+	}
+	return created, tx.Commit()
+}
+
+// UpdateElection implements ElectionStore
+func (s *SQLStore) UpdateElection(e models.Election) (models.Election, error) {
+	return scanElection(s.DB.QueryRow(`
+		UPDATE elections SET name = $1, election_date = $2, type = $3, status = $4 WHERE id = $5
+		RETURNING `+electionColumns, e.Name, nullableString(e.Date), e.Type, e.Status, e.ID))
+}
+
+// RiskWeights implements ElectionStore
+func (s *SQLStore) RiskWeights(electionID string) (risk.Weights, error) {
+	weights := risk.DefaultWeights()
+	var raw []byte
+	err := s.DB.QueryRow("SELECT weights FROM risk_weights WHERE election_id = $1", electionID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return weights, nil
+	}
+	if err != nil {
+		return weights, err
+	}
+	return risk.ParseWeights(raw)
+}
+
+// SetRiskWeights implements ElectionStore
+func (s *SQLStore) SetRiskWeights(electionID string, weights risk.Weights) error {
+	weightsJSON, err := json.Marshal(weights)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec(`
+		INSERT INTO risk_weights (election_id, weights, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (election_id) DO UPDATE SET
+			weights = EXCLUDED.weights,
+			updated_at = NOW()`, electionID, weightsJSON)
+	return err
+}
+
+const wardColumns = `id, area_council_id, name, total_polling_units, registered_voters`
+
+func scanWard(row rowScanner) (models.Ward, error) {
+	var w models.Ward
+	err := row.Scan(&w.ID, &w.AreaCouncilID, &w.Name, &w.TotalPollingUnits, &w.RegisteredVoters)
+	return w, notFound(err)
+}
+
+// Ward implements WardStore
+func (s *SQLStore) Ward(id string) (models.Ward, error) {
+	return scanWard(s.DB.QueryRow(`SELECT `+wardColumns+` FROM wards WHERE id = $1`, id))
+}
+
+// Wards implements WardStore
+func (s *SQLStore) Wards(lgaID string) ([]models.Ward, error) {
+	rows, err := s.DB.Query(`SELECT `+wardColumns+` FROM wards
+		WHERE $1 = '' OR area_council_id = $1
+		ORDER BY area_council_id, id`, lgaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wards := []models.Ward{}
+	for rows.Next() {
+		w, err := scanWard(rows)
+		if err != nil {
+			return nil, err
+		}
+		wards = append(wards, w)
+	}
+	return wards, rows.Err()
+}
+
+// AreaCouncils implements WardStore
+func (s *SQLStore) AreaCouncils() ([]models.AreaCouncil, error) {
+	rows, err := s.DB.Query("SELECT id, name, state FROM area_councils ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	councils := []models.AreaCouncil{}
+	for rows.Next() {
+		var ac models.AreaCouncil
+		if err := rows.Scan(&ac.ID, &ac.Name, &ac.State); err != nil {
+			return nil, err
+		}
+		councils = append(councils, ac)
+	}
+	return councils, rows.Err()
+}
+
+// AreaCouncilExists implements WardStore
+func (s *SQLStore) AreaCouncilExists(id string) (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM area_councils WHERE id = $1)", id).Scan(&exists)
+	return exists, err
+}
+
+// AreaCouncilParties implements PartyStore
+func (s *SQLStore) AreaCouncilParties(electionID, lgaID string) ([]string, error) {
+	var partiesJSON []byte
+	err := s.DB.QueryRow("SELECT parties FROM area_council_parties WHERE election_id = $1 AND area_council_id = $2", electionID, lgaID).Scan(&partiesJSON)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	var parties []string
+	err = json.Unmarshal(partiesJSON, &parties)
+	return parties, err
+}
+
+// SetAreaCouncilParties implements PartyStore
+func (s *SQLStore) SetAreaCouncilParties(electionID, lgaID string, parties []string) error {
+	partiesJSON, err := json.Marshal(parties)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec(`
+		INSERT INTO area_council_parties (election_id, area_council_id, parties, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (election_id, area_council_id) DO UPDATE SET
+			parties = EXCLUDED.parties,
+			updated_at = NOW()`, electionID, lgaID, partiesJSON)
+	return err
+}
+
+// UserByUsername implements UserStore
+func (s *SQLStore) UserByUsername(username string) (models.User, error) {
+	var u models.User
+	err := s.DB.QueryRow("SELECT id, username, password_hash, role, active, must_change_password, totp_enabled FROM users WHERE username = $1", username).
+		Scan(&u.ID, &u.Username, &u.Password, &u.Role, &u.Active, &u.MustChangePassword, &u.TwoFactorEnabled)
+	return u, notFound(err)
+}
+
+// CreateUser implements UserStore
+func (s *SQLStore) CreateUser(username, passwordHash, role string) (models.User, error) {
+	u := models.User{Username: username, Role: role}
+	err := s.DB.QueryRow("INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3) RETURNING id, active, created_at",
+		username, passwordHash, role).Scan(&u.ID, &u.Active, &u.CreatedAt)
+	if isUniqueViolation(err) {
+		return u, ErrConflict
+	}
+	return u, err
+}
+
+// Users implements UserStore
+func (s *SQLStore) Users() ([]models.User, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, username, role, active, must_change_password, totp_enabled, deactivated_at, created_at
+		FROM users ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		var deactivatedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.Active, &u.MustChangePassword, &u.TwoFactorEnabled, &deactivatedAt, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		u.DeactivatedAt = nullTimePtr(deactivatedAt)
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// AssignedToWard implements UserStore
+func (s *SQLStore) AssignedToWard(userID int, wardID string) (bool, error) {
+	var assigned bool
+	err := s.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_jurisdictions j
+			WHERE j.user_id = $1 AND (j.ward_id = w.id OR j.area_council_id = w.area_council_id)
+		)
+		FROM wards w WHERE w.id = $2`, userID, wardID).Scan(&assigned)
+	return assigned, notFound(err)
+}
+
+// AssignedToAreaCouncil implements UserStore
+func (s *SQLStore) AssignedToAreaCouncil(userID int, lgaID string) (bool, error) {
+	var assigned bool
+	err := s.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM user_jurisdictions WHERE user_id = $1 AND area_council_id = $2)",
+		userID, lgaID).Scan(&assigned)
+	return assigned, err
+}
+
+// Append implements AuditStore
+func (s *SQLStore) Append(e audit.Entry) (audit.Entry, error) {
+	return audit.Append(s.DB, e)
+}
+
+// VerifyAuditLog implements AuditStore
+func (s *SQLStore) VerifyAuditLog(trusted audit.TrustedKeys) (audit.Report, error) {
+	return audit.Verify(s.DB, trusted)
+}
+
+// AuditArchives implements AuditStore
+func (s *SQLStore) AuditArchives() ([]audit.Archive, error) {
+	return audit.Archives(s.DB)
+}
+
+// AuditCheckpoints implements AuditStore
+func (s *SQLStore) AuditCheckpoints() ([]audit.Checkpoint, error) {
+	return audit.Checkpoints(s.DB)
+}
+
+const auditLogColumns = `a.id, a.user_id, COALESCE(u.username, ''), a.api_key_id, COALESCE(k.name, ''),
+	a.action, COALESCE(a.details, ''), COALESCE(a.entity_type, ''), COALESCE(a.entity_id, ''), a.changes,
+	COALESCE(a.ip_address, ''), COALESCE(a.request_id, ''), COALESCE(a.method, ''), COALESCE(a.path, ''),
+	COALESCE(a.status, 0), a.timestamp, COALESCE(a.prev_hash, ''), COALESCE(a.hash, '')`
+
+// Entries of deleted users and API keys keep their user and key IDs
+const auditLogTables = `audit_logs a
+	LEFT JOIN users u ON a.user_id = u.id
+	LEFT JOIN api_keys k ON a.api_key_id = k.id`
+
+func scanAuditLog(row rowScanner) (models.AuditLog, error) {
+	var l models.AuditLog
+	var userID, keyID sql.NullInt64
+	var changes []byte
+	err := row.Scan(&l.ID, &userID, &l.Username, &keyID, &l.APIKeyName,
+		&l.Action, &l.Details, &l.EntityType, &l.EntityID, &changes,
+		&l.IPAddress, &l.RequestID, &l.Method, &l.Path,
+		&l.Status, &l.Timestamp, &l.PrevHash, &l.Hash)
+	l.UserID = nullIntPtr(userID)
+	l.APIKeyID = nullIntPtr(keyID)
+	if len(changes) > 0 {
+		l.Changes = json.RawMessage(changes)
+	}
+	return l, err
+}
+
+// EachAuditLog implements AuditStore. Rows are handed to fn as they are read,
+// so large exports don't build up in memory.
+func (s *SQLStore) EachAuditLog(f AuditFilter, fn func(models.AuditLog) error) error {
+	var conditions []string
+	var args []interface{}
+	addFilter := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.UserID != nil {
+		addFilter("a.user_id = $%d", *f.UserID)
+	}
+	if f.APIKeyID != nil {
+		addFilter("a.api_key_id = $%d", *f.APIKeyID)
+	}
+	if f.Username != "" {
+		addFilter("LOWER(u.username) = LOWER($%d)", f.Username)
+	}
+	if len(f.Actions) > 0 {
+		addFilter("a.action = ANY($%d)", pq.Array(f.Actions))
+	}
+	if f.EntityType != "" {
+		addFilter("a.entity_type = $%d", f.EntityType)
+	}
+	if f.EntityID != "" {
+		addFilter("a.entity_id = $%d", f.EntityID)
+	}
+	if f.IP != "" {
+		addFilter("a.ip_address = $%d", f.IP)
+	}
+	if f.RequestID != "" {
+		addFilter("a.request_id = $%d", f.RequestID)
+	}
+	if f.Search != "" {
+		addFilter("to_tsvector('simple', COALESCE(a.details, '')) @@ websearch_to_tsquery('simple', $%d)", f.Search)
+	}
+	// Timestamps are stored in UTC
+	if !f.From.IsZero() {
+		addFilter("a.timestamp >= $%d", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		addFilter("a.timestamp <= $%d", f.To.UTC())
+	}
+	if f.Before > 0 {
+		addFilter("a.id < $%d", f.Before)
+	}
+
+	query := `SELECT ` + auditLogColumns + ` FROM ` + auditLogTables
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if f.Oldest {
+		query += " ORDER BY a.id"
+	} else {
+		query += " ORDER BY a.id DESC"
+	}
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullBoolPtr(b sql.NullBool) *bool {
+	if !b.Valid {
+		return nil
+	}
+	return &b.Bool
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullUserID stores the anonymous user 0 as NULL
+func nullUserID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,631 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// interval formats a duration as a Postgres interval in milliseconds. Expiry
+// times are computed by the database so they compare cleanly with NOW().
+func interval(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10) + " milliseconds"
+}
+
+// User implements UserStore
+func (s *SQLStore) User(id int) (models.User, error) {
+	var u models.User
+	err := s.DB.QueryRow("SELECT id, username, password_hash, role, active, must_change_password, totp_enabled FROM users WHERE id = $1", id).
+		Scan(&u.ID, &u.Username, &u.Password, &u.Role, &u.Active, &u.MustChangePassword, &u.TwoFactorEnabled)
+	return u, notFound(err)
+}
+
+// otherActiveAdmins counts the active admins besides the given users, locking
+// them so two requests can't each remove one of the last two
+func otherActiveAdmins(tx *sql.Tx, userIDs []int) (int, error) {
+	rows, err := tx.Query("SELECT id FROM users WHERE role = $1 AND active AND NOT (id = ANY($2)) FOR UPDATE",
+		auth.RoleAdmin, pq.Array(userIDs))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
+
+// SetUserRole implements UserStore
+func (s *SQLStore) SetUserRole(id int, role string) (models.User, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	var u models.User
+	err = tx.QueryRow("SELECT id, username, role, active FROM users WHERE id = $1 FOR UPDATE", id).
+		Scan(&u.ID, &u.Username, &u.Role, &u.Active)
+	if err != nil {
+		return u, notFound(err)
+	}
+	if u.Role == role {
+		return u, nil
+	}
+	if u.Role == auth.RoleAdmin && u.Active {
+		n, err := otherActiveAdmins(tx, []int{id})
+		if err != nil {
+			return u, err
+		}
+		if n == 0 {
+			return u, ErrConflict
+		}
+	}
+	if _, err := tx.Exec("UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2", role, id); err != nil {
+		return u, err
+	}
+	return u, tx.Commit()
+}
+
+// SetUsersActive implements UserStore
+func (s *SQLStore) SetUsersActive(ids []int, active bool, by int) ([]models.User, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if !active {
+		n, err := otherActiveAdmins(tx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrConflict
+		}
+	}
+
+	var rows *sql.Rows
+	if active {
+		rows, err = tx.Query(`
+			UPDATE users SET active = true, deactivated_at = NULL, deactivated_by = NULL, updated_at = NOW()
+			WHERE id = ANY($1) AND NOT active
+			RETURNING id, username, role, active`, pq.Array(ids))
+	} else {
+		rows, err = tx.Query(`
+			UPDATE users SET active = false, deactivated_at = NOW(), deactivated_by = $2, updated_at = NOW()
+			WHERE id = ANY($1) AND active
+			RETURNING id, username, role, active`, pq.Array(ids), nullUserID(by))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var changed []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.Active); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changed = append(changed, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changed, tx.Commit()
+}
+
+// SetPassword implements UserStore
+func (s *SQLStore) SetPassword(id int, passwordHash string, mustChange bool) (models.User, error) {
+	var u models.User
+	err := s.DB.QueryRow(`
+		UPDATE users SET password_hash = $1, must_change_password = $3, password_changed_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+		RETURNING id, username, role, active, must_change_password, totp_enabled`, passwordHash, id, mustChange).
+		Scan(&u.ID, &u.Username, &u.Role, &u.Active, &u.MustChangePassword, &u.TwoFactorEnabled)
+	return u, notFound(err)
+}
+
+// TwoFactorUser implements UserStore
+func (s *SQLStore) TwoFactorUser(id int) (TwoFactorUser, error) {
+	var u TwoFactorUser
+	err := s.DB.QueryRow(`
+		SELECT id, username, password_hash, role, active, must_change_password, totp_enabled, COALESCE(totp_secret, ''), totp_last_step
+		FROM users WHERE id = $1`, id).Scan(
+		&u.ID, &u.Username, &u.Password, &u.Role, &u.Active, &u.MustChangePassword, &u.TwoFactorEnabled, &u.Secret, &u.LastStep,
+	)
+	return u, notFound(err)
+}
+
+// SetTwoFactorSecret implements UserStore
+func (s *SQLStore) SetTwoFactorSecret(userID int, secret string) error {
+	_, err := s.DB.Exec("UPDATE users SET totp_secret = $1, totp_last_step = 0, updated_at = NOW() WHERE id = $2", secret, userID)
+	return err
+}
+
+// UseTOTPStep implements UserStore. The update only succeeds if no later
+// code got there first.
+func (s *SQLStore) UseTOTPStep(userID int, step int64) (bool, error) {
+	res, err := s.DB.Exec("UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2", userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// replaceRecoveryCodes swaps a user's recovery codes for new ones
+func replaceRecoveryCodes(tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnableTwoFactor implements UserStore
+func (s *SQLStore) EnableTwoFactor(userID int, recoveryHashes []string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_enabled = true, totp_enabled_at = NOW(), updated_at = NOW() WHERE id = $1", userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClearTwoFactor implements UserStore
+func (s *SQLStore) ClearTwoFactor(userID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled = false, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+		WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes implements UserStore
+func (s *SQLStore) ReplaceRecoveryCodes(userID int, hashes []string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := replaceRecoveryCodes(tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode implements UserStore
+func (s *SQLStore) UseRecoveryCode(userID int, hash string) (bool, error) {
+	res, err := s.DB.Exec("UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RecoveryCodesLeft implements UserStore
+func (s *SQLStore) RecoveryCodesLeft(userID int) (int, error) {
+	var n int
+	err := s.DB.QueryRow("SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&n)
+	return n, err
+}
+
+// Jurisdiction implements UserStore
+func (s *SQLStore) Jurisdiction(userID int) (models.Jurisdiction, error) {
+	return jurisdiction(s.DB, userID)
+}
+
+func jurisdiction(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, userID int) (models.Jurisdiction, error) {
+	j := models.Jurisdiction{AreaCouncilIDs: []string{}, WardIDs: []string{}}
+	rows, err := q.Query(`
+		SELECT COALESCE(area_council_id, ''), COALESCE(ward_id, '')
+		FROM user_jurisdictions WHERE user_id = $1
+		ORDER BY area_council_id, ward_id`, userID)
+	if err != nil {
+		return j, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lgaID, wardID string
+		if err := rows.Scan(&lgaID, &wardID); err != nil {
+			return j, err
+		}
+		if lgaID != "" {
+			j.AreaCouncilIDs = append(j.AreaCouncilIDs, lgaID)
+		} else {
+			j.WardIDs = append(j.WardIDs, wardID)
+		}
+	}
+	return j, rows.Err()
+}
+
+// SetJurisdiction implements UserStore
+func (s *SQLStore) SetJurisdiction(userID int, j models.Jurisdiction, by int) (models.Jurisdiction, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.Jurisdiction{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&id); err != nil {
+		return models.Jurisdiction{}, notFound(err)
+	}
+	if _, err := tx.Exec("DELETE FROM user_jurisdictions WHERE user_id = $1", userID); err != nil {
+		return models.Jurisdiction{}, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO user_jurisdictions (user_id, area_council_id, created_by)
+		SELECT $1, id, $3 FROM UNNEST($2::varchar[]) AS id
+		ON CONFLICT DO NOTHING`, userID, pq.Array(j.AreaCouncilIDs), nullUserID(by))
+	if err != nil {
+		return models.Jurisdiction{}, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO user_jurisdictions (user_id, ward_id, created_by)
+		SELECT $1, id, $3 FROM UNNEST($2::varchar[]) AS id
+		ON CONFLICT DO NOTHING`, userID, pq.Array(j.WardIDs), nullUserID(by))
+	if err != nil {
+		return models.Jurisdiction{}, err
+	}
+	stored, err := jurisdiction(tx, userID)
+	if err != nil {
+		return stored, err
+	}
+	return stored, tx.Commit()
+}
+
+// CreateSession implements auth.SessionStore
+func (s *SQLStore) CreateSession(session models.Session, refreshHash string, ttl time.Duration) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO user_sessions (id, user_id, ip_address, user_agent) VALUES ($1, $2, $3, $4)",
+		session.ID, session.UserID, session.IPAddress, session.UserAgent)
+	if err != nil {
+		return err
+	}
+	if err := issueRefreshToken(tx, session.ID, refreshHash, ttl); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func issueRefreshToken(tx *sql.Tx, sessionID, hash string, ttl time.Duration) error {
+	_, err := tx.Exec(`
+		INSERT INTO refresh_tokens (token_hash, session_id, expires_at)
+		VALUES ($1, $2, NOW() + $3::interval)`, hash, sessionID, interval(ttl))
+	return err
+}
+
+// RotateRefreshToken implements auth.SessionStore
+func (s *SQLStore) RotateRefreshToken(hash, nextHash string, ttl time.Duration) (auth.RefreshedSession, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return auth.RefreshedSession{}, err
+	}
+	defer tx.Rollback()
+
+	var rs auth.RefreshedSession
+	var used, expired, revoked bool
+	err = tx.QueryRow(`
+		SELECT s.id, s.user_id, u.role, u.must_change_password, u.totp_enabled, rt.used_at IS NOT NULL, rt.expires_at < NOW(),
+			s.revoked_at IS NOT NULL OR NOT u.active
+		FROM refresh_tokens rt
+		JOIN user_sessions s ON rt.session_id = s.id
+		JOIN users u ON s.user_id = u.id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s`, hash).Scan(&rs.ID, &rs.UserID, &rs.Role, &rs.MustChangePassword, &rs.TwoFactorEnabled, &used, &expired, &revoked)
+	if err == sql.ErrNoRows {
+		return rs, auth.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return rs, err
+	}
+	if revoked || expired {
+		return rs, auth.ErrInvalidRefreshToken
+	}
+	if used {
+		_, err = tx.Exec("UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $2 WHERE id = $1", rs.ID, auth.RevokedRefreshReuse)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return rs, err
+		}
+		return rs, auth.ErrRefreshTokenReused
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1", hash); err != nil {
+		return rs, err
+	}
+	if _, err := tx.Exec("UPDATE user_sessions SET last_refreshed_at = NOW() WHERE id = $1", rs.ID); err != nil {
+		return rs, err
+	}
+	// Used tokens are only kept to detect reuse, and only while they could still be valid
+	if _, err := tx.Exec("DELETE FROM refresh_tokens WHERE session_id = $1 AND used_at IS NOT NULL AND expires_at < NOW()", rs.ID); err != nil {
+		return rs, err
+	}
+	if err := issueRefreshToken(tx, rs.ID, nextHash, ttl); err != nil {
+		return rs, err
+	}
+	return rs, tx.Commit()
+}
+
+// EndSession implements auth.SessionStore
+func (s *SQLStore) EndSession(sessionID, reason, jti string, userID int, remaining time.Duration) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $2 WHERE id = $1 AND revoked_at IS NULL",
+		sessionID, reason)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM revoked_tokens WHERE expires_at < NOW()"); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, NOW() + $3::interval)
+		ON CONFLICT (jti) DO NOTHING`, jti, userID, interval(remaining))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeUserSessions implements auth.SessionStore
+func (s *SQLStore) RevokeUserSessions(userID int, reason string) (int, error) {
+	res, err := s.DB.Exec("UPDATE user_sessions SET revoked_at = NOW(), revoked_reason = $2 WHERE user_id = $1 AND revoked_at IS NULL",
+		userID, reason)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// IsRevoked implements auth.SessionStore
+func (s *SQLStore) IsRevoked(jti, sessionID string) (bool, error) {
+	var revoked bool
+	err := s.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR NOT EXISTS (SELECT 1 FROM user_sessions WHERE id = $2 AND revoked_at IS NULL)`,
+		jti, sessionID).Scan(&revoked)
+	return revoked, err
+}
+
+// UserSessions implements SessionStore
+func (s *SQLStore) UserSessions(userID, limit int) ([]models.Session, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, last_refreshed_at, revoked_at, COALESCE(revoked_reason, '')
+		FROM user_sessions WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		var refreshedAt, revokedAt sql.NullTime
+		if err := rows.Scan(&session.ID, &session.UserID, &session.IPAddress, &session.UserAgent, &session.CreatedAt,
+			&refreshedAt, &revokedAt, &session.RevokedReason); err != nil {
+			return nil, err
+		}
+		session.LastRefreshedAt = nullTimePtr(refreshedAt)
+		session.RevokedAt = nullTimePtr(revokedAt)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// LoginFailures implements auth.ThrottleStore
+func (s *SQLStore) LoginFailures(keys []string, window time.Duration) ([]auth.ThrottleState, error) {
+	rows, err := s.DB.Query(`
+		SELECT failures,
+			EXTRACT(EPOCH FROM NOW() - last_failure_at),
+			COALESCE(EXTRACT(EPOCH FROM locked_until - NOW()), 0)
+		FROM login_throttles
+		WHERE key = ANY($1) AND last_failure_at > NOW() - $2::interval`,
+		pq.Array(keys), interval(window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []auth.ThrottleState
+	for rows.Next() {
+		var state auth.ThrottleState
+		var since, locked float64
+		if err := rows.Scan(&state.Failures, &since, &locked); err != nil {
+			return nil, err
+		}
+		state.Since = seconds(since)
+		if locked > 0 {
+			state.LockedFor = seconds(locked)
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RecordLoginFailures implements auth.ThrottleStore
+func (s *SQLStore) RecordLoginFailures(limits []auth.ThrottleLimit, window, lockout time.Duration) ([]bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Forget stale failures so credential stuffing with made-up usernames doesn't grow the table forever
+	_, err = tx.Exec("DELETE FROM login_throttles WHERE last_failure_at < NOW() - $1::interval AND (locked_until IS NULL OR locked_until < NOW())",
+		interval(window))
+	if err != nil {
+		return nil, err
+	}
+	locked := make([]bool, len(limits))
+	for i, limit := range limits {
+		var failures int
+		err := tx.QueryRow(`
+			INSERT INTO login_throttles (key, failures, last_failure_at) VALUES ($1, 1, NOW())
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE WHEN login_throttles.last_failure_at < NOW() - $2::interval THEN 1 ELSE login_throttles.failures + 1 END,
+				last_failure_at = NOW()
+			RETURNING failures`, limit.Key, interval(window)).Scan(&failures)
+		if err != nil {
+			return nil, err
+		}
+		if failures < limit.Max {
+			continue
+		}
+		_, err = tx.Exec("UPDATE login_throttles SET locked_until = NOW() + $2::interval WHERE key = $1", limit.Key, interval(lockout))
+		if err != nil {
+			return nil, err
+		}
+		locked[i] = true
+	}
+	return locked, tx.Commit()
+}
+
+// ClearLoginFailures implements auth.ThrottleStore
+func (s *SQLStore) ClearLoginFailures(key string) error {
+	_, err := s.DB.Exec("DELETE FROM login_throttles WHERE key = $1", key)
+	return err
+}
+
+// UnlockLogins implements auth.ThrottleStore
+func (s *SQLStore) UnlockLogins(key string) (bool, error) {
+	var locked bool
+	err := s.DB.QueryRow("DELETE FROM login_throttles WHERE key = $1 RETURNING COALESCE(locked_until > NOW(), false)", key).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return locked, err
+}
+
+const apiKeyColumns = `id, name, prefix, scopes, area_council_ids, expires_at, last_used_at, COALESCE(last_used_ip, ''), created_by, created_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	var createdBy sql.NullInt64
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), pq.Array(&k.AreaCouncilIDs),
+		&expiresAt, &lastUsedAt, &k.LastUsedIP, &createdBy, &k.CreatedAt, &revokedAt)
+	if k.AreaCouncilIDs == nil {
+		k.AreaCouncilIDs = []string{}
+	}
+	k.ExpiresAt = nullTimePtr(expiresAt)
+	k.LastUsedAt = nullTimePtr(lastUsedAt)
+	k.RevokedAt = nullTimePtr(revokedAt)
+	k.CreatedBy = nullIntPtr(createdBy)
+	return k, notFound(err)
+}
+
+// UseAPIKey implements auth.APIKeyStore
+func (s *SQLStore) UseAPIKey(hash, ip string) (*auth.APIKey, error) {
+	k := &auth.APIKey{}
+	err := s.DB.QueryRow(`
+		SELECT id, name, scopes, area_council_ids FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`,
+		hash).Scan(&k.ID, &k.Name, pq.Array(&k.Scopes), pq.Array(&k.AreaCouncilIDs))
+	if err == sql.ErrNoRows {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Busy keys would otherwise write on every request; a minute's precision is plenty
+	s.DB.Exec(`
+		UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip IS DISTINCT FROM $2)`,
+		k.ID, ip)
+	return k, nil
+}
+
+// APIKeys implements APIKeyStore
+func (s *SQLStore) APIKeys() ([]models.APIKey, error) {
+	rows, err := s.DB.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateAPIKey implements APIKeyStore
+func (s *SQLStore) CreateAPIKey(k models.APIKey, hash string, expiresIn time.Duration) (models.APIKey, error) {
+	var expiry interface{}
+	if expiresIn > 0 {
+		expiry = interval(expiresIn)
+	}
+	var createdBy int
+	if k.CreatedBy != nil {
+		createdBy = *k.CreatedBy
+	}
+	return scanAPIKey(s.DB.QueryRow(`
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, area_council_ids, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, NOW() + $6::interval, $7)
+		RETURNING `+apiKeyColumns,
+		k.Name, k.Prefix, hash, pq.Array(k.Scopes), pq.Array(k.AreaCouncilIDs), expiry, nullUserID(createdBy)))
+}
+
+// RevokeAPIKey implements APIKeyStore
+func (s *SQLStore) RevokeAPIKey(id int) (models.APIKey, error) {
+	return scanAPIKey(s.DB.QueryRow("UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 RETURNING "+apiKeyColumns, id))
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"reflect"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+const areaCouncilResultColumns = `election_id, area_council_id, COALESCE(arrival_time, ''), COALESCE(collation_start_time, ''),
+	COALESCE(inec_staff, 0), COALESCE(security_present, false), COALESCE(party_agents, 0),
+	COALESCE(ec8b_submitted, false), COALESCE(ec8c_collated, false), COALESCE(csrvs_done, false),
+	COALESCE(votes_announced, false), COALESCE(agents_countersigned, false), COALESCE(ec60e_displayed, false),
+	COALESCE(accredited_voters, 0), COALESCE(valid_votes, 0), COALESCE(rejected_votes, 0), COALESCE(votes_cast, 0),
+	observer_permitted, COALESCE(denial_reason, ''), denied_at, COALESCE(updated_at, NOW())`
+
+func scanAreaCouncilResult(row rowScanner) (models.AreaCouncilResult, error) {
+	var r models.AreaCouncilResult
+	var observerPermitted sql.NullBool
+	var deniedAt sql.NullTime
+	err := row.Scan(&r.ElectionID, &r.AreaCouncilID, &r.ArrivalTime, &r.CollationStartTime,
+		&r.INECStaff, &r.SecurityPresent, &r.PartyAgents,
+		&r.EC8BSubmitted, &r.EC8CCollated, &r.CSRVSDone,
+		&r.VotesAnnounced, &r.AgentsCountersigned, &r.EC60EDisplayed,
+		&r.AccreditedVoters, &r.ValidVotes, &r.RejectedVotes, &r.VotesCast,
+		&observerPermitted, &r.DenialReason, &deniedAt, &r.UpdatedAt)
+	if err != nil {
+		return r, err
+	}
+	r.ObserverPermitted = nullBoolPtr(observerPermitted)
+	r.DeniedAt = nullTimePtr(deniedAt)
+	r.PartyResults = make(map[string]int)
+	return r, nil
+}
+
+// queryer is what the collation reads need from a connection or transaction
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func loadAreaCouncilResult(q queryer, electionID, lgaID string) (models.AreaCouncilResult, error) {
+	r, err := scanAreaCouncilResult(q.QueryRow(`SELECT `+areaCouncilResultColumns+`
+		FROM area_council_results WHERE election_id = $1 AND area_council_id = $2`, electionID, lgaID))
+	if err != nil {
+		return r, notFound(err)
+	}
+
+	rows, err := q.Query("SELECT party_name, score FROM area_council_party_results WHERE election_id = $1 AND area_council_id = $2", electionID, lgaID)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var party string
+		var score int
+		if err := rows.Scan(&party, &score); err != nil {
+			return r, err
+		}
+		r.PartyResults[party] = score
+	}
+	return r, rows.Err()
+}
+
+// AreaCouncilResult implements CollationStore
+func (s *SQLStore) AreaCouncilResult(electionID, lgaID string) (models.AreaCouncilResult, error) {
+	return loadAreaCouncilResult(s.DB, electionID, lgaID)
+}
+
+// AreaCouncilResults implements CollationStore
+func (s *SQLStore) AreaCouncilResults(electionID string) ([]models.AreaCouncilResult, error) {
+	rows, err := s.DB.Query(`SELECT `+areaCouncilResultColumns+`
+		FROM area_council_results WHERE election_id = $1 ORDER BY area_council_id`, electionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.AreaCouncilResult{}
+	index := make(map[string]int)
+	for rows.Next() {
+		r, err := scanAreaCouncilResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[r.AreaCouncilID] = len(results)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	partyRows, err := s.DB.Query("SELECT area_council_id, party_name, score FROM area_council_party_results WHERE election_id = $1", electionID)
+	if err != nil {
+		return nil, err
+	}
+	defer partyRows.Close()
+	for partyRows.Next() {
+		var lgaID, party string
+		var score int
+		if err := partyRows.Scan(&lgaID, &party, &score); err != nil {
+			return nil, err
+		}
+		if i, ok := index[lgaID]; ok {
+			results[i].PartyResults[party] = score
+		}
+	}
+	return results, partyRows.Err()
+}
+
+// SaveAreaCouncilResult implements CollationStore. The row is created if
+// needed and locked before change sees it, as SaveWardResult does for wards.
+func (s *SQLStore) SaveAreaCouncilResult(electionID, lgaID string, change func(*models.AreaCouncilResult) error) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO area_council_results (election_id, area_council_id) VALUES ($1, $2)
+		ON CONFLICT (election_id, area_council_id) DO NOTHING`, electionID, lgaID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("SELECT 1 FROM area_council_results WHERE election_id = $1 AND area_council_id = $2 FOR UPDATE", electionID, lgaID); err != nil {
+		return err
+	}
+	r, err := loadAreaCouncilResult(tx, electionID, lgaID)
+	if err != nil {
+		return err
+	}
+	previousScores := r.PartyResults
+	if err := change(&r); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE area_council_results SET
+			arrival_time = $3, collation_start_time = $4, inec_staff = $5, security_present = $6, party_agents = $7,
+			ec8b_submitted = $8, ec8c_collated = $9, csrvs_done = $10, votes_announced = $11,
+			agents_countersigned = $12, ec60e_displayed = $13,
+			accredited_voters = $14, valid_votes = $15, rejected_votes = $16, votes_cast = $17,
+			observer_permitted = $18, denial_reason = $19, denied_at = $20, updated_at = NOW()
+		WHERE election_id = $1 AND area_council_id = $2`,
+		electionID, lgaID, nullableString(r.ArrivalTime), nullableString(r.CollationStartTime),
+		r.INECStaff, r.SecurityPresent, r.PartyAgents,
+		r.EC8BSubmitted, r.EC8CCollated, r.CSRVSDone, r.VotesAnnounced, r.AgentsCountersigned, r.EC60EDisplayed,
+		r.AccreditedVoters, r.ValidVotes, r.RejectedVotes, r.VotesCast,
+		r.ObserverPermitted, nullableString(r.DenialReason), r.DeniedAt)
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(previousScores, r.PartyResults) {
+		if _, err := tx.Exec("DELETE FROM area_council_party_results WHERE election_id = $1 AND area_council_id = $2", electionID, lgaID); err != nil {
+			return err
+		}
+		for party, score := range r.PartyResults {
+			_, err := tx.Exec("INSERT INTO area_council_party_results (election_id, area_council_id, party_name, score) VALUES ($1, $2, $3, $4)",
+				electionID, lgaID, party, score)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+const incidentColumns = `
+	i.id, i.election_id, COALESCE(i.ward_id, ''), COALESCE(w.area_council_id, ''), i.title, COALESCE(i.description, ''),
+	COALESCE(i.type, ''), COALESCE(i.severity, ''), i.status, i.timestamp,
+	i.reported_by, i.status_updated_by, i.status_updated_at`
+
+func scanIncident(row rowScanner) (models.Incident, error) {
+	var inc models.Incident
+	var reportedBy, updatedBy sql.NullInt64
+	var updatedAt sql.NullTime
+	err := row.Scan(
+		&inc.ID, &inc.ElectionID, &inc.WardID, &inc.AreaCouncilID, &inc.Title, &inc.Description,
+		&inc.Type, &inc.Severity, &inc.Status, &inc.Timestamp,
+		&reportedBy, &updatedBy, &updatedAt,
+	)
+	if err != nil {
+		return inc, err
+	}
+	inc.ReportedBy = nullIntPtr(reportedBy)
+	inc.StatusUpdatedBy = nullIntPtr(updatedBy)
+	inc.StatusUpdatedAt = nullTimePtr(updatedAt)
+	return inc, nil
+}
+
+// CreateIncident implements IncidentStore
+func (s *SQLStore) CreateIncident(inc models.Incident) (models.Incident, error) {
+	reportedBy := 0
+	if inc.ReportedBy != nil {
+		reportedBy = *inc.ReportedBy
+	}
+	var id int
+	err := s.DB.QueryRow(`
+		INSERT INTO incidents (election_id, ward_id, title, description, type, severity, status, timestamp, reported_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		inc.ElectionID, inc.WardID, inc.Title, inc.Description, inc.Type, inc.Severity,
+		inc.Status, inc.Timestamp, nullUserID(reportedBy),
+	).Scan(&id)
+	if err != nil {
+		return inc, err
+	}
+	return scanIncident(s.DB.QueryRow(`SELECT `+incidentColumns+`
+		FROM incidents i JOIN wards w ON i.ward_id = w.id WHERE i.id = $1`, id))
+}
+
+// Incident implements IncidentStore
+func (s *SQLStore) Incident(id int) (models.Incident, error) {
+	inc, err := scanIncident(s.DB.QueryRow(`SELECT `+incidentColumns+`
+		FROM incidents i JOIN wards w ON i.ward_id = w.id WHERE i.id = $1`, id))
+	if err != nil {
+		return inc, notFound(err)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT id, incident_id, from_status, to_status, COALESCE(note, ''), changed_by, changed_at
+		FROM incident_status_history WHERE incident_id = $1 ORDER BY changed_at, id`, inc.ID)
+	if err != nil {
+		return inc, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.IncidentStatusChange
+		var changedBy sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.IncidentID, &c.FromStatus, &c.ToStatus, &c.Note, &changedBy, &c.ChangedAt); err != nil {
+			return inc, err
+		}
+		c.ChangedBy = nullIntPtr(changedBy)
+		inc.History = append(inc.History, c)
+	}
+	return inc, rows.Err()
+}
+
+// Incidents implements IncidentStore
+func (s *SQLStore) Incidents(f IncidentFilter) ([]models.Incident, int, error) {
+	var conditions []string
+	var args []interface{}
+	addFilter := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.ElectionID != "" {
+		addFilter("i.election_id = $%d", f.ElectionID)
+	}
+	if f.WardID != "" {
+		addFilter("i.ward_id = $%d", f.WardID)
+	}
+	if f.AreaCouncilID != "" {
+		addFilter("w.area_council_id = $%d", f.AreaCouncilID)
+	}
+	if f.Type != "" {
+		addFilter("LOWER(i.type) = LOWER($%d)", f.Type)
+	}
+	if f.Severity != "" {
+		addFilter("LOWER(i.severity) = LOWER($%d)", f.Severity)
+	}
+	if f.Status != "" {
+		addFilter("i.status = $%d", f.Status)
+	}
+	if !f.From.IsZero() {
+		addFilter("i.timestamp >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		addFilter("i.timestamp <= $%d", f.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err := s.DB.QueryRow("SELECT COUNT(*) FROM incidents i JOIN wards w ON i.ward_id = w.id "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + incidentColumns + `
+		FROM incidents i JOIN wards w ON i.ward_id = w.id
+		` + where + `
+		ORDER BY i.timestamp DESC, i.id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit, f.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	incidents := []models.Incident{}
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		incidents = append(incidents, inc)
+	}
+	return incidents, total, rows.Err()
+}
+
+// ChangeIncidentStatus implements IncidentStore. The incident is locked while
+// its status is checked and changed, so concurrent changes can't both pass.
+func (s *SQLStore) ChangeIncidentStatus(id int, status, note string, userID int, allowed func(from, to string) bool) (models.Incident, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.Incident{}, err
+	}
+	defer tx.Rollback()
+
+	inc, err := scanIncident(tx.QueryRow(`SELECT `+incidentColumns+`
+		FROM incidents i LEFT JOIN wards w ON i.ward_id = w.id
+		WHERE i.id = $1 FOR UPDATE OF i`, id))
+	if err != nil {
+		return inc, notFound(err)
+	}
+	if !allowed(inc.Status, status) {
+		return inc, ErrConflict
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE incidents SET status = $1, status_updated_by = $2, status_updated_at = NOW()
+		WHERE id = $3`, status, nullUserID(userID), id); err != nil {
+		return inc, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO incident_status_history (incident_id, from_status, to_status, note, changed_by)
+		VALUES ($1, $2, $3, $4, $5)`, id, inc.Status, status, note, nullUserID(userID)); err != nil {
+		return inc, err
+	}
+	return inc, tx.Commit()
+}
+
+// IncidentCounts implements IncidentStore
+func (s *SQLStore) IncidentCounts(electionID, wardID string) (map[string][]risk.IncidentCount, error) {
+	rows, err := s.DB.Query(`
+		SELECT ward_id, COALESCE(type, ''), COALESCE(severity, ''), COUNT(*)
+		FROM incidents
+		WHERE election_id = $1 AND ward_id IS NOT NULL AND ($2 = '' OR ward_id = $2)
+		GROUP BY 1, 2, 3`, electionID, wardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string][]risk.IncidentCount)
+	for rows.Next() {
+		var ward string
+		var c risk.IncidentCount
+		if err := rows.Scan(&ward, &c.Type, &c.Severity, &c.Count); err != nil {
+			return nil, err
+		}
+		counts[ward] = append(counts[ward], c)
+	}
+	return counts, rows.Err()
+}
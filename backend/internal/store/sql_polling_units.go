@@ -0,0 +1,456 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// Polling unit statuses the roll-up acts on
+const (
+	puCancelled = "cancelled"
+	puNotOpened = "not_opened"
+)
+
+// sectionPollingUnits is the ward history section for totals rolled up from polling units
+const sectionPollingUnits = "polling_units"
+
+// pollingUnitColumns selects a polling unit with its status in the election bound to $1
+const pollingUnitColumns = `
+	pu.id, pu.code, pu.ward_id, pu.name, pu.registered_voters, COALESCE(s.status, 'not_opened'), pu.updated_at
+	FROM polling_units pu
+	LEFT JOIN polling_unit_statuses s ON s.polling_unit_id = pu.id AND s.election_id = $1`
+
+func scanPollingUnit(row rowScanner) (models.PollingUnit, error) {
+	var pu models.PollingUnit
+	err := row.Scan(&pu.ID, &pu.Code, &pu.WardID, &pu.Name, &pu.RegisteredVoters, &pu.Status, &pu.UpdatedAt)
+	return pu, err
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// PollingUnits implements PollingUnitStore
+func (s *SQLStore) PollingUnits(electionID, wardID string) ([]models.PollingUnit, error) {
+	rows, err := s.DB.Query(`SELECT `+pollingUnitColumns+`
+		WHERE pu.ward_id = $2 ORDER BY pu.code`, electionID, wardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := []models.PollingUnit{}
+	for rows.Next() {
+		pu, err := scanPollingUnit(rows)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, pu)
+	}
+	return units, rows.Err()
+}
+
+// PollingUnit implements PollingUnitStore
+func (s *SQLStore) PollingUnit(electionID string, id int) (models.PollingUnit, error) {
+	pu, err := scanPollingUnit(s.DB.QueryRow(`SELECT `+pollingUnitColumns+`
+		WHERE pu.id = $2`, electionID, id))
+	return pu, notFound(err)
+}
+
+// PollingUnitResult implements PollingUnitStore
+func (s *SQLStore) PollingUnitResult(electionID string, id int) (models.PollingUnitResult, error) {
+	var res models.PollingUnitResult
+	err := s.DB.QueryRow(`
+		SELECT election_id, polling_unit_id, COALESCE(accredited_voters, 0), COALESCE(valid_votes, 0),
+			COALESCE(rejected_votes, 0), COALESCE(votes_cast, 0), updated_at
+		FROM pu_results WHERE election_id = $1 AND polling_unit_id = $2`, electionID, id).Scan(
+		&res.ElectionID, &res.PollingUnitID, &res.AccreditedVoters, &res.ValidVotes, &res.RejectedVotes, &res.VotesCast, &res.UpdatedAt,
+	)
+	if err != nil {
+		return res, notFound(err)
+	}
+
+	rows, err := s.DB.Query("SELECT party_name, score FROM pu_party_results WHERE election_id = $1 AND polling_unit_id = $2", electionID, id)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+	res.PartyResults = make(map[string]int)
+	for rows.Next() {
+		var party string
+		var score int
+		if err := rows.Scan(&party, &score); err != nil {
+			return res, err
+		}
+		res.PartyResults[party] = score
+	}
+	return res, rows.Err()
+}
+
+// PollingUnitStatusCounts implements PollingUnitStore
+func (s *SQLStore) PollingUnitStatusCounts(electionID string) (map[string]int, error) {
+	rows, err := s.DB.Query(`
+		SELECT COALESCE(s.status, 'not_opened'), COUNT(*)
+		FROM polling_units pu
+		LEFT JOIN polling_unit_statuses s ON s.polling_unit_id = pu.id AND s.election_id = $1
+		GROUP BY 1`, electionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// PollingUnitWards implements PollingUnitStore
+func (s *SQLStore) PollingUnitWards(codes []string) ([]string, error) {
+	rows, err := s.DB.Query("SELECT DISTINCT ward_id FROM polling_units WHERE code = ANY($1) ORDER BY ward_id", pq.Array(codes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wards []string
+	for rows.Next() {
+		var wardID string
+		if err := rows.Scan(&wardID); err != nil {
+			return nil, err
+		}
+		wards = append(wards, wardID)
+	}
+	return wards, rows.Err()
+}
+
+// CreatePollingUnit implements PollingUnitStore
+func (s *SQLStore) CreatePollingUnit(pu models.PollingUnit, electionID string, userID int) (models.PollingUnit, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return pu, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO polling_units (code, ward_id, name, registered_voters)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, updated_at`,
+		pu.Code, pu.WardID, pu.Name, pu.RegisteredVoters,
+	).Scan(&pu.ID, &pu.UpdatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return pu, ErrConflict
+	case isForeignKeyViolation(err):
+		return pu, ErrNotFound
+	case err != nil:
+		return pu, err
+	}
+	if pu.Status != "" {
+		if err := setPollingUnitStatus(tx, electionID, pu.ID, pu.Status, userID); err != nil {
+			return pu, err
+		}
+	}
+	return pu, tx.Commit()
+}
+
+// UpdatePollingUnit implements PollingUnitStore. Moving a unit to another
+// ward rolls both wards up again in every election it has results in.
+func (s *SQLStore) UpdatePollingUnit(pu models.PollingUnit, electionID string, userID int) (models.PollingUnit, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return pu, err
+	}
+	defer tx.Rollback()
+
+	var previousWard string
+	err = tx.QueryRow("SELECT ward_id FROM polling_units WHERE id = $1 FOR UPDATE", pu.ID).Scan(&previousWard)
+	if err != nil {
+		return pu, notFound(err)
+	}
+
+	err = tx.QueryRow(`
+		UPDATE polling_units SET code = $1, ward_id = $2, name = $3, registered_voters = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at`,
+		pu.Code, pu.WardID, pu.Name, pu.RegisteredVoters, pu.ID,
+	).Scan(&pu.UpdatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return pu, ErrConflict
+	case isForeignKeyViolation(err):
+		return pu, ErrNotFound
+	case err != nil:
+		return pu, err
+	}
+	if pu.Status != "" {
+		if err := setPollingUnitStatus(tx, electionID, pu.ID, pu.Status, userID); err != nil {
+			return pu, err
+		}
+	}
+	if previousWard != pu.WardID {
+		if err := rollUpPollingUnitMove(tx, pu.ID, previousWard, pu.WardID, userID); err != nil {
+			return pu, err
+		}
+	}
+	return pu, tx.Commit()
+}
+
+// DeletePollingUnit implements PollingUnitStore
+func (s *SQLStore) DeletePollingUnit(id, userID int) (models.PollingUnit, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.PollingUnit{}, err
+	}
+	defer tx.Rollback()
+
+	// Remember which elections the unit contributed results to before its rows cascade away
+	elections, err := pollingUnitElections(tx, id)
+	if err != nil {
+		return models.PollingUnit{}, err
+	}
+
+	var pu models.PollingUnit
+	err = tx.QueryRow(`
+		DELETE FROM polling_units WHERE id = $1
+		RETURNING id, code, ward_id, name, registered_voters, updated_at`, id).Scan(
+		&pu.ID, &pu.Code, &pu.WardID, &pu.Name, &pu.RegisteredVoters, &pu.UpdatedAt)
+	if err != nil {
+		return pu, notFound(err)
+	}
+	for _, electionID := range elections {
+		if err := rollUpWardResults(tx, electionID, pu.WardID, userID); err != nil {
+			return pu, err
+		}
+	}
+	return pu, tx.Commit()
+}
+
+// ImportPollingUnits implements PollingUnitStore
+func (s *SQLStore) ImportPollingUnits(units []models.PollingUnit, electionID string, userID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO polling_units (code, ward_id, name, registered_voters, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (code) DO UPDATE SET
+			ward_id = EXCLUDED.ward_id,
+			name = EXCLUDED.name,
+			registered_voters = EXCLUDED.registered_voters,
+			updated_at = NOW()
+		RETURNING id
+	`
+	for i, pu := range units {
+		var previousWard string
+		err := tx.QueryRow("SELECT ward_id FROM polling_units WHERE code = $1 FOR UPDATE", pu.Code).Scan(&previousWard)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		var id int
+		if err := tx.QueryRow(query, pu.Code, pu.WardID, pu.Name, pu.RegisteredVoters).Scan(&id); err != nil {
+			return &ImportError{Row: i + 1, Code: pu.Code, Err: err}
+		}
+		if previousWard != "" && previousWard != pu.WardID {
+			if err := rollUpPollingUnitMove(tx, id, previousWard, pu.WardID, userID); err != nil {
+				return err
+			}
+		}
+		if pu.Status != "" {
+			if err := setPollingUnitStatus(tx, electionID, id, pu.Status, userID); err != nil {
+				return &ImportError{Row: i + 1, Code: pu.Code, Err: err}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// SetPollingUnitStatus implements PollingUnitStore
+func (s *SQLStore) SetPollingUnitStatus(electionID string, id int, status string, userID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPollingUnitStatus(tx, electionID, id, status, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setPollingUnitStatus records a polling unit's status in an election.
+// Cancelled units don't count towards their ward's totals, so a move into or
+// out of cancelled rolls the ward up again if the unit has results.
+func setPollingUnitStatus(tx *sql.Tx, electionID string, puID int, status string, userID int) error {
+	var from, wardID string
+	var hasResults bool
+	err := tx.QueryRow(`
+		SELECT COALESCE(s.status, 'not_opened'), pu.ward_id,
+			EXISTS (SELECT 1 FROM pu_results r WHERE r.election_id = $1 AND r.polling_unit_id = pu.id)
+		FROM polling_units pu
+		LEFT JOIN polling_unit_statuses s ON s.polling_unit_id = pu.id AND s.election_id = $1
+		WHERE pu.id = $2`, electionID, puID).Scan(&from, &wardID, &hasResults)
+	if err != nil {
+		return notFound(err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO polling_unit_statuses (election_id, polling_unit_id, status, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (election_id, polling_unit_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			updated_at = NOW()`, electionID, puID, status)
+	if err != nil {
+		return err
+	}
+	if hasResults && (from == puCancelled) != (status == puCancelled) {
+		return rollUpWardResults(tx, electionID, wardID, userID)
+	}
+	return nil
+}
+
+// SavePollingUnitResult implements PollingUnitStore, rolling the unit's ward up again
+func (s *SQLStore) SavePollingUnitResult(res models.PollingUnitResult, userID int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wardID string
+	if err := tx.QueryRow("SELECT ward_id FROM polling_units WHERE id = $1", res.PollingUnitID).Scan(&wardID); err != nil {
+		return notFound(err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO pu_results (
+			election_id, polling_unit_id, accredited_voters, valid_votes, rejected_votes, votes_cast, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (election_id, polling_unit_id) DO UPDATE SET
+			accredited_voters = EXCLUDED.accredited_voters,
+			valid_votes = EXCLUDED.valid_votes,
+			rejected_votes = EXCLUDED.rejected_votes,
+			votes_cast = EXCLUDED.votes_cast,
+			updated_at = NOW()`,
+		res.ElectionID, res.PollingUnitID, res.AccreditedVoters, res.ValidVotes, res.RejectedVotes, res.VotesCast)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM pu_party_results WHERE election_id = $1 AND polling_unit_id = $2", res.ElectionID, res.PollingUnitID); err != nil {
+		return err
+	}
+	for party, score := range res.PartyResults {
+		_, err := tx.Exec("INSERT INTO pu_party_results (election_id, polling_unit_id, party_name, score) VALUES ($1, $2, $3, $4)",
+			res.ElectionID, res.PollingUnitID, party, score)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := rollUpWardResults(tx, res.ElectionID, wardID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pollingUnitElections lists the elections a polling unit has results in
+func pollingUnitElections(tx *sql.Tx, puID int) ([]string, error) {
+	rows, err := tx.Query("SELECT election_id FROM pu_results WHERE polling_unit_id = $1", puID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var elections []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		elections = append(elections, id)
+	}
+	return elections, rows.Err()
+}
+
+// rollUpPollingUnitMove recomputes both wards' totals after a polling unit changes ward
+func rollUpPollingUnitMove(tx *sql.Tx, puID int, fromWard, toWard string, userID int) error {
+	elections, err := pollingUnitElections(tx, puID)
+	if err != nil {
+		return err
+	}
+	for _, electionID := range elections {
+		if err := rollUpWardResults(tx, electionID, fromWard, userID); err != nil {
+			return err
+		}
+		if err := rollUpWardResults(tx, electionID, toWard, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollUpWardResults recomputes a ward's vote counts and party scores in an
+// election from the results of its polling units that weren't cancelled, and
+// records the totals as a new ward version. Only call it for a ward whose
+// polling unit results changed: wards that never had any are left alone, so
+// ward-level submissions keep working where PU data isn't collected, while a
+// ward whose last PU result is deleted, moved away or cancelled drops to zero
+// rather than keeping stale totals.
+func rollUpWardResults(tx *sql.Tx, electionID, wardID string, userID int) error {
+	// Cancelled units' results stay on record but don't count
+	const counted = `
+		FROM polling_units pu
+		LEFT JOIN polling_unit_statuses s ON s.polling_unit_id = pu.id AND s.election_id = $1
+		WHERE pu.ward_id = $2 AND COALESCE(s.status, 'not_opened') <> 'cancelled'`
+
+	_, err := tx.Exec(`
+		INSERT INTO ward_results (election_id, ward_id, accredited_voters, valid_votes, rejected_votes, votes_cast, updated_at)
+		SELECT $1::VARCHAR, $2::VARCHAR,
+			COALESCE(SUM(r.accredited_voters), 0), COALESCE(SUM(r.valid_votes), 0),
+			COALESCE(SUM(r.rejected_votes), 0), COALESCE(SUM(r.votes_cast), 0), NOW()
+		FROM pu_results r
+		JOIN (SELECT pu.id`+counted+`) pu ON r.polling_unit_id = pu.id
+		WHERE r.election_id = $1
+		ON CONFLICT (election_id, ward_id) DO UPDATE SET
+			accredited_voters = EXCLUDED.accredited_voters,
+			valid_votes = EXCLUDED.valid_votes,
+			rejected_votes = EXCLUDED.rejected_votes,
+			votes_cast = EXCLUDED.votes_cast,
+			updated_at = NOW()`, electionID, wardID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM party_results WHERE election_id = $1 AND ward_id = $2", electionID, wardID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO party_results (election_id, ward_id, party_name, score)
+		SELECT $1::VARCHAR, $2::VARCHAR, p.party_name, SUM(p.score)
+		FROM pu_party_results p
+		JOIN (SELECT pu.id`+counted+`) pu ON p.polling_unit_id = pu.id
+		WHERE p.election_id = $1
+		GROUP BY p.party_name`, electionID, wardID)
+	if err != nil {
+		return err
+	}
+
+	_, err = recordWardVersion(tx, electionID, wardID, sectionPollingUnits, userID, nil)
+	return err
+}
@@ -0,0 +1,449 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// wardResultColumns selects a ward_results row aliased wr, with the fields a
+// section hasn't reported yet at their zero values
+const wardResultColumns = `wr.election_id, wr.ward_id, COALESCE(wr.arrival_time, ''), COALESCE(wr.collation_start_time, ''),
+	COALESCE(wr.inec_staff, 0), COALESCE(wr.security_present, false), COALESCE(wr.party_agents, 0),
+	COALESCE(wr.ec8b_submitted, false), COALESCE(wr.ec8c_collated, false), COALESCE(wr.csrvs_done, false),
+	COALESCE(wr.votes_announced, false), COALESCE(wr.agents_countersigned, false), COALESCE(wr.ec60e_displayed, false),
+	COALESCE(wr.accredited_voters, 0), COALESCE(wr.valid_votes, 0), COALESCE(wr.rejected_votes, 0), COALESCE(wr.votes_cast, 0),
+	wr.observer_permitted, COALESCE(wr.denial_reason, ''), wr.denied_at, wr.cancelled_pus, wr.cancelled_pu_voters,
+	COALESCE(wr.updated_at, NOW())`
+
+// scanWardResult scans wardResultColumns followed by any extra columns
+func scanWardResult(row rowScanner, extra ...interface{}) (models.WardResult, error) {
+	var r models.WardResult
+	var observerPermitted sql.NullBool
+	var deniedAt sql.NullTime
+	dest := []interface{}{
+		&r.ElectionID, &r.WardID, &r.ArrivalTime, &r.CollationStartTime,
+		&r.INECStaff, &r.SecurityPresent, &r.PartyAgents,
+		&r.EC8BSubmitted, &r.EC8CCollated, &r.CSRVSDone,
+		&r.VotesAnnounced, &r.AgentsCountersigned, &r.EC60EDisplayed,
+		&r.AccreditedVoters, &r.ValidVotes, &r.RejectedVotes, &r.VotesCast,
+		&observerPermitted, &r.DenialReason, &deniedAt, &r.CancelledPUs, &r.CancelledPUVoters,
+		&r.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return r, err
+	}
+	r.ObserverPermitted = nullBoolPtr(observerPermitted)
+	r.DeniedAt = nullTimePtr(deniedAt)
+	r.PartyResults = make(map[string]int)
+	return r, nil
+}
+
+// loadWardSnapshot reads a ward's current result row and party scores within a transaction
+func loadWardSnapshot(tx *sql.Tx, electionID, wardID string) (models.WardResult, error) {
+	s, err := scanWardResult(tx.QueryRow(`SELECT `+wardResultColumns+`
+		FROM ward_results wr WHERE wr.election_id = $1 AND wr.ward_id = $2`, electionID, wardID))
+	if err != nil {
+		return s, err
+	}
+
+	rows, err := tx.Query("SELECT party_name, score FROM party_results WHERE election_id = $1 AND ward_id = $2", electionID, wardID)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var party string
+		var score int
+		if err := rows.Scan(&party, &score); err != nil {
+			return s, err
+		}
+		s.PartyResults[party] = score
+	}
+	return s, rows.Err()
+}
+
+// snapshotFields flattens a snapshot into comparable fields, with party scores
+// keyed as party_results.<PARTY>. The update timestamp is not a field of its own.
+func snapshotFields(s *models.WardResult) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if s == nil {
+		return fields
+	}
+	raw, _ := json.Marshal(s)
+	json.Unmarshal(raw, &fields)
+	delete(fields, "election_id")
+	delete(fields, "ward_id")
+	delete(fields, "updated_at")
+	delete(fields, "party_results")
+	for party, score := range s.PartyResults {
+		fields["party_results."+party] = float64(score)
+	}
+	return fields
+}
+
+// diffSnapshots returns the fields that differ between two snapshots. A nil
+// previous snapshot means every field of the next one is new.
+func diffSnapshots(prev, next *models.WardResult) map[string]models.FieldChange {
+	before, after := snapshotFields(prev), snapshotFields(next)
+	diff := make(map[string]models.FieldChange)
+	for field, to := range after {
+		from, ok := before[field]
+		if !ok || !reflect.DeepEqual(from, to) {
+			diff[field] = models.FieldChange{From: from, To: to}
+		}
+	}
+	for field, from := range before {
+		if _, ok := after[field]; !ok {
+			diff[field] = models.FieldChange{From: from, To: nil}
+		}
+	}
+	return diff
+}
+
+// recordWardVersion snapshots a ward's submission after a change and stores it
+// as the next version, with its diff against the previous version. Call it in
+// the same transaction as the write, after the ward_results row has been
+// written (which locks the row, so concurrent submissions are numbered in
+// order). A recorded change sends the submission back to draft for review.
+// It returns 0 without recording anything if nothing changed.
+func recordWardVersion(tx *sql.Tx, electionID, wardID, section string, userID int, revertedFrom *int) (int, error) {
+	current, err := loadWardSnapshot(tx, electionID, wardID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var prev *models.WardResult
+	var latest int
+	var raw []byte
+	err = tx.QueryRow(`
+		SELECT version, snapshot FROM ward_result_versions
+		WHERE election_id = $1 AND ward_id = $2
+		ORDER BY version DESC LIMIT 1`, electionID, wardID).Scan(&latest, &raw)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if err == nil {
+		prev = &models.WardResult{}
+		if err := json.Unmarshal(raw, prev); err != nil {
+			return 0, err
+		}
+	}
+
+	diff := diffSnapshots(prev, &current)
+	if len(diff) == 0 && prev != nil && revertedFrom == nil {
+		return 0, nil
+	}
+
+	snapshot, err := json.Marshal(current)
+	if err != nil {
+		return 0, err
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return 0, err
+	}
+
+	version := latest + 1
+	_, err = tx.Exec(`
+		INSERT INTO ward_result_versions (election_id, ward_id, version, section, snapshot, diff, submitted_by, reverted_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		electionID, wardID, version, section, snapshot, diffJSON, nullUserID(userID), revertedFrom)
+	if err != nil {
+		return 0, err
+	}
+	return version, resetWardReview(tx, electionID, wardID, userID)
+}
+
+// Review statuses the store acts on itself
+const (
+	reviewDraft     = "draft" // Changed since it was last submitted for review
+	reviewSubmitted = "submitted"
+	reviewApproved  = "approved"
+)
+
+// resetWardReview returns a ward's submission to draft after its data changes,
+// so edits to approved or pending figures must be reviewed again.
+func resetWardReview(tx *sql.Tx, electionID, wardID string, userID int) error {
+	var from string
+	err := tx.QueryRow("SELECT review_status FROM ward_results WHERE election_id = $1 AND ward_id = $2", electionID, wardID).Scan(&from)
+	if err != nil {
+		return err
+	}
+	if from == reviewDraft {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE ward_results SET review_status = 'draft', review_reason = NULL
+		WHERE election_id = $1 AND ward_id = $2`, electionID, wardID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO ward_review_history (election_id, ward_id, version, from_status, to_status, reason, changed_by)
+		VALUES ($1, $2, (SELECT MAX(version) FROM ward_result_versions WHERE election_id = $1 AND ward_id = $2), $3, $4, $5, $6)`,
+		electionID, wardID, from, reviewDraft, "Submission changed", nullUserID(userID))
+	return err
+}
+
+// SaveWardResult implements ResultStore. The ward's row is created if needed
+// and locked before change sees it, so concurrent saves of different sections
+// don't overwrite each other.
+func (s *SQLStore) SaveWardResult(electionID, wardID, section string, userID int, revertedFrom *int, change func(*models.WardResult) error) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO ward_results (election_id, ward_id) VALUES ($1, $2) ON CONFLICT (election_id, ward_id) DO NOTHING", electionID, wardID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("SELECT 1 FROM ward_results WHERE election_id = $1 AND ward_id = $2 FOR UPDATE", electionID, wardID); err != nil {
+		return 0, err
+	}
+	r, err := loadWardSnapshot(tx, electionID, wardID)
+	if err != nil {
+		return 0, err
+	}
+	previousScores := r.PartyResults
+	if err := change(&r); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE ward_results SET
+			arrival_time = $3, collation_start_time = $4, inec_staff = $5, security_present = $6, party_agents = $7,
+			ec8b_submitted = $8, ec8c_collated = $9, csrvs_done = $10, votes_announced = $11,
+			agents_countersigned = $12, ec60e_displayed = $13,
+			accredited_voters = $14, valid_votes = $15, rejected_votes = $16, votes_cast = $17,
+			observer_permitted = $18, denial_reason = $19, denied_at = $20,
+			cancelled_pus = $21, cancelled_pu_voters = $22, updated_at = NOW()
+		WHERE election_id = $1 AND ward_id = $2`,
+		electionID, wardID, nullableString(r.ArrivalTime), nullableString(r.CollationStartTime),
+		r.INECStaff, r.SecurityPresent, r.PartyAgents,
+		r.EC8BSubmitted, r.EC8CCollated, r.CSRVSDone, r.VotesAnnounced, r.AgentsCountersigned, r.EC60EDisplayed,
+		r.AccreditedVoters, r.ValidVotes, r.RejectedVotes, r.VotesCast,
+		r.ObserverPermitted, nullableString(r.DenialReason), r.DeniedAt, r.CancelledPUs, r.CancelledPUVoters)
+	if err != nil {
+		return 0, err
+	}
+
+	// Replace the ward's party scores so parties dropped from a resubmission don't linger
+	if !reflect.DeepEqual(previousScores, r.PartyResults) {
+		if _, err := tx.Exec("DELETE FROM party_results WHERE election_id = $1 AND ward_id = $2", electionID, wardID); err != nil {
+			return 0, err
+		}
+		for party, score := range r.PartyResults {
+			_, err := tx.Exec("INSERT INTO party_results (election_id, ward_id, party_name, score) VALUES ($1, $2, $3, $4)", electionID, wardID, party, score)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	version, err := recordWardVersion(tx, electionID, wardID, section, userID, revertedFrom)
+	if err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+const wardVersionColumns = `v.id, v.election_id, v.ward_id, v.version, v.section, v.snapshot, v.diff,
+	v.submitted_by, COALESCE(u.username, ''), v.reverted_from, v.created_at`
+
+func scanWardVersion(row rowScanner) (models.WardResultVersion, error) {
+	var v models.WardResultVersion
+	var snapshot, diff []byte
+	var submittedBy, revertedFrom sql.NullInt64
+	err := row.Scan(&v.ID, &v.ElectionID, &v.WardID, &v.Version, &v.Section, &snapshot, &diff,
+		&submittedBy, &v.SubmittedByName, &revertedFrom, &v.CreatedAt)
+	if err != nil {
+		return v, err
+	}
+	v.SubmittedBy = nullIntPtr(submittedBy)
+	v.RevertedFrom = nullIntPtr(revertedFrom)
+	if err := json.Unmarshal(snapshot, &v.Snapshot); err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(diff, &v.Diff)
+	return v, err
+}
+
+// WardVersion implements ResultStore
+func (s *SQLStore) WardVersion(electionID, wardID string, version int) (models.WardResultVersion, error) {
+	v, err := scanWardVersion(s.DB.QueryRow(`
+		SELECT `+wardVersionColumns+`
+		FROM ward_result_versions v
+		LEFT JOIN users u ON v.submitted_by = u.id
+		WHERE v.election_id = $1 AND v.ward_id = $2 AND v.version = $3`, electionID, wardID, version))
+	return v, notFound(err)
+}
+
+// WardHistory implements ResultStore
+func (s *SQLStore) WardHistory(electionID, wardID string) ([]models.WardResultVersion, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+wardVersionColumns+`
+		FROM ward_result_versions v
+		LEFT JOIN users u ON v.submitted_by = u.id
+		WHERE v.election_id = $1 AND v.ward_id = $2
+		ORDER BY v.version DESC`, electionID, wardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.WardResultVersion{}
+	for rows.Next() {
+		v, err := scanWardVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, v)
+	}
+	return history, rows.Err()
+}
+
+// publishedFilter limits a query on ward_results aliased wr to the submissions
+// the public can see when only published results count: approved ones, and
+// ones changed since they were last approved
+func publishedFilter(published bool) string {
+	if published {
+		return " AND (wr.review_status = '" + reviewApproved + "' OR wr.approved_version IS NOT NULL)"
+	}
+	return ""
+}
+
+// approvedFilter limits a query on ward_results aliased wr to approved
+// submissions when only published results count
+func approvedFilter(published bool) string {
+	if published {
+		return " AND wr.review_status = '" + reviewApproved + "'"
+	}
+	return ""
+}
+
+// publishedSnapshotColumn selects, when only published results count, the
+// snapshot of the version last approved for a submission changed since; NULL
+// when the row itself is what's shown
+func publishedSnapshotColumn(published bool) string {
+	if published {
+		return `(SELECT v.snapshot FROM ward_result_versions v
+			WHERE v.election_id = wr.election_id AND v.ward_id = wr.ward_id
+				AND v.version = wr.approved_version AND wr.review_status <> '` + reviewApproved + `')`
+	}
+	return "NULL"
+}
+
+// usePublishedSnapshot replaces a submission with the approved snapshot
+// selected by publishedSnapshotColumn, if there is one
+func usePublishedSnapshot(sub *WardSubmission, snapshot []byte) error {
+	if snapshot == nil {
+		return nil
+	}
+	var r models.WardResult
+	if err := json.Unmarshal(snapshot, &r); err != nil {
+		return err
+	}
+	if r.PartyResults == nil {
+		r.PartyResults = make(map[string]int)
+	}
+	sub.WardResult, sub.ReviewStatus = r, reviewApproved
+	return nil
+}
+
+// WardResult implements ResultStore. A published submission changed since it
+// was approved is shown as it was approved.
+func (s *SQLStore) WardResult(electionID, wardID string, published bool) (WardSubmission, error) {
+	var sub WardSubmission
+	var snapshot []byte
+	r, err := scanWardResult(s.DB.QueryRow(`SELECT `+wardResultColumns+`, wr.review_status, `+publishedSnapshotColumn(published)+`
+		FROM ward_results wr WHERE wr.election_id = $1 AND wr.ward_id = $2`+publishedFilter(published),
+		electionID, wardID), &sub.ReviewStatus, &snapshot)
+	if err != nil {
+		return sub, notFound(err)
+	}
+	sub.WardResult = r
+	if snapshot != nil {
+		return sub, usePublishedSnapshot(&sub, snapshot)
+	}
+
+	rows, err := s.DB.Query("SELECT party_name, score FROM party_results WHERE election_id = $1 AND ward_id = $2", electionID, wardID)
+	if err != nil {
+		return sub, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var party string
+		var score int
+		if err := rows.Scan(&party, &score); err != nil {
+			return sub, err
+		}
+		sub.PartyResults[party] = score
+	}
+	return sub, rows.Err()
+}
+
+// WardResults implements ResultStore in two queries, however many wards there are
+func (s *SQLStore) WardResults(electionID string, published bool) ([]WardSubmission, error) {
+	rows, err := s.DB.Query(`SELECT `+wardResultColumns+`, wr.review_status, `+publishedSnapshotColumn(published)+`
+		FROM ward_results wr WHERE wr.election_id = $1`+publishedFilter(published)+`
+		ORDER BY wr.ward_id`, electionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []WardSubmission{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var sub WardSubmission
+		var snapshot []byte
+		r, err := scanWardResult(rows, &sub.ReviewStatus, &snapshot)
+		if err != nil {
+			return nil, err
+		}
+		sub.WardResult = r
+		if snapshot != nil {
+			if err := usePublishedSnapshot(&sub, snapshot); err != nil {
+				return nil, err
+			}
+		} else {
+			index[r.WardID] = len(subs)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Scores for the submissions shown as they stand; snapshots carry their own
+	partyRows, err := s.DB.Query(`
+		SELECT pr.ward_id, pr.party_name, pr.score
+		FROM party_results pr
+		JOIN ward_results wr ON wr.election_id = pr.election_id AND wr.ward_id = pr.ward_id
+		WHERE pr.election_id = $1`+approvedFilter(published), electionID)
+	if err != nil {
+		return nil, err
+	}
+	defer partyRows.Close()
+	for partyRows.Next() {
+		var wardID, party string
+		var score int
+		if err := partyRows.Scan(&wardID, &party, &score); err != nil {
+			return nil, err
+		}
+		if i, ok := index[wardID]; ok {
+			subs[i].PartyResults[party] = score
+		}
+	}
+	return subs, partyRows.Err()
+}
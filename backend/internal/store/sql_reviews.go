@@ -0,0 +1,143 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+const wardReviewColumns = `wr.election_id, wr.ward_id, w.name, w.area_council_id, wr.review_status,
+	COALESCE(wr.review_reason, ''), wr.submitted_by, wr.submitted_at, wr.reviewed_by, wr.reviewed_at,
+	wr.reviewed_version, (SELECT MAX(version) FROM ward_result_versions v WHERE v.election_id = wr.election_id AND v.ward_id = wr.ward_id)`
+
+func scanWardReview(row rowScanner) (models.WardReview, error) {
+	var rv models.WardReview
+	var submittedBy, reviewedBy, reviewedVersion, version sql.NullInt64
+	var submittedAt, reviewedAt sql.NullTime
+	err := row.Scan(&rv.ElectionID, &rv.WardID, &rv.WardName, &rv.AreaCouncilID, &rv.Status,
+		&rv.Reason, &submittedBy, &submittedAt, &reviewedBy, &reviewedAt, &reviewedVersion, &version)
+	if err != nil {
+		return rv, err
+	}
+	rv.SubmittedBy = nullIntPtr(submittedBy)
+	rv.ReviewedBy = nullIntPtr(reviewedBy)
+	rv.ReviewedVersion = nullIntPtr(reviewedVersion)
+	rv.SubmittedAt = nullTimePtr(submittedAt)
+	rv.ReviewedAt = nullTimePtr(reviewedAt)
+	if version.Valid {
+		rv.Version = int(version.Int64)
+	}
+	return rv, nil
+}
+
+// WardReview implements ReviewStore
+func (s *SQLStore) WardReview(electionID, wardID string) (models.WardReview, error) {
+	rv, err := scanWardReview(s.DB.QueryRow(`
+		SELECT `+wardReviewColumns+`
+		FROM ward_results wr
+		JOIN wards w ON wr.ward_id = w.id
+		WHERE wr.election_id = $1 AND wr.ward_id = $2`, electionID, wardID))
+	if err != nil {
+		return rv, notFound(err)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT id, version, from_status, to_status, COALESCE(reason, ''), changed_by, changed_at
+		FROM ward_review_history
+		WHERE election_id = $1 AND ward_id = $2
+		ORDER BY changed_at, id`, electionID, wardID)
+	if err != nil {
+		return rv, err
+	}
+	defer rows.Close()
+
+	rv.History = []models.WardReviewChange{}
+	for rows.Next() {
+		var c models.WardReviewChange
+		var version, changedBy sql.NullInt64
+		if err := rows.Scan(&c.ID, &version, &c.FromStatus, &c.ToStatus, &c.Reason, &changedBy, &c.ChangedAt); err != nil {
+			return rv, err
+		}
+		c.Version = nullIntPtr(version)
+		c.ChangedBy = nullIntPtr(changedBy)
+		rv.History = append(rv.History, c)
+	}
+	return rv, rows.Err()
+}
+
+// ReviewQueue implements ReviewStore
+func (s *SQLStore) ReviewQueue(electionID, status, lgaID string) ([]models.WardReview, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+wardReviewColumns+`
+		FROM ward_results wr
+		JOIN wards w ON wr.ward_id = w.id
+		WHERE wr.election_id = $1 AND wr.review_status = $2 AND ($3 = '' OR w.area_council_id = $3)
+		ORDER BY wr.submitted_at NULLS LAST, w.id`, electionID, status, lgaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queue := []models.WardReview{}
+	for rows.Next() {
+		rv, err := scanWardReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, rv)
+	}
+	return queue, rows.Err()
+}
+
+// ChangeWardReview implements ReviewStore. The ward's row is locked while
+// allowed decides, so the version it sees is the one reviewed.
+func (s *SQLStore) ChangeWardReview(electionID, wardID, status, reason string, userID int, allowed func(models.WardReview) bool) (models.WardReview, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.WardReview{}, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanWardReview(tx.QueryRow(`
+		SELECT `+wardReviewColumns+`
+		FROM ward_results wr
+		JOIN wards w ON wr.ward_id = w.id
+		WHERE wr.election_id = $1 AND wr.ward_id = $2
+		FOR UPDATE OF wr`, electionID, wardID))
+	if err != nil {
+		return before, notFound(err)
+	}
+	if !allowed(before) {
+		return before, ErrConflict
+	}
+
+	var version interface{}
+	if before.Version > 0 {
+		version = before.Version
+	}
+	if status == reviewSubmitted {
+		_, err = tx.Exec(`
+			UPDATE ward_results SET review_status = $3, review_reason = NULL, submitted_by = $4, submitted_at = NOW(),
+				reviewed_by = NULL, reviewed_at = NULL, reviewed_version = NULL
+			WHERE election_id = $1 AND ward_id = $2`, electionID, wardID, status, nullUserID(userID))
+	} else {
+		_, err = tx.Exec(`
+			UPDATE ward_results SET review_status = $3, review_reason = $4, reviewed_by = $5, reviewed_at = NOW(), reviewed_version = $6
+			WHERE election_id = $1 AND ward_id = $2`, electionID, wardID, status, nullableString(reason), nullUserID(userID), version)
+	}
+	if err == nil && status == reviewApproved {
+		_, err = tx.Exec("UPDATE ward_results SET approved_version = $3 WHERE election_id = $1 AND ward_id = $2", electionID, wardID, version)
+	}
+	if err != nil {
+		return before, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO ward_review_history (election_id, ward_id, version, from_status, to_status, reason, changed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		electionID, wardID, version, before.Status, status, nullableString(reason), nullUserID(userID))
+	if err != nil {
+		return before, err
+	}
+	return before, tx.Commit()
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/webhooks"
+)
+
+const webhookColumns = `id, name, url, event_types, COALESCE(area_council_id, ''), active, created_by, created_at, updated_at`
+
+func scanWebhook(row rowScanner) (models.WebhookSubscription, error) {
+	var s models.WebhookSubscription
+	var createdBy sql.NullInt64
+	err := row.Scan(&s.ID, &s.Name, &s.URL, pq.Array(&s.EventTypes), &s.AreaCouncilID, &s.Active, &createdBy, &s.CreatedAt, &s.UpdatedAt)
+	if s.EventTypes == nil {
+		s.EventTypes = []string{}
+	}
+	s.CreatedBy = nullIntPtr(createdBy)
+	return s, notFound(err)
+}
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, COALESCE(last_error, ''), created_at, delivered_at`
+
+func scanWebhookDelivery(row rowScanner) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	var statusCode sql.NullInt64
+	var deliveredAt sql.NullTime
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&statusCode, &d.LastError, &d.CreatedAt, &deliveredAt)
+	d.Payload = payload
+	d.LastStatusCode = nullIntPtr(statusCode)
+	d.DeliveredAt = nullTimePtr(deliveredAt)
+	return d, notFound(err)
+}
+
+// Webhooks implements WebhookStore
+func (s *SQLStore) Webhooks() ([]models.WebhookSubscription, error) {
+	rows, err := s.DB.Query(`SELECT ` + webhookColumns + ` FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// CreateWebhook implements WebhookStore
+func (s *SQLStore) CreateWebhook(sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	var createdBy int
+	if sub.CreatedBy != nil {
+		createdBy = *sub.CreatedBy
+	}
+	return scanWebhook(s.DB.QueryRow(`
+		INSERT INTO webhook_subscriptions (name, url, secret, event_types, area_council_id, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+webhookColumns,
+		sub.Name, sub.URL, sub.Secret, pq.Array(sub.EventTypes), nullableString(sub.AreaCouncilID), sub.Active, nullUserID(createdBy)))
+}
+
+// UpdateWebhook implements WebhookStore
+func (s *SQLStore) UpdateWebhook(sub models.WebhookSubscription, active *bool) (models.WebhookSubscription, error) {
+	return scanWebhook(s.DB.QueryRow(`
+		UPDATE webhook_subscriptions SET
+			name = $2, url = $3, secret = COALESCE($4, secret), event_types = $5, area_council_id = $6,
+			active = COALESCE($7, active), updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+webhookColumns,
+		sub.ID, sub.Name, sub.URL, nullableString(sub.Secret), pq.Array(sub.EventTypes),
+		nullableString(sub.AreaCouncilID), active))
+}
+
+// DeleteWebhook implements WebhookStore
+func (s *SQLStore) DeleteWebhook(id int) (models.WebhookSubscription, error) {
+	return scanWebhook(s.DB.QueryRow("DELETE FROM webhook_subscriptions WHERE id = $1 RETURNING "+webhookColumns, id))
+}
+
+// WebhookDeliveries implements WebhookStore
+func (s *SQLStore) WebhookDeliveries(f WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error) {
+	var conditions []string
+	var args []interface{}
+	addFilter := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.SubscriptionID != 0 {
+		addFilter("subscription_id = $%d", f.SubscriptionID)
+	}
+	if f.Status != "" {
+		addFilter("status = $%d", f.Status)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.DB.QueryRow("SELECT COUNT(*) FROM webhook_deliveries "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries ` + where + `
+		ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit, f.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// WebhookDelivery implements WebhookStore
+func (s *SQLStore) WebhookDelivery(id int) (models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(s.DB.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+	if err != nil {
+		return d, err
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT attempt, status_code, COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempted_at, id`, d.ID)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a models.WebhookAttempt
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&a.Attempt, &statusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return d, err
+		}
+		a.StatusCode = nullIntPtr(statusCode)
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+	return d, rows.Err()
+}
+
+// RetryWebhookDelivery implements WebhookStore
+func (s *SQLStore) RetryWebhookDelivery(id int) (models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(s.DB.QueryRow(`
+		UPDATE webhook_deliveries SET status = $2, attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = $3
+		RETURNING `+webhookDeliveryColumns, id, webhooks.StatusPending, webhooks.StatusDead))
+	if err != ErrNotFound {
+		return d, err
+	}
+	// Tell a delivery that isn't dead apart from one that doesn't exist
+	d, err = scanWebhookDelivery(s.DB.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+	if err != nil {
+		return d, err
+	}
+	return d, ErrConflict
+}
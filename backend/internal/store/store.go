@@ -0,0 +1,346 @@
+// Package store is the storage behind the HTTP handlers. Each kind of data has
+// an interface, implemented over Postgres for the server and in memory for
+// tests, so handlers don't depend on a database connection.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+	"github.com/yiaga/abuja-watch/backend/internal/risk"
+)
+
+var (
+	// ErrNotFound is returned when the record asked for doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write clashes with existing data, such
+	// as a username that is already taken
+	ErrConflict = errors.New("conflict")
+)
+
+// Stores are the stores the handlers work with
+type Stores struct {
+	Elections    ElectionStore
+	Wards        WardStore
+	Results      ResultStore
+	Reviews      ReviewStore
+	PollingUnits PollingUnitStore
+	Collations   CollationStore
+	Parties      PartyStore
+	Incidents    IncidentStore
+	Users        UserStore
+	Sessions     SessionStore
+	Throttles    auth.ThrottleStore
+	APIKeys      APIKeyStore
+	Webhooks     WebhookStore
+	Audit        AuditStore
+}
+
+// ElectionStore keeps elections and the risk engine settings for each
+type ElectionStore interface {
+	// Election returns the election with the given ID
+	Election(id string) (models.Election, error)
+	// ActiveElection returns the most recent active election
+	ActiveElection() (models.Election, error)
+	// Elections lists every election, most recent first
+	Elections() ([]models.Election, error)
+	// CreateElection adds an election and gives every Area Council the
+	// default party list for it. It returns ErrConflict if the ID is taken.
+	CreateElection(e models.Election) (models.Election, error)
+	// UpdateElection replaces an election's name, date, type and status
+	UpdateElection(e models.Election) (models.Election, error)
+	// RiskWeights returns the weights configured for an election, falling
+	// back to the built-in defaults for anything not configured
+	RiskWeights(electionID string) (risk.Weights, error)
+	SetRiskWeights(electionID string, weights risk.Weights) error
+}
+
+// WardStore looks up wards and Area Councils
+type WardStore interface {
+	Ward(id string) (models.Ward, error)
+	// Wards lists the wards in an Area Council, or every ward for "", by Area Council and ID
+	Wards(lgaID string) ([]models.Ward, error)
+	// AreaCouncils lists the Area Councils by ID
+	AreaCouncils() ([]models.AreaCouncil, error)
+	AreaCouncilExists(id string) (bool, error)
+}
+
+// WardSubmission is a ward's submission together with where it is in review
+type WardSubmission struct {
+	models.WardResult
+	ReviewStatus string
+}
+
+// ResultStore keeps ward submissions and their version history
+type ResultStore interface {
+	// SaveWardResult applies change to a ward's submission in an election and
+	// records the outcome as the ward's next version, in one transaction. An
+	// error from change aborts the save. A recorded change sends the
+	// submission back to draft for review. It returns the new version, or 0
+	// if nothing changed. revertedFrom names the version a revert restores.
+	SaveWardResult(electionID, wardID, section string, userID int, revertedFrom *int, change func(*models.WardResult) error) (int, error)
+	// WardVersion returns one recorded version of a ward's submission
+	WardVersion(electionID, wardID string, version int) (models.WardResultVersion, error)
+	// WardHistory returns every recorded version of a ward's submission, newest first
+	WardHistory(electionID, wardID string) ([]models.WardResultVersion, error)
+	// WardResult returns a ward's submission with its party scores. With
+	// published set only approved submissions count, and one changed since
+	// it was approved is returned as the version last approved; ErrNotFound
+	// means the ward has no submission to show.
+	WardResult(electionID, wardID string, published bool) (WardSubmission, error)
+	// WardResults returns every ward's submission in an election, by ward ID,
+	// as WardResult would
+	WardResults(electionID string, published bool) ([]WardSubmission, error)
+}
+
+// ReviewStore keeps where ward submissions are in the review workflow
+type ReviewStore interface {
+	// WardReview returns a ward's review state with the history of its review decisions
+	WardReview(electionID, wardID string) (models.WardReview, error)
+	// ReviewQueue lists the submissions with a review status, optionally in
+	// one Area Council, oldest submission first
+	ReviewQueue(electionID, status, lgaID string) ([]models.WardReview, error)
+	// ChangeWardReview moves a ward's submission to status and records the
+	// change, if allowed permits it given the review as it stands; otherwise
+	// it returns ErrConflict. Submitting records the submitter; approving or
+	// rejecting records the reviewer, the reason and the version decided on.
+	// Either way it returns the review as it was before.
+	ChangeWardReview(electionID, wardID, status, reason string, userID int, allowed func(models.WardReview) bool) (models.WardReview, error)
+}
+
+// PollingUnitStore keeps the polling unit registry, each unit's status and
+// results in an election, and rolls the results up into their wards. Ward
+// totals are recomputed, and recorded as a new ward version, whenever the
+// results counted towards them change: a unit's results are saved, it moves
+// ward or is deleted, or it is cancelled or uncancelled.
+type PollingUnitStore interface {
+	// PollingUnits lists a ward's polling units by code, with their status in an election
+	PollingUnits(electionID, wardID string) ([]models.PollingUnit, error)
+	// PollingUnit returns a polling unit with its status in an election
+	PollingUnit(electionID string, id int) (models.PollingUnit, error)
+	// PollingUnitResult returns the result submitted for a polling unit in an election
+	PollingUnitResult(electionID string, id int) (models.PollingUnitResult, error)
+	// PollingUnitStatusCounts counts every polling unit by its status in an
+	// election; units without one count as not_opened
+	PollingUnitStatusCounts(electionID string) (map[string]int, error)
+	// PollingUnitWards returns the wards of the polling units with the given codes
+	PollingUnitWards(codes []string) ([]string, error)
+	// CreatePollingUnit adds a polling unit and, if it has one, sets its
+	// status in an election. It returns ErrConflict if the code is taken and
+	// ErrNotFound for an unknown ward.
+	CreatePollingUnit(pu models.PollingUnit, electionID string, userID int) (models.PollingUnit, error)
+	// UpdatePollingUnit replaces a polling unit's details and, if it has one,
+	// its status in an election
+	UpdatePollingUnit(pu models.PollingUnit, electionID string, userID int) (models.PollingUnit, error)
+	// DeletePollingUnit removes a polling unit with its results and returns it as it was
+	DeletePollingUnit(id, userID int) (models.PollingUnit, error)
+	// ImportPollingUnits creates or updates polling units by code, all or
+	// nothing. A unit that can't be written fails the import with an ImportError.
+	ImportPollingUnits(units []models.PollingUnit, electionID string, userID int) error
+	// SetPollingUnitStatus records a polling unit's status in an election
+	SetPollingUnitStatus(electionID string, id int, status string, userID int) error
+	// SavePollingUnitResult replaces a polling unit's result in an election
+	SavePollingUnitResult(res models.PollingUnitResult, userID int) error
+}
+
+// ImportError is a polling unit an import couldn't write
+type ImportError struct {
+	Row  int // From 1
+	Code string
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("Row %d (%s): %v", e.Row, e.Code, e.Err)
+}
+
+// CollationStore keeps what observers report from Area Council collation centres
+type CollationStore interface {
+	// AreaCouncilResult returns an Area Council's collation with its party scores
+	AreaCouncilResult(electionID, lgaID string) (models.AreaCouncilResult, error)
+	// AreaCouncilResults returns every Area Council's collation in an election, by Area Council ID
+	AreaCouncilResults(electionID string) ([]models.AreaCouncilResult, error)
+	// SaveAreaCouncilResult applies change to an Area Council's collation in
+	// an election, creating it if needed. An error from change aborts the save.
+	SaveAreaCouncilResult(electionID, lgaID string, change func(*models.AreaCouncilResult) error) error
+}
+
+// PartyStore keeps the parties on the ballot in each Area Council
+type PartyStore interface {
+	AreaCouncilParties(electionID, lgaID string) ([]string, error)
+	SetAreaCouncilParties(electionID, lgaID string, parties []string) error
+}
+
+// IncidentFilter narrows a listing of incidents. Empty fields match everything.
+type IncidentFilter struct {
+	ElectionID    string
+	WardID        string
+	AreaCouncilID string
+	Type          string // Matched ignoring case
+	Severity      string // Matched ignoring case
+	Status        string
+	From, To      time.Time
+	Offset, Limit int
+}
+
+// IncidentStore keeps reported incidents and their status workflow
+type IncidentStore interface {
+	// CreateIncident saves a new incident and returns it as stored
+	CreateIncident(inc models.Incident) (models.Incident, error)
+	// Incident returns an incident with its status history
+	Incident(id int) (models.Incident, error)
+	// Incidents returns a page of matching incidents, newest first, and how many match in all
+	Incidents(f IncidentFilter) ([]models.Incident, int, error)
+	// ChangeIncidentStatus moves an incident to status and records the change
+	// in its history, if allowed permits the move from its current status;
+	// otherwise it returns ErrConflict. Either way it returns the incident as
+	// it was before.
+	ChangeIncidentStatus(id int, status, note string, userID int, allowed func(from, to string) bool) (models.Incident, error)
+	// IncidentCounts counts a ward's incidents in an election by type and
+	// severity, or every ward's for "", keyed by ward ID
+	IncidentCounts(electionID, wardID string) (map[string][]risk.IncidentCount, error)
+}
+
+// TwoFactorUser is a user with their two-factor authentication secret
+type TwoFactorUser struct {
+	models.User
+	Secret   string // Set at enrolment, before two-factor authentication is enabled
+	LastStep int64  // Time step of the last code used
+}
+
+// UserStore keeps user accounts, their two-factor authentication and the
+// jurisdictions they are assigned to
+type UserStore interface {
+	// User returns a user with their password hash
+	User(id int) (models.User, error)
+	// UserByUsername returns a user with their password hash
+	UserByUsername(username string) (models.User, error)
+	// CreateUser adds a user, returning ErrConflict if the username is taken
+	CreateUser(username, passwordHash, role string) (models.User, error)
+	// Users lists every user, newest first, without password hashes
+	Users() ([]models.User, error)
+	// SetUserRole changes a user's role and returns the user as they were. It
+	// returns ErrConflict rather than demote the last active admin.
+	SetUserRole(id int, role string) (models.User, error)
+	// SetUsersActive activates or deactivates users, recording who deactivated
+	// them, and returns the users that changed. It returns ErrConflict rather
+	// than deactivate every active admin.
+	SetUsersActive(ids []int, active bool, by int) ([]models.User, error)
+	// SetPassword replaces a user's password hash and returns the user.
+	// mustChange makes them choose another password once they log in.
+	SetPassword(id int, passwordHash string, mustChange bool) (models.User, error)
+
+	// TwoFactorUser returns a user with their two-factor secret
+	TwoFactorUser(id int) (TwoFactorUser, error)
+	// SetTwoFactorSecret stores the secret a user is enrolling with
+	SetTwoFactorSecret(userID int, secret string) error
+	// UseTOTPStep records that a user's code for a time step was used. It
+	// reports false if a code for that step or a later one already was.
+	UseTOTPStep(userID int, step int64) (bool, error)
+	// EnableTwoFactor turns two-factor authentication on and replaces the
+	// user's recovery codes with the given hashes
+	EnableTwoFactor(userID int, recoveryHashes []string) error
+	// ClearTwoFactor turns two-factor authentication off, removing the
+	// secret and recovery codes
+	ClearTwoFactor(userID int) error
+	// ReplaceRecoveryCodes replaces a user's recovery codes with the given hashes
+	ReplaceRecoveryCodes(userID int, hashes []string) error
+	// UseRecoveryCode spends the unused recovery code with the hash,
+	// reporting false if there is none
+	UseRecoveryCode(userID int, hash string) (bool, error)
+	// RecoveryCodesLeft counts a user's unused recovery codes
+	RecoveryCodesLeft(userID int) (int, error)
+
+	// Jurisdiction returns the Area Councils and wards a user is assigned to
+	Jurisdiction(userID int) (models.Jurisdiction, error)
+	// SetJurisdiction replaces a user's assignments, recording who made them,
+	// and returns them as stored
+	SetJurisdiction(userID int, j models.Jurisdiction, by int) (models.Jurisdiction, error)
+	// AssignedToWard reports whether a user is assigned to a ward or its
+	// Area Council; ErrNotFound for an unknown ward
+	AssignedToWard(userID int, wardID string) (bool, error)
+	// AssignedToAreaCouncil reports whether a user is assigned to a whole Area Council
+	AssignedToAreaCouncil(userID int, lgaID string) (bool, error)
+}
+
+// SessionStore keeps login sessions, and lists them for admins
+type SessionStore interface {
+	auth.SessionStore
+	// UserSessions returns a user's most recent sessions, newest first
+	UserSessions(userID, limit int) ([]models.Session, error)
+}
+
+// APIKeyStore keeps API keys for machine clients
+type APIKeyStore interface {
+	auth.APIKeyStore
+	// APIKeys lists every key, newest first
+	APIKeys() ([]models.APIKey, error)
+	// CreateAPIKey saves a key under the hash of its secret. It expires after
+	// expiresIn, or never if that is 0.
+	CreateAPIKey(k models.APIKey, hash string, expiresIn time.Duration) (models.APIKey, error)
+	// RevokeAPIKey stops a key working and returns it
+	RevokeAPIKey(id int) (models.APIKey, error)
+}
+
+// WebhookDeliveryFilter narrows a listing of webhook deliveries. Empty fields match everything.
+type WebhookDeliveryFilter struct {
+	SubscriptionID int
+	Status         string
+	Offset, Limit  int
+}
+
+// WebhookStore keeps partner webhook subscriptions and the log of deliveries to them
+type WebhookStore interface {
+	// Webhooks lists every subscription, without secrets
+	Webhooks() ([]models.WebhookSubscription, error)
+	// CreateWebhook saves a subscription and returns it as stored
+	CreateWebhook(sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	// UpdateWebhook replaces a subscription's settings. An empty secret keeps
+	// the current one, as does a nil active.
+	UpdateWebhook(sub models.WebhookSubscription, active *bool) (models.WebhookSubscription, error)
+	// DeleteWebhook removes a subscription with its deliveries and returns it
+	DeleteWebhook(id int) (models.WebhookSubscription, error)
+	// WebhookDeliveries returns a page of matching deliveries, newest first, and how many match in all
+	WebhookDeliveries(f WebhookDeliveryFilter) ([]models.WebhookDelivery, int, error)
+	// WebhookDelivery returns a delivery with a log of every attempt
+	WebhookDelivery(id int) (models.WebhookDelivery, error)
+	// RetryWebhookDelivery queues a dead delivery again with a fresh set of
+	// attempts. Other deliveries give ErrConflict, returned as they are.
+	RetryWebhookDelivery(id int) (models.WebhookDelivery, error)
+}
+
+// AuditFilter narrows a search of the audit log. Empty fields match everything.
+type AuditFilter struct {
+	UserID     *int
+	Username   string // Matched ignoring case
+	APIKeyID   *int
+	Actions    []string
+	EntityType string
+	EntityID   string
+	IP         string
+	RequestID  string
+	Search     string // Words that must all appear in the details
+	From, To   time.Time
+	Before     int  // Only entries with lower IDs, for paging
+	Limit      int  // 0 for no limit
+	Oldest     bool // Oldest first instead of newest first
+}
+
+// AuditStore keeps the audit log
+type AuditStore interface {
+	// Append adds an entry to the end of the hash chain
+	Append(e audit.Entry) (audit.Entry, error)
+	// EachAuditLog calls fn with every matching entry in order, stopping at
+	// the first error fn returns
+	EachAuditLog(f AuditFilter, fn func(models.AuditLog) error) error
+	// VerifyAuditLog checks the hash chain and checkpoints, as audit.Verify does
+	VerifyAuditLog(trusted audit.TrustedKeys) (audit.Report, error)
+	// AuditArchives lists the archives of entries past retention, oldest first
+	AuditArchives() ([]audit.Archive, error)
+	// AuditCheckpoints lists the signed checkpoints of the log, oldest first
+	AuditCheckpoints() ([]audit.Checkpoint, error)
+}
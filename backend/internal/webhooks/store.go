@@ -0,0 +1,109 @@
+package webhooks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// SQLStore keeps deliveries in Postgres. Several server instances can share
+// it: due deliveries are claimed with SKIP LOCKED and leased while in flight.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+// NewSQLStore creates a store on the given connection pool
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+// Enqueue implements Store. The matching rules are the same as Wants.
+func (s *SQLStore) Enqueue(e events.Event, payload []byte) (int, error) {
+	res, err := s.DB.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3 FROM webhook_subscriptions
+		WHERE active
+			AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+			AND (area_council_id IS NULL OR $4 = '' OR area_council_id = $4)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		int64(e.ID), e.Type, payload, e.AreaCouncilID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Due implements Store
+func (s *SQLStore) Due(limit int, lease time.Duration) ([]Job, error) {
+	rows, err := s.DB.Query(`
+		WITH due AS (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON d.subscription_id = s.id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND d.subscription_id = s.id
+		RETURNING d.id, d.event_type, d.payload, d.attempts, s.url, s.secret`,
+		limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.DeliveryID, &j.EventType, &j.Payload, &j.Attempts, &j.URL, &j.Secret); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// Record implements Store
+func (s *SQLStore) Record(deliveryID int, attempt models.WebhookAttempt, status string, retryIn time.Duration) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)`,
+		deliveryID, attempt.Attempt, attempt.StatusCode, nullString(attempt.Error), attempt.DurationMs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = $3,
+			last_status_code = $4,
+			last_error = $5,
+			next_attempt_at = NOW() + $6 * INTERVAL '1 millisecond',
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() ELSE delivered_at END
+		WHERE id = $1`,
+		deliveryID, status, attempt.Attempt, attempt.StatusCode, nullString(attempt.Error), retryIn.Milliseconds())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,307 @@
+// Package webhooks delivers live events to partner organisations. Each event
+// from the bus is queued once for every subscription that wants it, then
+// POSTed as signed JSON and retried with exponential backoff until the
+// receiver accepts it or the delivery runs out of attempts and is
+// dead-lettered.
+//
+// Every request carries:
+//
+//	X-Webhook-ID         the delivery ID, stable across retries
+//	X-Webhook-Event      the event type
+//	X-Webhook-Timestamp  Unix seconds when the attempt was made
+//	X-Webhook-Signature  sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the secret>
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Job is a due delivery together with where and how to send it
+type Job struct {
+	DeliveryID int
+	EventType  string
+	Payload    []byte
+	Attempts   int // Attempts already made
+	URL        string
+	Secret     string
+}
+
+// Store persists subscriptions' deliveries. The dispatcher only needs these
+// operations, so tests can run it against an in-memory store.
+type Store interface {
+	// Enqueue queues the payload for every active subscription that wants the
+	// event, at most once per subscription, and returns how many were queued
+	Enqueue(e events.Event, payload []byte) (int, error)
+	// Due claims up to limit pending deliveries whose next attempt is due,
+	// holding them for lease so no other worker picks them up meanwhile
+	Due(limit int, lease time.Duration) ([]Job, error)
+	// Record stores the outcome of an attempt and the delivery's new status;
+	// pending deliveries become due again after retryIn
+	Record(deliveryID int, attempt models.WebhookAttempt, status string, retryIn time.Duration) error
+}
+
+// Wants reports whether a subscription should receive an event. Events that
+// aren't tied to an Area Council go to every subscription.
+func Wants(sub models.WebhookSubscription, e events.Event) bool {
+	if !sub.Active {
+		return false
+	}
+	if sub.AreaCouncilID != "" && e.AreaCouncilID != "" && sub.AreaCouncilID != e.AreaCouncilID {
+		return false
+	}
+	if len(sub.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range sub.EventTypes {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks an X-Webhook-Signature header value, as a receiver would
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	expected := "sha256=" + Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Dispatcher turns bus events into deliveries and sends them
+type Dispatcher struct {
+	Store       Store
+	Client      *http.Client
+	MaxAttempts int           // Attempts before a delivery is dead-lettered
+	BaseDelay   time.Duration // Wait after the first failure, doubled after each one
+	MaxDelay    time.Duration // Longest wait between attempts
+	Poll        time.Duration // How often to look for due retries
+	BatchSize   int           // Deliveries claimed and sent at a time
+
+	wake chan struct{}
+}
+
+// NewDispatcher creates a dispatcher with the default retry policy: 8 attempts
+// over roughly two hours. A nil client gets a 10 second timeout and doesn't
+// follow redirects.
+func NewDispatcher(store Store, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return &Dispatcher{
+		Store:       store,
+		Client:      client,
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+		Poll:        5 * time.Second,
+		BatchSize:   20,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Backoff is how long to wait after the given failed attempt (1-based)
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempt && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxDelay {
+		delay = d.MaxDelay
+	}
+	return delay
+}
+
+// Run consumes events from the bus and delivers them until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context, bus *events.Bus) {
+	go d.consume(ctx, bus)
+
+	ticker := time.NewTicker(d.Poll)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// consume queues every event published on the bus. If the subscription is
+// dropped for falling behind it resubscribes from the last event it saw.
+func (d *Dispatcher) consume(ctx context.Context, bus *events.Bus) {
+	var lastID uint64
+	for {
+		sub, missed, complete := bus.Subscribe(lastID, events.Filter{})
+		if !complete {
+			log.Printf("webhooks: events after %d are no longer held and won't be delivered", lastID)
+		}
+		for _, e := range missed {
+			d.Enqueue(e)
+			lastID = e.ID
+		}
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Close()
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					break receive
+				}
+				d.Enqueue(e)
+				lastID = e.ID
+			}
+		}
+	}
+}
+
+// Enqueue queues an event for its subscribers and wakes the sender
+func (d *Dispatcher) Enqueue(e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	n, err := d.Store.Enqueue(e, payload)
+	if err != nil {
+		log.Printf("webhooks: failed to queue event %d: %v", e.ID, err)
+		return err
+	}
+	if n > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// DeliverDue sends every delivery that is due, a batch at a time, and returns
+// how many attempts were made
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	// Hold claimed deliveries for longer than an attempt can take
+	lease := 2 * time.Minute
+	if d.Client.Timeout > 0 && 2*d.Client.Timeout > lease {
+		lease = 2 * d.Client.Timeout
+	}
+
+	sent := 0
+	for ctx.Err() == nil {
+		jobs, err := d.Store.Due(d.BatchSize, lease)
+		if err != nil {
+			return sent, err
+		}
+		if len(jobs) == 0 {
+			break
+		}
+
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			wg.Add(1)
+			go func(job Job) {
+				defer wg.Done()
+				d.attempt(ctx, job)
+			}(job)
+		}
+		wg.Wait()
+		sent += len(jobs)
+	}
+	return sent, nil
+}
+
+// attempt makes one delivery attempt and records its outcome
+func (d *Dispatcher) attempt(ctx context.Context, job Job) {
+	attempt := models.WebhookAttempt{Attempt: job.Attempts + 1, AttemptedAt: time.Now()}
+	statusCode, err := d.send(ctx, job)
+	attempt.DurationMs = int(time.Since(attempt.AttemptedAt).Milliseconds())
+	if statusCode > 0 {
+		attempt.StatusCode = &statusCode
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	status, retryIn := StatusDelivered, time.Duration(0)
+	if err != nil {
+		if attempt.Attempt >= d.MaxAttempts {
+			status = StatusDead
+		} else {
+			status, retryIn = StatusPending, d.Backoff(attempt.Attempt)
+		}
+	}
+	if err := d.Store.Record(job.DeliveryID, attempt, status, retryIn); err != nil {
+		log.Printf("webhooks: failed to record attempt %d of delivery %d: %v", attempt.Attempt, job.DeliveryID, err)
+	}
+}
+
+// send POSTs the signed payload. Any 2xx response counts as delivered.
+func (d *Dispatcher) send(ctx context.Context, job Job) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AbujaWatch-Webhooks/1.0")
+	req.Header.Set("X-Webhook-ID", strconv.Itoa(job.DeliveryID))
+	req.Header.Set("X-Webhook-Event", job.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(job.Secret, timestamp, job.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := resp.Status
+		if text := strings.TrimSpace(string(body)); text != "" {
+			msg += ": " + text
+		}
+		return resp.StatusCode, fmt.Errorf("%s", msg)
+	}
+	return resp.StatusCode, nil
+}
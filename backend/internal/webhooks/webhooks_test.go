@@ -0,0 +1,311 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yiaga/abuja-watch/backend/internal/events"
+	"github.com/yiaga/abuja-watch/backend/internal/models"
+)
+
+// memStore is an in-memory Store
+type memStore struct {
+	mu         sync.Mutex
+	subs       []models.WebhookSubscription
+	secrets    map[int]string
+	deliveries []*memDelivery
+}
+
+type memDelivery struct {
+	models.WebhookDelivery
+	leasedUntil time.Time
+}
+
+func newMemStore(subs ...models.WebhookSubscription) *memStore {
+	s := &memStore{secrets: make(map[int]string)}
+	for _, sub := range subs {
+		s.secrets[sub.ID] = sub.Secret
+		s.subs = append(s.subs, sub)
+	}
+	return s
+}
+
+func (s *memStore) Enqueue(e events.Event, payload []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, sub := range s.subs {
+		if !Wants(sub, e) {
+			continue
+		}
+		s.deliveries = append(s.deliveries, &memDelivery{WebhookDelivery: models.WebhookDelivery{
+			ID:             len(s.deliveries) + 1,
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        payload,
+			Status:         StatusPending,
+			NextAttemptAt:  time.Now(),
+		}})
+		n++
+	}
+	return n, nil
+}
+
+func (s *memStore) Due(limit int, lease time.Duration) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var jobs []Job
+	for _, d := range s.deliveries {
+		if len(jobs) == limit {
+			break
+		}
+		if d.Status != StatusPending || d.NextAttemptAt.After(now) || d.leasedUntil.After(now) {
+			continue
+		}
+		d.leasedUntil = now.Add(lease)
+		var url string
+		for _, sub := range s.subs {
+			if sub.ID == d.SubscriptionID {
+				url = sub.URL
+			}
+		}
+		jobs = append(jobs, Job{
+			DeliveryID: d.ID,
+			EventType:  d.EventType,
+			Payload:    d.Payload,
+			Attempts:   d.Attempts,
+			URL:        url,
+			Secret:     s.secrets[d.SubscriptionID],
+		})
+	}
+	return jobs, nil
+}
+
+func (s *memStore) Record(deliveryID int, attempt models.WebhookAttempt, status string, retryIn time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[deliveryID-1]
+	d.Status = status
+	d.Attempts = attempt.Attempt
+	d.LastStatusCode = attempt.StatusCode
+	d.LastError = attempt.Error
+	d.NextAttemptAt = time.Now().Add(retryIn)
+	d.leasedUntil = time.Time{}
+	d.AttemptLog = append(d.AttemptLog, attempt)
+	return nil
+}
+
+func (s *memStore) delivery(id int) models.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deliveries[id-1].WebhookDelivery
+}
+
+// receiver is an httptest endpoint that checks signatures and answers with
+// the given status codes in turn, repeating the last one
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	codes    []int
+	received []*http.Request
+	bodies   [][]byte
+	badSigs  int
+}
+
+func newReceiver(t *testing.T, secret string, codes ...int) *receiver {
+	rc := &receiver{codes: codes}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		if !Verify(secret, r.Header.Get("X-Webhook-Timestamp"), body, r.Header.Get("X-Webhook-Signature")) {
+			rc.badSigs++
+		}
+		rc.received = append(rc.received, r)
+		rc.bodies = append(rc.bodies, body)
+		code := rc.codes[len(rc.codes)-1]
+		if len(rc.received) <= len(rc.codes) {
+			code = rc.codes[len(rc.received)-1]
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.received)
+}
+
+func (rc *receiver) snapshot() ([]*http.Request, [][]byte, int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.received, rc.bodies, rc.badSigs
+}
+
+func testDispatcher(store Store) *Dispatcher {
+	d := NewDispatcher(store, nil)
+	d.MaxAttempts = 3
+	d.BaseDelay = time.Millisecond
+	d.MaxDelay = 4 * time.Millisecond
+	d.Poll = 5 * time.Millisecond
+	return d
+}
+
+// deliverUntil keeps delivering due retries until the delivery leaves pending
+func deliverUntil(t *testing.T, d *Dispatcher, store *memStore, id int) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := d.DeliverDue(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := store.delivery(id); got.Status != StatusPending {
+			return got
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("delivery %d still pending", id)
+	return models.WebhookDelivery{}
+}
+
+func TestDeliversSignedPayload(t *testing.T) {
+	const secret = "0123456789abcdef"
+	rc := newReceiver(t, secret, http.StatusOK)
+	store := newMemStore(models.WebhookSubscription{ID: 1, URL: rc.URL, Secret: secret, Active: true})
+	d := testDispatcher(store)
+
+	if err := d.Enqueue(events.Event{ID: 42, Type: events.WardResultApproved, WardID: "amac-01"}); err != nil {
+		t.Fatal(err)
+	}
+	got := deliverUntil(t, d, store, 1)
+
+	if got.Status != StatusDelivered || got.Attempts != 1 {
+		t.Fatalf("got status %s after %d attempts, want delivered after 1", got.Status, got.Attempts)
+	}
+	received, bodies, badSigs := rc.snapshot()
+	if badSigs != 0 {
+		t.Fatalf("%d requests had a bad signature", badSigs)
+	}
+	req := received[0]
+	if req.Header.Get("X-Webhook-Event") != events.WardResultApproved || req.Header.Get("X-Webhook-ID") != "1" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	if string(bodies[0]) != string(got.Payload) {
+		t.Errorf("body %s, want %s", bodies[0], got.Payload)
+	}
+}
+
+func TestRetriesThenDeadLetters(t *testing.T) {
+	const secret = "0123456789abcdef"
+	rc := newReceiver(t, secret, http.StatusInternalServerError)
+	store := newMemStore(models.WebhookSubscription{ID: 1, URL: rc.URL, Secret: secret, Active: true})
+	d := testDispatcher(store)
+
+	d.Enqueue(events.Event{ID: 1, Type: events.IncidentReported})
+	got := deliverUntil(t, d, store, 1)
+
+	if got.Status != StatusDead || got.Attempts != d.MaxAttempts {
+		t.Fatalf("got status %s after %d attempts, want dead after %d", got.Status, got.Attempts, d.MaxAttempts)
+	}
+	if rc.count() != d.MaxAttempts {
+		t.Fatalf("receiver saw %d requests, want %d", rc.count(), d.MaxAttempts)
+	}
+	if got.LastStatusCode == nil || *got.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("last status code %v, want 500", got.LastStatusCode)
+	}
+}
+
+func TestRecoversAfterFailure(t *testing.T) {
+	const secret = "0123456789abcdef"
+	rc := newReceiver(t, secret, http.StatusServiceUnavailable, http.StatusNoContent)
+	store := newMemStore(models.WebhookSubscription{ID: 1, URL: rc.URL, Secret: secret, Active: true})
+	d := testDispatcher(store)
+
+	d.Enqueue(events.Event{ID: 1, Type: events.IncidentReported})
+	got := deliverUntil(t, d, store, 1)
+
+	if got.Status != StatusDelivered || got.Attempts != 2 {
+		t.Fatalf("got status %s after %d attempts, want delivered after 2", got.Status, got.Attempts)
+	}
+	if got.AttemptLog[0].Error == "" || got.AttemptLog[1].Error != "" {
+		t.Errorf("unexpected attempt log %+v", got.AttemptLog)
+	}
+}
+
+func TestSubscriptionFilters(t *testing.T) {
+	sub := models.WebhookSubscription{
+		Active:        true,
+		EventTypes:    []string{events.WardResultApproved},
+		AreaCouncilID: "amac",
+	}
+	cases := []struct {
+		event events.Event
+		want  bool
+	}{
+		{events.Event{Type: events.WardResultApproved, AreaCouncilID: "amac"}, true},
+		{events.Event{Type: events.WardResultApproved, AreaCouncilID: "bwari"}, false},
+		{events.Event{Type: events.WardResultUpdated, AreaCouncilID: "amac"}, false},
+		{events.Event{Type: events.WardResultApproved}, true}, // Not tied to an Area Council
+	}
+	for _, c := range cases {
+		if got := Wants(sub, c.event); got != c.want {
+			t.Errorf("Wants(%s in %q) = %t, want %t", c.event.Type, c.event.AreaCouncilID, got, c.want)
+		}
+	}
+
+	sub.Active = false
+	if Wants(sub, cases[0].event) {
+		t.Error("inactive subscription wants events")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil)
+	d.BaseDelay, d.MaxDelay = time.Second, 10*time.Second
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := d.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRunDeliversBusEvents(t *testing.T) {
+	const secret = "0123456789abcdef"
+	rc := newReceiver(t, secret, http.StatusOK)
+	store := newMemStore(models.WebhookSubscription{ID: 1, URL: rc.URL, Secret: secret, Active: true, AreaCouncilID: "amac"})
+	d := testDispatcher(store)
+	bus := events.NewBus(10, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx, bus)
+
+	// Give the dispatcher time to subscribe before publishing
+	deadline := time.Now().Add(2 * time.Second)
+	for rc.count() == 0 && time.Now().Before(deadline) {
+		bus.Publish(events.Event{Type: events.IncidentReported, AreaCouncilID: "bwari"})
+		bus.Publish(events.Event{Type: events.IncidentReported, AreaCouncilID: "amac"})
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rc.count() == 0 {
+		t.Fatal("no events were delivered")
+	}
+	_, bodies, _ := rc.snapshot()
+	for _, body := range bodies {
+		if strings.Contains(string(body), "bwari") {
+			t.Errorf("delivered an event for another Area Council: %s", body)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -8,9 +9,14 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	"github.com/yiaga/abuja-watch/backend/internal/audit"
+	"github.com/yiaga/abuja-watch/backend/internal/auth"
 	"github.com/yiaga/abuja-watch/backend/internal/db"
+	"github.com/yiaga/abuja-watch/backend/internal/events"
 	"github.com/yiaga/abuja-watch/backend/internal/handlers"
 	authMiddleware "github.com/yiaga/abuja-watch/backend/internal/middleware"
+	"github.com/yiaga/abuja-watch/backend/internal/store"
+	"github.com/yiaga/abuja-watch/backend/internal/webhooks"
 )
 
 func main() {
@@ -19,55 +25,224 @@ func main() {
 		log.Println("No .env file found in current directory")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	// Load JWT signing keys; there is no built-in fallback key
+	if err := auth.LoadConfig(); err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
+
+	if err := audit.LoadConfig(); err != nil {
+		log.Fatalf("Invalid audit configuration: %v", err)
+	}
+
+	// Only proxies listed here may say which address a request came from
+	proxies, err := authMiddleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Connect to database
 	if err := db.Connect(); err != nil {
 		log.Fatalf("Could not connect to database: %v", err)
 	}
+	migrateUp()
+
+	// Sign checkpoints of the audit log's hash chain in the background
+	if signer := audit.Config.Signer; signer != nil {
+		go audit.RunCheckpoints(context.Background(), db.DB, signer, audit.Config.TrustedKeys, audit.Config.Interval)
+	} else {
+		log.Println("AUDIT_CHECKPOINT_KEY not set; audit log checkpoints are disabled")
+	}
+
+	// Archive audit entries past the retention period
+	if audit.Config.Retention > 0 {
+		go audit.RunRetention(context.Background(), db.DB, audit.Config.Retention, audit.Config.ArchiveDir)
+	}
+
+	// Deliver live events to partner webhooks in the background
+	go webhooks.NewDispatcher(webhooks.NewSQLStore(db.DB), nil).Run(context.Background(), events.Default)
+
+	stores := store.NewSQLStore(db.DB).Stores()
+	h := handlers.New(stores)
+	authenticate := authMiddleware.AuthMiddleware(stores.Sessions, stores.APIKeys)
 
 	// Initialize Router
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
+	r.Use(authMiddleware.RealIP(proxies))
+	r.Use(authMiddleware.AuditWrites(stores.Audit.Append))
 
 	// Routes
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Abuja Watch Backend API is running"))
 	})
 
+	// Public keys for verifying our tokens
+	r.Get("/.well-known/jwks.json", h.GetJWKS)
+
 	r.Route("/api", func(r chi.Router) {
 
 		// Auth Routes
-		r.Post("/login", handlers.Login)
+		r.Post("/login", h.Login)
+		r.Post("/login/2fa", h.VerifyTwoFactorLogin)
+		r.Post("/token/refresh", h.RefreshToken)
+
+		// Signed audit log checkpoints, published for outside verification
+		r.Get("/audit-logs/checkpoints", h.GetAuditCheckpoints)
+
+		// Signed-in routes that stay available while a password change or
+		// two-factor enrolment is pending
+		r.Group(func(r chi.Router) {
+			r.Use(authenticate)
+			r.Post("/logout", h.Logout)
+			r.Put("/me/password", h.ChangePassword)
+			r.Get("/me/2fa", h.GetTwoFactorStatus)
+			r.Post("/me/2fa/setup", h.SetupTwoFactor)
+			r.Post("/me/2fa/enable", h.EnableTwoFactor)
+		})
 
 		// Protected Routes
 		r.Group(func(r chi.Router) {
-			r.Use(authMiddleware.AuthMiddleware)
+			r.Use(authenticate)
+			r.Use(authMiddleware.RequireFullAccess)
+
+			r.Post("/me/2fa/disable", h.DisableTwoFactor)
+			r.Post("/me/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+
+			// User Management
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermManageUsers))
+				r.Post("/users", h.CreateUser)
+				r.Get("/users", h.GetUsers)
+				r.Put("/users/{userID}/role", h.UpdateUserRole)
+				r.Post("/users/{userID}/deactivate", h.DeactivateUser)
+				r.Post("/users/{userID}/reactivate", h.ReactivateUser)
+				r.Post("/users/deactivate", h.DeactivateUsers)
+				r.Post("/users/{userID}/reset-password", h.ResetUserPassword)
+				r.Post("/users/{userID}/unlock", h.UnlockUserLogin)
+				r.Post("/users/{userID}/2fa/reset", h.ResetUserTwoFactor)
+				r.Post("/login/unlock-ip", h.UnlockLoginIP)
+				r.Get("/users/{userID}/sessions", h.GetUserSessions)
+				r.Post("/users/{userID}/sessions/revoke", h.RevokeUserSessions)
+				r.Get("/users/{userID}/jurisdictions", h.GetUserJurisdictions)
+				r.Put("/users/{userID}/jurisdictions", h.SetUserJurisdictions)
+			})
 
-			// Admin Only: User Management & Audit Logs
+			// Audit Log
 			r.Group(func(r chi.Router) {
-				r.Use(func(next http.Handler) http.Handler {
-					return authMiddleware.RequireRole("admin", next.ServeHTTP)
-				})
-				r.Post("/users", handlers.CreateUser)
-				r.Get("/users", handlers.GetUsers)
-				r.Get("/audit-logs", handlers.GetAuditLogs)
+				r.Use(authMiddleware.RequirePermission(auth.PermViewAuditLogs))
+				r.Get("/audit-logs", h.GetAuditLogs)
+				r.Get("/audit-logs/verify", h.VerifyAuditLogs)
+				r.Get("/audit-logs/retention", h.GetAuditRetention)
 			})
 
-			// Protected Submission Routes (Available to admin and editor)
-			r.Post("/submit/logistics", handlers.SubmitLogistics)
-			r.Post("/submit/staffing", handlers.SubmitStaffing)
-			r.Post("/submit/integrity", handlers.SubmitIntegrity)
-			r.Post("/submit/results", handlers.SubmitResults)
-			r.Post("/area-councils/{lgaID}/parties", handlers.UpdateAreaCouncilParties)
+			r.With(authMiddleware.RequirePermission(auth.PermManageRisk)).Put("/risk/weights", h.UpdateRiskWeights)
+			r.With(authMiddleware.RequirePermission(auth.PermManageElections)).Post("/elections", h.CreateElection)
+			r.With(authMiddleware.RequirePermission(auth.PermManageElections)).Put("/elections/{electionID}", h.UpdateElection)
+			r.With(authMiddleware.RequirePermission(auth.PermRevertResults)).Post("/wards/{wardID}/history/{version}/revert", h.RevertWardResult)
+
+			// API Keys for machine clients, e.g. the SMS gateway. Keys are sent as
+			// X-API-Key or as a Bearer token and reach routes their scopes allow.
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermManageAPIKeys))
+				r.Get("/api-keys", h.GetAPIKeys)
+				r.Post("/api-keys", h.CreateAPIKey)
+				r.Delete("/api-keys/{keyID}", h.RevokeAPIKey)
+			})
+
+			// Partner Webhooks
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermManageWebhooks))
+				r.Get("/webhooks", h.GetWebhooks)
+				r.Post("/webhooks", h.CreateWebhook)
+				r.Put("/webhooks/{webhookID}", h.UpdateWebhook)
+				r.Delete("/webhooks/{webhookID}", h.DeleteWebhook)
+				r.Get("/webhooks/{webhookID}/deliveries", h.GetWebhookDeliveries)
+				r.Get("/webhooks/dead-letters", h.GetWebhookDeadLetters)
+				r.Get("/webhooks/deliveries/{deliveryID}", h.GetWebhookDelivery)
+				r.Post("/webhooks/deliveries/{deliveryID}/retry", h.RetryWebhookDelivery)
+			})
+
+			// Protected Submission Routes, limited to the caller's assigned Area Councils and wards
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermSubmitResults))
+				r.Post("/submit/logistics", h.SubmitLogistics)
+				r.Post("/submit/staffing", h.SubmitStaffing)
+				r.Post("/submit/integrity", h.SubmitIntegrity)
+				r.Post("/submit/results", h.SubmitResults)
+				r.Post("/submit/observer-access", h.SubmitObserverAccess)
+				r.Post("/submit/cancelled-pus", h.SubmitCancelledPUs)
+				r.Post("/submit/polling-unit-status", h.SubmitPollingUnitStatus)
+				r.Post("/submit/polling-unit-results", h.SubmitPollingUnitResults)
+				r.Post("/submit/area-council/logistics", h.SubmitAreaCouncilLogistics)
+				r.Post("/submit/area-council/staffing", h.SubmitAreaCouncilStaffing)
+				r.Post("/submit/area-council/integrity", h.SubmitAreaCouncilIntegrity)
+				r.Post("/submit/area-council/results", h.SubmitAreaCouncilResults)
+				r.Post("/submit/area-council/observer-access", h.SubmitAreaCouncilObserverAccess)
+				r.Post("/wards/{wardID}/review/submit", h.SubmitWardForReview)
+			})
+			r.With(authMiddleware.RequirePermission(auth.PermManageParties)).Post("/area-councils/{lgaID}/parties", h.UpdateAreaCouncilParties)
+
+			// Submission History and Review Workflow: editors submit drafts, reviewers approve or reject them
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermViewSubmissions))
+				r.Get("/wards/{wardID}/history", h.GetWardHistory)
+				r.Get("/reviews", h.GetReviewQueue)
+				r.Get("/wards/{wardID}/review", h.GetWardReview)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermReviewResults))
+				r.Post("/wards/{wardID}/review/approve", h.ApproveWardResult)
+				r.Post("/wards/{wardID}/review/reject", h.RejectWardResult)
+			})
+
+			// Polling Units, limited to the caller's assigned wards
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermManagePollingUnits))
+				r.Post("/polling-units", h.CreatePollingUnit)
+				r.Post("/polling-units/import", h.ImportPollingUnits)
+				r.Put("/polling-units/{puID}", h.UpdatePollingUnit)
+				r.Delete("/polling-units/{puID}", h.DeletePollingUnit)
+			})
+
+			// Incident Reporting & Workflow
+			r.With(authMiddleware.RequirePermission(auth.PermReportIncidents)).Post("/incidents", h.CreateIncident)
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequirePermission(auth.PermManageIncidents))
+				r.Patch("/incidents/{incidentID}/status", h.UpdateIncidentStatus)
+				r.Post("/incidents/{incidentID}/resolve", h.ResolveIncident)
+			})
 		})
 
 		// Public Read-Only Routes
-		r.Get("/area-councils", handlers.GetAreaCouncils)
-		r.Get("/area-councils/{lgaID}/wards", handlers.GetWards)
-		r.Get("/wards/{wardID}", handlers.GetWardDetails)
-		r.Get("/dashboard/stats", handlers.GetDashboardStats)
-		r.Get("/area-councils/{lgaID}/parties", handlers.GetAreaCouncilParties)
+		// Results are scoped to ?election_id=, defaulting to the active election.
+		// Anonymous callers see approved ward results only; signed-in users also see pending ones.
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware.OptionalAuth(stores.Sessions, stores.APIKeys))
+			r.Get("/elections", h.GetElections)
+			r.Get("/elections/{electionID}", h.GetElection)
+			r.Get("/area-councils", h.GetAreaCouncils)
+			r.Get("/area-councils/{lgaID}/wards", h.GetWards)
+			r.Get("/wards/{wardID}", h.GetWardDetails)
+			r.Get("/wards/{wardID}/polling-units", h.GetPollingUnits)
+			r.Get("/wards/{wardID}/compare", h.CompareWardAcrossElections)
+			r.Get("/polling-units/{puID}", h.GetPollingUnit)
+			r.Get("/dashboard/stats", h.GetDashboardStats)
+			r.Get("/stream", h.StreamEvents)
+			r.Get("/red-flags/denied-access", h.GetDeniedAccessFlags)
+			r.Get("/area-councils/{lgaID}/parties", h.GetAreaCouncilParties)
+			r.Get("/area-councils/{lgaID}/collation", h.GetAreaCouncilCollation)
+			r.Get("/area-councils/{lgaID}/reconciliation", h.GetAreaCouncilReconciliation)
+			r.Get("/risk/weights", h.GetRiskWeights)
+			r.Get("/incidents", h.GetIncidents)
+			r.Get("/incidents/{incidentID}", h.GetIncident)
+		})
 	})
 
 	// Start Server
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/yiaga/abuja-watch/backend/internal/db"
+	"github.com/yiaga/abuja-watch/backend/internal/migrate"
+	"github.com/yiaga/abuja-watch/backend/migrations"
+)
+
+const migrateUsage = `usage: backend migrate [command]
+
+  up             apply pending migrations (the default)
+  down [n]       reverse the last n applied migrations, default 1 (development only)
+  status         list migrations and when each was applied
+  baseline <n>   record migrations up to n as applied without running them, for
+                 databases migrated by hand before migrations were tracked`
+
+// migrateUp brings the schema up to date before the server starts, and
+// refuses to start if an applied migration has changed since it ran
+func migrateUp() {
+	all, err := migrate.Load(migrations.Files)
+	if err != nil {
+		log.Fatalf("Invalid migrations: %v", err)
+	}
+	ran, err := migrate.Up(db.DB, all)
+	for _, m := range ran {
+		log.Printf("Applied migration %03d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("Database migration failed: %v", err)
+	}
+}
+
+// runMigrate implements the migrate subcommand
+func runMigrate(args []string) {
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	all, err := migrate.Load(migrations.Files)
+	if err != nil {
+		log.Fatalf("Invalid migrations: %v", err)
+	}
+	if err := db.Connect(); err != nil {
+		log.Fatalf("Could not connect to database: %v", err)
+	}
+	defer db.DB.Close()
+
+	switch command {
+	case "up":
+		migrateUp()
+		log.Println("Database is up to date")
+
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				log.Fatal("down takes a positive number of migrations to reverse")
+			}
+		}
+		reversed, err := migrate.Down(db.DB, all, steps)
+		for _, m := range reversed {
+			log.Printf("Reversed migration %03d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Database migration failed: %v", err)
+		}
+
+	case "status":
+		statuses, err := migrate.List(db.DB, all)
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "baseline":
+		if len(args) != 1 {
+			log.Fatal(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatal("baseline takes the last migration already applied by hand")
+		}
+		marked, err := migrate.Baseline(db.DB, all, version)
+		for _, m := range marked {
+			log.Printf("Recorded migration %03d_%s as applied", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Baseline failed: %v", err)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
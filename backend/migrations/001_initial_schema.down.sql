@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS incidents;
+DROP TABLE IF EXISTS party_results;
+DROP TABLE IF EXISTS ward_results;
+DROP TABLE IF EXISTS wards;
+DROP TABLE IF EXISTS area_councils;
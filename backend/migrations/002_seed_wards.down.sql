@@ -0,0 +1,2 @@
+-- Removing the wards also clears everything recorded against them
+TRUNCATE wards CASCADE;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS area_council_parties;
@@ -0,0 +1,8 @@
+-- Risk engine weights, configurable per election.
+-- Until elections are modelled explicitly everything uses the 'default' row;
+-- when no row exists the server falls back to its built-in defaults.
+CREATE TABLE IF NOT EXISTS risk_weights (
+    election_id VARCHAR(50) PRIMARY KEY,
+    weights JSONB NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
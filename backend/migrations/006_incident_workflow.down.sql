@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS incident_status_history;
+DROP INDEX IF EXISTS idx_incidents_timestamp;
+DROP INDEX IF EXISTS idx_incidents_ward_id;
+ALTER TABLE incidents DROP COLUMN IF EXISTS status_updated_at;
+ALTER TABLE incidents DROP COLUMN IF EXISTS status_updated_by;
+ALTER TABLE incidents DROP COLUMN IF EXISTS reported_by;
@@ -0,0 +1,20 @@
+-- Incident reporting and status workflow (reported -> verified -> escalated -> resolved)
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS reported_by INT REFERENCES users(id);
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS status_updated_by INT REFERENCES users(id);
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_incidents_ward_id ON incidents(ward_id);
+CREATE INDEX IF NOT EXISTS idx_incidents_timestamp ON incidents(timestamp);
+
+-- Every status change, with who made it and when
+CREATE TABLE IF NOT EXISTS incident_status_history (
+    id SERIAL PRIMARY KEY,
+    incident_id INT NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    note TEXT,
+    changed_by INT REFERENCES users(id),
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_incident_status_history_incident_id ON incident_status_history(incident_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS pu_party_results;
+DROP TABLE IF EXISTS pu_results;
+DROP TABLE IF EXISTS polling_units;
@@ -0,0 +1,33 @@
+-- Polling Units (below Wards)
+CREATE TABLE IF NOT EXISTS polling_units (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL, -- INEC PU code, e.g. FC/01/01/001
+    ward_id VARCHAR(50) NOT NULL REFERENCES wards(id),
+    name VARCHAR(255) NOT NULL,
+    registered_voters INT DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'not_opened', -- open, late, cancelled, not_opened
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (status IN ('open', 'late', 'cancelled', 'not_opened'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_polling_units_ward_id ON polling_units(ward_id);
+
+-- Polling Unit Results; totals roll up into ward_results
+CREATE TABLE IF NOT EXISTS pu_results (
+    polling_unit_id INT PRIMARY KEY REFERENCES polling_units(id) ON DELETE CASCADE,
+    accredited_voters INT DEFAULT 0,
+    valid_votes INT DEFAULT 0,
+    rejected_votes INT DEFAULT 0,
+    votes_cast INT DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Party Results per Polling Unit; roll up into party_results
+CREATE TABLE IF NOT EXISTS pu_party_results (
+    id SERIAL PRIMARY KEY,
+    polling_unit_id INT NOT NULL REFERENCES polling_units(id) ON DELETE CASCADE,
+    party_name VARCHAR(20) NOT NULL,
+    score INT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(polling_unit_id, party_name)
+);
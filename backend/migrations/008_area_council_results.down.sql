@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS area_council_party_results;
+DROP TABLE IF EXISTS area_council_results;
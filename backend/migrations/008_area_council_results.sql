@@ -0,0 +1,40 @@
+-- Area Council (LGA) Collation Results, as officially collated at the Area Council level.
+-- Mirrors ward_results so the two can be reconciled.
+CREATE TABLE IF NOT EXISTS area_council_results (
+    area_council_id VARCHAR(50) PRIMARY KEY REFERENCES area_councils(id),
+
+    -- Logistics
+    arrival_time VARCHAR(50),
+    collation_start_time VARCHAR(50),
+
+    -- Staffing
+    inec_staff INT DEFAULT 0,
+    security_present BOOLEAN DEFAULT false,
+    party_agents INT DEFAULT 0,
+
+    -- Integrity Checks
+    ec8b_submitted BOOLEAN DEFAULT false,
+    ec8c_collated BOOLEAN DEFAULT false,
+    csrvs_done BOOLEAN DEFAULT false,
+    votes_announced BOOLEAN DEFAULT false,
+    agents_countersigned BOOLEAN DEFAULT false,
+    ec60e_displayed BOOLEAN DEFAULT false,
+
+    -- Voting Stats
+    accredited_voters INT DEFAULT 0,
+    valid_votes INT DEFAULT 0,
+    rejected_votes INT DEFAULT 0,
+    votes_cast INT DEFAULT 0,
+
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Party Results per Area Council collation
+CREATE TABLE IF NOT EXISTS area_council_party_results (
+    id SERIAL PRIMARY KEY,
+    area_council_id VARCHAR(50) REFERENCES area_councils(id),
+    party_name VARCHAR(20) NOT NULL,
+    score INT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(area_council_id, party_name)
+);
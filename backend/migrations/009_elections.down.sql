@@ -0,0 +1,58 @@
+-- Only the 'default' election's data fits the single-election schema; the
+-- rest is deleted.
+
+-- Area Council Collation Results
+ALTER TABLE area_council_party_results DROP CONSTRAINT IF EXISTS area_council_party_results_election_ac_party_key;
+DELETE FROM area_council_party_results WHERE election_id <> 'default';
+ALTER TABLE area_council_party_results DROP COLUMN IF EXISTS election_id;
+ALTER TABLE area_council_party_results ADD CONSTRAINT area_council_party_results_area_council_id_party_name_key UNIQUE (area_council_id, party_name);
+
+ALTER TABLE area_council_results DROP CONSTRAINT IF EXISTS area_council_results_pkey;
+DELETE FROM area_council_results WHERE election_id <> 'default';
+ALTER TABLE area_council_results DROP COLUMN IF EXISTS election_id;
+ALTER TABLE area_council_results ADD PRIMARY KEY (area_council_id);
+
+-- Polling Unit Results
+ALTER TABLE pu_party_results DROP CONSTRAINT IF EXISTS pu_party_results_election_pu_party_key;
+DELETE FROM pu_party_results WHERE election_id <> 'default';
+ALTER TABLE pu_party_results DROP COLUMN IF EXISTS election_id;
+ALTER TABLE pu_party_results ADD CONSTRAINT pu_party_results_polling_unit_id_party_name_key UNIQUE (polling_unit_id, party_name);
+
+ALTER TABLE pu_results DROP CONSTRAINT IF EXISTS pu_results_pkey;
+DELETE FROM pu_results WHERE election_id <> 'default';
+ALTER TABLE pu_results DROP COLUMN IF EXISTS election_id;
+ALTER TABLE pu_results ADD PRIMARY KEY (polling_unit_id);
+
+-- Polling Unit status moves back onto the polling unit
+ALTER TABLE polling_units ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'not_opened'
+    CHECK (status IN ('open', 'late', 'cancelled', 'not_opened'));
+UPDATE polling_units pu SET status = s.status
+FROM polling_unit_statuses s WHERE s.polling_unit_id = pu.id AND s.election_id = 'default';
+DROP TABLE IF EXISTS polling_unit_statuses;
+
+-- Risk Weights
+ALTER TABLE risk_weights DROP CONSTRAINT IF EXISTS risk_weights_election_id_fkey;
+
+-- Party Configuration per Area Council
+ALTER TABLE area_council_parties DROP CONSTRAINT IF EXISTS area_council_parties_pkey;
+DELETE FROM area_council_parties WHERE election_id <> 'default';
+ALTER TABLE area_council_parties DROP COLUMN IF EXISTS election_id;
+ALTER TABLE area_council_parties ADD PRIMARY KEY (area_council_id);
+
+-- Incidents are kept whichever election they were reported in
+DROP INDEX IF EXISTS idx_incidents_election_id;
+ALTER TABLE incidents DROP COLUMN IF EXISTS election_id;
+
+-- Party Results per Ward
+ALTER TABLE party_results DROP CONSTRAINT IF EXISTS party_results_election_ward_party_key;
+DELETE FROM party_results WHERE election_id <> 'default';
+ALTER TABLE party_results DROP COLUMN IF EXISTS election_id;
+ALTER TABLE party_results ADD CONSTRAINT party_results_ward_id_party_name_key UNIQUE (ward_id, party_name);
+
+-- Ward Results
+ALTER TABLE ward_results DROP CONSTRAINT IF EXISTS ward_results_pkey;
+DELETE FROM ward_results WHERE election_id <> 'default';
+ALTER TABLE ward_results DROP COLUMN IF EXISTS election_id;
+ALTER TABLE ward_results ADD PRIMARY KEY (ward_id);
+
+DROP TABLE IF EXISTS elections;
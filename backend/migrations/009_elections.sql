@@ -0,0 +1,96 @@
+-- Elections: every submission and result is scoped to an election
+CREATE TABLE IF NOT EXISTS elections (
+    id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    election_date DATE,
+    type VARCHAR(20) NOT NULL DEFAULT 'general', -- general, by_election, re_run
+    status VARCHAR(20) NOT NULL DEFAULT 'upcoming', -- upcoming, active, concluded
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (type IN ('general', 'by_election', 're_run')),
+    CHECK (status IN ('upcoming', 'active', 'concluded'))
+);
+
+-- Existing data belongs to the election that was being monitored before this migration
+INSERT INTO elections (id, name, type, status)
+VALUES ('default', 'FCT Area Council Elections', 'general', 'active')
+ON CONFLICT (id) DO NOTHING;
+
+-- Ward Results
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE ward_results SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE ward_results ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE ward_results DROP CONSTRAINT IF EXISTS ward_results_pkey;
+ALTER TABLE ward_results ADD PRIMARY KEY (election_id, ward_id);
+
+-- Party Results per Ward
+ALTER TABLE party_results ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE party_results SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE party_results ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE party_results DROP CONSTRAINT IF EXISTS party_results_ward_id_party_name_key;
+ALTER TABLE party_results DROP CONSTRAINT IF EXISTS party_results_election_ward_party_key;
+ALTER TABLE party_results ADD CONSTRAINT party_results_election_ward_party_key UNIQUE (election_id, ward_id, party_name);
+
+-- Incidents
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE incidents SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE incidents ALTER COLUMN election_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_incidents_election_id ON incidents(election_id);
+
+-- Party Configuration per Area Council
+ALTER TABLE area_council_parties ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE area_council_parties SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE area_council_parties ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE area_council_parties DROP CONSTRAINT IF EXISTS area_council_parties_pkey;
+ALTER TABLE area_council_parties ADD PRIMARY KEY (election_id, area_council_id);
+
+-- Risk Weights
+ALTER TABLE risk_weights DROP CONSTRAINT IF EXISTS risk_weights_election_id_fkey;
+ALTER TABLE risk_weights ADD CONSTRAINT risk_weights_election_id_fkey FOREIGN KEY (election_id) REFERENCES elections(id);
+
+-- Polling Unit status is per election; the polling unit register itself is not
+CREATE TABLE IF NOT EXISTS polling_unit_statuses (
+    election_id VARCHAR(50) NOT NULL REFERENCES elections(id),
+    polling_unit_id INT NOT NULL REFERENCES polling_units(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'not_opened', -- open, late, cancelled, not_opened
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (election_id, polling_unit_id),
+    CHECK (status IN ('open', 'late', 'cancelled', 'not_opened'))
+);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'polling_units' AND column_name = 'status') THEN
+        INSERT INTO polling_unit_statuses (election_id, polling_unit_id, status, updated_at)
+        SELECT 'default', id, status, updated_at FROM polling_units
+        ON CONFLICT DO NOTHING;
+        ALTER TABLE polling_units DROP COLUMN status;
+    END IF;
+END $$;
+
+-- Polling Unit Results
+ALTER TABLE pu_results ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE pu_results SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE pu_results ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE pu_results DROP CONSTRAINT IF EXISTS pu_results_pkey;
+ALTER TABLE pu_results ADD PRIMARY KEY (election_id, polling_unit_id);
+
+ALTER TABLE pu_party_results ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE pu_party_results SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE pu_party_results ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE pu_party_results DROP CONSTRAINT IF EXISTS pu_party_results_polling_unit_id_party_name_key;
+ALTER TABLE pu_party_results DROP CONSTRAINT IF EXISTS pu_party_results_election_pu_party_key;
+ALTER TABLE pu_party_results ADD CONSTRAINT pu_party_results_election_pu_party_key UNIQUE (election_id, polling_unit_id, party_name);
+
+-- Area Council Collation Results
+ALTER TABLE area_council_results ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE area_council_results SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE area_council_results ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE area_council_results DROP CONSTRAINT IF EXISTS area_council_results_pkey;
+ALTER TABLE area_council_results ADD PRIMARY KEY (election_id, area_council_id);
+
+ALTER TABLE area_council_party_results ADD COLUMN IF NOT EXISTS election_id VARCHAR(50) REFERENCES elections(id);
+UPDATE area_council_party_results SET election_id = 'default' WHERE election_id IS NULL;
+ALTER TABLE area_council_party_results ALTER COLUMN election_id SET NOT NULL;
+ALTER TABLE area_council_party_results DROP CONSTRAINT IF EXISTS area_council_party_results_area_council_id_party_name_key;
+ALTER TABLE area_council_party_results DROP CONSTRAINT IF EXISTS area_council_party_results_election_ac_party_key;
+ALTER TABLE area_council_party_results ADD CONSTRAINT area_council_party_results_election_ac_party_key UNIQUE (election_id, area_council_id, party_name);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ward_result_versions;
@@ -0,0 +1,27 @@
+-- Ward Result Versions: an immutable snapshot of a ward's submission after every change
+CREATE TABLE IF NOT EXISTS ward_result_versions (
+    id SERIAL PRIMARY KEY,
+    election_id VARCHAR(50) NOT NULL REFERENCES elections(id),
+    ward_id VARCHAR(50) NOT NULL REFERENCES wards(id),
+    version INT NOT NULL,
+    section VARCHAR(20) NOT NULL, -- baseline, logistics, staffing, integrity, results, polling_units, revert
+    snapshot JSONB NOT NULL, -- the full ward_results row plus party_results
+    diff JSONB NOT NULL DEFAULT '{}', -- field -> {from, to} against the previous version
+    submitted_by INT REFERENCES users(id),
+    reverted_from INT, -- version restored by a revert
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (election_id, ward_id, version)
+);
+
+-- Results submitted before versioning become each ward's first version
+INSERT INTO ward_result_versions (election_id, ward_id, version, section, snapshot)
+SELECT wr.election_id, wr.ward_id, 1, 'baseline',
+    (to_jsonb(wr) - 'updated_at') || jsonb_build_object(
+        'updated_at', TO_CHAR(wr.updated_at, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+        'party_results', COALESCE(
+        (SELECT jsonb_object_agg(p.party_name, p.score) FROM party_results p
+         WHERE p.election_id = wr.election_id AND p.ward_id = wr.ward_id), '{}'::jsonb))
+FROM ward_results wr
+WHERE NOT EXISTS (
+    SELECT 1 FROM ward_result_versions v WHERE v.election_id = wr.election_id AND v.ward_id = wr.ward_id
+);
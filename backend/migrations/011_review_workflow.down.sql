@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS ward_review_history;
+DROP INDEX IF EXISTS idx_ward_results_review_status;
+ALTER TABLE ward_results DROP CONSTRAINT IF EXISTS ward_results_review_status_check;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS reviewed_version;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS submitted_at;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS submitted_by;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS review_reason;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS review_status;
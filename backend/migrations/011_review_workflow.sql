@@ -0,0 +1,32 @@
+-- Review Workflow: ward submissions are published only once a reviewer approves them.
+-- draft -> submitted -> approved | rejected; any change to the data returns it to draft.
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS review_status VARCHAR(20) NOT NULL DEFAULT 'draft';
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS review_reason TEXT;
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS submitted_by INT REFERENCES users(id);
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP;
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS reviewed_by INT REFERENCES users(id);
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS reviewed_version INT; -- ward_result_versions.version that was approved or rejected
+ALTER TABLE ward_results DROP CONSTRAINT IF EXISTS ward_results_review_status_check;
+ALTER TABLE ward_results ADD CONSTRAINT ward_results_review_status_check
+    CHECK (review_status IN ('draft', 'submitted', 'approved', 'rejected'));
+CREATE INDEX IF NOT EXISTS idx_ward_results_review_status ON ward_results(election_id, review_status);
+
+-- Results published before the workflow existed stay published
+UPDATE ward_results SET review_status = 'approved'
+WHERE review_status = 'draft' AND reviewed_at IS NULL AND submitted_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM ward_result_versions v WHERE v.election_id = ward_results.election_id AND v.ward_id = ward_results.ward_id AND v.section <> 'baseline');
+
+-- Review History
+CREATE TABLE IF NOT EXISTS ward_review_history (
+    id SERIAL PRIMARY KEY,
+    election_id VARCHAR(50) NOT NULL REFERENCES elections(id),
+    ward_id VARCHAR(50) NOT NULL REFERENCES wards(id),
+    version INT, -- ward_result_versions.version at the time of the change
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    changed_by INT REFERENCES users(id),
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_ward_review_history_ward ON ward_review_history(election_id, ward_id);
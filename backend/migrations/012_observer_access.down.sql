@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_ward_results_observer_denied;
+ALTER TABLE area_council_results DROP COLUMN IF EXISTS denied_at;
+ALTER TABLE area_council_results DROP COLUMN IF EXISTS denial_reason;
+ALTER TABLE area_council_results DROP COLUMN IF EXISTS observer_permitted;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS denied_at;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS denial_reason;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS observer_permitted;
@@ -0,0 +1,11 @@
+-- Observer Access: whether our observer was permitted to watch collation, per ward and per Area Council.
+-- NULL means access has not been reported yet.
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS observer_permitted BOOLEAN;
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS denial_reason TEXT;
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS denied_at TIMESTAMP;
+
+ALTER TABLE area_council_results ADD COLUMN IF NOT EXISTS observer_permitted BOOLEAN;
+ALTER TABLE area_council_results ADD COLUMN IF NOT EXISTS denial_reason TEXT;
+ALTER TABLE area_council_results ADD COLUMN IF NOT EXISTS denied_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_ward_results_observer_denied ON ward_results(election_id) WHERE observer_permitted = false;
@@ -0,0 +1,3 @@
+ALTER TABLE ward_results DROP CONSTRAINT IF EXISTS ward_results_cancelled_check;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS cancelled_pu_voters;
+ALTER TABLE ward_results DROP COLUMN IF EXISTS cancelled_pus;
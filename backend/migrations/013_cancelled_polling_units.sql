@@ -0,0 +1,6 @@
+-- Cancelled Polling Units: how many of a ward's PUs were cancelled and the registered voters in them
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS cancelled_pus INT NOT NULL DEFAULT 0;
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS cancelled_pu_voters INT NOT NULL DEFAULT 0;
+ALTER TABLE ward_results DROP CONSTRAINT IF EXISTS ward_results_cancelled_check;
+ALTER TABLE ward_results ADD CONSTRAINT ward_results_cancelled_check
+    CHECK (cancelled_pus >= 0 AND cancelled_pu_voters >= 0);
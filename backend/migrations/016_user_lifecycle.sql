@@ -0,0 +1,13 @@
+-- User Lifecycle: accounts are deactivated rather than deleted, so audit logs
+-- and submissions keep pointing at who made them.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_by INT REFERENCES users(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT false; -- Set by an admin password reset
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+-- Roles were never validated; anything unrecognised gets the least privileged role
+UPDATE users SET role = 'editor' WHERE role NOT IN ('admin', 'editor', 'reviewer');
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'editor', 'reviewer'));
@@ -0,0 +1,16 @@
+-- User Jurisdictions: the Area Councils and wards a user may submit data for.
+-- An Area Council assignment covers all of its wards. Users whose role is not
+-- limited to assigned jurisdictions (admins) need none; everyone else can't
+-- submit anything until an admin assigns them.
+CREATE TABLE IF NOT EXISTS user_jurisdictions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    area_council_id VARCHAR(50) REFERENCES area_councils(id) ON DELETE CASCADE,
+    ward_id VARCHAR(50) REFERENCES wards(id) ON DELETE CASCADE,
+    created_by INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((area_council_id IS NULL) <> (ward_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_jurisdictions_area_council ON user_jurisdictions(user_id, area_council_id) WHERE area_council_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_jurisdictions_ward ON user_jurisdictions(user_id, ward_id) WHERE ward_id IS NOT NULL;
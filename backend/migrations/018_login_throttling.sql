@@ -0,0 +1,11 @@
+-- Login Throttling: failed login attempts counted per username and per IP.
+-- Keys look like 'user:<username>' or 'ip:<address>'; unknown usernames are
+-- tracked too so lockouts don't reveal which accounts exist.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure ON login_throttles(last_failure_at);
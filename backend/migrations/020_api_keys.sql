@@ -0,0 +1,20 @@
+-- API Keys: credentials for machine clients such as the SMS gateway and
+-- partner dashboards. Only a SHA-256 hash of each key is stored; prefix is
+-- the key's first characters, kept so admins can tell keys apart.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,                            -- read, submit, webhooks
+    area_council_ids TEXT[] NOT NULL DEFAULT '{}',     -- Where the submit scope applies
+    expires_at TIMESTAMP,                              -- NULL: never expires
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(50),
+    created_by INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Actions taken with an API key are attributed to the key
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS api_key_id INT REFERENCES api_keys(id);
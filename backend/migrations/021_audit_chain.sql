@@ -0,0 +1,17 @@
+-- Hash-Chained Audit Log: each entry stores the SHA-256 of its own content and
+-- the previous entry's hash, so editing or deleting an entry breaks the chain.
+-- Entries written before this migration have no hash and sit before the chain.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+-- Signed checkpoints of the chain head, for publishing outside the database.
+-- Truncating the log behind a published checkpoint is then detectable too.
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id SERIAL PRIMARY KEY,
+    entry_id INT NOT NULL,          -- Last audit entry covered
+    entry_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    key_id VARCHAR(32) NOT NULL,
+    public_key TEXT NOT NULL,       -- Base64 Ed25519 public key
+    signature TEXT NOT NULL         -- Base64 Ed25519 signature of the checkpoint statement
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_audit_logs_request;
+DROP INDEX IF EXISTS idx_audit_logs_entity;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS status;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS path;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS method;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS request_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS changes;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS entity_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS entity_type;
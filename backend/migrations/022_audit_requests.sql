@@ -0,0 +1,13 @@
+-- Audit Request Capture: every mutating request is audited with the entity it
+-- targeted, its before and after values and the request's outcome.
+-- changes is JSON rather than JSONB so the stored text is exactly what was hashed.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS entity_type VARCHAR(50);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS entity_id VARCHAR(100);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS changes JSON;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS request_id VARCHAR(100);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS method VARCHAR(10);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS path TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS status INT; -- HTTP status the request ended with
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request ON audit_logs(request_id);
@@ -0,0 +1,16 @@
+-- Audit Log Search: full-text search on details, and a record of entries
+-- archived out of the table once past the retention period.
+CREATE INDEX IF NOT EXISTS idx_audit_logs_details_search ON audit_logs USING GIN (to_tsvector('simple', COALESCE(details, '')));
+CREATE INDEX IF NOT EXISTS idx_audit_logs_timestamp ON audit_logs(timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+
+CREATE TABLE IF NOT EXISTS audit_archives (
+    id SERIAL PRIMARY KEY,
+    up_to_id INT NOT NULL UNIQUE,   -- Every entry up to this ID is in the file
+    last_hash VARCHAR(64),          -- Hash of the last archived entry; the remaining chain starts from it
+    entries INT NOT NULL,
+    file TEXT NOT NULL,
+    file_sha256 VARCHAR(64) NOT NULL,
+    archived_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE ward_results DROP COLUMN IF EXISTS approved_version;
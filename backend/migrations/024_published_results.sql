@@ -0,0 +1,8 @@
+-- Published Results: the last approved version of each ward's submission stays
+-- public while later changes to it are reviewed.
+ALTER TABLE ward_results ADD COLUMN IF NOT EXISTS approved_version INT; -- ward_result_versions.version last approved
+
+-- An approved submission hasn't changed since its latest version
+UPDATE ward_results wr SET approved_version = COALESCE(wr.reviewed_version,
+    (SELECT MAX(v.version) FROM ward_result_versions v WHERE v.election_id = wr.election_id AND v.ward_id = wr.ward_id))
+WHERE wr.review_status = 'approved';
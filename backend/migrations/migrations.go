@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations so the server can apply them
+// itself. Each NNN_name.sql has an NNN_name.down.sql that reverses it.
+package migrations
+
+import "embed"
+
+// Files holds every migration
+//
+//go:embed *.sql
+var Files embed.FS